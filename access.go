@@ -0,0 +1,190 @@
+package infrared
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessListConfig allows or denies clients by their IP. Entries are IPs like 203.0.113.5
+// or CIDR ranges like 203.0.113.0/24. Denied IPs are always rejected and if the allow list
+// isn't empty, only the IPs on it are allowed. The entries of the list files are added to the lists
+// and the entries of the deny feeds to the deny list.
+type AccessListConfig struct {
+	Allow      []string     `json:"allow"`
+	Deny       []string     `json:"deny"`
+	AllowFiles []string     `json:"allowFiles"`
+	DenyFiles  []string     `json:"denyFiles"`
+	DenyFeeds  []FeedConfig `json:"denyFeeds"`
+}
+
+// validate checks that all entries and the entries of all files are IPs or CIDR ranges
+func (cfg AccessListConfig) validate() error {
+	for _, entry := range append(append([]string(nil), cfg.Allow...), cfg.Deny...) {
+		if _, err := parseIPNet(entry); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range append(append([]string(nil), cfg.AllowFiles...), cfg.DenyFiles...) {
+		if _, err := loadAccessFile(path); err != nil {
+			return err
+		}
+	}
+
+	for _, feed := range cfg.DenyFeeds {
+		if err := feed.validate(); err != nil {
+			return err
+		}
+		// The first fetch starts right away instead of with the first connection
+		loadFeed(feed)
+	}
+	return nil
+}
+
+// allows reports whether the client with the IP is allowed
+func (cfg AccessListConfig) allows(ip net.IP) bool {
+	if containsIP(cfg.Deny, ip) || filesContainIP(cfg.DenyFiles, ip) || feedsContainIP(cfg.DenyFeeds, ip) {
+		return false
+	}
+	if len(cfg.Allow) == 0 && len(cfg.AllowFiles) == 0 {
+		return true
+	}
+	return containsIP(cfg.Allow, ip) || filesContainIP(cfg.AllowFiles, ip)
+}
+
+// allowsAddr reports whether the client with the address is allowed. Addresses without an IP,
+// like the ones of Unix domain sockets, are always allowed.
+func (cfg AccessListConfig) allowsAddr(addr net.Addr) bool {
+	ip, _, ok := splitIPAddr(unmapAddr(addr))
+	return !ok || cfg.allows(ip)
+}
+
+// accessFileCheckInterval is how often access list files are checked for changes
+var accessFileCheckInterval = time.Second
+
+type accessFileEntry struct {
+	checked time.Time
+	modTime time.Time
+	size    int64
+	nets    []*net.IPNet
+	err     error
+}
+
+// accessFiles caches the ranges of access list files until the files change
+var accessFiles = struct {
+	sync.Mutex
+	entries map[string]*accessFileEntry
+}{entries: map[string]*accessFileEntry{}}
+
+// loadAccessFile returns the ranges of the access list file. The file is read again when it changes.
+// If the changed file is invalid, the ranges of the last valid file are kept.
+func loadAccessFile(path string) ([]*net.IPNet, error) {
+	accessFiles.Lock()
+	defer accessFiles.Unlock()
+
+	now := time.Now()
+	entry, ok := accessFiles.entries[path]
+	if ok && now.Sub(entry.checked) < accessFileCheckInterval {
+		return entry.nets, entry.err
+	}
+	if !ok {
+		entry = &accessFileEntry{}
+		accessFiles.entries[path] = entry
+	}
+	entry.checked = now
+
+	info, err := os.Stat(path)
+	if err != nil {
+		entry.err = err
+		return entry.nets, err
+	}
+	if entry.err == nil && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() && entry.nets != nil {
+		return entry.nets, nil
+	}
+
+	nets, err := readAccessFile(path)
+	if err != nil {
+		if entry.nets != nil {
+			log.Printf("[w] Keeping the last access list of %s; error: %s", path, err)
+		}
+		entry.err = err
+		return entry.nets, err
+	}
+
+	entry.modTime, entry.size, entry.nets, entry.err = info.ModTime(), info.Size(), nets, nil
+	return nets, nil
+}
+
+// readAccessFile parses the file with one IP or CIDR range per line. Empty lines and comments after # are skipped.
+func readAccessFile(path string) ([]*net.IPNet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	nets := []*net.IPNet{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := scanner.Text()
+		if i := strings.IndexByte(entry, '#'); i >= 0 {
+			entry = entry[:i]
+		}
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		ipNet, err := parseIPNet(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d; %s", path, line, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, scanner.Err()
+}
+
+func filesContainIP(paths []string, ip net.IP) bool {
+	for _, path := range paths {
+		nets, _ := loadAccessFile(path)
+		for _, ipNet := range nets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsIP(entries []string, ip net.IP) bool {
+	for _, entry := range entries {
+		ipNet, err := parseIPNet(entry)
+		if err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIPNet parses a CIDR range or an IP, which is a range of only that IP
+func parseIPNet(entry string) (*net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, ipNet, err := net.ParseCIDR(entry)
+		return ipNet, err
+	}
+
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, fmt.Errorf("%s is no IP or CIDR range", entry)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
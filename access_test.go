@@ -0,0 +1,113 @@
+package infrared
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAccessListConfig_Allows(t *testing.T) {
+	tt := []struct {
+		name    string
+		access  AccessListConfig
+		ip      string
+		allowed bool
+	}{
+		{name: "empty", ip: "203.0.113.5", allowed: true},
+		{name: "denied ip", access: AccessListConfig{Deny: []string{"203.0.113.5"}}, ip: "203.0.113.5", allowed: false},
+		{name: "denied range", access: AccessListConfig{Deny: []string{"203.0.113.0/24"}}, ip: "203.0.113.5", allowed: false},
+		{name: "other ip", access: AccessListConfig{Deny: []string{"203.0.113.0/24"}}, ip: "198.51.100.1", allowed: true},
+		{name: "allowed", access: AccessListConfig{Allow: []string{"2001:db8::/32"}}, ip: "2001:db8::1", allowed: true},
+		{name: "not allowed", access: AccessListConfig{Allow: []string{"2001:db8::/32"}}, ip: "198.51.100.1", allowed: false},
+		{name: "deny wins", access: AccessListConfig{Allow: []string{"203.0.113.0/24"}, Deny: []string{"203.0.113.5"}}, ip: "203.0.113.5", allowed: false},
+	}
+
+	for _, tc := range tt {
+		if allowed := tc.access.allows(net.ParseIP(tc.ip)); allowed != tc.allowed {
+			t.Errorf("%s: got %t; want %t", tc.name, allowed, tc.allowed)
+		}
+	}
+}
+
+func TestAccessListConfig_Validate(t *testing.T) {
+	if err := (AccessListConfig{Allow: []string{"203.0.113.5", "2001:db8::/32"}}).validate(); err != nil {
+		t.Errorf("got %v; want valid entries", err)
+	}
+	if err := (AccessListConfig{Deny: []string{"example.com"}}).validate(); err == nil {
+		t.Error("got no error; want an invalid entry")
+	}
+}
+
+func TestAccessListConfig_Files(t *testing.T) {
+	defer func(interval time.Duration) { accessFileCheckInterval = interval }(accessFileCheckInterval)
+	accessFileCheckInterval = 0
+
+	path := filepath.Join(t.TempDir(), "deny.txt")
+	if err := os.WriteFile(path, []byte("# scanners\n203.0.113.0/24\n\n2001:db8::1 # single host\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	access := AccessListConfig{DenyFiles: []string{path}}
+	if err := access.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if access.allows(net.ParseIP("203.0.113.5")) || access.allows(net.ParseIP("2001:db8::1")) {
+		t.Error("got an IP of the file allowed; want it denied")
+	}
+	if !access.allows(net.ParseIP("198.51.100.1")) {
+		t.Error("got an IP that isn't in the file denied; want it allowed")
+	}
+
+	if err := os.WriteFile(path, []byte("198.51.100.0/24\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if access.allows(net.ParseIP("198.51.100.1")) || !access.allows(net.ParseIP("203.0.113.5")) {
+		t.Error("got the old entries; want the changed file to be reloaded")
+	}
+
+	if err := os.WriteFile(path, []byte("example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if access.validate() == nil {
+		t.Error("got no error; want an invalid entry")
+	}
+	if access.allows(net.ParseIP("198.51.100.1")) {
+		t.Error("got the IP allowed; want the last valid entries to be kept")
+	}
+}
+
+func TestAccessListConfig_MissingAllowFile(t *testing.T) {
+	access := AccessListConfig{AllowFiles: []string{filepath.Join(t.TempDir(), "missing.txt")}}
+	if access.validate() == nil {
+		t.Error("got no error; want the missing file to be invalid")
+	}
+	if access.allows(net.ParseIP("203.0.113.5")) {
+		t.Error("got the IP allowed; want a missing allow file to allow nobody")
+	}
+}
+
+func TestProxy_AllowsBedrockClient(t *testing.T) {
+	proxy := &Proxy{Config: &ProxyConfig{
+		Bedrock: BedrockConfig{
+			Access: AccessListConfig{Deny: []string{"203.0.113.0/24"}},
+		},
+	}}
+
+	denied := &net.UDPAddr{IP: net.ParseIP("::ffff:203.0.113.5"), Port: 19132}
+	if proxy.allowsBedrockClient(denied) {
+		t.Errorf("got %s allowed; want it denied", denied)
+	}
+
+	allowed := &net.UDPAddr{IP: net.ParseIP("198.51.100.1"), Port: 19132}
+	if !proxy.allowsBedrockClient(allowed) {
+		t.Errorf("got %s denied; want it allowed", allowed)
+	}
+}
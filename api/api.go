@@ -6,14 +6,16 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/haveachin/infrared"
+	"github.com/haveachin/infrared/provider"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"sigs.k8s.io/yaml"
 )
 
 // ListenAndServe StartWebserver Start Webserver if environment variable "api-enable" is set to true
-func ListenAndServe(configPath string, apiBind string) {
+func ListenAndServe(configPath string, apiBind string, providers *provider.Composite, gateway *infrared.Gateway) {
 	fmt.Println("Starting WebAPI on " + apiBind)
 	router := chi.NewRouter()
 	router.Use(middleware.Logger)
@@ -21,6 +23,14 @@ func ListenAndServe(configPath string, apiBind string) {
 	router.Post("/proxies", addProxy(configPath))
 	router.Post("/proxies/{fileName}", addProxyWithName(configPath))
 	router.Delete("/proxies/{fileName}", removeProxy(configPath))
+	router.Put("/proxies/{fileName}/maintenance", setMaintenance(configPath))
+	router.Put("/proxies/{fileName}/protection-profile", setProtectionProfile(configPath))
+	router.Get("/protection-profiles", getProtectionProfiles())
+	router.Put("/protection-profiles/override", setProtectionOverride())
+	router.Delete("/status-cache", clearStatusCache())
+	router.Get("/providers", getProviderStatuses(providers))
+	router.Get("/configs", getEffectiveConfigs(providers))
+	router.Get("/bans", getBans(gateway))
 
 	err := http.ListenAndServe(apiBind, router)
 	if err != nil {
@@ -29,6 +39,38 @@ func ListenAndServe(configPath string, apiBind string) {
 	}
 }
 
+func getProviderStatuses(providers *provider.Composite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(providers.Statuses()); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func getEffectiveConfigs(providers *provider.Composite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bb, err := json.Marshal(infrared.EffectiveConfigs(providers))
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "yaml" {
+			if bb, err = yaml.JSONToYAML(bb); err != nil {
+				log.Println(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/yaml")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.Write(bb)
+	}
+}
+
 func addProxy(configPath string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		rawData, err := ioutil.ReadAll(r.Body)
@@ -85,6 +127,150 @@ func removeProxy(configPath string) http.HandlerFunc {
 	}
 }
 
+// setMaintenance turns the maintenance mode of a JSON proxy config on or off.
+// The config file is rewritten, so the proxy is reloaded like after any other change.
+func setMaintenance(configPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := configPath + "/" + chi.URLParam(r, "fileName")
+
+		var body struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		setConfigField(w, path, "maintenance", body.Enabled)
+	}
+}
+
+// setProtectionProfile sets the protection profile of a JSON proxy config. An empty profile removes it.
+// The config file is rewritten, so the proxy is reloaded like after any other change.
+func setProtectionProfile(configPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := configPath + "/" + chi.URLParam(r, "fileName")
+
+		var body struct {
+			Profile string `json:"profile"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !hasProtectionProfile(body.Profile) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("{'error': 'unknown protection profile'}"))
+			return
+		}
+
+		setConfigField(w, path, "protectionProfile", body.Profile)
+	}
+}
+
+func hasProtectionProfile(name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, profile := range infrared.ProtectionProfileNames() {
+		if profile == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setConfigField sets the field of the JSON proxy config at the path and rewrites the file
+func setConfigField(w http.ResponseWriter, path, field string, value interface{}) {
+	rawData, err := os.ReadFile(path)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(rawData, &cfg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("{'error': 'only JSON configs can be changed'}"))
+		return
+	}
+	cfg[field] = value
+
+	rawData, err = json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := os.WriteFile(path, rawData, 0644); err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+// getProtectionProfiles lists the names of the protection profiles and the override of all proxies
+func getProtectionProfiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"profiles": infrared.ProtectionProfileNames(),
+			"override": infrared.ProtectionOverride(),
+		}); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// setProtectionOverride switches all proxies to a protection profile at once. An empty profile lifts the override.
+// The override isn't saved, so it's lifted when Infrared restarts.
+func setProtectionOverride() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Profile string `json:"profile"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := infrared.SetProtectionOverride(body.Profile); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("{'error': 'unknown protection profile'}"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// clearStatusCache drops all cached status responses, so the next status requests reach the backends
+func clearStatusCache() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		infrared.ClearStatusCache()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getBans lists the runtime bans of the gateway as JSON or in the format of the query
+func getBans(gateway *infrared.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = infrared.JSONBanFormat
+		}
+
+		if format == infrared.JSONBanFormat {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/plain")
+		}
+		if err := infrared.WriteBans(w, gateway.Bans(), format, r.URL.Query().Get("ipset")); err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+}
+
 // Helper method to check for domainName and proxyTo in a given JSON array
 // If the filename is empty the domain will be used as the filename - files with the same name will be overwritten
 func checkJSONAndRegister(rawData []byte, filename string, configPath string) (successful bool) {
@@ -95,7 +281,7 @@ func checkJSONAndRegister(rawData []byte, filename string, configPath string) (s
 		return false
 	}
 
-	if cfg.DomainName == "" || cfg.ProxyTo == "" {
+	if cfg.DomainName == "" || len(cfg.BackendAddrs()) == 0 {
 		return false
 	}
 
@@ -0,0 +1,290 @@
+package infrared
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/haveachin/infrared/protocol/login"
+)
+
+const (
+	// DefaultSessionServer is the session server of Mojang that online mode servers check players with
+	DefaultSessionServer = "https://sessionserver.mojang.com"
+	// BungeeCordForwarding forwards the IP, the UUID and the skin of authenticated players in the handshake,
+	// like BungeeCord does for backends with bungeecord enabled
+	BungeeCordForwarding = "bungeecord"
+	// minAuthProtocolVersion is the first protocol version (1.8) whose encryption packets are prefixed with VarInts
+	minAuthProtocolVersion = 47
+)
+
+var (
+	// errAuthFailed is the error of players that the session server doesn't know
+	errAuthFailed = errors.New("failed to verify username")
+	// errAuthUnsupported is the error of clients whose version can't be authenticated
+	errAuthUnsupported = errors.New("protocol version can't be authenticated")
+)
+
+// AuthConfig authenticates players like an online mode server with the session server before
+// their login reaches the backend, so backends in offline mode only see authenticated players.
+type AuthConfig struct {
+	Enabled       bool   `json:"enabled"`
+	SessionServer string `json:"sessionServer"`
+	// PreventProxyConnections requires the player to be authenticated from the same IP as its session
+	PreventProxyConnections bool   `json:"preventProxyConnections"`
+	Forwarding              string `json:"forwarding"`
+	// Timeout is how many milliseconds the session server has to answer
+	Timeout int    `json:"timeout"`
+	Message string `json:"message"`
+}
+
+func (cfg AuthConfig) validate() error {
+	if cfg.Forwarding != "" && cfg.Forwarding != BungeeCordForwarding {
+		return fmt.Errorf("unknown forwarding %q", cfg.Forwarding)
+	}
+	if cfg.SessionServer != "" {
+		if _, err := url.Parse(cfg.SessionServer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gameProfile is the profile of an authenticated player
+type gameProfile struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Properties []profileProperty `json:"properties"`
+}
+
+type profileProperty struct {
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// authKey is the RSA key of the encryption with clients. It's generated once per process like vanilla servers do.
+var authKey struct {
+	once sync.Once
+	key  *rsa.PrivateKey
+	der  []byte
+	err  error
+}
+
+func serverKey() (*rsa.PrivateKey, []byte, error) {
+	authKey.once.Do(func() {
+		authKey.key, authKey.err = rsa.GenerateKey(rand.Reader, 1024)
+		if authKey.err != nil {
+			return
+		}
+		authKey.der, authKey.err = x509.MarshalPKIXPublicKey(&authKey.key.PublicKey)
+	})
+	return authKey.key, authKey.der, authKey.err
+}
+
+// minecraftDigest returns the SHA-1 hash of the parts as a signed hexadecimal number, like Minecraft does
+func minecraftDigest(parts ...[]byte) string {
+	h := sha1.New()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	sum := h.Sum(nil)
+
+	n := new(big.Int).SetBytes(sum)
+	if sum[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(sum)*8)))
+	}
+	return n.Text(16)
+}
+
+func (proxy *Proxy) Authentication() AuthConfig {
+	if profile := proxy.protectionProfile(); profile.Authentication != nil {
+		return *profile.Authentication
+	}
+
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Authentication
+}
+
+// authenticate reads the login start of the client, encrypts the connection and checks the player with the session
+// server. The login start is put back in front of the connection, so it's read again like without authentication.
+func (proxy *Proxy) authenticate(conn Conn, hs handshaking.ServerBoundHandshake, connRemoteAddr net.Addr) (gameProfile, error) {
+	c, ok := unwrapConn(conn)
+	if !ok {
+		return gameProfile{}, errors.New("connection can't be encrypted")
+	}
+
+	loginStartPk, err := c.ReadPacket()
+	if err != nil {
+		return gameProfile{}, err
+	}
+	defer c.unreadPacket(loginStartPk)
+
+	ls, err := login.UnmarshalServerBoundLoginStart(loginStartPk)
+	if err != nil {
+		return gameProfile{}, err
+	}
+	if hs.ProtocolVersion < minAuthProtocolVersion {
+		return gameProfile{}, errAuthUnsupported
+	}
+
+	key, der, err := serverKey()
+	if err != nil {
+		return gameProfile{}, err
+	}
+
+	verifyToken := make([]byte, 4)
+	if _, err := rand.Read(verifyToken); err != nil {
+		return gameProfile{}, err
+	}
+
+	if err := c.WritePacket(login.ClientBoundEncryptionRequest{
+		PublicKey:          der,
+		VerifyToken:        verifyToken,
+		ShouldAuthenticate: true,
+	}.Marshal(hs.ProtocolVersion)); err != nil {
+		return gameProfile{}, err
+	}
+
+	pk, err := c.ReadPacket()
+	if err != nil {
+		return gameProfile{}, err
+	}
+	resp, err := login.UnmarshalServerBoundEncryptionResponse(pk, hs.ProtocolVersion)
+	if err != nil {
+		return gameProfile{}, err
+	}
+
+	sharedSecret, err := rsa.DecryptPKCS1v15(rand.Reader, key, resp.SharedSecret)
+	if err != nil {
+		return gameProfile{}, err
+	}
+	// Clients of 1.19 up to 1.19.2 that sign the verify token only send its signature,
+	// which only the session server check below can vouch for
+	if len(resp.VerifyToken) > 0 {
+		token, err := rsa.DecryptPKCS1v15(rand.Reader, key, resp.VerifyToken)
+		if err != nil {
+			return gameProfile{}, err
+		}
+		if !bytes.Equal(token, verifyToken) {
+			return gameProfile{}, errors.New("invalid verify token")
+		}
+	}
+
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return gameProfile{}, err
+	}
+	c.SetCipher(newCFB8Encrypter(block, sharedSecret), newCFB8Decrypter(block, sharedSecret))
+
+	var ip net.IP
+	if proxy.Authentication().PreventProxyConnections {
+		ip, _, _ = splitIPAddr(unmapAddr(connRemoteAddr))
+	}
+	return proxy.hasJoined(string(ls.Name), minecraftDigest(sharedSecret, der), ip)
+}
+
+// hasJoined asks the session server whether the player joined the server with the hash
+func (proxy *Proxy) hasJoined(username, serverHash string, ip net.IP) (gameProfile, error) {
+	cfg := proxy.Authentication()
+	sessionServer := cfg.SessionServer
+	if sessionServer == "" {
+		sessionServer = DefaultSessionServer
+	}
+
+	query := url.Values{}
+	query.Set("username", username)
+	query.Set("serverId", serverHash)
+	if ip != nil {
+		query.Set("ip", ip.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*time.Duration(cfg.Timeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(sessionServer, "/")+"/session/minecraft/hasJoined?"+query.Encode(), nil)
+	if err != nil {
+		return gameProfile{}, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return gameProfile{}, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return gameProfile{}, errAuthFailed
+	default:
+		return gameProfile{}, fmt.Errorf("session server responded with %s", res.Status)
+	}
+
+	var profile gameProfile
+	if err := json.NewDecoder(res.Body).Decode(&profile); err != nil {
+		return gameProfile{}, err
+	}
+	if !strings.EqualFold(profile.Name, username) {
+		return gameProfile{}, errAuthFailed
+	}
+	return profile, nil
+}
+
+// bungeeCordAddress returns the server address of the handshake with the IP, the UUID and the
+// properties of the player in the format of BungeeCord IP forwarding
+func bungeeCordAddress(hs handshaking.ServerBoundHandshake, connRemoteAddr net.Addr, profile gameProfile) (string, error) {
+	properties := profile.Properties
+	if properties == nil {
+		properties = []profileProperty{}
+	}
+	bb, err := json.Marshal(properties)
+	if err != nil {
+		return "", err
+	}
+
+	ip, _, _ := splitIPAddr(unmapAddr(connRemoteAddr))
+	return strings.Join([]string{
+		hs.ParseServerAddress(),
+		ip.String(),
+		profile.ID,
+		string(bb),
+	}, handshaking.ForgeSeparator), nil
+}
+
+// unwrapConn returns the connection of Infrared under the conn
+func unwrapConn(c Conn) (*conn, bool) {
+	if p, ok := c.(*pendingConn); ok {
+		c = p.Conn
+	}
+	inner, ok := c.(*conn)
+	return inner, ok
+}
+
+// unreadPacket puts the packet back in front of the connection, so it's read again
+func (c *conn) unreadPacket(pk protocol.Packet) error {
+	bb, err := pk.Marshal()
+	if err != nil {
+		return err
+	}
+	c.r = bufio.NewReader(io.MultiReader(bytes.NewReader(bb), c.r))
+	return nil
+}
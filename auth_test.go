@@ -0,0 +1,211 @@
+package infrared
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/haveachin/infrared/protocol/login"
+)
+
+func TestCFB8(t *testing.T) {
+	// NIST SP 800-38A F.3.7 CFB8-AES128.Encrypt
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	iv, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	plaintext, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d")
+	ciphertext, _ := hex.DecodeString("3b79424c9c0dd436bace9e0ed4586a4f32b9")
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := make([]byte, len(plaintext))
+	newCFB8Encrypter(block, iv).XORKeyStream(encrypted, plaintext)
+	if !bytes.Equal(encrypted, ciphertext) {
+		t.Errorf("got %x; want %x", encrypted, ciphertext)
+	}
+
+	decrypted := make([]byte, len(ciphertext))
+	newCFB8Decrypter(block, iv).XORKeyStream(decrypted, ciphertext)
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("got %x; want %x", decrypted, plaintext)
+	}
+}
+
+func TestMinecraftDigest(t *testing.T) {
+	tt := []struct {
+		name   string
+		digest string
+	}{
+		{name: "Notch", digest: "4ed1f46bbe04bc756bcb17c0c7ce3e4632f06a48"},
+		{name: "jeb_", digest: "-7c9d5b0044c130109a5d7b5fb5c317c02b4e28c1"},
+		{name: "simon", digest: "88e16a1019277b15d58faf0541e11910eb756f6"},
+	}
+
+	for _, tc := range tt {
+		if digest := minecraftDigest([]byte(tc.name)); digest != tc.digest {
+			t.Errorf("%s: got %s; want %s", tc.name, digest, tc.digest)
+		}
+	}
+}
+
+func TestBungeeCordAddress(t *testing.T) {
+	hs := handshaking.ServerBoundHandshake{ServerAddress: "mc.example.com"}
+	addr := &net.TCPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 51234}
+	profile := gameProfile{
+		ID:         "069a79f444e94726a5befca90e38aaf5",
+		Name:       "Notch",
+		Properties: []profileProperty{{Name: "textures", Value: "e30=", Signature: "c2ln"}},
+	}
+
+	got, err := bungeeCordAddress(hs, addr, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "mc.example.com\x00203.0.113.7\x00069a79f444e94726a5befca90e38aaf5\x00" +
+		`[{"name":"textures","value":"e30=","signature":"c2ln"}]`
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+// authClient plays the client side of the authentication against the proxy and returns its encrypted connection
+func authClient(c net.Conn, username string) (Conn, error) {
+	client := wrapConn(c)
+	if err := client.WritePacket(loginStart(username)); err != nil {
+		return nil, err
+	}
+
+	pk, err := client.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		serverID    protocol.String
+		der         protocol.ByteArray
+		verifyToken protocol.ByteArray
+	)
+	if err := pk.Scan(&serverID, &der, &verifyToken); err != nil {
+		return nil, err
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedSecret := make([]byte, 16)
+	rand.Read(sharedSecret)
+	encryptedSecret, err := rsa.EncryptPKCS1v15(rand.Reader, pub.(*rsa.PublicKey), sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+	encryptedToken, err := rsa.EncryptPKCS1v15(rand.Reader, pub.(*rsa.PublicKey), verifyToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.WritePacket(protocol.MarshalPacket(
+		login.ServerBoundEncryptionResponsePacketID,
+		protocol.ByteArray(encryptedSecret),
+		protocol.ByteArray(encryptedToken),
+	)); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+	client.SetCipher(newCFB8Encrypter(block, sharedSecret), newCFB8Decrypter(block, sharedSecret))
+	return client, nil
+}
+
+func TestProxy_Authenticate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/session/minecraft/hasJoined" || r.URL.Query().Get("serverId") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("username") != "Notch" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(gameProfile{ID: "069a79f444e94726a5befca90e38aaf5", Name: "Notch"})
+	}))
+	defer server.Close()
+
+	proxy := &Proxy{Config: &ProxyConfig{
+		Authentication: AuthConfig{
+			Enabled:       true,
+			SessionServer: server.URL,
+			Timeout:       1000,
+		},
+	}}
+	hs := handshaking.ServerBoundHandshake{ProtocolVersion: 766, NextState: handshaking.ServerBoundHandshakeLoginState}
+
+	tt := []struct {
+		username string
+		success  bool
+	}{
+		{username: "Notch", success: true},
+		{username: "Herobrine", success: false},
+	}
+
+	for _, tc := range tt {
+		c1, c2 := net.Pipe()
+		clientErr := make(chan error, 1)
+		var client Conn
+		go func() {
+			var err error
+			client, err = authClient(c2, tc.username)
+			clientErr <- err
+		}()
+
+		conn := wrapConn(c1)
+		profile, err := proxy.authenticate(conn, hs, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err := <-clientErr; err != nil {
+			t.Fatalf("%s: %s", tc.username, err)
+		}
+		if (err == nil) != tc.success {
+			t.Fatalf("%s: got %v; want success %t", tc.username, err, tc.success)
+		}
+		if tc.success && profile.ID != "069a79f444e94726a5befca90e38aaf5" {
+			t.Errorf("%s: got profile %+v", tc.username, profile)
+		}
+
+		// The login start is read again after the authentication
+		pk, err := conn.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ls, err := login.UnmarshalServerBoundLoginStart(pk)
+		if err != nil || string(ls.Name) != tc.username {
+			t.Errorf("%s: got login start of %q, %v", tc.username, ls.Name, err)
+		}
+
+		// Everything after the encryption response is encrypted
+		go conn.WritePacket(login.ClientBoundDisconnect{Reason: "bye"}.Marshal())
+		pk, err = client.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reason protocol.String
+		if err := pk.Scan(&reason); err != nil || !strings.Contains(string(reason), "bye") {
+			t.Errorf("%s: got disconnect %q, %v", tc.username, reason, err)
+		}
+
+		c1.Close()
+		c2.Close()
+	}
+}
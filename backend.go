@@ -0,0 +1,184 @@
+package infrared
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Load balancing strategies that pick one of the backends of a proxy
+const (
+	RoundRobinStrategy       = "round-robin"
+	RandomStrategy           = "random"
+	LeastConnectionsStrategy = "least-connections"
+)
+
+// Keys that sessions of players are identified by
+const (
+	UsernameSessionKey = "username"
+	IPSessionKey       = "ip"
+)
+
+type session struct {
+	addr     string
+	lastUsed time.Time
+}
+
+// loadBalancer picks the backend of a connection and keeps track of
+// the open connections of every backend
+type loadBalancer struct {
+	mu       sync.Mutex
+	current  map[string]int
+	conns    map[string]int
+	sessions map[string]session
+	pruned   time.Time
+}
+
+// pick returns one of the addrs by the strategy and counts a connection to it.
+// Every addr gets new connections in proportion to its weight; addrs without a weight have a weight of 1.
+// The connection has to be released again after it is closed.
+func (lb *loadBalancer) pick(strategy string, addrs []string, weights map[string]int) string {
+	return lb.pickSession(strategy, addrs, weights, "", 0)
+}
+
+// pickSession works like pick, but returns the same addr for a key until the key was not used
+// for the timeout or the addr is no longer one of addrs. An empty key has no session.
+func (lb *loadBalancer) pickSession(strategy string, addrs []string, weights map[string]int, key string, timeout time.Duration) string {
+	if len(addrs) == 0 {
+		return ""
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.conns == nil {
+		lb.conns = map[string]int{}
+	}
+
+	now := time.Now()
+	if key != "" {
+		lb.pruneSessions(now, timeout)
+		if s, ok := lb.sessions[key]; ok && now.Sub(s.lastUsed) < timeout && containsAddr(addrs, s.addr) {
+			lb.sessions[key] = session{addr: s.addr, lastUsed: now}
+			lb.conns[s.addr]++
+			return s.addr
+		}
+	}
+
+	addr := lb.pickAddr(strategy, addrs, weights)
+	lb.conns[addr]++
+	if key != "" {
+		lb.sessions[key] = session{addr: addr, lastUsed: now}
+	}
+	return addr
+}
+
+// pruneSessions removes the expired sessions at most once per timeout
+func (lb *loadBalancer) pruneSessions(now time.Time, timeout time.Duration) {
+	if lb.sessions == nil {
+		lb.sessions = map[string]session{}
+	}
+
+	if now.Sub(lb.pruned) < timeout {
+		return
+	}
+	lb.pruned = now
+
+	for key, s := range lb.sessions {
+		if now.Sub(s.lastUsed) >= timeout {
+			delete(lb.sessions, key)
+		}
+	}
+}
+
+func (lb *loadBalancer) pickAddr(strategy string, addrs []string, weights map[string]int) string {
+	switch strategy {
+	case RandomStrategy:
+		total := 0
+		for _, a := range addrs {
+			total += backendWeight(weights, a)
+		}
+
+		n := rand.Intn(total)
+		for _, a := range addrs {
+			n -= backendWeight(weights, a)
+			if n < 0 {
+				return a
+			}
+		}
+		return addrs[len(addrs)-1]
+	case LeastConnectionsStrategy:
+		// Compares conns[a]/weight[a] < conns[addr]/weight[addr] without dividing
+		addr := addrs[0]
+		for _, a := range addrs[1:] {
+			if lb.conns[a]*backendWeight(weights, addr) < lb.conns[addr]*backendWeight(weights, a) {
+				addr = a
+			}
+		}
+		return addr
+	default:
+		// Smooth weighted round-robin; with equal weights it is plain round-robin
+		if lb.current == nil {
+			lb.current = map[string]int{}
+		}
+
+		total := 0
+		addr := addrs[0]
+		for _, a := range addrs {
+			weight := backendWeight(weights, a)
+			total += weight
+			lb.current[a] += weight
+			if lb.current[a] > lb.current[addr] {
+				addr = a
+			}
+		}
+		lb.current[addr] -= total
+		return addr
+	}
+}
+
+// backendWeight returns the weight of the addr or 1 if it has none
+func backendWeight(weights map[string]int, addr string) int {
+	if weight, ok := weights[addr]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+func containsAddr(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (lb *loadBalancer) release(addr string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.conns[addr] <= 1 {
+		delete(lb.conns, addr)
+		return
+	}
+	lb.conns[addr]--
+}
+
+// connectionsNotTo returns the number of open connections to other addrs than the addrs
+func (lb *loadBalancer) connectionsNotTo(addrs []string) int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	n := 0
+	for addr, conns := range lb.conns {
+		if !containsAddr(addrs, addr) {
+			n += conns
+		}
+	}
+	return n
+}
+
+// connections returns the number of open connections to the addr
+func (lb *loadBalancer) connections(addr string) int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.conns[addr]
+}
@@ -0,0 +1,96 @@
+package infrared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadBalancer_Pick(t *testing.T) {
+	addrs := []string{":25566", ":25567", ":25568"}
+
+	t.Run("round-robin", func(t *testing.T) {
+		var lb loadBalancer
+		for i := 0; i < 6; i++ {
+			if addr := lb.pick(RoundRobinStrategy, addrs, nil); addr != addrs[i%3] {
+				t.Errorf("pick %d: got %s; want %s", i, addr, addrs[i%3])
+			}
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		var lb loadBalancer
+		for i := 0; i < 10; i++ {
+			addr := lb.pick(RandomStrategy, addrs, nil)
+			if addr != addrs[0] && addr != addrs[1] && addr != addrs[2] {
+				t.Errorf("got unknown addr %s", addr)
+			}
+		}
+	})
+
+	t.Run("least-connections", func(t *testing.T) {
+		var lb loadBalancer
+		first := lb.pick(LeastConnectionsStrategy, addrs, nil)
+		second := lb.pick(LeastConnectionsStrategy, addrs, nil)
+		if first != addrs[0] || second != addrs[1] {
+			t.Errorf("got %s and %s; want %s and %s", first, second, addrs[0], addrs[1])
+		}
+
+		lb.release(first)
+		if addr := lb.pick(LeastConnectionsStrategy, addrs, nil); addr != addrs[0] {
+			t.Errorf("got %s after release; want %s", addr, addrs[0])
+		}
+		if n := lb.connections(addrs[1]); n != 1 {
+			t.Errorf("got %d connections to %s; want 1", n, addrs[1])
+		}
+	})
+
+	t.Run("no backends", func(t *testing.T) {
+		var lb loadBalancer
+		if addr := lb.pick(RoundRobinStrategy, nil, nil); addr != "" {
+			t.Errorf("got %s; want no addr", addr)
+		}
+	})
+}
+
+func TestLoadBalancer_PickSession(t *testing.T) {
+	addrs := []string{":25566", ":25567", ":25568"}
+
+	var lb loadBalancer
+	first := lb.pickSession(RoundRobinStrategy, addrs, nil, "notch", time.Hour)
+	if addr := lb.pickSession(RoundRobinStrategy, addrs, nil, "jeb_", time.Hour); addr == first {
+		t.Errorf("got %s for another session; want the next backend", addr)
+	}
+	if addr := lb.pickSession(RoundRobinStrategy, addrs, nil, "notch", time.Hour); addr != first {
+		t.Errorf("got %s; want %s of the session", addr, first)
+	}
+
+	if addr := lb.pickSession(RoundRobinStrategy, addrs[1:], nil, "notch", time.Hour); addr == first {
+		t.Errorf("got %s; want another backend after %s was removed", addr, first)
+	}
+
+	lb.sessions["expired"] = session{addr: addrs[2], lastUsed: time.Now().Add(-2 * time.Hour)}
+	lb.pruned = time.Time{}
+	lb.pickSession(RoundRobinStrategy, addrs, nil, "notch", time.Hour)
+	if _, ok := lb.sessions["expired"]; ok {
+		t.Error("got expired session; want it to be pruned")
+	}
+}
+
+func TestLoadBalancer_PickWeighted(t *testing.T) {
+	addrs := []string{":25566", ":25567"}
+	weights := map[string]int{":25566": 3}
+
+	for _, strategy := range []string{RoundRobinStrategy, RandomStrategy, LeastConnectionsStrategy} {
+		t.Run(strategy, func(t *testing.T) {
+			var lb loadBalancer
+			for i := 0; i < 400; i++ {
+				lb.pick(strategy, addrs, weights)
+			}
+
+			heavy, light := lb.connections(addrs[0]), lb.connections(addrs[1])
+			if heavy < 250 || heavy > 350 || heavy+light != 400 {
+				t.Errorf("got %d and %d connections; want about 300 and 100", heavy, light)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package infrared
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formats of exported bans
+const (
+	// PlainBanFormat lists one banned IP per line
+	PlainBanFormat = "plain"
+	// IPSetBanFormat lists the bans as `ipset restore` commands with the remaining ban time as timeout
+	IPSetBanFormat = "ipset"
+	// JSONBanFormat lists the bans as a JSON array
+	JSONBanFormat = "json"
+)
+
+// DefaultIPSet is the name of the ipset that the ipset format adds bans to
+const DefaultIPSet = "infrared"
+
+// banExpireInterval is how often expired bans are lifted
+var banExpireInterval = time.Second
+
+// Ban is an IP that Infrared bans at runtime, like for malformed handshakes
+type Ban struct {
+	IP     string    `json:"ip"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// BanExportConfig exports the runtime bans of the gateway, so a firewall can block the IPs in the kernel.
+// File is rewritten with the bans in the Format after every change. BanCommand and UnbanCommand run
+// for every ban and lifted ban; `{{ip}}`, `{{duration}}` (seconds) and `{{reason}}` are replaced in their arguments.
+type BanExportConfig struct {
+	File         string
+	Format       string
+	IPSet        string
+	BanCommand   string
+	UnbanCommand string
+}
+
+func (cfg BanExportConfig) validate() error {
+	switch cfg.Format {
+	case "", PlainBanFormat, IPSetBanFormat, JSONBanFormat:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", cfg.Format)
+}
+
+// banList holds the runtime bans of the gateway by IP
+type banList struct {
+	mu   sync.Mutex
+	bans map[string]Ban
+	// exportMu keeps an older export from replacing a newer one
+	exportMu sync.Mutex
+}
+
+// add bans the IP until the time and reports whether the IP wasn't banned yet
+func (l *banList) add(ip net.IP, until time.Time, reason string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.bans == nil {
+		l.bans = map[string]Ban{}
+	}
+
+	key := ip.String()
+	ban, ok := l.bans[key]
+	if ok && ban.Until.After(until) {
+		return false
+	}
+	l.bans[key] = Ban{IP: key, Until: until, Reason: reason}
+	return !ok
+}
+
+// banned reports whether the IP is banned
+func (l *banList) banned(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ban, ok := l.bans[ip.String()]
+	return ok && time.Now().Before(ban.Until)
+}
+
+// expire lifts the bans that ended before the time and returns them
+func (l *banList) expire(now time.Time) []Ban {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var expired []Ban
+	for key, ban := range l.bans {
+		if !now.Before(ban.Until) {
+			expired = append(expired, ban)
+			delete(l.bans, key)
+		}
+	}
+	return expired
+}
+
+// list returns the bans ordered by IP
+func (l *banList) list() []Ban {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bans := make([]Ban, 0, len(l.bans))
+	for _, ban := range l.bans {
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].IP < bans[j].IP
+	})
+	return bans
+}
+
+// WriteBans writes the bans in the format. The ipset format adds them to the ipset with the name.
+func WriteBans(w io.Writer, bans []Ban, format, ipset string) error {
+	switch format {
+	case JSONBanFormat:
+		return json.NewEncoder(w).Encode(bans)
+	case IPSetBanFormat:
+		if ipset == "" {
+			ipset = DefaultIPSet
+		}
+		for _, ban := range bans {
+			if _, err := fmt.Fprintf(w, "add %s %s timeout %d -exist\n", ipset, ban.IP, banSeconds(ban)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "", PlainBanFormat:
+		for _, ban := range bans {
+			if _, err := fmt.Fprintln(w, ban.IP); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// banSeconds returns the remaining seconds of the ban, which are at least one
+func banSeconds(ban Ban) int {
+	seconds := int(time.Until(ban.Until).Seconds())
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// Bans returns the runtime bans of the gateway
+func (gateway *Gateway) Bans() []Ban {
+	return gateway.bans.list()
+}
+
+// ban bans the IP for the duration, exports the bans and runs the ban command
+func (gateway *Gateway) ban(ip net.IP, duration time.Duration, reason string) {
+	if ip == nil {
+		return
+	}
+
+	until := time.Now().Add(duration)
+	if !gateway.bans.add(ip, until, reason) {
+		return
+	}
+	log.Printf("[i] Banning %s for %s; reason: %s", ip, duration, reason)
+
+	gateway.exportBans()
+	gateway.runBanCommand(gateway.BanExport.BanCommand, Ban{IP: ip.String(), Until: until, Reason: reason})
+}
+
+// expireBans lifts the expired bans every banExpireInterval
+func (gateway *Gateway) expireBans() {
+	ticker := time.NewTicker(banExpireInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		expired := gateway.bans.expire(now)
+		if len(expired) == 0 {
+			continue
+		}
+
+		gateway.exportBans()
+		for _, ban := range expired {
+			log.Printf("[i] Lifting the ban of %s", ban.IP)
+			gateway.runBanCommand(gateway.BanExport.UnbanCommand, ban)
+		}
+	}
+}
+
+// exportBans rewrites the ban file, if the gateway has one
+func (gateway *Gateway) exportBans() {
+	cfg := gateway.BanExport
+	if cfg.File == "" {
+		return
+	}
+	gateway.bans.exportMu.Lock()
+	defer gateway.bans.exportMu.Unlock()
+
+	// Write to a temporary file first, so readers never see half a list
+	tmp, err := os.CreateTemp(filepath.Dir(cfg.File), filepath.Base(cfg.File)+".*")
+	if err != nil {
+		log.Printf("[w] Could not export bans; error: %s", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := WriteBans(tmp, gateway.Bans(), cfg.Format, cfg.IPSet); err != nil {
+		tmp.Close()
+		log.Printf("[w] Could not export bans; error: %s", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Printf("[w] Could not export bans; error: %s", err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), cfg.File); err != nil {
+		log.Printf("[w] Could not export bans; error: %s", err)
+	}
+}
+
+// banCommandArgs returns the arguments of the command with the placeholders of the ban replaced
+func banCommandArgs(command string, ban Ban) []string {
+	replacer := strings.NewReplacer(
+		"{{ip}}", ban.IP,
+		"{{duration}}", strconv.Itoa(banSeconds(ban)),
+		"{{reason}}", ban.Reason,
+	)
+
+	args := strings.Fields(command)
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+	return args
+}
+
+// runBanCommand runs the command for the ban in the background, if the command is set
+func (gateway *Gateway) runBanCommand(command string, ban Ban) {
+	args := banCommandArgs(command, ban)
+	if len(args) == 0 {
+		return
+	}
+
+	go func() {
+		out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+		if err != nil {
+			log.Printf("[w] Ban command for %s failed; error: %s; output: %s", ban.IP, err, strings.TrimSpace(string(out)))
+		}
+	}()
+}
@@ -0,0 +1,101 @@
+package infrared
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBanList(t *testing.T) {
+	var l banList
+	ip := net.ParseIP("203.0.113.5")
+	now := time.Now()
+
+	if !l.add(ip, now.Add(time.Hour), "test") {
+		t.Fatal("got not added; want a new ban")
+	}
+	if l.add(ip, now.Add(time.Minute), "test") {
+		t.Error("got added; want the longer ban to stay")
+	}
+	if !l.banned(ip) {
+		t.Error("got not banned; want banned")
+	}
+	if l.banned(net.ParseIP("198.51.100.1")) {
+		t.Error("other ip: got banned; want not banned")
+	}
+
+	if expired := l.expire(now); len(expired) != 0 {
+		t.Errorf("got %v expired; want none", expired)
+	}
+	if expired := l.expire(now.Add(2 * time.Hour)); len(expired) != 1 || expired[0].IP != "203.0.113.5" {
+		t.Errorf("got %v expired; want the ban of 203.0.113.5", expired)
+	}
+	if l.banned(ip) {
+		t.Error("got banned; want the ban lifted")
+	}
+}
+
+func TestWriteBans(t *testing.T) {
+	bans := []Ban{
+		{IP: "198.51.100.1", Until: time.Now().Add(time.Minute + time.Second)},
+		{IP: "2001:db8::1", Until: time.Now().Add(time.Minute + time.Second)},
+	}
+
+	tt := []struct {
+		format string
+		out    string
+	}{
+		{format: PlainBanFormat, out: "198.51.100.1\n2001:db8::1\n"},
+		{format: IPSetBanFormat, out: "add blocked 198.51.100.1 timeout 60 -exist\nadd blocked 2001:db8::1 timeout 60 -exist\n"},
+	}
+
+	for _, tc := range tt {
+		var buf bytes.Buffer
+		if err := WriteBans(&buf, bans, tc.format, "blocked"); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.out {
+			t.Errorf("%s: got %q; want %q", tc.format, buf.String(), tc.out)
+		}
+	}
+
+	if err := WriteBans(&bytes.Buffer{}, bans, "iptables", ""); err == nil {
+		t.Error("got no error; want an unknown format")
+	}
+}
+
+func TestBanCommandArgs(t *testing.T) {
+	ban := Ban{IP: "203.0.113.5", Until: time.Now().Add(time.Minute + time.Second), Reason: "malformed handshakes"}
+	args := banCommandArgs("fail2ban-client set infrared banip {{ip}}", ban)
+	want := []string{"fail2ban-client", "set", "infrared", "banip", "203.0.113.5"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("got %q; want %q", args, want)
+	}
+
+	args = banCommandArgs("ipset add infrared {{ip}} timeout {{duration}}", ban)
+	if args[len(args)-1] != "60" {
+		t.Errorf("got duration %q; want 60", args[len(args)-1])
+	}
+
+	if args := banCommandArgs("", ban); len(args) != 0 {
+		t.Errorf("got %q; want no command", args)
+	}
+}
+
+func TestGateway_ExportBans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.txt")
+	gateway := Gateway{BanExport: BanExportConfig{File: path}}
+
+	gateway.ban(net.ParseIP("203.0.113.5"), time.Hour, "test")
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bb) != "203.0.113.5\n" {
+		t.Errorf("got %q; want the banned ip", bb)
+	}
+}
@@ -0,0 +1,369 @@
+package infrared
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haveachin/infrared/protocol/raknet"
+)
+
+// BedrockScheme is the prefix of listen addresses of Bedrock Edition proxies, like udp://:19132.
+// Bedrock clients connect via RakNet over UDP.
+const BedrockScheme = "udp://"
+
+// bedrockSessionTimeout is how long a Bedrock session lasts without packets from its backend
+var bedrockSessionTimeout = time.Second * 30
+
+// DefaultMaxBedrockSessions is the maximum number of sessions of a Bedrock listener of gateways without
+// MaxBedrockSessions. Every session has its own UDP socket to the backend.
+const DefaultMaxBedrockSessions = 4096
+
+// BedrockConfig holds the Bedrock Edition settings of a proxy. Everything else, like the domains,
+// the callback server and the load balancer, is shared by the Java and Bedrock clients of the proxy.
+type BedrockConfig struct {
+	Address  string              `json:"address"`
+	ListenTo string              `json:"listenTo"`
+	Status   BedrockStatusConfig `json:"status"`
+	Access   AccessListConfig    `json:"access"`
+	Limits   BedrockLimitsConfig `json:"limits"`
+}
+
+func isBedrockAddr(addr string) bool {
+	return strings.HasPrefix(addr, BedrockScheme)
+}
+
+// bedrockListener receives the packets of Bedrock clients and pipes them to the backends of their sessions
+type bedrockListener struct {
+	net.PacketConn
+	key         string
+	limiter     *bedrockLimiter
+	maxSessions int
+	mu          sync.Mutex
+	sessions    map[string]*bedrockSession
+}
+
+// bedrockSession is the UDP connection of a Bedrock client to its backend
+type bedrockSession struct {
+	proxy   *Proxy
+	backend string
+	// ready is closed once the backend is dialed. The conn and header are set before.
+	ready chan struct{}
+	conn  net.Conn
+	// header is the PROXY protocol header that is sent in front of every packet, if the proxy uses it
+	header []byte
+	// closeOnce releases the backend once, however the session ends
+	closeOnce sync.Once
+}
+
+// close closes the connection of the session to its backend and releases the backend in the balancer
+func (session *bedrockSession) close() {
+	session.closeOnce.Do(func() {
+		if session.conn != nil {
+			session.conn.Close()
+		}
+		session.proxy.balancer.release(session.backend)
+	})
+}
+
+// dialed reports whether the backend of the session is connected
+func (session *bedrockSession) dialed() bool {
+	select {
+	case <-session.ready:
+		return session.conn != nil
+	default:
+		return false
+	}
+}
+
+// write sends the packet of the client to the backend
+func (session *bedrockSession) write(client net.Addr, pk []byte) error {
+	if pk[0] == raknet.OpenConnectionRequest2PacketID {
+		if request, err := raknet.UnmarshalOpenConnectionRequest2(pk); err == nil {
+			log.Printf("[i] Bedrock client %s with GUID %d connects through %s to %s",
+				client, request.ClientGUID, session.proxy.UID(), session.backend)
+		}
+	}
+
+	if session.header != nil {
+		pk = append(append([]byte(nil), session.header...), pk...)
+	}
+	_, err := session.conn.Write(pk)
+	return err
+}
+
+func listenBedrock(addr, key string) (*bedrockListener, error) {
+	conn, err := net.ListenPacket("udp", strings.TrimPrefix(addr, BedrockScheme))
+	if err != nil {
+		return nil, err
+	}
+
+	return &bedrockListener{
+		PacketConn:  conn,
+		key:         key,
+		limiter:     newBedrockLimiter(),
+		maxSessions: DefaultMaxBedrockSessions,
+		sessions:    map[string]*bedrockSession{},
+	}, nil
+}
+
+// Close closes the listener and the sessions of all of its clients.
+// Sessions that are still dialing their backend close it once it is dialed.
+func (l *bedrockListener) Close() error {
+	err := l.PacketConn.Close()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, session := range l.sessions {
+		session.close()
+	}
+	l.sessions = map[string]*bedrockSession{}
+	return err
+}
+
+func (proxy *Proxy) BedrockAddress() string {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Bedrock.Address
+}
+
+// BedrockListenTo returns the Bedrock listener of a proxy with a Bedrock address or an empty string.
+// Addresses without a scheme get the BedrockScheme.
+func (proxy *Proxy) BedrockListenTo() string {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	if proxy.Config.Bedrock.Address == "" {
+		return ""
+	}
+
+	listenTo := proxy.Config.Bedrock.ListenTo
+	if strings.Contains(listenTo, ":") && !strings.Contains(listenTo, "://") {
+		listenTo = BedrockScheme + listenTo
+	}
+	return listenTo
+}
+
+func (proxy *Proxy) BedrockAccess() AccessListConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Bedrock.Access
+}
+
+// allowsBedrockClient reports whether the Bedrock access list of the proxy allows the client
+func (proxy *Proxy) allowsBedrockClient(addr net.Addr) bool {
+	return proxy.BedrockAccess().allowsAddr(addr)
+}
+
+// bedrockBackends returns the backends of the Bedrock clients of the proxy on the listener
+func (proxy *Proxy) bedrockBackends(key string) []string {
+	if proxy.ListenTo() == key {
+		return proxy.availableBackends()
+	}
+	return []string{proxy.BedrockAddress()}
+}
+
+// bedrockProxy returns the proxy of the Bedrock listener; either a Bedrock proxy on the listener or a Java proxy
+// with a Bedrock address. Bedrock clients don't send the domain they connect to,
+// so the proxy with the highest priority on the listener gets all of its clients.
+func bedrockProxy(proxies *sync.Map, key string) (*Proxy, bool) {
+	var match *Proxy
+	proxies.Range(func(k, v interface{}) bool {
+		proxy := v.(*Proxy)
+		if proxy.ListenTo() != key && proxy.BedrockListenTo() != key {
+			return true
+		}
+
+		if match == nil || proxy.Priority() > match.Priority() ||
+			proxy.Priority() == match.Priority() && proxy.UID() < match.UID() {
+			match = proxy
+		}
+		return true
+	})
+	return match, match != nil
+}
+
+func (gateway *Gateway) serveBedrock(listener *bedrockListener) error {
+	defer gateway.wg.Done()
+
+	buffer := make([]byte, 0xffff)
+	for {
+		n, addr, err := listener.ReadFrom(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				log.Println("Closing Bedrock listener on", listener.key)
+				return nil
+			}
+			continue
+		}
+		// Empty datagrams don't even have a packet ID
+		if n == 0 {
+			continue
+		}
+		pk := buffer[:n]
+
+		if !gateway.allowBedrockPacket(listener, addr, n) {
+			continue
+		}
+
+		// Pings are answered from the cached pong of the backend
+		if pk[0] == raknet.UnconnectedPingPacketID || pk[0] == raknet.UnconnectedPingOpenConnectionsPacketID {
+			go gateway.answerBedrockPing(listener, addr, append([]byte(nil), pk...))
+			continue
+		}
+
+		session, err := gateway.bedrockSession(listener, addr, pk)
+		if err != nil {
+			log.Printf("[x] Dropping Bedrock packet of %s on %s; error: %s", addr, listener.key, err)
+			continue
+		}
+
+		// Packets of a session that is still dialing its backend are dropped, RakNet clients resend them
+		if session == nil || !session.dialed() {
+			continue
+		}
+
+		if err := session.write(addr, pk); err != nil {
+			listener.closeSession(addr.String(), session)
+		}
+	}
+}
+
+// bedrockSession returns the session of the client or starts a new one, if the packet is
+// an offline message like the start of a connection. New sessions dial their backend off the
+// read loop of the listener and send the packet themselves, so no session is returned for them.
+func (gateway *Gateway) bedrockSession(listener *bedrockListener, addr net.Addr, pk []byte) (*bedrockSession, error) {
+	listener.mu.Lock()
+	session, ok := listener.sessions[addr.String()]
+	sessions := len(listener.sessions)
+	listener.mu.Unlock()
+	if ok {
+		return session, nil
+	}
+
+	if !raknet.IsOfflineMessage(pk) {
+		return nil, errors.New("no session")
+	}
+
+	// Offline messages are easy to spoof, so the sessions that they start are limited
+	if sessions >= listener.maxSessions {
+		return nil, fmt.Errorf("%d sessions on the listener", sessions)
+	}
+
+	proxy, ok := bedrockProxy(&gateway.Proxies, listener.key)
+	if !ok {
+		return nil, fmt.Errorf("no proxy on %s", listener.key)
+	}
+
+	if !proxy.allowsBedrockClient(addr) {
+		return nil, fmt.Errorf("denied by the access list of %s", proxy.UID())
+	}
+
+	backend := proxy.balancer.pick(proxy.LoadBalancer(), proxy.bedrockBackends(listener.key), proxy.BackendWeights())
+	if backend == "" {
+		return nil, fmt.Errorf("no backend for %s", proxy.UID())
+	}
+
+	session = &bedrockSession{
+		proxy:   proxy,
+		backend: backend,
+		ready:   make(chan struct{}),
+	}
+	listener.mu.Lock()
+	listener.sessions[addr.String()] = session
+	listener.mu.Unlock()
+
+	go listener.dialSession(session, addr, append([]byte(nil), pk...))
+	return nil, nil
+}
+
+// dialSession dials the backend of the new session, sends it the first packet of the client
+// and pipes the packets of the backend to the client
+func (l *bedrockListener) dialSession(session *bedrockSession, client net.Addr, pk []byte) {
+	conn, err := session.dial(client)
+
+	l.mu.Lock()
+	if err == nil && l.sessions[client.String()] != session {
+		conn.Close()
+		err = errors.New("listener closed")
+	}
+	if err != nil {
+		if l.sessions[client.String()] == session {
+			delete(l.sessions, client.String())
+		}
+		l.mu.Unlock()
+		close(session.ready)
+		session.close()
+		log.Printf("[x] Dropping Bedrock packet of %s on %s; error: %s", client, l.key, err)
+		return
+	}
+	session.conn = conn
+	l.mu.Unlock()
+	close(session.ready)
+
+	if err := session.write(client, pk); err != nil {
+		l.closeSession(client.String(), session)
+		return
+	}
+	l.pipeBackend(session, client)
+}
+
+// dial connects to the backend of the session and formats its PROXY protocol header
+func (session *bedrockSession) dial(client net.Addr) (net.Conn, error) {
+	conn, err := dialBedrock(session.backend, session.proxy.Timeout())
+	if err != nil {
+		return nil, err
+	}
+
+	if session.proxy.ProxyProtocol() {
+		// Bedrock backends like Geyser read the header of every datagram, because UDP has no connection
+		session.header, err = proxyProtocolHeader(client, conn.RemoteAddr()).Format()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// dialBedrock resolves the backend address and connects a UDP socket to it
+func dialBedrock(addr string, timeout time.Duration) (net.Conn, error) {
+	addr, err := resolveBackend(addr)
+	if err != nil {
+		return nil, err
+	}
+	return net.DialTimeout("udp", strings.TrimPrefix(addr, BedrockScheme), timeout)
+}
+
+// pipeBackend sends the packets of the backend to the client until the session times out
+func (l *bedrockListener) pipeBackend(session *bedrockSession, client net.Addr) {
+	defer l.closeSession(client.String(), session)
+
+	buffer := make([]byte, 0xffff)
+	for {
+		if err := session.conn.SetReadDeadline(time.Now().Add(bedrockSessionTimeout)); err != nil {
+			return
+		}
+
+		n, err := session.conn.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		if _, err := l.WriteTo(buffer[:n], client); err != nil {
+			return
+		}
+	}
+}
+
+func (l *bedrockListener) closeSession(client string, session *bedrockSession) {
+	l.mu.Lock()
+	if l.sessions[client] == session {
+		delete(l.sessions, client)
+	}
+	l.mu.Unlock()
+	session.close()
+}
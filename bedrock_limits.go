@@ -0,0 +1,131 @@
+package infrared
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var bedrockDroppedPackets = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "infrared_bedrock_dropped_packets",
+	Help: "The total number of Bedrock packets dropped by the packet limits",
+}, []string{"listener"})
+
+// BedrockLimitsConfig limits the packets that an IP sends to a Bedrock listener.
+// An IP that sends a packet bigger than MaxPacketSize or more than PacketsPerSecond
+// packets is dropped for DropDuration milliseconds. Limits of 0 are disabled.
+type BedrockLimitsConfig struct {
+	MaxPacketSize    int `json:"maxPacketSize"`
+	PacketsPerSecond int `json:"packetsPerSecond"`
+	DropDuration     int `json:"dropDuration"`
+}
+
+// bedrockLimiterSweepInterval is how often the limiter forgets IPs that stopped sending packets
+var bedrockLimiterSweepInterval = time.Minute
+
+type bedrockLimiterEntry struct {
+	window       time.Time
+	packets      int
+	droppedUntil time.Time
+}
+
+// bedrockLimiter counts the packets of every IP on a listener in windows of one second
+type bedrockLimiter struct {
+	mu        sync.Mutex
+	entries   map[string]*bedrockLimiterEntry
+	lastSweep time.Time
+}
+
+func newBedrockLimiter() *bedrockLimiter {
+	return &bedrockLimiter{
+		entries:   map[string]*bedrockLimiterEntry{},
+		lastSweep: time.Now(),
+	}
+}
+
+// allow counts the packet of the size from the IP and reports whether it is within the limits.
+// The first packet over a limit drops the IP.
+func (l *bedrockLimiter) allow(ip net.IP, size int, cfg BedrockLimitsConfig) bool {
+	if cfg.MaxPacketSize <= 0 && cfg.PacketsPerSecond <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	key := ip.String()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > bedrockLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	entry, ok := l.entries[key]
+	if !ok {
+		entry = &bedrockLimiterEntry{window: now}
+		l.entries[key] = entry
+	}
+
+	if now.Before(entry.droppedUntil) {
+		return false
+	}
+
+	if now.Sub(entry.window) >= time.Second {
+		entry.window = now
+		entry.packets = 0
+	}
+	entry.packets++
+
+	if cfg.MaxPacketSize > 0 && size > cfg.MaxPacketSize ||
+		cfg.PacketsPerSecond > 0 && entry.packets > cfg.PacketsPerSecond {
+		entry.droppedUntil = now.Add(time.Millisecond * time.Duration(cfg.DropDuration))
+		log.Printf("[i] Dropping Bedrock packets of %s for %dms; packet limits exceeded", key, cfg.DropDuration)
+		return false
+	}
+	return true
+}
+
+// sweep removes the IPs that are neither dropped nor sent packets in the last second
+func (l *bedrockLimiter) sweep(now time.Time) {
+	for key, entry := range l.entries {
+		if now.Sub(entry.window) >= time.Second && !now.Before(entry.droppedUntil) {
+			delete(l.entries, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (proxy *Proxy) BedrockLimits() BedrockLimitsConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Bedrock.Limits
+}
+
+// allowBedrockPacket applies the packet limits of the proxy of the client to its packet
+func (gateway *Gateway) allowBedrockPacket(listener *bedrockListener, addr net.Addr, size int) bool {
+	ip, _, ok := splitIPAddr(unmapAddr(addr))
+	if !ok {
+		return true
+	}
+
+	listener.mu.Lock()
+	session, ok := listener.sessions[addr.String()]
+	listener.mu.Unlock()
+
+	var proxy *Proxy
+	if ok {
+		proxy = session.proxy
+	} else if proxy, ok = bedrockProxy(&gateway.Proxies, listener.key); !ok {
+		return true
+	}
+
+	if !listener.limiter.allow(ip, size, proxy.BedrockLimits()) {
+		bedrockDroppedPackets.WithLabelValues(listener.key).Inc()
+		return false
+	}
+	return true
+}
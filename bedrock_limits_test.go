@@ -0,0 +1,54 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBedrockLimiter_Allow(t *testing.T) {
+	cfg := BedrockLimitsConfig{
+		MaxPacketSize:    100,
+		PacketsPerSecond: 3,
+		DropDuration:     60000,
+	}
+	ip := net.ParseIP("203.0.113.5")
+	other := net.ParseIP("198.51.100.1")
+
+	l := newBedrockLimiter()
+	for i := 0; i < 3; i++ {
+		if !l.allow(ip, 10, cfg) {
+			t.Fatalf("got packet %d dropped; want it allowed", i)
+		}
+	}
+	if l.allow(ip, 10, cfg) {
+		t.Error("got the fourth packet allowed; want it dropped by the rate limit")
+	}
+	if !l.allow(other, 10, cfg) {
+		t.Error("got the packet of another IP dropped; want it allowed")
+	}
+
+	l.entries[ip.String()].window = time.Now().Add(-time.Second)
+	if l.allow(ip, 10, cfg) {
+		t.Error("got a packet allowed in the next window; want the IP to be dropped")
+	}
+
+	if l.allow(other, 101, cfg) || l.allow(other, 10, cfg) {
+		t.Error("got packets allowed after an oversized packet; want the IP to be dropped")
+	}
+
+	if !l.allow(ip, 1000, BedrockLimitsConfig{}) {
+		t.Error("got a packet dropped without limits; want it allowed")
+	}
+}
+
+func TestBedrockLimiter_Sweep(t *testing.T) {
+	l := newBedrockLimiter()
+	cfg := BedrockLimitsConfig{PacketsPerSecond: 1}
+	l.allow(net.ParseIP("203.0.113.5"), 10, cfg)
+
+	l.sweep(time.Now().Add(time.Second))
+	if len(l.entries) != 0 {
+		t.Errorf("got %d entries; want the idle IP to be removed", len(l.entries))
+	}
+}
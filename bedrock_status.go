@@ -0,0 +1,164 @@
+package infrared
+
+import (
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/haveachin/infrared/protocol/raknet"
+)
+
+// BedrockStatusConfig overrides the pong of the backend of a Bedrock proxy.
+// Empty fields keep the value of the backend. With passthrough, every ping is
+// forwarded to the backend and its pong is sent to the client unchanged.
+type BedrockStatusConfig struct {
+	Passthrough   bool   `json:"passthrough"`
+	CacheTTL      int    `json:"cacheTtl"`
+	MOTD          string `json:"motd"`
+	SubMOTD       string `json:"subMotd"`
+	PlayersOnline int    `json:"playersOnline"`
+	MaxPlayers    int    `json:"maxPlayers"`
+	GameMode      string `json:"gameMode"`
+}
+
+// apply overrides the fields of the server ID that are set in the config
+func (cfg BedrockStatusConfig) apply(id raknet.ServerID) raknet.ServerID {
+	if cfg.MOTD != "" {
+		id.MOTD = cfg.MOTD
+	}
+	if cfg.SubMOTD != "" {
+		id.SubMOTD = cfg.SubMOTD
+	}
+	if cfg.PlayersOnline != 0 {
+		id.PlayersOnline = cfg.PlayersOnline
+	}
+	if cfg.MaxPlayers != 0 {
+		id.MaxPlayers = cfg.MaxPlayers
+	}
+	if cfg.GameMode != "" {
+		id.GameMode = cfg.GameMode
+	}
+	return id
+}
+
+// offlineBedrockServerID is the server ID of backends that don't answer pings
+var offlineBedrockServerID = raknet.ServerID{
+	Edition:    "MCPE",
+	MOTD:       "Powered by Infrared",
+	MaxPlayers: 20,
+	GameMode:   "Survival",
+	GameModeID: 1,
+}
+
+// infraredGUID is the server GUID of the pongs that Infrared answers itself
+var infraredGUID = rand.Int63()
+
+type bedrockPongEntry struct {
+	pong    raknet.UnconnectedPong
+	expires time.Time
+}
+
+// bedrockPongCache caches the pongs of Bedrock backends until their TTL runs out
+var bedrockPongCache = struct {
+	sync.Mutex
+	entries map[string]bedrockPongEntry
+}{entries: map[string]bedrockPongEntry{}}
+
+func (proxy *Proxy) BedrockStatus() BedrockStatusConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Bedrock.Status
+}
+
+// bedrockPong returns the pong of the proxy to the ping. The pong of the backend is cached
+// for the cache TTL of the proxy and the overrides of its Bedrock status are applied to it.
+// In passthrough mode the ping is sent to the backend and its pong is returned as is.
+func (proxy *Proxy) bedrockPong(key string, ping raknet.UnconnectedPing) raknet.UnconnectedPong {
+	cfg := proxy.BedrockStatus()
+	pong := raknet.UnconnectedPong{ServerGUID: infraredGUID}
+	id := offlineBedrockServerID
+	id.ServerUniqueID = strconv.FormatInt(infraredGUID, 10)
+
+	backends := proxy.bedrockBackends(key)
+	if cfg.Passthrough && len(backends) > 0 {
+		if backendPong, err := pingBedrock(backends[0], ping, proxy.Timeout()); err == nil {
+			return backendPong
+		}
+	} else if len(backends) > 0 {
+		if backendPong, err := cachedBedrockPong(backends[0], time.Millisecond*time.Duration(cfg.CacheTTL), proxy.Timeout()); err == nil {
+			if backendID, err := raknet.ParseServerID(backendPong.ServerID); err == nil {
+				pong = backendPong
+				id = backendID
+			}
+		}
+	}
+
+	pong.SendTimestamp = ping.SendTimestamp
+	pong.ServerID = cfg.apply(id).String()
+	return pong
+}
+
+// cachedBedrockPong returns the cached pong of the backend or pings the backend, if the pong expired
+func cachedBedrockPong(backend string, ttl, timeout time.Duration) (raknet.UnconnectedPong, error) {
+	bedrockPongCache.Lock()
+	entry, ok := bedrockPongCache.entries[backend]
+	bedrockPongCache.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.pong, nil
+	}
+
+	ping := raknet.UnconnectedPing{
+		SendTimestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		ClientGUID:    infraredGUID,
+	}
+	pong, err := pingBedrock(backend, ping, timeout)
+	if err != nil {
+		return pong, err
+	}
+
+	bedrockPongCache.Lock()
+	bedrockPongCache.entries[backend] = bedrockPongEntry{pong: pong, expires: time.Now().Add(ttl)}
+	bedrockPongCache.Unlock()
+	return pong, nil
+}
+
+// pingBedrock sends the unconnected ping to the Bedrock backend and waits for its pong
+func pingBedrock(backend string, ping raknet.UnconnectedPing, timeout time.Duration) (raknet.UnconnectedPong, error) {
+	conn, err := dialBedrock(backend, timeout)
+	if err != nil {
+		return raknet.UnconnectedPong{}, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return raknet.UnconnectedPong{}, err
+	}
+
+	if _, err := conn.Write(ping.Marshal()); err != nil {
+		return raknet.UnconnectedPong{}, err
+	}
+
+	buffer := make([]byte, 0xffff)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return raknet.UnconnectedPong{}, err
+	}
+	return raknet.UnmarshalUnconnectedPong(buffer[:n])
+}
+
+// answerBedrockPing answers the ping of the client with the pong of the proxy on the listener
+func (gateway *Gateway) answerBedrockPing(listener *bedrockListener, addr net.Addr, pk []byte) {
+	ping, err := raknet.UnmarshalUnconnectedPing(pk)
+	if err != nil {
+		return
+	}
+
+	proxy, ok := bedrockProxy(&gateway.Proxies, listener.key)
+	if !ok || !proxy.allowsBedrockClient(addr) {
+		return
+	}
+
+	listener.WriteTo(proxy.bedrockPong(listener.key, ping).Marshal(), addr)
+}
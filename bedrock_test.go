@@ -0,0 +1,435 @@
+package infrared
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/haveachin/infrared/protocol/raknet"
+	"github.com/pires/go-proxyproto"
+)
+
+func TestBedrockProxy(t *testing.T) {
+	portEnd := 590
+	backend, err := net.ListenPacket("udp", "127.0.0.1"+serverAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	go func() {
+		buffer := make([]byte, 0xffff)
+		for {
+			n, addr, err := backend.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			backend.WriteTo(buffer[:n], addr)
+		}
+	}()
+
+	config := &ProxyConfig{
+		DomainName: "bedrock",
+		ListenTo:   BedrockScheme + "127.0.0.1" + gatewayAddr(portEnd),
+		ProxyTo:    "127.0.0.1" + serverAddr(portEnd),
+		Timeout:    1000,
+	}
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	exchange := func(pk []byte) ([]byte, error) {
+		conn, err := net.Dial("udp", "127.0.0.1"+gatewayAddr(portEnd))
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+
+		if _, err := conn.Write(pk); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		buffer := make([]byte, 0xffff)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+		return buffer[:n], nil
+	}
+
+	request := append([]byte{raknet.OpenConnectionRequest1PacketID}, raknet.Magic...)
+	request = append(request, 11, 0, 0, 0)
+	received, err := exchange(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, request) {
+		t.Errorf("got %v; want the request back from the backend", received)
+	}
+
+	if _, err := exchange([]byte{0x84, 0x00, 0x00, 0x00}); err == nil {
+		t.Error("got a response; want packets without a session to be dropped")
+	}
+}
+
+func TestBedrockPong(t *testing.T) {
+	portEnd := 591
+	backend, err := net.ListenPacket("udp", "127.0.0.1"+serverAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	var pings int32
+	go func() {
+		buffer := make([]byte, 0xffff)
+		for {
+			_, addr, err := backend.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&pings, 1)
+			pong := raknet.UnconnectedPong{
+				ServerGUID: 42,
+				ServerID:   "MCPE;Backend;594;1.20.0;3;10;42;Bedrock level;Survival;1;19132;19133;",
+			}
+			backend.WriteTo(pong.Marshal(), addr)
+		}
+	}()
+
+	config := &ProxyConfig{
+		ListenTo: BedrockScheme + "127.0.0.1" + gatewayAddr(portEnd),
+		ProxyTo:  "127.0.0.1" + serverAddr(portEnd),
+		Timeout:  1000,
+		Bedrock: BedrockConfig{
+			Status: BedrockStatusConfig{
+				CacheTTL:   60000,
+				MOTD:       "Infrared",
+				MaxPlayers: 100,
+			},
+		},
+	}
+	proxy := &Proxy{Config: config}
+
+	for i := int64(0); i < 3; i++ {
+		pong := proxy.bedrockPong(config.ListenTo, raknet.UnconnectedPing{SendTimestamp: i})
+		if pong.SendTimestamp != i || pong.ServerGUID != 42 {
+			t.Errorf("got timestamp %d and GUID %d; want %d and 42", pong.SendTimestamp, pong.ServerGUID, i)
+		}
+
+		expected := "MCPE;Infrared;594;1.20.0;3;100;42;Bedrock level;Survival;1;19132;19133;"
+		if pong.ServerID != expected {
+			t.Errorf("got %s; want %s", pong.ServerID, expected)
+		}
+	}
+
+	if n := atomic.LoadInt32(&pings); n != 1 {
+		t.Errorf("got %d pings to the backend; want 1 because of the cache", n)
+	}
+
+	backend.Close()
+	config.ProxyTo = "127.0.0.1" + serverAddr(portEnd+1)
+	pong := proxy.bedrockPong(config.ListenTo, raknet.UnconnectedPing{})
+	if id, err := raknet.ParseServerID(pong.ServerID); err != nil || id.MOTD != "Infrared" || id.MaxPlayers != 100 {
+		t.Errorf("got %s; want the offline pong with the overrides", pong.ServerID)
+	}
+}
+
+func TestBedrockPong_Passthrough(t *testing.T) {
+	portEnd := 595
+	backend, err := net.ListenPacket("udp", "127.0.0.1"+serverAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	go func() {
+		buffer := make([]byte, 0xffff)
+		for i := 0; ; i++ {
+			n, addr, err := backend.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			ping, err := raknet.UnmarshalUnconnectedPing(buffer[:n])
+			if err != nil {
+				continue
+			}
+			pong := raknet.UnconnectedPong{
+				SendTimestamp: ping.SendTimestamp,
+				ServerGUID:    42,
+				ServerID:      fmt.Sprintf("MCPE;Backend;594;1.20.0;%d;10;42;Bedrock level;Survival;1;19132;19133;", i),
+			}
+			backend.WriteTo(pong.Marshal(), addr)
+		}
+	}()
+
+	config := &ProxyConfig{
+		ListenTo: BedrockScheme + "127.0.0.1" + gatewayAddr(portEnd),
+		ProxyTo:  "127.0.0.1" + serverAddr(portEnd),
+		Timeout:  1000,
+		Bedrock: BedrockConfig{
+			Status: BedrockStatusConfig{
+				Passthrough: true,
+				CacheTTL:    60000,
+				MOTD:        "Infrared",
+			},
+		},
+	}
+	proxy := &Proxy{Config: config}
+
+	for i := int64(0); i < 2; i++ {
+		pong := proxy.bedrockPong(config.ListenTo, raknet.UnconnectedPing{SendTimestamp: i})
+		expected := fmt.Sprintf("MCPE;Backend;594;1.20.0;%d;10;42;Bedrock level;Survival;1;19132;19133;", i)
+		if pong.SendTimestamp != i || pong.ServerID != expected {
+			t.Errorf("got timestamp %d and %s; want %d and %s", pong.SendTimestamp, pong.ServerID, i, expected)
+		}
+	}
+}
+
+func TestBedrockProxyProtocol(t *testing.T) {
+	portEnd := 592
+	backend, err := net.ListenPacket("udp", "127.0.0.1"+serverAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	headerCh := make(chan *proxyproto.Header, 2)
+	go func() {
+		buffer := make([]byte, 0xffff)
+		for {
+			n, _, err := backend.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+
+			header, err := proxyproto.Read(bufio.NewReader(bytes.NewReader(buffer[:n])))
+			if err != nil {
+				headerCh <- nil
+				continue
+			}
+			headerCh <- header
+		}
+	}()
+
+	config := &ProxyConfig{
+		ListenTo:      BedrockScheme + "127.0.0.1" + gatewayAddr(portEnd),
+		ProxyTo:       "127.0.0.1" + serverAddr(portEnd),
+		ProxyProtocol: true,
+		Timeout:       1000,
+	}
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	conn, err := net.Dial("udp", "127.0.0.1"+gatewayAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	request := append([]byte{raknet.OpenConnectionRequest1PacketID}, raknet.Magic...)
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Write(request); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case header := <-headerCh:
+			if header == nil {
+				t.Fatal("got a packet without a PROXY protocol header")
+			}
+			if header.TransportProtocol != proxyproto.UDPv4 || header.SourceAddr.String() != conn.LocalAddr().String() {
+				t.Errorf("got %v from %s; want UDPv4 from %s", header.TransportProtocol, header.SourceAddr, conn.LocalAddr())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("got no packet at the backend")
+		}
+	}
+}
+
+func TestGeyserRouting(t *testing.T) {
+	portEnd := 593
+	errorCh := make(chan *testError, 1)
+	statusListen(statusListenerConfig{
+		addr:   serverAddr(portEnd),
+		status: statusPKWithVersion("java"),
+	}, errorCh)
+
+	geyser, err := net.ListenPacket("udp", "127.0.0.1"+serverAddr(portEnd+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer geyser.Close()
+
+	go func() {
+		buffer := make([]byte, 0xffff)
+		n, addr, err := geyser.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		geyser.WriteTo(buffer[:n], addr)
+	}()
+
+	config := &ProxyConfig{
+		DomainName:  serverDomain,
+		ListenTo:    gatewayAddr(portEnd),
+		JavaAddress: serverAddr(portEnd),
+		Bedrock: BedrockConfig{
+			Address:  "127.0.0.1" + serverAddr(portEnd+1),
+			ListenTo: "127.0.0.1" + gatewayAddr(portEnd),
+		},
+		Timeout: 1000,
+	}
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	receivedVersion, testErr := statusDial(statusDialConfig{
+		pk:          statusHandshakePort(portEnd),
+		gatewayAddr: gatewayAddr(portEnd),
+		dialerPort:  dialerPort(portEnd),
+	})
+	if testErr != nil {
+		t.Fatalf("%s: %v", testErr.Message, testErr.Error)
+	}
+	if receivedVersion != "java" {
+		t.Errorf("got version %s; want the Java backend", receivedVersion)
+	}
+
+	conn, err := net.Dial("udp", "127.0.0.1"+gatewayAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	request := append([]byte{raknet.OpenConnectionRequest1PacketID}, raknet.Magic...)
+	if _, err := conn.Write(request); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buffer := make([]byte, 0xffff)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer[:n], request) {
+		t.Errorf("got %v; want the request back from the Geyser backend", buffer[:n])
+	}
+}
+
+func TestBedrockProxy_EmptyPacket(t *testing.T) {
+	portEnd := 598
+	backend, err := net.ListenPacket("udp", "127.0.0.1"+serverAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	go func() {
+		buffer := make([]byte, 0xffff)
+		for {
+			n, addr, err := backend.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			backend.WriteTo(buffer[:n], addr)
+		}
+	}()
+
+	config := &ProxyConfig{
+		ListenTo: BedrockScheme + "127.0.0.1" + gatewayAddr(portEnd),
+		ProxyTo:  "127.0.0.1" + serverAddr(portEnd),
+		Timeout:  1000,
+	}
+	gateway := Gateway{MaxBedrockSessions: 1}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	exchange := func(conn net.Conn, pk []byte) ([]byte, error) {
+		if _, err := conn.Write(pk); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		buffer := make([]byte, 0xffff)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+		return buffer[:n], nil
+	}
+
+	first, err := net.Dial("udp", "127.0.0.1"+gatewayAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	if _, err := exchange(first, []byte{}); err == nil {
+		t.Error("got a response; want empty packets to be dropped")
+	}
+
+	request := append([]byte{raknet.OpenConnectionRequest1PacketID}, raknet.Magic...)
+	if received, err := exchange(first, request); err != nil || !bytes.Equal(received, request) {
+		t.Fatalf("got %v, %v; want the request back from the backend", received, err)
+	}
+
+	second, err := net.Dial("udp", "127.0.0.1"+gatewayAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	if _, err := exchange(second, request); err == nil {
+		t.Error("got a response; want the session over the limit to be dropped")
+	}
+}
+
+func TestBedrockListener_CloseReleasesBackends(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := &Proxy{Config: &ProxyConfig{}}
+	proxy.balancer.conns = map[string]int{"geyser:19132": 2}
+	listener := &bedrockListener{PacketConn: pc, sessions: map[string]*bedrockSession{}}
+	for _, client := range []string{"1.2.3.4:40000", "1.2.3.5:40000"} {
+		conn, err := net.Dial("udp", pc.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener.sessions[client] = &bedrockSession{proxy: proxy, backend: "geyser:19132", ready: make(chan struct{}), conn: conn}
+	}
+	sessions := make(map[string]*bedrockSession, len(listener.sessions))
+	for client, session := range listener.sessions {
+		sessions[client] = session
+	}
+
+	listener.Close()
+	// The pipes of the sessions end once the listener closed their connections
+	for client, session := range sessions {
+		listener.closeSession(client, session)
+	}
+
+	proxy.balancer.mu.Lock()
+	defer proxy.balancer.mu.Unlock()
+	if n := proxy.balancer.conns["geyser:19132"]; n != 0 {
+		t.Errorf("got %d connections to the backend; want 0 after the listener closed", n)
+	}
+}
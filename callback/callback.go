@@ -27,6 +27,11 @@ func newEventLog(event Event) EventLog {
 	}
 }
 
+// DefaultTimeout is the timeout of the requests of a Logger without its own client
+const DefaultTimeout = 10 * time.Second
+
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
 // Logger can post events to an http endpoint
 type Logger struct {
 	client HTTPClient
@@ -51,18 +56,19 @@ func (logger Logger) hasEvent(event Event) bool {
 	return hasEvent
 }
 
+// Posts reports whether LogEvent posts the event
+func (logger Logger) Posts(event Event) bool {
+	return logger.isValid() && logger.hasEvent(event)
+}
+
 // LogEvent posts the given event to an http endpoint if the Logger
 // holds a valid URL and the Logger.Events contains given event's type.
 func (logger Logger) LogEvent(event Event) (*EventLog, error) {
 	if logger.client == nil {
-		logger.client = http.DefaultClient
+		logger.client = defaultClient
 	}
 
-	if !logger.isValid() {
-		return nil, nil
-	}
-
-	if !logger.hasEvent(event) {
+	if !logger.Posts(event) {
 		return nil, nil
 	}
 
@@ -77,10 +83,13 @@ func (logger Logger) LogEvent(event Event) (*EventLog, error) {
 		return nil, err
 	}
 
-	_, err = logger.client.Do(request)
+	response, err := logger.client.Do(request)
 	if err != nil {
 		return nil, err
 	}
+	if response != nil && response.Body != nil {
+		response.Body.Close()
+	}
 
 	return &eventLog, nil
 }
@@ -6,6 +6,8 @@ const (
 	EventTypePlayerLeave    string = "PlayerLeave"
 	EventTypeContainerStart string = "ContainerStart"
 	EventTypeContainerStop  string = "ContainerStop"
+	EventTypeBackendDown    string = "BackendDown"
+	EventTypeBackendUp      string = "BackendUp"
 )
 
 type Event interface {
@@ -58,3 +60,22 @@ type ContainerStopEvent struct {
 func (event ContainerStopEvent) EventType() string {
 	return EventTypeContainerStop
 }
+
+type BackendDownEvent struct {
+	Address  string `json:"address"`
+	Error    string `json:"error"`
+	ProxyUID string `json:"proxyUid"`
+}
+
+func (event BackendDownEvent) EventType() string {
+	return EventTypeBackendDown
+}
+
+type BackendUpEvent struct {
+	Address  string `json:"address"`
+	ProxyUID string `json:"proxyUid"`
+}
+
+func (event BackendUpEvent) EventType() string {
+	return EventTypeBackendUp
+}
@@ -27,6 +27,14 @@ func TestErrorEvent_EventType(t *testing.T) {
 			event:     ContainerStopEvent{},
 			eventType: EventTypeContainerStop,
 		},
+		{
+			event:     BackendDownEvent{},
+			eventType: EventTypeBackendDown,
+		},
+		{
+			event:     BackendUpEvent{},
+			eventType: EventTypeBackendUp,
+		},
 	}
 
 	for _, tc := range tt {
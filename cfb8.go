@@ -0,0 +1,49 @@
+package infrared
+
+import "crypto/cipher"
+
+// cfb8 is the AES/CFB8 stream cipher that encrypts Minecraft connections after the login.
+// The standard library only has CFB with a segment size of a whole block.
+type cfb8 struct {
+	block   cipher.Block
+	sr      []byte
+	out     []byte
+	decrypt bool
+}
+
+func newCFB8(block cipher.Block, iv []byte, decrypt bool) cipher.Stream {
+	sr := make([]byte, block.BlockSize())
+	copy(sr, iv)
+	return &cfb8{
+		block:   block,
+		sr:      sr,
+		out:     make([]byte, block.BlockSize()),
+		decrypt: decrypt,
+	}
+}
+
+// newCFB8Encrypter returns a stream that encrypts with the block cipher in CFB8 mode
+func newCFB8Encrypter(block cipher.Block, iv []byte) cipher.Stream {
+	return newCFB8(block, iv, false)
+}
+
+// newCFB8Decrypter returns a stream that decrypts with the block cipher in CFB8 mode
+func newCFB8Decrypter(block cipher.Block, iv []byte) cipher.Stream {
+	return newCFB8(block, iv, true)
+}
+
+func (c *cfb8) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		c.block.Encrypt(c.out, c.sr)
+		in := src[i]
+		dst[i] = in ^ c.out[0]
+
+		// The shift register is fed with the cipher text
+		copy(c.sr, c.sr[1:])
+		if c.decrypt {
+			c.sr[len(c.sr)-1] = in
+		} else {
+			c.sr[len(c.sr)-1] = dst[i]
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package infrared
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/status"
+)
+
+// Text formats of the MOTDs and disconnect messages of proxies. Without a format, the text is shown as it is.
+const (
+	// MiniMessageFormat formats text with MiniMessage tags like <red>, <#ff5555>, <bold> and <gradient:red:blue>
+	MiniMessageFormat = "minimessage"
+	// AmpersandFormat formats text with legacy codes like &c and &l and hex colors like &#ff5555
+	AmpersandFormat = "ampersand"
+)
+
+// namedColors are the hex values of the named colors of chat components
+var namedColors = map[string]string{
+	"black":        "#000000",
+	"dark_blue":    "#0000aa",
+	"dark_green":   "#00aa00",
+	"dark_aqua":    "#00aaaa",
+	"dark_red":     "#aa0000",
+	"dark_purple":  "#aa00aa",
+	"gold":         "#ffaa00",
+	"gray":         "#aaaaaa",
+	"dark_gray":    "#555555",
+	"blue":         "#5555ff",
+	"green":        "#55ff55",
+	"aqua":         "#55ffff",
+	"red":          "#ff5555",
+	"light_purple": "#ff55ff",
+	"yellow":       "#ffff55",
+	"white":        "#ffffff",
+}
+
+// legacyColors are the named colors of the legacy color codes 0 to f
+var legacyColors = []string{
+	"black", "dark_blue", "dark_green", "dark_aqua", "dark_red", "dark_purple", "gold", "gray",
+	"dark_gray", "blue", "green", "aqua", "red", "light_purple", "yellow", "white",
+}
+
+type chatComponent struct {
+	Text          string          `json:"text"`
+	Color         string          `json:"color,omitempty"`
+	Bold          bool            `json:"bold,omitempty"`
+	Italic        bool            `json:"italic,omitempty"`
+	Underlined    bool            `json:"underlined,omitempty"`
+	Strikethrough bool            `json:"strikethrough,omitempty"`
+	Obfuscated    bool            `json:"obfuscated,omitempty"`
+	Extra         []chatComponent `json:"extra,omitempty"`
+}
+
+// chatGradient colors every character of its text with a color between its colors, or of the rainbow
+type chatGradient struct {
+	colors  [][3]float64
+	rainbow bool
+	length  int
+}
+
+type chatStyle struct {
+	color         string
+	gradient      *chatGradient
+	bold          bool
+	italic        bool
+	underlined    bool
+	strikethrough bool
+	obfuscated    bool
+}
+
+type chatSegment struct {
+	text  string
+	style chatStyle
+}
+
+// formatText compiles the text in the format into a chat component
+func formatText(text, format string) chatComponent {
+	var segments []chatSegment
+	switch format {
+	case MiniMessageFormat:
+		segments = parseMiniMessage(text)
+	case AmpersandFormat:
+		segments = parseAmpersand(text)
+	default:
+		return chatComponent{Text: text}
+	}
+	return chatComponent{Extra: chatComponents(segments)}
+}
+
+// formatChat returns the chat component of the text in the format
+func formatChat(text, format string) protocol.Chat {
+	bb, _ := json.Marshal(formatText(text, format))
+	return protocol.Chat(bb)
+}
+
+// formatDescription returns the status description of the MOTD in the format
+func formatDescription(motd, format string) status.DescriptionJSON {
+	component := formatText(motd, format)
+	description := status.DescriptionJSON{Text: component.Text}
+	for _, extra := range component.Extra {
+		bb, _ := json.Marshal(extra)
+		description.Extra = append(description.Extra, bb)
+	}
+	return description
+}
+
+// escapeText escapes the value of a placeholder, so it is shown as it is in the format
+func escapeText(value, format string) string {
+	if format == MiniMessageFormat {
+		return strings.NewReplacer(`\`, `\\`, "<", `\<`).Replace(value)
+	}
+	return value
+}
+
+// chatColor returns the color of a chat component of a color name or a hex color like #ff5555
+func chatColor(color string) (string, bool) {
+	color = strings.ReplaceAll(strings.ToLower(color), "grey", "gray")
+	if _, ok := namedColors[color]; ok {
+		return color, true
+	}
+	if len(color) != 7 || color[0] != '#' {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(color[1:], 16, 32); err != nil {
+		return "", false
+	}
+	return color, true
+}
+
+// colorRGB returns the red, green and blue values of the color of a chat component
+func colorRGB(color string) [3]float64 {
+	if hex, ok := namedColors[color]; ok {
+		color = hex
+	}
+	v, _ := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	return [3]float64{float64(v >> 16 & 0xff), float64(v >> 8 & 0xff), float64(v & 0xff)}
+}
+
+// parseMiniMessage parses the text with MiniMessage tags. Closing tags restore the style before their opening tag,
+// unknown tags are kept as text and \< escapes a tag.
+func parseMiniMessage(text string) []chatSegment {
+	type openTag struct {
+		name  string
+		style chatStyle
+	}
+
+	var segments []chatSegment
+	var stack []openTag
+	var style chatStyle
+	var sb strings.Builder
+	flush := func() {
+		if sb.Len() > 0 {
+			segments = append(segments, chatSegment{text: sb.String(), style: style})
+			sb.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '\\' && i+1 < len(text) && (text[i+1] == '<' || text[i+1] == '\\') {
+			sb.WriteByte(text[i+1])
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(text[i:], '>')
+		if c != '<' || end < 2 {
+			sb.WriteByte(c)
+			continue
+		}
+		tag := text[i+1 : i+end]
+
+		if strings.HasPrefix(tag, "/") {
+			name := strings.ToLower(strings.SplitN(tag[1:], ":", 2)[0])
+			closed := false
+			for j := len(stack) - 1; j >= 0; j-- {
+				if stack[j].name == name {
+					flush()
+					style = stack[j].style
+					stack = stack[:j]
+					closed = true
+					break
+				}
+			}
+			if !closed {
+				sb.WriteString(text[i : i+end+1])
+			}
+			i += end
+			continue
+		}
+
+		switch strings.ToLower(tag) {
+		case "reset":
+			flush()
+			style = chatStyle{}
+			stack = nil
+			i += end
+			continue
+		case "newline", "br":
+			sb.WriteByte('\n')
+			i += end
+			continue
+		}
+
+		next, ok := applyMiniMessageTag(tag, style)
+		if !ok {
+			sb.WriteString(text[i : i+end+1])
+			i += end
+			continue
+		}
+
+		flush()
+		name := strings.ToLower(strings.TrimPrefix(strings.SplitN(tag, ":", 2)[0], "!"))
+		stack = append(stack, openTag{name: name, style: style})
+		style = next
+		i += end
+	}
+	flush()
+	return segments
+}
+
+// applyMiniMessageTag returns the style after the opening tag and whether the tag is known
+func applyMiniMessageTag(tag string, style chatStyle) (chatStyle, bool) {
+	args := strings.Split(tag, ":")
+	name := strings.ToLower(args[0])
+	args = args[1:]
+	enabled := !strings.HasPrefix(name, "!")
+	name = strings.TrimPrefix(name, "!")
+
+	switch name {
+	case "bold", "b":
+		style.bold = enabled
+	case "italic", "i", "em":
+		style.italic = enabled
+	case "underlined", "u":
+		style.underlined = enabled
+	case "strikethrough", "st":
+		style.strikethrough = enabled
+	case "obfuscated", "obf":
+		style.obfuscated = enabled
+	case "color", "colour", "c":
+		if len(args) != 1 {
+			return style, false
+		}
+		color, ok := chatColor(args[0])
+		if !ok {
+			return style, false
+		}
+		style.color, style.gradient = color, nil
+	case "gradient":
+		gradient := &chatGradient{}
+		for _, arg := range args {
+			// The phase of the gradient is not supported
+			if color, ok := chatColor(arg); ok {
+				gradient.colors = append(gradient.colors, colorRGB(color))
+			}
+		}
+		switch len(gradient.colors) {
+		case 0:
+			gradient.colors = [][3]float64{colorRGB("white"), colorRGB("black")}
+		case 1:
+			gradient.colors = append(gradient.colors, gradient.colors[0])
+		}
+		style.color, style.gradient = "", gradient
+	case "rainbow":
+		style.color, style.gradient = "", &chatGradient{rainbow: true}
+	default:
+		color, ok := chatColor(name)
+		if !ok || len(args) > 0 || !enabled {
+			return style, false
+		}
+		style.color, style.gradient = color, nil
+	}
+	return style, true
+}
+
+// parseAmpersand parses the text with legacy codes that start with & or §, hex colors like &#ff5555
+// and hex colors of the BungeeCord format like &x&f&f&5&5&5&5. Like in the legacy format, colors reset the decorations.
+func parseAmpersand(text string) []chatSegment {
+	var segments []chatSegment
+	var style chatStyle
+	var sb strings.Builder
+	flush := func() {
+		if sb.Len() > 0 {
+			segments = append(segments, chatSegment{text: sb.String(), style: style})
+			sb.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		prefix := 0
+		if text[i] == '&' {
+			prefix = 1
+		} else if strings.HasPrefix(text[i:], "§") {
+			prefix = len("§")
+		}
+		if prefix == 0 || i+prefix >= len(text) {
+			sb.WriteByte(text[i])
+			i++
+			continue
+		}
+
+		code := text[i+prefix:]
+		if color, n, ok := parseHexCode(code); ok {
+			flush()
+			style = chatStyle{color: color}
+			i += prefix + n
+			continue
+		}
+
+		switch c := strings.ToLower(code[:1]); {
+		case strings.Contains("0123456789abcdef", c):
+			flush()
+			n, _ := strconv.ParseUint(c, 16, 8)
+			style = chatStyle{color: legacyColors[n]}
+		case c == "k":
+			flush()
+			style.obfuscated = true
+		case c == "l":
+			flush()
+			style.bold = true
+		case c == "m":
+			flush()
+			style.strikethrough = true
+		case c == "n":
+			flush()
+			style.underlined = true
+		case c == "o":
+			flush()
+			style.italic = true
+		case c == "r":
+			flush()
+			style = chatStyle{}
+		default:
+			sb.WriteByte(text[i])
+			i++
+			continue
+		}
+		i += prefix + 1
+	}
+	flush()
+	return segments
+}
+
+// parseHexCode parses the hex color at the start of a code after its & and returns the length of the code
+func parseHexCode(code string) (string, int, bool) {
+	if strings.HasPrefix(code, "#") && len(code) >= 7 {
+		if color, ok := chatColor(code[:7]); ok {
+			return color, 7, true
+		}
+	}
+
+	// &x&r&r&g&g&b&b
+	if len(code) < 13 || (code[0] != 'x' && code[0] != 'X') {
+		return "", 0, false
+	}
+	var hex strings.Builder
+	for i := 1; i < 13; i += 2 {
+		if code[i] != '&' && code[i] != '§' {
+			return "", 0, false
+		}
+		hex.WriteByte(code[i+1])
+	}
+	color, ok := chatColor("#" + hex.String())
+	return color, 13, ok
+}
+
+// chatComponents returns the components of the segments. Segments with a gradient are split into one component per character.
+func chatComponents(segments []chatSegment) []chatComponent {
+	for _, segment := range segments {
+		if segment.style.gradient != nil {
+			segment.style.gradient.length += len([]rune(segment.text))
+		}
+	}
+
+	var components []chatComponent
+	offsets := map[*chatGradient]int{}
+	for _, segment := range segments {
+		gradient := segment.style.gradient
+		if gradient == nil {
+			components = append(components, segment.style.component(segment.text, segment.style.color))
+			continue
+		}
+
+		for _, r := range segment.text {
+			color := gradient.colorAt(offsets[gradient])
+			offsets[gradient]++
+			components = append(components, segment.style.component(string(r), color))
+		}
+	}
+	return components
+}
+
+func (style chatStyle) component(text, color string) chatComponent {
+	return chatComponent{
+		Text:          text,
+		Color:         color,
+		Bold:          style.bold,
+		Italic:        style.italic,
+		Underlined:    style.underlined,
+		Strikethrough: style.strikethrough,
+		Obfuscated:    style.obfuscated,
+	}
+}
+
+// colorAt returns the hex color of the character at the index
+func (gradient *chatGradient) colorAt(i int) string {
+	t := 0.0
+	if gradient.length > 1 {
+		t = float64(i) / float64(gradient.length-1)
+	}
+
+	var rgb [3]float64
+	if gradient.rainbow {
+		rgb = hueRGB(float64(i) / math.Max(float64(gradient.length), 1))
+	} else {
+		pos := t * float64(len(gradient.colors)-1)
+		k := int(pos)
+		if k >= len(gradient.colors)-1 {
+			k = len(gradient.colors) - 2
+		}
+		frac := pos - float64(k)
+		from, to := gradient.colors[k], gradient.colors[k+1]
+		for c := range rgb {
+			rgb[c] = from[c] + (to[c]-from[c])*frac
+		}
+	}
+	return fmt.Sprintf("#%02x%02x%02x", uint8(math.Round(rgb[0])), uint8(math.Round(rgb[1])), uint8(math.Round(rgb[2])))
+}
+
+// hueRGB returns the fully saturated color of the hue between 0 and 1
+func hueRGB(hue float64) [3]float64 {
+	h := math.Mod(hue, 1) * 6
+	x := 255 * (1 - math.Abs(math.Mod(h, 2)-1))
+	switch int(h) {
+	case 0:
+		return [3]float64{255, x, 0}
+	case 1:
+		return [3]float64{x, 255, 0}
+	case 2:
+		return [3]float64{0, 255, x}
+	case 3:
+		return [3]float64{0, x, 255}
+	case 4:
+		return [3]float64{x, 0, 255}
+	default:
+		return [3]float64{255, 0, x}
+	}
+}
@@ -0,0 +1,85 @@
+package infrared
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func componentJSON(t *testing.T, component chatComponent) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(component); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func TestFormatText(t *testing.T) {
+	tt := []struct {
+		name   string
+		text   string
+		format string
+		json   string
+	}{
+		{
+			name: "Plain",
+			text: "<red>&cHello",
+			json: `{"text":"<red>&cHello"}`,
+		},
+		{
+			name:   "MiniMessageColors",
+			text:   "<red>Hello <#00ff00><b>World</b></#00ff00>!",
+			format: MiniMessageFormat,
+			json:   `{"text":"","extra":[{"text":"Hello ","color":"red"},{"text":"World","color":"#00ff00","bold":true},{"text":"!","color":"red"}]}`,
+		},
+		{
+			name:   "MiniMessageUnknownAndEscapedTags",
+			text:   `<unknown>\<red>a < b`,
+			format: MiniMessageFormat,
+			json:   `{"text":"","extra":[{"text":"<unknown><red>a < b"}]}`,
+		},
+		{
+			name:   "MiniMessageReset",
+			text:   "<gold><italic>a<reset>b",
+			format: MiniMessageFormat,
+			json:   `{"text":"","extra":[{"text":"a","color":"gold","italic":true},{"text":"b"}]}`,
+		},
+		{
+			name:   "MiniMessageGradient",
+			text:   "<gradient:#ff0000:#0000ff>abc</gradient>",
+			format: MiniMessageFormat,
+			json:   `{"text":"","extra":[{"text":"a","color":"#ff0000"},{"text":"b","color":"#800080"},{"text":"c","color":"#0000ff"}]}`,
+		},
+		{
+			name:   "Ampersand",
+			text:   "&6&lGold &r&#123abcHex &x&f&f&0&0&0&0Red",
+			format: AmpersandFormat,
+			json:   `{"text":"","extra":[{"text":"Gold ","color":"gold","bold":true},{"text":"Hex ","color":"#123abc"},{"text":"Red","color":"#ff0000"}]}`,
+		},
+		{
+			name:   "AmpersandColorResetsDecorations",
+			text:   "§lBold&cRed & more",
+			format: AmpersandFormat,
+			json:   `{"text":"","extra":[{"text":"Bold","bold":true},{"text":"Red & more","color":"red"}]}`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if s := componentJSON(t, formatText(tc.text, tc.format)); s != tc.json {
+				t.Errorf("got %s; want %s", s, tc.json)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	value := escapeText("<red>name", MiniMessageFormat)
+	s := componentJSON(t, formatText("<gray>"+value, MiniMessageFormat))
+	if expected := `{"text":"","extra":[{"text":"<red>name","color":"gray"}]}`; s != expected {
+		t.Errorf("got %s; want %s", s, expected)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/haveachin/infrared"
+	"github.com/haveachin/infrared/provider"
+	"sigs.k8s.io/yaml"
+)
+
+// cmdConfigDump is the subcommand that prints the effective proxy configs
+const cmdConfigDump = "config dump"
+
+// dumpFormat is the output format of the config dump; json or yaml
+var dumpFormat = "json"
+
+// dumpConfigs loads the proxy configs of all providers and prints them as Infrared
+// uses them, including the providers that supplied every config. Like validate,
+// nothing is bound. It returns the exit code, which is 1 if any config is invalid.
+func dumpConfigs() int {
+	if dumpFormat != "json" && dumpFormat != "yaml" {
+		fmt.Fprintf(os.Stderr, "Unknown format %q; use json or yaml\n", dumpFormat)
+		return 1
+	}
+
+	providers, err := newProviders()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed creating providers; error:", err)
+		return 1
+	}
+
+	composite := provider.NewComposite(providers...)
+	defer composite.Close()
+
+	if _, err := composite.Provide(make(chan provider.Data)); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed loading proxy configs; error:", err)
+		return 1
+	}
+
+	cfgs := infrared.EffectiveConfigs(composite)
+	bb, err := json.MarshalIndent(cfgs, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if dumpFormat == "yaml" {
+		if bb, err = yaml.JSONToYAML(bb); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	fmt.Println(string(bb))
+
+	for _, cfg := range cfgs {
+		if cfg.Error != "" {
+			return 1
+		}
+	}
+	return 0
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	_ "github.com/go-sql-driver/mysql"
 	"github.com/haveachin/infrared/api"
+	"github.com/haveachin/infrared/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/haveachin/infrared"
 )
@@ -14,29 +22,337 @@ import (
 const (
 	envPrefix               = "INFRARED_"
 	envConfigPath           = envPrefix + "CONFIG_PATH"
+	envConfigDebounce       = envPrefix + "CONFIG_DEBOUNCE"
+	envConfigInclude        = envPrefix + "CONFIG_INCLUDE"
+	envConfigExclude        = envPrefix + "CONFIG_EXCLUDE"
+	envConfigURL            = envPrefix + "CONFIG_URL"
+	envConfigURLInterval    = envPrefix + "CONFIG_URL_INTERVAL"
+	envEtcdEndpoint         = envPrefix + "ETCD_ENDPOINT"
+	envEtcdPrefix           = envPrefix + "ETCD_PREFIX"
+	envConsulAddress        = envPrefix + "CONSUL_ADDRESS"
+	envConsulPrefix         = envPrefix + "CONSUL_PREFIX"
+	envConsulToken          = envPrefix + "CONSUL_TOKEN"
+	envKubernetesEnabled    = envPrefix + "KUBERNETES_ENABLED"
+	envKubernetesNamespace  = envPrefix + "KUBERNETES_NAMESPACE"
+	envKubernetesSelector   = envPrefix + "KUBERNETES_LABEL_SELECTOR"
+	envKubernetesResource   = envPrefix + "KUBERNETES_RESOURCE"
+	envDockerDiscovery      = envPrefix + "DOCKER_DISCOVERY_ENABLED"
+	envRedisAddress         = envPrefix + "REDIS_ADDRESS"
+	envRedisPassword        = envPrefix + "REDIS_PASSWORD"
+	envRedisDB              = envPrefix + "REDIS_DB"
+	envRedisKey             = envPrefix + "REDIS_KEY"
+	envRedisChannel         = envPrefix + "REDIS_CHANNEL"
+	envS3Endpoint           = envPrefix + "S3_ENDPOINT"
+	envS3Region             = envPrefix + "S3_REGION"
+	envS3Bucket             = envPrefix + "S3_BUCKET"
+	envS3Prefix             = envPrefix + "S3_PREFIX"
+	envS3AccessKey          = envPrefix + "S3_ACCESS_KEY"
+	envS3SecretKey          = envPrefix + "S3_SECRET_KEY"
+	envS3Interval           = envPrefix + "S3_INTERVAL"
+	envGitRepository        = envPrefix + "GIT_REPOSITORY"
+	envGitBranch            = envPrefix + "GIT_BRANCH"
+	envGitDirectory         = envPrefix + "GIT_DIRECTORY"
+	envGitWorkDir           = envPrefix + "GIT_WORK_DIR"
+	envGitInterval          = envPrefix + "GIT_INTERVAL"
+	envSQLDriver            = envPrefix + "SQL_DRIVER"
+	envSQLDSN               = envPrefix + "SQL_DSN"
+	envSQLTable             = envPrefix + "SQL_TABLE"
+	envSQLInterval          = envPrefix + "SQL_INTERVAL"
+	envSQLNotifyChannel     = envPrefix + "SQL_NOTIFY_CHANNEL"
+	envZooKeeperServers     = envPrefix + "ZOOKEEPER_SERVERS"
+	envZooKeeperPath        = envPrefix + "ZOOKEEPER_PATH"
+	envGRPCAddress          = envPrefix + "GRPC_ADDRESS"
+	envGRPCNodeID           = envPrefix + "GRPC_NODE_ID"
+	envGRPCTLS              = envPrefix + "GRPC_TLS"
+	envConfigPipe           = envPrefix + "CONFIG_PIPE"
+	envNATSURL              = envPrefix + "NATS_URL"
+	envNATSBucket           = envPrefix + "NATS_BUCKET"
+	envDNSDomains           = envPrefix + "DNS_DOMAINS"
+	envDNSResolver          = envPrefix + "DNS_RESOLVER"
+	envProviderPriorities   = envPrefix + "PROVIDER_PRIORITIES"
+	envProviderNamespaces   = envPrefix + "PROVIDER_NAMESPACES"
+	envRetryAttempts        = envPrefix + "PROVIDER_RETRY_ATTEMPTS"
+	envRetryBackoff         = envPrefix + "PROVIDER_RETRY_BACKOFF"
+	envRetryMaxBackoff      = envPrefix + "PROVIDER_RETRY_MAX_BACKOFF"
+	envRetryJitter          = envPrefix + "PROVIDER_RETRY_JITTER"
+	envAgeKey               = envPrefix + "AGE_KEY"
+	envAgeKeyFile           = envPrefix + "AGE_KEY_FILE"
+	envVaultAddress         = envPrefix + "VAULT_ADDRESS"
+	envVaultToken           = envPrefix + "VAULT_TOKEN"
+	envVaultRefreshInterval = envPrefix + "VAULT_REFRESH_INTERVAL"
 	envReceiveProxyProtocol = envPrefix + "RECEIVE_PROXY_PROTOCOL"
+	envProxyProtoListeners  = envPrefix + "PROXY_PROTOCOL_LISTENERS"
+	envProxyProtoTrusted    = envPrefix + "PROXY_PROTOCOL_TRUSTED"
+	envListeners            = envPrefix + "LISTENERS"
+	envSocketOptions        = envPrefix + "SOCKET_OPTIONS"
+	envTLSListeners         = envPrefix + "TLS_LISTENERS"
+	envTLSCerts             = envPrefix + "TLS_CERTS"
+	envTLSKeys              = envPrefix + "TLS_KEYS"
+	envReusePort            = envPrefix + "REUSE_PORT"
+	envAcceptors            = envPrefix + "ACCEPTORS"
+	envListenerSocketOpts   = envPrefix + "LISTENER_SOCKET_OPTIONS"
+	envAcceptRate           = envPrefix + "ACCEPT_RATE"
+	envAcceptBurst          = envPrefix + "ACCEPT_BURST"
+	envAcceptBanDuration    = envPrefix + "ACCEPT_BAN_DURATION"
+	envAcceptGlobalRate     = envPrefix + "ACCEPT_GLOBAL_RATE"
+	envAcceptGlobalBurst    = envPrefix + "ACCEPT_GLOBAL_BURST"
+	envMaxConnsPerIP        = envPrefix + "MAX_CONNS_PER_IP"
+	envAllow                = envPrefix + "ALLOW"
+	envDeny                 = envPrefix + "DENY"
+	envAllowFile            = envPrefix + "ALLOW_FILE"
+	envDenyFile             = envPrefix + "DENY_FILE"
+	envDenyFeed             = envPrefix + "DENY_FEED"
+	envDenyFeedRefresh      = envPrefix + "DENY_FEED_REFRESH"
+	envAbuseIPDBKey         = envPrefix + "ABUSEIPDB_KEY"
+	envIPv6ConnPrefix       = envPrefix + "IPV6_CONN_PREFIX"
+	envGeoIPDatabase        = envPrefix + "GEOIP_DATABASE"
+	envProtectionProfiles   = envPrefix + "PROTECTION_PROFILES"
+	envMalformedThreshold   = envPrefix + "MALFORMED_BAN_THRESHOLD"
+	envMalformedBanDecay    = envPrefix + "MALFORMED_BAN_DECAY"
+	envMalformedBanDuration = envPrefix + "MALFORMED_BAN_DURATION"
+	envHandshakeTimeout     = envPrefix + "HANDSHAKE_TIMEOUT"
+	envLoginTimeout         = envPrefix + "LOGIN_TIMEOUT"
+	envMaxPendingConns      = envPrefix + "MAX_PENDING_CONNS"
+	envMaxBedrockSessions   = envPrefix + "MAX_BEDROCK_SESSIONS"
+	envStrictProtocol       = envPrefix + "STRICT_PROTOCOL"
+	envBanFile              = envPrefix + "BAN_FILE"
+	envBanFormat            = envPrefix + "BAN_FORMAT"
+	envBanIPSet             = envPrefix + "BAN_IPSET"
+	envBanCommand           = envPrefix + "BAN_COMMAND"
+	envUnbanCommand         = envPrefix + "UNBAN_COMMAND"
+	envCrowdSecURL          = envPrefix + "CROWDSEC_URL"
+	envCrowdSecAPIKey       = envPrefix + "CROWDSEC_API_KEY"
+	envCrowdSecMachineID    = envPrefix + "CROWDSEC_MACHINE_ID"
+	envCrowdSecPassword     = envPrefix + "CROWDSEC_PASSWORD"
+	envCrowdSecInterval     = envPrefix + "CROWDSEC_INTERVAL"
+	envCrowdSecBanDuration  = envPrefix + "CROWDSEC_BAN_DURATION"
 	envApiEnabled           = envPrefix + "API_ENABLED"
 	envApiBind              = envPrefix + "API_BIND"
 	envPrometheusEnabled    = envPrefix + "PROMETHEUS_ENABLED"
 	envPrometheusBind       = envPrefix + "PROMETHEUS_BIND"
 )
 
+// envProxiesPrefix is the prefix of all environment variables that define proxy configs
+const envProxiesPrefix = envPrefix + "PROXIES_"
+
 const (
 	clfConfigPath           = "config-path"
+	clfConfigDebounce       = "config-debounce"
+	clfConfigInclude        = "config-include"
+	clfConfigExclude        = "config-exclude"
+	clfConfigURL            = "config-url"
+	clfConfigURLInterval    = "config-url-interval"
+	clfEtcdEndpoint         = "etcd-endpoint"
+	clfEtcdPrefix           = "etcd-prefix"
+	clfConsulAddress        = "consul-address"
+	clfConsulPrefix         = "consul-prefix"
+	clfKubernetesEnabled    = "enable-kubernetes"
+	clfKubernetesNamespace  = "kubernetes-namespace"
+	clfKubernetesSelector   = "kubernetes-label-selector"
+	clfKubernetesResource   = "kubernetes-resource"
+	clfDockerDiscovery      = "enable-docker-discovery"
+	clfRedisAddress         = "redis-address"
+	clfRedisDB              = "redis-db"
+	clfRedisKey             = "redis-key"
+	clfRedisChannel         = "redis-channel"
+	clfS3Endpoint           = "s3-endpoint"
+	clfS3Region             = "s3-region"
+	clfS3Bucket             = "s3-bucket"
+	clfS3Prefix             = "s3-prefix"
+	clfS3Interval           = "s3-interval"
+	clfGitRepository        = "git-repository"
+	clfGitBranch            = "git-branch"
+	clfGitDirectory         = "git-directory"
+	clfGitWorkDir           = "git-work-dir"
+	clfGitInterval          = "git-interval"
+	clfSQLDriver            = "sql-driver"
+	clfSQLTable             = "sql-table"
+	clfSQLInterval          = "sql-interval"
+	clfSQLNotifyChannel     = "sql-notify-channel"
+	clfZooKeeperServers     = "zookeeper-servers"
+	clfZooKeeperPath        = "zookeeper-path"
+	clfGRPCAddress          = "grpc-address"
+	clfGRPCNodeID           = "grpc-node-id"
+	clfGRPCTLS              = "grpc-tls"
+	clfConfigPipe           = "config-pipe"
+	clfNATSURL              = "nats-url"
+	clfNATSBucket           = "nats-bucket"
+	clfDNSDomains           = "dns-domains"
+	clfDNSResolver          = "dns-resolver"
+	clfProviderPriorities   = "provider-priorities"
+	clfProviderNamespaces   = "provider-namespaces"
+	clfRetryAttempts        = "provider-retry-attempts"
+	clfRetryBackoff         = "provider-retry-backoff"
+	clfRetryMaxBackoff      = "provider-retry-max-backoff"
+	clfRetryJitter          = "provider-retry-jitter"
+	clfAgeKeyFile           = "age-key-file"
+	clfVaultAddress         = "vault-address"
+	clfVaultRefreshInterval = "vault-refresh-interval"
 	clfReceiveProxyProtocol = "receive-proxy-protocol"
+	clfProxyProtoListeners  = "proxy-protocol-listeners"
+	clfProxyProtoTrusted    = "proxy-protocol-trusted"
+	clfListeners            = "listeners"
+	clfSocketOptions        = "socket-options"
+	clfTLSListeners         = "tls-listeners"
+	clfTLSCerts             = "tls-certs"
+	clfTLSKeys              = "tls-keys"
+	clfReusePort            = "reuse-port"
+	clfAcceptors            = "acceptors"
+	clfListenerSocketOpts   = "listener-socket-options"
+	clfAcceptRate           = "accept-rate"
+	clfAcceptBurst          = "accept-burst"
+	clfAcceptBanDuration    = "accept-ban-duration"
+	clfAcceptGlobalRate     = "accept-global-rate"
+	clfAcceptGlobalBurst    = "accept-global-burst"
+	clfMaxConnsPerIP        = "max-conns-per-ip"
+	clfAllow                = "allow"
+	clfDeny                 = "deny"
+	clfAllowFile            = "allow-file"
+	clfDenyFile             = "deny-file"
+	clfDenyFeed             = "deny-feed"
+	clfDenyFeedRefresh      = "deny-feed-refresh"
+	clfAbuseIPDBKey         = "abuseipdb-key"
+	clfIPv6ConnPrefix       = "ipv6-conn-prefix"
+	clfGeoIPDatabase        = "geoip-database"
+	clfProtectionProfiles   = "protection-profiles"
+	clfMalformedThreshold   = "malformed-ban-threshold"
+	clfMalformedBanDecay    = "malformed-ban-decay"
+	clfMalformedBanDuration = "malformed-ban-duration"
+	clfHandshakeTimeout     = "handshake-timeout"
+	clfLoginTimeout         = "login-timeout"
+	clfMaxPendingConns      = "max-pending-conns"
+	clfMaxBedrockSessions   = "max-bedrock-sessions"
+	clfStrictProtocol       = "strict-protocol"
+	clfBanFile              = "ban-file"
+	clfBanFormat            = "ban-format"
+	clfBanIPSet             = "ban-ipset"
+	clfBanCommand           = "ban-command"
+	clfUnbanCommand         = "unban-command"
+	clfCrowdSecURL          = "crowdsec-url"
+	clfCrowdSecAPIKey       = "crowdsec-api-key"
+	clfCrowdSecMachineID    = "crowdsec-machine-id"
+	clfCrowdSecPassword     = "crowdsec-password"
+	clfCrowdSecInterval     = "crowdsec-interval"
+	clfCrowdSecBanDuration  = "crowdsec-ban-duration"
 	clfPrometheusEnabled    = "enable-prometheus"
 	clfPrometheusBind       = "prometheus-bind"
 )
 
 var (
 	configPath           = "./configs"
+	configDebounce       = provider.DefaultFileDebounce
+	configInclude        = ""
+	configExclude        = strings.Join(provider.DefaultFileExclude, ",")
+	configURL            = ""
+	configURLInterval    = 30 * time.Second
+	etcdEndpoint         = ""
+	etcdPrefix           = "/infrared/"
+	consulAddress        = ""
+	consulPrefix         = "infrared/"
+	consulToken          = ""
+	kubernetesEnabled    = false
+	kubernetesNamespace  = ""
+	kubernetesSelector   = "app.kubernetes.io/part-of=infrared"
+	kubernetesResource   = string(provider.KubernetesConfigMaps)
+	dockerDiscovery      = false
+	redisAddress         = ""
+	redisPassword        = ""
+	redisDB              = 0
+	redisKey             = "infrared:proxies"
+	redisChannel         = "infrared:reload"
+	s3Endpoint           = ""
+	s3Region             = "us-east-1"
+	s3Bucket             = ""
+	s3Prefix             = ""
+	s3AccessKey          = ""
+	s3SecretKey          = ""
+	s3Interval           = time.Minute
+	gitRepository        = ""
+	gitBranch            = ""
+	gitDirectory         = ""
+	gitWorkDir           = "./git-configs"
+	gitInterval          = time.Minute
+	sqlDriver            = "postgres"
+	sqlDSN               = ""
+	sqlTable             = "proxies"
+	sqlInterval          = time.Minute
+	sqlNotifyChannel     = ""
+	zooKeeperServers     = ""
+	zooKeeperPath        = "/infrared"
+	grpcAddress          = ""
+	grpcNodeID           = ""
+	grpcTLS              = false
+	configPipe           = ""
+	natsURL              = ""
+	natsBucket           = "infrared"
+	dnsDomains           = ""
+	dnsResolver          = ""
+	providerPriorities   = ""
+	providerNamespaces   = ""
+	retryAttempts        = provider.DefaultRetryPolicy.Attempts
+	retryBackoff         = provider.DefaultRetryPolicy.InitialBackoff
+	retryMaxBackoff      = provider.DefaultRetryPolicy.MaxBackoff
+	retryJitter          = provider.DefaultRetryPolicy.Jitter
+	ageKey               = ""
+	ageKeyFile           = ""
+	vaultAddress         = ""
+	vaultToken           = ""
+	vaultRefreshInterval = 5 * time.Minute
 	receiveProxyProtocol = false
+	proxyProtoListeners  = ""
+	proxyProtoTrusted    = ""
+	listeners            = ""
+	socketOptions        = ""
+	tlsListeners         = ""
+	tlsCerts             = ""
+	tlsKeys              = ""
+	reusePort            = false
+	acceptors            = 0
+	listenerSocketOpts   = ""
+	acceptRate           = 0.0
+	acceptBurst          = 0
+	acceptBanDuration    = time.Duration(0)
+	acceptGlobalRate     = 0.0
+	acceptGlobalBurst    = 0
+	maxConnsPerIP        = 0
+	allow                = ""
+	deny                 = ""
+	allowFile            = ""
+	denyFile             = ""
+	denyFeed             = ""
+	denyFeedRefresh      = time.Hour
+	abuseIPDBKey         = ""
+	ipv6ConnPrefix       = infrared.DefaultIPv6ConnPrefix
+	geoIPDatabase        = ""
+	protectionProfiles   = ""
+	malformedThreshold   = 0
+	malformedBanDecay    = time.Minute
+	malformedBanDuration = 15 * time.Minute
+	handshakeTimeout     = 5 * time.Second
+	loginTimeout         = 10 * time.Second
+	maxPendingConns      = 0
+	maxBedrockSessions   = infrared.DefaultMaxBedrockSessions
+	strictProtocol       = false
+	banFile              = ""
+	banFormat            = infrared.PlainBanFormat
+	banIPSet             = infrared.DefaultIPSet
+	banCommand           = ""
+	unbanCommand         = ""
+	crowdSecURL          = ""
+	crowdSecAPIKey       = ""
+	crowdSecMachineID    = ""
+	crowdSecPassword     = ""
+	crowdSecInterval     = 10 * time.Second
+	crowdSecBanDuration  = infrared.DefaultCrowdSecBanDuration
 	prometheusEnabled    = false
 	prometheusBind       = ":9100"
 	apiEnabled           = false
 	apiBind              = "127.0.0.1:8080"
 )
 
+// command is the subcommand that Infrared was started with, if any
+var command string
+
 func envBool(name string, value bool) bool {
 	envString := os.Getenv(name)
 	if envString == "" {
@@ -60,9 +376,154 @@ func envString(name string, value string) string {
 	return envString
 }
 
+func envInt(name string, value int) int {
+	envString := os.Getenv(name)
+	if envString == "" {
+		return value
+	}
+
+	envInt, err := strconv.Atoi(envString)
+	if err != nil {
+		return value
+	}
+
+	return envInt
+}
+
+func envFloat(name string, value float64) float64 {
+	envString := os.Getenv(name)
+	if envString == "" {
+		return value
+	}
+
+	envFloat, err := strconv.ParseFloat(envString, 64)
+	if err != nil {
+		return value
+	}
+
+	return envFloat
+}
+
+func envDuration(name string, value time.Duration) time.Duration {
+	envString := os.Getenv(name)
+	if envString == "" {
+		return value
+	}
+
+	envDuration, err := time.ParseDuration(envString)
+	if err != nil {
+		return value
+	}
+
+	return envDuration
+}
+
 func initEnv() {
 	configPath = envString(envConfigPath, configPath)
+	configDebounce = envDuration(envConfigDebounce, configDebounce)
+	configInclude = envString(envConfigInclude, configInclude)
+	configExclude = envString(envConfigExclude, configExclude)
+	configURL = envString(envConfigURL, configURL)
+	configURLInterval = envDuration(envConfigURLInterval, configURLInterval)
+	etcdEndpoint = envString(envEtcdEndpoint, etcdEndpoint)
+	etcdPrefix = envString(envEtcdPrefix, etcdPrefix)
+	consulAddress = envString(envConsulAddress, consulAddress)
+	consulPrefix = envString(envConsulPrefix, consulPrefix)
+	consulToken = envString(envConsulToken, consulToken)
+	kubernetesEnabled = envBool(envKubernetesEnabled, kubernetesEnabled)
+	kubernetesNamespace = envString(envKubernetesNamespace, kubernetesNamespace)
+	kubernetesSelector = envString(envKubernetesSelector, kubernetesSelector)
+	kubernetesResource = envString(envKubernetesResource, kubernetesResource)
+	dockerDiscovery = envBool(envDockerDiscovery, dockerDiscovery)
+	redisAddress = envString(envRedisAddress, redisAddress)
+	redisPassword = envString(envRedisPassword, redisPassword)
+	redisDB = envInt(envRedisDB, redisDB)
+	redisKey = envString(envRedisKey, redisKey)
+	redisChannel = envString(envRedisChannel, redisChannel)
+	s3Endpoint = envString(envS3Endpoint, s3Endpoint)
+	s3Region = envString(envS3Region, s3Region)
+	s3Bucket = envString(envS3Bucket, s3Bucket)
+	s3Prefix = envString(envS3Prefix, s3Prefix)
+	s3AccessKey = envString(envS3AccessKey, s3AccessKey)
+	s3SecretKey = envString(envS3SecretKey, s3SecretKey)
+	s3Interval = envDuration(envS3Interval, s3Interval)
+	gitRepository = envString(envGitRepository, gitRepository)
+	gitBranch = envString(envGitBranch, gitBranch)
+	gitDirectory = envString(envGitDirectory, gitDirectory)
+	gitWorkDir = envString(envGitWorkDir, gitWorkDir)
+	gitInterval = envDuration(envGitInterval, gitInterval)
+	sqlDriver = envString(envSQLDriver, sqlDriver)
+	sqlDSN = envString(envSQLDSN, sqlDSN)
+	sqlTable = envString(envSQLTable, sqlTable)
+	sqlInterval = envDuration(envSQLInterval, sqlInterval)
+	sqlNotifyChannel = envString(envSQLNotifyChannel, sqlNotifyChannel)
+	zooKeeperServers = envString(envZooKeeperServers, zooKeeperServers)
+	zooKeeperPath = envString(envZooKeeperPath, zooKeeperPath)
+	grpcAddress = envString(envGRPCAddress, grpcAddress)
+	grpcNodeID = envString(envGRPCNodeID, grpcNodeID)
+	grpcTLS = envBool(envGRPCTLS, grpcTLS)
+	configPipe = envString(envConfigPipe, configPipe)
+	natsURL = envString(envNATSURL, natsURL)
+	natsBucket = envString(envNATSBucket, natsBucket)
+	dnsDomains = envString(envDNSDomains, dnsDomains)
+	dnsResolver = envString(envDNSResolver, dnsResolver)
+	providerPriorities = envString(envProviderPriorities, providerPriorities)
+	providerNamespaces = envString(envProviderNamespaces, providerNamespaces)
+	retryAttempts = envInt(envRetryAttempts, retryAttempts)
+	retryBackoff = envDuration(envRetryBackoff, retryBackoff)
+	retryMaxBackoff = envDuration(envRetryMaxBackoff, retryMaxBackoff)
+	retryJitter = envFloat(envRetryJitter, retryJitter)
+	ageKey = envString(envAgeKey, ageKey)
+	ageKeyFile = envString(envAgeKeyFile, ageKeyFile)
+	vaultAddress = envString(envVaultAddress, vaultAddress)
+	vaultToken = envString(envVaultToken, vaultToken)
+	vaultRefreshInterval = envDuration(envVaultRefreshInterval, vaultRefreshInterval)
 	receiveProxyProtocol = envBool(envReceiveProxyProtocol, receiveProxyProtocol)
+	proxyProtoListeners = envString(envProxyProtoListeners, proxyProtoListeners)
+	proxyProtoTrusted = envString(envProxyProtoTrusted, proxyProtoTrusted)
+	listeners = envString(envListeners, listeners)
+	socketOptions = envString(envSocketOptions, socketOptions)
+	tlsListeners = envString(envTLSListeners, tlsListeners)
+	tlsCerts = envString(envTLSCerts, tlsCerts)
+	tlsKeys = envString(envTLSKeys, tlsKeys)
+	reusePort = envBool(envReusePort, reusePort)
+	acceptors = envInt(envAcceptors, acceptors)
+	listenerSocketOpts = envString(envListenerSocketOpts, listenerSocketOpts)
+	acceptRate = envFloat(envAcceptRate, acceptRate)
+	acceptBurst = envInt(envAcceptBurst, acceptBurst)
+	acceptBanDuration = envDuration(envAcceptBanDuration, acceptBanDuration)
+	acceptGlobalRate = envFloat(envAcceptGlobalRate, acceptGlobalRate)
+	acceptGlobalBurst = envInt(envAcceptGlobalBurst, acceptGlobalBurst)
+	maxConnsPerIP = envInt(envMaxConnsPerIP, maxConnsPerIP)
+	allow = envString(envAllow, allow)
+	deny = envString(envDeny, deny)
+	allowFile = envString(envAllowFile, allowFile)
+	denyFile = envString(envDenyFile, denyFile)
+	denyFeed = envString(envDenyFeed, denyFeed)
+	denyFeedRefresh = envDuration(envDenyFeedRefresh, denyFeedRefresh)
+	abuseIPDBKey = envString(envAbuseIPDBKey, abuseIPDBKey)
+	ipv6ConnPrefix = envInt(envIPv6ConnPrefix, ipv6ConnPrefix)
+	geoIPDatabase = envString(envGeoIPDatabase, geoIPDatabase)
+	protectionProfiles = envString(envProtectionProfiles, protectionProfiles)
+	malformedThreshold = envInt(envMalformedThreshold, malformedThreshold)
+	malformedBanDecay = envDuration(envMalformedBanDecay, malformedBanDecay)
+	malformedBanDuration = envDuration(envMalformedBanDuration, malformedBanDuration)
+	handshakeTimeout = envDuration(envHandshakeTimeout, handshakeTimeout)
+	loginTimeout = envDuration(envLoginTimeout, loginTimeout)
+	maxPendingConns = envInt(envMaxPendingConns, maxPendingConns)
+	maxBedrockSessions = envInt(envMaxBedrockSessions, maxBedrockSessions)
+	strictProtocol = envBool(envStrictProtocol, strictProtocol)
+	banFile = envString(envBanFile, banFile)
+	banFormat = envString(envBanFormat, banFormat)
+	banIPSet = envString(envBanIPSet, banIPSet)
+	banCommand = envString(envBanCommand, banCommand)
+	unbanCommand = envString(envUnbanCommand, unbanCommand)
+	crowdSecURL = envString(envCrowdSecURL, crowdSecURL)
+	crowdSecAPIKey = envString(envCrowdSecAPIKey, crowdSecAPIKey)
+	crowdSecMachineID = envString(envCrowdSecMachineID, crowdSecMachineID)
+	crowdSecPassword = envString(envCrowdSecPassword, crowdSecPassword)
+	crowdSecInterval = envDuration(envCrowdSecInterval, crowdSecInterval)
+	crowdSecBanDuration = envDuration(envCrowdSecBanDuration, crowdSecBanDuration)
 	apiEnabled = envBool(envApiEnabled, apiEnabled)
 	apiBind = envString(envApiBind, apiBind)
 	prometheusEnabled = envBool(envPrometheusEnabled, prometheusEnabled)
@@ -71,9 +532,118 @@ func initEnv() {
 
 func initFlags() {
 	flag.StringVar(&configPath, clfConfigPath, configPath, "path of all proxy configs")
+	flag.DurationVar(&configDebounce, clfConfigDebounce, configDebounce, "time without changes in the config path after which the configs are reloaded")
+	flag.StringVar(&configInclude, clfConfigInclude, configInclude, "comma separated glob patterns of the files in the config path that are loaded")
+	flag.StringVar(&configExclude, clfConfigExclude, configExclude, "comma separated glob patterns of the files in the config path that are skipped")
+	flag.StringVar(&configURL, clfConfigURL, configURL, "URL to poll additional proxy configs from")
+	flag.DurationVar(&configURLInterval, clfConfigURLInterval, configURLInterval, "interval for polling the config URL")
+	flag.StringVar(&etcdEndpoint, clfEtcdEndpoint, etcdEndpoint, "etcd endpoint to load additional proxy configs from")
+	flag.StringVar(&etcdPrefix, clfEtcdPrefix, etcdPrefix, "key prefix of all proxy configs in etcd")
+	flag.StringVar(&consulAddress, clfConsulAddress, consulAddress, "Consul agent address to load additional proxy configs from")
+	flag.StringVar(&consulPrefix, clfConsulPrefix, consulPrefix, "key prefix of all proxy configs in the Consul KV store")
+	flag.BoolVar(&kubernetesEnabled, clfKubernetesEnabled, kubernetesEnabled, "should load additional proxy configs from the Kubernetes cluster it runs in")
+	flag.StringVar(&kubernetesNamespace, clfKubernetesNamespace, kubernetesNamespace, "Kubernetes namespace of all proxy configs")
+	flag.StringVar(&kubernetesSelector, clfKubernetesSelector, kubernetesSelector, "label selector of all proxy configs in Kubernetes")
+	flag.StringVar(&kubernetesResource, clfKubernetesResource, kubernetesResource, "Kubernetes resource to read proxy configs from; configmaps or proxyconfigs")
+	flag.BoolVar(&dockerDiscovery, clfDockerDiscovery, dockerDiscovery, "should discover additional proxy configs from Docker container labels")
+	flag.StringVar(&redisAddress, clfRedisAddress, redisAddress, "Redis address to load additional proxy configs from")
+	flag.IntVar(&redisDB, clfRedisDB, redisDB, "Redis database of the proxy configs")
+	flag.StringVar(&redisKey, clfRedisKey, redisKey, "Redis hash that contains all proxy configs")
+	flag.StringVar(&redisChannel, clfRedisChannel, redisChannel, "Redis channel that triggers a reload of the proxy configs")
+	flag.StringVar(&s3Endpoint, clfS3Endpoint, s3Endpoint, "S3 compatible endpoint to load additional proxy configs from")
+	flag.StringVar(&s3Region, clfS3Region, s3Region, "region of the S3 bucket")
+	flag.StringVar(&s3Bucket, clfS3Bucket, s3Bucket, "S3 bucket that contains the proxy configs")
+	flag.StringVar(&s3Prefix, clfS3Prefix, s3Prefix, "key prefix of all proxy configs in the S3 bucket")
+	flag.DurationVar(&s3Interval, clfS3Interval, s3Interval, "interval for polling the S3 bucket")
+	flag.StringVar(&gitRepository, clfGitRepository, gitRepository, "git repository to load additional proxy configs from")
+	flag.StringVar(&gitBranch, clfGitBranch, gitBranch, "branch of the git repository")
+	flag.StringVar(&gitDirectory, clfGitDirectory, gitDirectory, "directory inside of the git repository that contains the proxy configs")
+	flag.StringVar(&gitWorkDir, clfGitWorkDir, gitWorkDir, "local directory the git repository is cloned into")
+	flag.DurationVar(&gitInterval, clfGitInterval, gitInterval, "interval for pulling the git repository")
+	flag.StringVar(&sqlDriver, clfSQLDriver, sqlDriver, "SQL driver of the proxy config database; postgres or mysql")
+	flag.StringVar(&sqlTable, clfSQLTable, sqlTable, "SQL table that contains the proxy configs")
+	flag.DurationVar(&sqlInterval, clfSQLInterval, sqlInterval, "interval for polling the SQL table")
+	flag.StringVar(&sqlNotifyChannel, clfSQLNotifyChannel, sqlNotifyChannel, "Postgres channel that triggers a reload of the proxy configs")
+	flag.StringVar(&zooKeeperServers, clfZooKeeperServers, zooKeeperServers, "comma separated ZooKeeper servers to load additional proxy configs from")
+	flag.StringVar(&zooKeeperPath, clfZooKeeperPath, zooKeeperPath, "znode whose children are the proxy configs")
+	flag.StringVar(&grpcAddress, clfGRPCAddress, grpcAddress, "address of a ConfigSource gRPC service to stream additional proxy configs from")
+	flag.StringVar(&grpcNodeID, clfGRPCNodeID, grpcNodeID, "node ID that identifies this instance at the ConfigSource")
+	flag.BoolVar(&grpcTLS, clfGRPCTLS, grpcTLS, "should connect to the ConfigSource with TLS")
+	flag.StringVar(&configPipe, clfConfigPipe, configPipe, "named pipe or - for stdin to read config snapshots from")
+	flag.StringVar(&natsURL, clfNATSURL, natsURL, "NATS server URL to load additional proxy configs from")
+	flag.StringVar(&natsBucket, clfNATSBucket, natsBucket, "JetStream key-value bucket that contains the proxy configs")
+	flag.StringVar(&dnsDomains, clfDNSDomains, dnsDomains, "comma separated domains whose TXT records define additional proxy configs")
+	flag.StringVar(&dnsResolver, clfDNSResolver, dnsResolver, "DNS server to resolve the TXT records and SRV backends with")
+	flag.StringVar(&providerPriorities, clfProviderPriorities, providerPriorities, "comma separated priorities of the providers, like file=10,http=5")
+	flag.StringVar(&providerNamespaces, clfProviderNamespaces, providerNamespaces, "comma separated providers whose configs are mounted under their own namespace, like http,redis")
+	flag.IntVar(&retryAttempts, clfRetryAttempts, retryAttempts, "maximum attempts to load the configs of a provider; 0 retries forever")
+	flag.DurationVar(&retryBackoff, clfRetryBackoff, retryBackoff, "backoff after the first failed attempt to load the configs of a provider")
+	flag.DurationVar(&retryMaxBackoff, clfRetryMaxBackoff, retryMaxBackoff, "maximum backoff between attempts to load the configs of a provider")
+	flag.Float64Var(&retryJitter, clfRetryJitter, retryJitter, "fraction of the backoff that is randomized")
+	flag.StringVar(&ageKeyFile, clfAgeKeyFile, ageKeyFile, "age key file to decrypt encrypted config files with")
+	flag.StringVar(&vaultAddress, clfVaultAddress, vaultAddress, "Vault address to resolve secret references in proxy configs from")
+	flag.DurationVar(&vaultRefreshInterval, clfVaultRefreshInterval, vaultRefreshInterval, "interval for reading Vault secrets without a lease again")
 	flag.BoolVar(&receiveProxyProtocol, clfReceiveProxyProtocol, receiveProxyProtocol, "should accept proxy protocol")
+	flag.StringVar(&proxyProtoListeners, clfProxyProtoListeners, proxyProtoListeners, "comma separated listener names or addresses that require a proxy protocol header")
+	flag.StringVar(&proxyProtoTrusted, clfProxyProtoTrusted, proxyProtoTrusted, "comma separated IPs and CIDR ranges that may send proxy protocol headers; empty trusts all")
+	flag.StringVar(&listeners, clfListeners, listeners, "comma separated named listeners like public=:25565 that proxies can listen to by name")
+	flag.BoolVar(&reusePort, clfReusePort, reusePort, "opens several sockets with SO_REUSEPORT for every listen address on Linux that accept connections in parallel")
+	flag.IntVar(&acceptors, clfAcceptors, acceptors, "number of sockets of every listen address with reuse port; 0 uses GOMAXPROCS")
+	flag.StringVar(&tlsListeners, clfTLSListeners, tlsListeners, "comma separated listener names or addresses that terminate TLS and route by SNI")
+	flag.StringVar(&tlsCerts, clfTLSCerts, tlsCerts, "comma separated PEM certificate files of the TLS listeners")
+	flag.StringVar(&tlsKeys, clfTLSKeys, tlsKeys, "comma separated PEM key files of the certificates in the same order")
+	flag.StringVar(&socketOptions, clfSocketOptions, socketOptions, "space separated TCP options of all listeners like nodelay=true keepalive=30s read-buffer=65536 write-buffer=65536 linger=0")
+	flag.StringVar(&listenerSocketOpts, clfListenerSocketOpts, listenerSocketOpts, "comma separated TCP options of listeners like public=keepalive=1m linger=0 that override the socket options")
+	flag.Float64Var(&acceptRate, clfAcceptRate, acceptRate, "connections per second that every IP can open; 0 disables the limit")
+	flag.IntVar(&acceptBurst, clfAcceptBurst, acceptBurst, "connections that an IP can open at once before the accept rate applies")
+	flag.DurationVar(&acceptBanDuration, clfAcceptBanDuration, acceptBanDuration, "time that an IP over the accept rate is dropped for")
+	flag.Float64Var(&acceptGlobalRate, clfAcceptGlobalRate, acceptGlobalRate, "connections per second that all IPs together can open; 0 disables the limit")
+	flag.IntVar(&acceptGlobalBurst, clfAcceptGlobalBurst, acceptGlobalBurst, "connections that all IPs together can open at once before the global accept rate applies")
+	flag.IntVar(&maxConnsPerIP, clfMaxConnsPerIP, maxConnsPerIP, "maximum simultaneous connections of an IP; 0 disables the limit")
+	flag.StringVar(&allow, clfAllow, allow, "comma separated IPs and CIDR ranges that are the only ones allowed on all listeners")
+	flag.StringVar(&deny, clfDeny, deny, "comma separated IPs and CIDR ranges that are denied on all listeners")
+	flag.StringVar(&allowFile, clfAllowFile, allowFile, "comma separated files with allowed IPs and CIDR ranges, one per line")
+	flag.StringVar(&denyFile, clfDenyFile, denyFile, "comma separated files with denied IPs and CIDR ranges, one per line")
+	flag.StringVar(&denyFeed, clfDenyFeed, denyFeed, "comma separated URLs of feeds with denied IPs and CIDR ranges, one per line")
+	flag.DurationVar(&denyFeedRefresh, clfDenyFeedRefresh, denyFeedRefresh, "interval at which the deny feeds are downloaded again")
+	flag.StringVar(&abuseIPDBKey, clfAbuseIPDBKey, abuseIPDBKey, "API key of AbuseIPDB whose blacklist is denied; empty disables the blacklist")
+	flag.IntVar(&ipv6ConnPrefix, clfIPv6ConnPrefix, ipv6ConnPrefix, "prefix length of the IPv6 networks whose connections are counted together")
+	flag.StringVar(&geoIPDatabase, clfGeoIPDatabase, geoIPDatabase, "path of the MaxMind GeoIP database, like GeoLite2-Country.mmdb")
+	flag.StringVar(&protectionProfiles, clfProtectionProfiles, protectionProfiles, "path of the config file with the protection profiles that proxies can use")
+	flag.IntVar(&malformedThreshold, clfMalformedThreshold, malformedThreshold, "malformed handshakes after which an IP is banned; 0 disables the bans")
+	flag.DurationVar(&malformedBanDecay, clfMalformedBanDecay, malformedBanDecay, "time after which one malformed handshake of an IP is forgiven")
+	flag.DurationVar(&malformedBanDuration, clfMalformedBanDuration, malformedBanDuration, "time that an IP is banned for after malformed handshakes")
+	flag.DurationVar(&handshakeTimeout, clfHandshakeTimeout, handshakeTimeout, "time that a connection has to send its handshake; 0 disables the timeout")
+	flag.DurationVar(&loginTimeout, clfLoginTimeout, loginTimeout, "time that a connection has to finish its status request or login start after its handshake; 0 disables the timeout")
+	flag.IntVar(&maxPendingConns, clfMaxPendingConns, maxPendingConns, "maximum connections that didn't reach their backend yet; 0 disables the limit")
+	flag.IntVar(&maxBedrockSessions, clfMaxBedrockSessions, maxBedrockSessions, "maximum sessions of every Bedrock listener")
+	flag.BoolVar(&strictProtocol, clfStrictProtocol, strictProtocol, "should reject handshakes and login starts that are out of spec")
+	flag.StringVar(&banFile, clfBanFile, banFile, "file that the runtime bans are exported to")
+	flag.StringVar(&banFormat, clfBanFormat, banFormat, "format of the ban file: plain, ipset or json")
+	flag.StringVar(&banIPSet, clfBanIPSet, banIPSet, "name of the ipset that the ipset format adds bans to")
+	flag.StringVar(&banCommand, clfBanCommand, banCommand, "command that runs for every ban, like \"ipset add infrared {{ip}}\"")
+	flag.StringVar(&unbanCommand, clfUnbanCommand, unbanCommand, "command that runs for every lifted ban")
+	flag.StringVar(&crowdSecURL, clfCrowdSecURL, crowdSecURL, "URL of the CrowdSec local API whose ban decisions are applied; empty disables CrowdSec")
+	flag.StringVar(&crowdSecAPIKey, clfCrowdSecAPIKey, crowdSecAPIKey, "API key of the CrowdSec bouncer")
+	flag.StringVar(&crowdSecMachineID, clfCrowdSecMachineID, crowdSecMachineID, "machine ID of the CrowdSec watcher that pushes alerts; empty pushes no alerts")
+	flag.StringVar(&crowdSecPassword, clfCrowdSecPassword, crowdSecPassword, "password of the CrowdSec watcher")
+	flag.DurationVar(&crowdSecInterval, clfCrowdSecInterval, crowdSecInterval, "interval at which CrowdSec decisions are pulled and alerts are pushed")
+	flag.DurationVar(&crowdSecBanDuration, clfCrowdSecBanDuration, crowdSecBanDuration, "duration of the ban decisions of pushed alerts")
 	flag.BoolVar(&prometheusEnabled, clfPrometheusEnabled, prometheusEnabled, "should run prometheus client exposing metrics")
 	flag.StringVar(&prometheusBind, clfPrometheusBind, prometheusBind, "bind address and/or port for prometheus")
+
+	// Subcommands come before their flags
+	if len(os.Args) > 1 && os.Args[1] == cmdValidate {
+		command = cmdValidate
+		flag.CommandLine.Parse(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 2 && os.Args[1]+" "+os.Args[2] == cmdConfigDump {
+		command = cmdConfigDump
+		flag.StringVar(&dumpFormat, "format", dumpFormat, "output format of the configs; json or yaml")
+		flag.CommandLine.Parse(os.Args[3:])
+		return
+	}
 	flag.Parse()
 }
 
@@ -82,12 +652,306 @@ func init() {
 	initFlags()
 }
 
+// loadAgeIdentities loads the age identities that encrypted config files are decrypted with
+func loadAgeIdentities() error {
+	if ageKey != "" {
+		if err := provider.AddAgeIdentities(strings.NewReader(ageKey)); err != nil {
+			return fmt.Errorf("invalid age key; %s", err)
+		}
+	}
+
+	if ageKeyFile != "" {
+		file, err := os.Open(ageKeyFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err := provider.AddAgeIdentities(file); err != nil {
+			return fmt.Errorf("invalid age key file %s; %s", ageKeyFile, err)
+		}
+	}
+
+	return nil
+}
+
+func newProviders() ([]provider.Prioritized, error) {
+	if err := loadAgeIdentities(); err != nil {
+		return nil, err
+	}
+
+	priorities, err := parseProviderPriorities(providerPriorities)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := map[provider.Type]bool{}
+	for _, name := range strings.Split(providerNamespaces, ",") {
+		namespaces[provider.Type(strings.TrimSpace(name))] = true
+	}
+
+	retryPolicy := provider.RetryPolicy{
+		Attempts:       retryAttempts,
+		InitialBackoff: retryBackoff,
+		MaxBackoff:     retryMaxBackoff,
+		Jitter:         retryJitter,
+	}
+
+	var providers []provider.Prioritized
+	add := func(typ provider.Type, prov provider.Provider) {
+		if vaultAddress != "" {
+			vault := provider.NewVault(prov, vaultAddress, vaultToken)
+			vault.RefreshInterval = vaultRefreshInterval
+			prov = vault
+		}
+
+		// The environment can not change, so retrying it is pointless
+		if typ != provider.EnvType || vaultAddress != "" {
+			prov = provider.NewRetry(prov, retryPolicy)
+		}
+
+		prioritized := provider.Prioritized{
+			Provider: prov,
+			Priority: priorities[typ],
+		}
+		if namespaces[typ] {
+			prioritized.Namespace = string(typ)
+		}
+		providers = append(providers, prioritized)
+	}
+
+	// Without priorities, providers are merged in this order, so that
+	// configs from config files override configs from the environment
+	add(provider.EnvType, provider.NewEnv(envProxiesPrefix, &infrared.ProxyConfig{}))
+	fileProvider := provider.NewFile(configPath, false)
+	fileProvider.Debounce = configDebounce
+	fileProvider.Include = splitList(configInclude)
+	fileProvider.Exclude = splitList(configExclude)
+	add(provider.FileType, fileProvider)
+
+	if configURL != "" {
+		add(provider.HTTPType, provider.NewHTTP(configURL, configURLInterval))
+	}
+
+	if etcdEndpoint != "" {
+		add(provider.EtcdType, provider.NewEtcd(etcdEndpoint, etcdPrefix))
+	}
+
+	if consulAddress != "" {
+		add(provider.ConsulType, provider.NewConsul(consulAddress, consulPrefix, consulToken))
+	}
+
+	if kubernetesEnabled {
+		kubernetes, err := provider.NewKubernetesInCluster(
+			kubernetesNamespace,
+			kubernetesSelector,
+			provider.KubernetesResource(kubernetesResource),
+		)
+		if err != nil {
+			return nil, err
+		}
+		add(provider.KubernetesType, kubernetes)
+	}
+
+	if dockerDiscovery {
+		docker, err := provider.NewDocker()
+		if err != nil {
+			return nil, err
+		}
+		add(provider.DockerType, docker)
+	}
+
+	if redisAddress != "" {
+		add(provider.RedisType, provider.NewRedis(redisAddress, redisPassword, redisDB, redisKey, redisChannel))
+	}
+
+	if s3Endpoint != "" && s3Bucket != "" {
+		add(provider.S3Type, provider.NewS3(s3Endpoint, s3Region, s3Bucket, s3Prefix, s3AccessKey, s3SecretKey, s3Interval))
+	}
+
+	if gitRepository != "" {
+		add(provider.GitType, provider.NewGit(gitRepository, gitBranch, gitDirectory, gitWorkDir, gitInterval))
+	}
+
+	if sqlDSN != "" {
+		add(provider.SQLType, provider.NewSQL(sqlDriver, sqlDSN, sqlTable, sqlInterval, sqlNotifyChannel))
+	}
+
+	if zooKeeperServers != "" {
+		add(provider.ZooKeeperType, provider.NewZooKeeper(strings.Split(zooKeeperServers, ","), zooKeeperPath))
+	}
+
+	if grpcAddress != "" {
+		transport := grpc.WithInsecure()
+		if grpcTLS {
+			transport = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+		}
+		add(provider.GRPCType, provider.NewGRPC(grpcAddress, grpcNodeID, transport))
+	}
+
+	if configPipe != "" {
+		add(provider.PipeType, provider.NewPipe(configPipe))
+	}
+
+	if natsURL != "" {
+		add(provider.NATSType, provider.NewNATS(natsURL, natsBucket))
+	}
+
+	if dnsDomains != "" {
+		add(provider.DNSType, provider.NewDNS(strings.Split(dnsDomains, ","), dnsResolver))
+	}
+
+	return providers, nil
+}
+
+// parseProviderPriorities parses a comma separated list of provider priorities like "file=10,http=5"
+func parseProviderPriorities(s string) (map[provider.Type]int, error) {
+	priorities := map[provider.Type]int{}
+	if s == "" {
+		return priorities, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid provider priority %q", entry)
+		}
+
+		priority, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid provider priority %q; %s", entry, err)
+		}
+		priorities[provider.Type(strings.TrimSpace(parts[0]))] = priority
+	}
+
+	return priorities, nil
+}
+
+// parseListeners parses named listeners like "public=:25565,staging=10.0.0.1:25566"
+func parseListeners(list string) (map[string]string, error) {
+	named := map[string]string{}
+	for _, entry := range splitList(list) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid listener %q; want name=address", entry)
+		}
+		named[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return named, nil
+}
+
+// parseListenerSocketOptions parses the socket options of listeners like "public=keepalive=1m linger=0,:25566=nodelay=false"
+func parseListenerSocketOptions(list string) (map[string]infrared.SocketOptions, error) {
+	options := map[string]infrared.SocketOptions{}
+	for _, entry := range splitList(list) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid listener socket options %q; want listener=options", entry)
+		}
+
+		opts, err := infrared.ParseSocketOptions(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid listener socket options %q; %s", entry, err)
+		}
+		options[strings.TrimSpace(parts[0])] = opts
+	}
+	return options, nil
+}
+
+// loadTLSCertificates loads the certificates of the TLS flags, which pair the certificate and key files by their order
+func loadTLSCertificates() ([]tls.Certificate, error) {
+	certFiles, keyFiles := splitList(tlsCerts), splitList(tlsKeys)
+	if len(certFiles) != len(keyFiles) {
+		return nil, fmt.Errorf("%d certificates but %d keys", len(certFiles), len(keyFiles))
+	}
+
+	var certificates []tls.Certificate
+	for i, certFile := range certFiles {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFiles[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s; %s", certFile, err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// splitList splits a comma separated list and drops empty entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// loadProtectionProfiles loads the protection profiles of the flag, so the proxy configs can use them
+func loadProtectionProfiles() error {
+	if protectionProfiles == "" {
+		return nil
+	}
+
+	profiles, err := infrared.LoadProtectionProfiles(protectionProfiles)
+	if err != nil {
+		return err
+	}
+	infrared.SetProtectionProfiles(profiles)
+	return nil
+}
+
+// denyFeeds returns the feeds of the deny feed flag and the AbuseIPDB blacklist, if it has a key
+func denyFeeds() []infrared.FeedConfig {
+	var feeds []infrared.FeedConfig
+	refresh := int(denyFeedRefresh / time.Millisecond)
+	for _, url := range splitList(denyFeed) {
+		feeds = append(feeds, infrared.FeedConfig{
+			URL:     url,
+			Format:  infrared.PlainFeedFormat,
+			Refresh: refresh,
+		})
+	}
+
+	if abuseIPDBKey != "" {
+		feeds = append(feeds, infrared.FeedConfig{
+			URL:     infrared.DefaultAbuseIPDBURL,
+			Format:  infrared.AbuseIPDBFeedFormat,
+			APIKey:  abuseIPDBKey,
+			Refresh: refresh,
+		})
+	}
+	return feeds
+}
+
 func main() {
+	switch command {
+	case cmdValidate:
+		os.Exit(validate())
+	case cmdConfigDump:
+		os.Exit(dumpConfigs())
+	}
+
+	if err := loadProtectionProfiles(); err != nil {
+		log.Println("Failed loading protection profiles; error:", err)
+		return
+	}
+
 	log.Println("Loading proxy configs")
 
-	cfgs, err := infrared.LoadProxyConfigsFromPath(configPath, false)
+	infrared.SRVResolver = dnsResolver
+	outCfgs := make(chan *infrared.ProxyConfig)
+
+	providers, err := newProviders()
 	if err != nil {
-		log.Printf("Failed loading proxy configs from %s; error: %s", configPath, err)
+		log.Println("Failed creating providers; error:", err)
+		return
+	}
+
+	composite := provider.NewComposite(providers...)
+	cfgs, err := infrared.LoadProxyConfigsFromProvider(composite, outCfgs)
+	if err != nil {
+		log.Println("Failed loading proxy configs; error:", err)
 		return
 	}
 
@@ -98,15 +962,88 @@ func main() {
 		})
 	}
 
-	outCfgs := make(chan *infrared.ProxyConfig)
-	go func() {
-		if err := infrared.WatchProxyConfigFolder(configPath, outCfgs); err != nil {
-			log.Println("Failed watching config folder; error:", err)
-			log.Println("SYSTEM FAILURE: CONFIG WATCHER FAILED")
-		}
-	}()
+	namedListeners, err := parseListeners(listeners)
+	if err != nil {
+		log.Println("Failed parsing listeners; error:", err)
+		return
+	}
 
-	gateway := infrared.Gateway{ReceiveProxyProtocol: receiveProxyProtocol}
+	gatewaySocketOptions, err := infrared.ParseSocketOptions(socketOptions)
+	if err != nil {
+		log.Println("Failed parsing socket options; error:", err)
+		return
+	}
+
+	listenerSocketOptions, err := parseListenerSocketOptions(listenerSocketOpts)
+	if err != nil {
+		log.Println("Failed parsing listener socket options; error:", err)
+		return
+	}
+
+	tlsCertificates, err := loadTLSCertificates()
+	if err != nil {
+		log.Println("Failed loading TLS certificates; error:", err)
+		return
+	}
+
+	gateway := infrared.Gateway{
+		ReceiveProxyProtocol:   receiveProxyProtocol,
+		ProxyProtocolListeners: splitList(proxyProtoListeners),
+		ProxyProtocolTrusted:   splitList(proxyProtoTrusted),
+		Listeners:              namedListeners,
+		ReusePort:              reusePort,
+		Acceptors:              acceptors,
+		TLSListeners:           splitList(tlsListeners),
+		TLSCertificates:        tlsCertificates,
+		SocketOptions:          gatewaySocketOptions,
+		ListenerSocketOptions:  listenerSocketOptions,
+		AcceptLimits: infrared.ConnLimitsConfig{
+			PerIP: infrared.TokenBucketConfig{
+				Rate:        acceptRate,
+				Burst:       acceptBurst,
+				BanDuration: int(acceptBanDuration / time.Millisecond),
+			},
+			Global: infrared.TokenBucketConfig{
+				Rate:  acceptGlobalRate,
+				Burst: acceptGlobalBurst,
+			},
+		},
+		MaxConnsPerIP:  maxConnsPerIP,
+		IPv6ConnPrefix: ipv6ConnPrefix,
+		Access: infrared.AccessListConfig{
+			Allow:      splitList(allow),
+			Deny:       splitList(deny),
+			AllowFiles: splitList(allowFile),
+			DenyFiles:  splitList(denyFile),
+			DenyFeeds:  denyFeeds(),
+		},
+		GeoIPDatabase: geoIPDatabase,
+		MalformedBans: infrared.MalformedBanConfig{
+			Threshold:   malformedThreshold,
+			Decay:       malformedBanDecay,
+			BanDuration: malformedBanDuration,
+		},
+		HandshakeTimeout:   handshakeTimeout,
+		LoginTimeout:       loginTimeout,
+		MaxPendingConns:    maxPendingConns,
+		MaxBedrockSessions: maxBedrockSessions,
+		StrictProtocol:     strictProtocol,
+		BanExport: infrared.BanExportConfig{
+			File:         banFile,
+			Format:       banFormat,
+			IPSet:        banIPSet,
+			BanCommand:   banCommand,
+			UnbanCommand: unbanCommand,
+		},
+		CrowdSec: infrared.CrowdSecConfig{
+			URL:         crowdSecURL,
+			APIKey:      crowdSecAPIKey,
+			MachineID:   crowdSecMachineID,
+			Password:    crowdSecPassword,
+			Interval:    crowdSecInterval,
+			BanDuration: crowdSecBanDuration,
+		},
+	}
 	go func() {
 		for {
 			cfg, ok := <-outCfgs
@@ -122,7 +1059,7 @@ func main() {
 	}()
 
 	if apiEnabled {
-		go api.ListenAndServe(configPath, apiBind)
+		go api.ListenAndServe(configPath, apiBind, composite, &gateway)
 	}
 
 	if prometheusEnabled {
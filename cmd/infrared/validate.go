@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/haveachin/infrared"
+	"github.com/haveachin/infrared/provider"
+)
+
+// cmdValidate is the subcommand that validates the proxy configs without starting Infrared
+const cmdValidate = "validate"
+
+// validate loads the proxy configs of all providers and prints them as a table.
+// Nothing is bound, so it can run next to a running Infrared and in CI pipelines.
+// It returns the exit code, which is 1 if any config is invalid.
+func validate() int {
+	if err := loadProtectionProfiles(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed loading protection profiles; error:", err)
+		return 1
+	}
+
+	providers, err := newProviders()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed creating providers; error:", err)
+		return 1
+	}
+
+	composite := provider.NewComposite(providers...)
+	defer composite.Close()
+
+	data, err := composite.Provide(make(chan provider.Data))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed loading proxy configs; error:", err)
+		return 1
+	}
+
+	ids := make([]string, 0, len(data.Configs))
+	for id := range data.Configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	failed := false
+	owners := map[string]string{}
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tDOMAIN\tLISTEN\tPROXY TO")
+	for _, id := range ids {
+		var cfg infrared.ProxyConfig
+		if err := cfg.LoadFromBytes(data.Configs[id]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", id, err)
+			failed = true
+			continue
+		}
+
+		uid := strings.ToLower(cfg.DomainName) + "@" + cfg.ListenTo
+		if owner, ok := owners[uid]; ok {
+			fmt.Fprintf(os.Stderr, "%s: %s is already defined by %s\n", id, uid, owner)
+			failed = true
+			continue
+		}
+		owners[uid] = id
+
+		proxyTo := strings.Join(cfg.BackendAddrs(), ",")
+		if cfg.Docker.IsDocker() {
+			proxyTo = "docker:" + cfg.Docker.ContainerName
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", id, cfg.DomainName, cfg.ListenTo, proxyTo)
+	}
+
+	if err := table.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
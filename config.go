@@ -1,48 +1,86 @@
 package infrared
 
 import (
-	"bufio"
-	"encoding/base64"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/fs"
-	"io/ioutil"
 	"log"
 	"net"
-	"os"
-	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/haveachin/infrared/process"
 	"github.com/haveachin/infrared/protocol"
 	"github.com/haveachin/infrared/protocol/status"
+	"github.com/haveachin/infrared/provider"
 )
 
 // ProxyConfig is a data representation of a Proxy configuration
 type ProxyConfig struct {
 	sync.RWMutex
-	watcher *fsnotify.Watcher
 
 	removeCallback func()
 	changeCallback func()
 	dialer         *Dialer
+	domainRegexp   *regexp.Regexp
 	process        process.Process
 
-	DomainName        string               `json:"domainName"`
-	ListenTo          string               `json:"listenTo"`
-	ProxyTo           string               `json:"proxyTo"`
-	ProxyBind         string               `json:"proxyBind"`
-	SpoofForcedHost   string               `json:"spoofForcedHost"`
-	ProxyProtocol     bool                 `json:"proxyProtocol"`
-	RealIP            bool                 `json:"realIp"`
-	Timeout           int                  `json:"timeout"`
-	DisconnectMessage string               `json:"disconnectMessage"`
-	Docker            DockerConfig         `json:"docker"`
-	OnlineStatus      StatusConfig         `json:"onlineStatus"`
-	OfflineStatus     StatusConfig         `json:"offlineStatus"`
-	CallbackServer    CallbackServerConfig `json:"callbackServer"`
+	DomainName                string                `json:"domainName"`
+	ListenTo                  string                `json:"listenTo"`
+	Priority                  int                   `json:"priority"`
+	ProxyTo                   string                `json:"proxyTo"`
+	JavaAddress               string                `json:"javaAddress"`
+	Backends                  []string              `json:"backends"`
+	BackendWeights            map[string]int        `json:"backendWeights"`
+	LoadBalancer              string                `json:"loadBalancer"`
+	SessionAffinity           SessionAffinityConfig `json:"sessionAffinity"`
+	HealthCheck               HealthCheckConfig     `json:"healthCheck"`
+	FailoverTo                string                `json:"failoverTo"`
+	UsernameRoutes            []UsernameRoute       `json:"usernameRoutes"`
+	VersionRoutes             []VersionRoute        `json:"versionRoutes"`
+	UnsupportedVersionMessage string                `json:"unsupportedVersionMessage"`
+	Transfers                 TransferConfig        `json:"transfers"`
+	Drain                     DrainConfig           `json:"drain"`
+	Maintenance               bool                  `json:"maintenance"`
+	MaintenanceMessage        string                `json:"maintenanceMessage"`
+	MaintenanceStatus         StatusConfig          `json:"maintenanceStatus"`
+	Bedrock                   BedrockConfig         `json:"bedrock"`
+	LegacyStatus              LegacyStatusConfig    `json:"legacyStatus"`
+	TextFormat                string                `json:"textFormat"`
+	RateLimits                RateLimitsConfig      `json:"rateLimits"`
+	HandshakeLimits           ConnLimitsConfig      `json:"handshakeLimits"`
+	Access                    AccessListConfig      `json:"access"`
+	GeoIP                     GeoIPConfig           `json:"geoIP"`
+	RejoinVerification        RejoinConfig          `json:"rejoinVerification"`
+	UsernameFilter            UsernameFilterConfig  `json:"usernameFilter"`
+	Authentication            AuthConfig            `json:"authentication"`
+	ProtectionProfile         string                `json:"protectionProfile"`
+	Localizations             []Localization        `json:"localizations"`
+	ProxyBind                 string                `json:"proxyBind"`
+	UpstreamProxy             string                `json:"upstreamProxy"`
+	SpoofForcedHost           string                `json:"spoofForcedHost"`
+	SpoofForcedPort           int                   `json:"spoofForcedPort"`
+	ProxyProtocol             bool                  `json:"proxyProtocol"`
+	Transparent               bool                  `json:"transparent"`
+	BackendSocket             SocketOptions         `json:"backendSocket"`
+	HappyEyeballsDelay        int                   `json:"happyEyeballsDelay"`
+	IdleTimeout               IdleTimeoutConfig     `json:"idleTimeout"`
+	ProxyProtocolTLVs         ProxyTLVsConfig       `json:"proxyProtocolTlvs"`
+	RealIP                    bool                  `json:"realIp"`
+	Timeout                   int                   `json:"timeout"`
+	DisconnectMessage         string                `json:"disconnectMessage"`
+	DisconnectComponent       json.RawMessage       `json:"disconnectComponent"`
+	Docker                    DockerConfig          `json:"docker"`
+	OnlineStatus              StatusConfig          `json:"onlineStatus"`
+	OfflineStatus             StatusConfig          `json:"offlineStatus"`
+	StatusCacheTTL            int                   `json:"statusCacheTtl"`
+	StatusOverride            StatusOverrideConfig  `json:"statusOverride"`
+	PingMode                  string                `json:"pingMode"`
+	CallbackServer            CallbackServerConfig  `json:"callbackServer"`
 }
 
 func (cfg *ProxyConfig) Dialer() (*Dialer, error) {
@@ -50,17 +88,121 @@ func (cfg *ProxyConfig) Dialer() (*Dialer, error) {
 		return cfg.dialer, nil
 	}
 
+	upstreamProxy, err := parseUpstreamProxy(cfg.UpstreamProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyBind, err := bindIP(cfg.ProxyBind)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg.dialer = &Dialer{
 		Dialer: net.Dialer{
 			Timeout: time.Millisecond * time.Duration(cfg.Timeout),
 			LocalAddr: &net.TCPAddr{
-				IP: net.ParseIP(cfg.ProxyBind),
+				IP: proxyBind,
 			},
+			// Backends with A and AAAA records are dialed via the preferred family first and via the other one
+			// after the delay, and the first connection wins. A negative delay dials the addresses one after another.
+			FallbackDelay: time.Millisecond * time.Duration(cfg.HappyEyeballsDelay),
 		},
+		UpstreamProxy: upstreamProxy,
+		SocketOptions: cfg.BackendSocket,
 	}
 	return cfg.dialer, nil
 }
 
+// bindIP returns the IP of the proxyBind, which is an IP or the name of a network interface like eth1.
+// Interfaces bind to their first IPv4 address or, if they have none, to their first IPv6 address that isn't link-local.
+func bindIP(proxyBind string) (net.IP, error) {
+	if proxyBind == "" {
+		return nil, nil
+	}
+	if ip := net.ParseIP(proxyBind); ip != nil {
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(proxyBind)
+	if err != nil {
+		return nil, fmt.Errorf("%q is no IP or network interface", proxyBind)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ip6 net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			return ip4, nil
+		}
+		if ip6 == nil && !ipNet.IP.IsLinkLocalUnicast() {
+			ip6 = ipNet.IP
+		}
+	}
+	if ip6 == nil {
+		return nil, fmt.Errorf("network interface %s has no IP", proxyBind)
+	}
+	return ip6, nil
+}
+
+// BackendAddrs returns the Backends or the JavaAddress or ProxyTo address if there are no Backends
+func (cfg *ProxyConfig) BackendAddrs() []string {
+	if len(cfg.Backends) > 0 {
+		return cfg.Backends
+	}
+	if cfg.JavaAddress != "" {
+		return []string{cfg.JavaAddress}
+	}
+	if cfg.ProxyTo == "" {
+		return nil
+	}
+	return []string{cfg.ProxyTo}
+}
+
+// isDomainRegexp reports whether the domain is a regular expression instead of a domain name
+func isDomainRegexp(domain string) bool {
+	return strings.HasPrefix(domain, "^")
+}
+
+// DomainRegexp returns the compiled regular expression of the DomainName
+// or nil if the DomainName is no regular expression
+func (cfg *ProxyConfig) DomainRegexp() (*regexp.Regexp, error) {
+	if cfg.domainRegexp != nil || !isDomainRegexp(cfg.DomainName) {
+		return cfg.domainRegexp, nil
+	}
+
+	re, err := regexp.Compile(cfg.DomainName)
+	if err != nil {
+		return nil, err
+	}
+	cfg.domainRegexp = re
+	return re, nil
+}
+
+type SessionAffinityConfig struct {
+	Key     string `json:"key"`
+	Timeout int    `json:"timeout"`
+}
+
+type HealthCheckConfig struct {
+	Interval int `json:"interval"`
+	Timeout  int `json:"timeout"`
+}
+
+// TransferConfig controls clients that were transferred to the proxy by another server
+type TransferConfig struct {
+	Policy        string `json:"policy"`
+	ProxyTo       string `json:"proxyTo"`
+	RejectMessage string `json:"rejectMessage"`
+}
+
 type DockerConfig struct {
 	DNSServer     string `json:"dnsServer"`
 	ContainerName string `json:"containerName"`
@@ -91,13 +233,15 @@ type PlayerSample struct {
 type StatusConfig struct {
 	cachedPacket *protocol.Packet
 
-	VersionName    string         `json:"versionName"`
-	ProtocolNumber int            `json:"protocolNumber"`
-	MaxPlayers     int            `json:"maxPlayers"`
-	PlayersOnline  int            `json:"playersOnline"`
-	PlayerSamples  []PlayerSample `json:"playerSamples"`
-	IconPath       string         `json:"iconPath"`
-	MOTD           string         `json:"motd"`
+	VersionName    string          `json:"versionName"`
+	ProtocolNumber int             `json:"protocolNumber"`
+	MaxPlayers     int             `json:"maxPlayers"`
+	PlayersOnline  int             `json:"playersOnline"`
+	PlayerSamples  []PlayerSample  `json:"playerSamples"`
+	IconPath       string          `json:"iconPath"`
+	Icon           string          `json:"icon"`
+	MOTD           string          `json:"motd"`
+	MOTDs          []ScheduledMOTD `json:"motds"`
 }
 
 func (cfg StatusConfig) StatusResponsePacket() (protocol.Packet, error) {
@@ -105,6 +249,26 @@ func (cfg StatusConfig) StatusResponsePacket() (protocol.Packet, error) {
 		return *cfg.cachedPacket, nil
 	}
 
+	packet, err := cfg.responsePacket(status.DescriptionJSON{Text: cfg.MOTD})
+	if err != nil {
+		return protocol.Packet{}, err
+	}
+
+	cfg.cachedPacket = &packet
+	return packet, nil
+}
+
+// templatedResponsePacket returns the status response with the MOTD that is selected for the rotation count
+// and its placeholders replaced
+func (cfg StatusConfig) templatedResponsePacket(statusReq statusRequest, rotation uint32, textFormat string) (protocol.Packet, error) {
+	motd, err := renderMOTD(cfg.selectMOTD(time.Now(), rotation), statusReq)
+	if err != nil {
+		return protocol.Packet{}, err
+	}
+	return cfg.responsePacket(formatDescription(motd, textFormat))
+}
+
+func (cfg StatusConfig) responsePacket(description status.DescriptionJSON) (protocol.Packet, error) {
 	var samples []status.PlayerSampleJSON
 	for _, sample := range cfg.PlayerSamples {
 		samples = append(samples, status.PlayerSampleJSON{
@@ -123,56 +287,23 @@ func (cfg StatusConfig) StatusResponsePacket() (protocol.Packet, error) {
 			Online: cfg.PlayersOnline,
 			Sample: samples,
 		},
-		Description: status.DescriptionJSON{
-			Text: cfg.MOTD,
-		},
+		Description: description,
 	}
 
-	if cfg.IconPath != "" {
-		img64, err := loadImageAndEncodeToBase64String(cfg.IconPath)
-		if err != nil {
-			return protocol.Packet{}, err
-		}
-		responseJSON.Favicon = fmt.Sprintf("data:image/png;base64,%s", img64)
+	favicon, err := cfg.favicon()
+	if err != nil {
+		return protocol.Packet{}, err
 	}
+	responseJSON.Favicon = favicon
 
 	bb, err := json.Marshal(responseJSON)
 	if err != nil {
 		return protocol.Packet{}, err
 	}
 
-	packet := status.ClientBoundResponse{
+	return status.ClientBoundResponse{
 		JSONResponse: protocol.String(bb),
-	}.Marshal()
-
-	cfg.cachedPacket = &packet
-	return packet, nil
-}
-
-func loadImageAndEncodeToBase64String(path string) (string, error) {
-	if path == "" {
-		return "", nil
-	}
-
-	imgFile, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer imgFile.Close()
-
-	fileInfo, err := imgFile.Stat()
-	if err != nil {
-		return "", err
-	}
-
-	buffer := make([]byte, fileInfo.Size())
-	fileReader := bufio.NewReader(imgFile)
-	_, err = fileReader.Read(buffer)
-	if err != nil {
-		return "", nil
-	}
-
-	return base64.StdEncoding.EncodeToString(buffer), nil
+	}.Marshal(), nil
 }
 
 type CallbackServerConfig struct {
@@ -182,10 +313,25 @@ type CallbackServerConfig struct {
 
 func DefaultProxyConfig() ProxyConfig {
 	return ProxyConfig{
-		DomainName:        "localhost",
-		ListenTo:          ":25565",
-		Timeout:           1000,
-		DisconnectMessage: "Sorry {{username}}, but the server is offline.",
+		DomainName:         "localhost",
+		ListenTo:           ":25565",
+		Timeout:            1000,
+		HappyEyeballsDelay: 250,
+		LoadBalancer:       RoundRobinStrategy,
+		DisconnectMessage:  "Sorry {{username}}, but the server is offline.",
+		SessionAffinity: SessionAffinityConfig{
+			Timeout: 3600000,
+		},
+		HealthCheck: HealthCheckConfig{
+			Timeout: 1000,
+		},
+		Drain: DrainConfig{
+			Message: "The server is shutting down. Please try again later.",
+		},
+		Transfers: TransferConfig{
+			Policy:        AcceptTransfers,
+			RejectMessage: "Transfers to this server are not allowed.",
+		},
 		Docker: DockerConfig{
 			DNSServer: "127.0.0.11",
 			Timeout:   300000,
@@ -196,281 +342,344 @@ func DefaultProxyConfig() ProxyConfig {
 			MaxPlayers:     20,
 			MOTD:           "Powered by Infrared",
 		},
+		RateLimits: RateLimitsConfig{
+			Status: RateLimitConfig{
+				Window: 1000,
+			},
+			Login: RateLimitConfig{
+				Window: 1000,
+			},
+			Message: "You are connecting too fast. Please try again later.",
+		},
+		UsernameFilter: UsernameFilterConfig{
+			Message: "Your username is not allowed on this server.",
+		},
+		Authentication: AuthConfig{
+			Timeout: 5000,
+			Message: "Failed to verify username!",
+		},
+		RejoinVerification: RejoinConfig{
+			AttackDuration: 300000,
+			Window:         60000,
+			VerifiedFor:    86400000,
+			Message:        "Please reconnect to verify that you are not a bot.",
+		},
+		LegacyStatus: LegacyStatusConfig{
+			VersionName:    "Infrared 1.6.4",
+			ProtocolNumber: 78,
+			MaxPlayers:     20,
+			MOTD:           "Powered by Infrared",
+		},
+		Bedrock: BedrockConfig{
+			ListenTo: BedrockScheme + ":19132",
+			Status: BedrockStatusConfig{
+				CacheTTL: 5000,
+			},
+			Limits: BedrockLimitsConfig{
+				MaxPacketSize: 1500,
+				DropDuration:  10000,
+			},
+		},
+		MaintenanceMessage: "The server is under maintenance. Please try again later.",
+		MaintenanceStatus: StatusConfig{
+			VersionName: "Maintenance",
+			MaxPlayers:  20,
+			MOTD:        "The server is under maintenance",
+		},
 	}
 }
 
-func ReadFilePaths(path string, recursive bool) ([]string, error) {
-	if recursive {
-		return readFilePathsRecursively(path)
+// onConfigUpdate resets all cached values that depend on the config
+// and notifies the gateway about the change
+func (cfg *ProxyConfig) onConfigUpdate() {
+	cfg.OnlineStatus.cachedPacket = nil
+	cfg.OfflineStatus.cachedPacket = nil
+	cfg.MaintenanceStatus.cachedPacket = nil
+	cfg.dialer = nil
+	cfg.domainRegexp = nil
+	cfg.process = nil
+	if cfg.changeCallback != nil {
+		cfg.changeCallback()
 	}
-
-	return readFilePaths(path)
-}
-
-func readFilePathsRecursively(path string) ([]string, error) {
-	var filePaths []string
-
-	err := filepath.WalkDir(path, func(path string, dir fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if dir.IsDir() {
-			return nil
-		}
-
-		// check the type of file that is behind symlinks link
-		if dir.Type()&os.ModeSymlink == os.ModeSymlink {
-			linkedToDir, err := isLinkedToDir(path)
-			if err != nil {
-				return err
-			}
-
-			if linkedToDir {
-				return nil
-			}
-		}
-
-		filePaths = append(filePaths, path)
-		return nil
-	})
-
-	return filePaths, err
 }
 
-func readFilePaths(path string) ([]string, error) {
-	var filePaths []string
-	files, err := ioutil.ReadDir(path)
+// LoadFromPath loads the ProxyConfig from a file
+func (cfg *ProxyConfig) LoadFromPath(path string) error {
+	bb, err := provider.ReadConfigFile(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
+	return cfg.LoadFromBytes(bb)
+}
+
+// mergeDefaults overwrites the defaults with the values of the loaded config.
+// Objects are merged field by field, so nested objects keep the defaults of the fields they don't set.
+func mergeDefaults(defaults, loaded map[string]interface{}) {
+	for k, v := range loaded {
+		loadedObj, ok := v.(map[string]interface{})
+		defaultObj, isObj := defaults[k].(map[string]interface{})
+		if ok && isObj {
+			mergeDefaults(defaultObj, loadedObj)
 			continue
 		}
+		defaults[k] = v
+	}
+}
 
-		fullPathFile := filepath.Join(path, file.Name())
+// LoadFromBytes loads the ProxyConfig from its JSON representation.
+// The config is validated against the ProxyConfigSchema first; see ValidateProxyConfig.
+// The ProxyConfig is only changed if the whole config is valid.
+func (cfg *ProxyConfig) LoadFromBytes(bb []byte) error {
+	if err := ValidateProxyConfig(bb); err != nil {
+		return err
+	}
 
-		// check the type of file that is behind symlinks link
-		if file.Mode()&os.ModeSymlink == os.ModeSymlink {
-			linkedToDir, err := isLinkedToDir(fullPathFile)
-			if err != nil {
-				return nil, err
-			}
+	var defaultCfg map[string]interface{}
+	defaultBB, err := json.Marshal(DefaultProxyConfig())
+	if err != nil {
+		return err
+	}
 
-			if linkedToDir {
-				continue
-			}
-		}
+	if err := json.Unmarshal(defaultBB, &defaultCfg); err != nil {
+		return err
+	}
 
-		filePaths = append(filePaths, fullPathFile)
+	var loadedCfg map[string]interface{}
+	if err := json.Unmarshal(bb, &loadedCfg); err != nil {
+		log.Println(string(bb))
+		return err
 	}
 
-	return filePaths, err
-}
+	mergeDefaults(defaultCfg, loadedCfg)
 
-func isLinkedToDir(path string) (bool, error) {
-	linkedFile, err := filepath.EvalSymlinks(path)
+	bb, err = json.Marshal(defaultCfg)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	linkedFileInfo, err := os.Lstat(linkedFile)
-	if err != nil {
-		return false, err
+	var loaded ProxyConfig
+	if err := json.Unmarshal(bb, &loaded); err != nil {
+		return err
+	}
+	if err := loaded.validate(); err != nil {
+		return err
 	}
 
-	return linkedFileInfo.IsDir(), nil
+	cfg.Lock()
+	defer cfg.Unlock()
+
+	// Unmarshal merges into existing maps, so keys that were removed would remain
+	cfg.BackendWeights = nil
+	if err := json.Unmarshal(bb, cfg); err != nil {
+		return err
+	}
+	cfg.domainRegexp = loaded.domainRegexp
+	return nil
 }
 
-func LoadProxyConfigsFromPath(path string, recursive bool) ([]*ProxyConfig, error) {
-	filePaths, err := ReadFilePaths(path, recursive)
-	if err != nil {
-		return nil, err
+// validate checks the values of a loaded config that the ProxyConfigSchema can't check
+func (cfg *ProxyConfig) validate() error {
+	if _, err := cfg.DomainRegexp(); err != nil {
+		return fmt.Errorf("invalid domainName; %s", err)
 	}
 
-	var cfgs []*ProxyConfig
+	if _, err := parseUpstreamProxy(cfg.UpstreamProxy); err != nil {
+		return fmt.Errorf("invalid upstreamProxy; %s", err)
+	}
+	if _, err := bindIP(cfg.ProxyBind); err != nil {
+		return fmt.Errorf("invalid proxyBind; %s", err)
+	}
+	if err := cfg.IdleTimeout.validate(); err != nil {
+		return fmt.Errorf("invalid idleTimeout; %s", err)
+	}
+	if err := cfg.BackendSocket.validate(); err != nil {
+		return fmt.Errorf("invalid backendSocket; %s", err)
+	}
+	if cfg.HappyEyeballsDelay == 0 {
+		// net.Dialer treats a FallbackDelay of 0 as its own default of 300ms
+		return errors.New("invalid happyEyeballsDelay; use a positive delay or a negative one to dial one after another")
+	}
+	if cfg.Transparent && cfg.UpstreamProxy != "" {
+		return errors.New("transparent backends can't be dialed through an upstreamProxy")
+	}
 
-	for _, filePath := range filePaths {
-		cfg, err := NewProxyConfigFromPath(filePath)
-		if err != nil {
-			return nil, err
+	for name, statusCfg := range map[string]StatusConfig{
+		"onlineStatus":      cfg.OnlineStatus,
+		"offlineStatus":     cfg.OfflineStatus,
+		"maintenanceStatus": cfg.MaintenanceStatus,
+	} {
+		if err := statusCfg.validateMOTDs(); err != nil {
+			return fmt.Errorf("invalid motd of %s; %s", name, err)
+		}
+		if err := statusCfg.validateIcon(); err != nil {
+			return fmt.Errorf("invalid %s; %s", name, err)
 		}
-		cfgs = append(cfgs, cfg)
 	}
 
-	return cfgs, nil
-}
-
-// NewProxyConfigFromPath loads a ProxyConfig from a file path and then starts watching
-// it for changes. On change the ProxyConfig will automatically LoadFromPath itself
-func NewProxyConfigFromPath(path string) (*ProxyConfig, error) {
-	log.Println("Loading", path)
-
-	var cfg ProxyConfig
-	if err := cfg.LoadFromPath(path); err != nil {
-		return nil, err
+	if err := cfg.Access.validate(); err != nil {
+		return fmt.Errorf("invalid access; %s", err)
 	}
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
+	if err := cfg.GeoIP.validate(); err != nil {
+		return fmt.Errorf("invalid geoIP; %s", err)
 	}
-	cfg.watcher = watcher
-
-	go func() {
-		defer watcher.Close()
-		log.Printf("Starting to watch %s", path)
-		cfg.watch(path, time.Millisecond*50)
-		log.Printf("Stopping to watch %s", path)
-	}()
 
-	if err := watcher.Add(path); err != nil {
-		return nil, err
+	if err := cfg.UsernameFilter.validate(); err != nil {
+		return fmt.Errorf("invalid pattern of usernameFilter; %s", err)
 	}
 
-	return &cfg, err
-}
+	if err := cfg.Authentication.validate(); err != nil {
+		return fmt.Errorf("invalid authentication; %s", err)
+	}
 
-func (cfg *ProxyConfig) watch(path string, interval time.Duration) {
-	// The interval protects the watcher from write event spams
-	// This is necessary due to how some text editors handle file safes
-	tick := time.Tick(interval)
-	var lastEvent *fsnotify.Event
+	if err := cfg.ProxyProtocolTLVs.validate(); err != nil {
+		return fmt.Errorf("invalid PROXY protocol TLVs; %s", err)
+	}
 
-	for {
-		select {
-		case <-tick:
-			if lastEvent == nil {
-				continue
-			}
-			cfg.onConfigWrite(*lastEvent)
-			lastEvent = nil
-		case event, ok := <-cfg.watcher.Events:
-			if !ok {
-				return
-			}
-			if event.Op&fsnotify.Remove == fsnotify.Remove {
-				cfg.removeCallback()
-				return
-			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				lastEvent = &event
-			}
-		case err, ok := <-cfg.watcher.Errors:
-			if !ok {
-				return
-			}
-			log.Printf("Failed watching %s; error %s", path, err)
+	for i, localization := range cfg.Localizations {
+		if err := localization.validate(); err != nil {
+			return fmt.Errorf("invalid localization %d; %s", i, err)
 		}
 	}
-}
 
-func (cfg *ProxyConfig) onConfigWrite(event fsnotify.Event) {
-	log.Println("Updating", event.Name)
-	if err := cfg.LoadFromPath(event.Name); err != nil {
-		log.Printf("Failed update on %s; error %s", event.Name, err)
-		return
+	if err := validateProtectionProfile(cfg.ProtectionProfile); err != nil {
+		return fmt.Errorf("invalid protectionProfile; %s", err)
 	}
-	cfg.OnlineStatus.cachedPacket = nil
-	cfg.OfflineStatus.cachedPacket = nil
-	cfg.dialer = nil
-	cfg.process = nil
-	cfg.changeCallback()
-}
 
-// LoadFromPath loads the ProxyConfig from a file
-func (cfg *ProxyConfig) LoadFromPath(path string) error {
-	cfg.Lock()
-	defer cfg.Unlock()
-
-	var defaultCfg map[string]interface{}
-	bb, err := json.Marshal(DefaultProxyConfig())
-	if err != nil {
-		return err
+	if err := cfg.RejoinVerification.validate(); err != nil {
+		return fmt.Errorf("invalid rejoinVerification; %s", err)
 	}
 
-	if err := json.Unmarshal(bb, &defaultCfg); err != nil {
-		return err
+	if err := cfg.Bedrock.Access.validate(); err != nil {
+		return fmt.Errorf("invalid bedrock access; %s", err)
 	}
 
-	bb, err = ioutil.ReadFile(path)
-	if err != nil {
-		return err
+	for i, route := range cfg.UsernameRoutes {
+		if _, err := regexp.Compile(route.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern of username route %d; %s", i, err)
+		}
 	}
+	return nil
+}
 
-	var loadedCfg map[string]interface{}
-	if err := json.Unmarshal(bb, &loadedCfg); err != nil {
-		log.Println(string(bb))
-		return err
-	}
+// EffectiveConfig is a config as Infrared uses it, after merging, interpolation
+// and defaults, together with the providers that supplied it
+type EffectiveConfig struct {
+	ID      string          `json:"id"`
+	Sources []provider.Type `json:"sources"`
+	Config  *ProxyConfig    `json:"config,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
 
-	for k, v := range loadedCfg {
-		defaultCfg[k] = v
-	}
+// EffectiveConfigs returns the effective configs that the Composite currently supplies.
+// Configs that can not be loaded contain the Error instead of the Config.
+func EffectiveConfigs(composite *provider.Composite) []EffectiveConfig {
+	data := composite.Current()
+	sources := composite.Sources()
+
+	var cfgs []EffectiveConfig
+	for _, id := range sortedConfigIDs(data.Configs) {
+		effective := EffectiveConfig{
+			ID:      id,
+			Sources: sources[id],
+		}
 
-	bb, err = json.Marshal(defaultCfg)
-	if err != nil {
-		return err
+		var cfg ProxyConfig
+		if err := cfg.LoadFromBytes(data.Configs[id]); err != nil {
+			effective.Error = err.Error()
+		} else {
+			effective.Config = &cfg
+		}
+		cfgs = append(cfgs, effective)
 	}
-
-	return json.Unmarshal(bb, cfg)
+	return cfgs
 }
 
-func WatchProxyConfigFolder(path string, out chan *ProxyConfig) error {
-	watcher, err := fsnotify.NewWatcher()
+// LoadProxyConfigsFromProvider loads all ProxyConfigs that the provider currently supplies
+// and keeps them in sync with it. ProxyConfigs that the provider supplies later on are sent to out.
+func LoadProxyConfigsFromProvider(prov provider.Provider, out chan *ProxyConfig) ([]*ProxyConfig, error) {
+	dataCh := make(chan provider.Data)
+	data, err := prov.Provide(dataCh)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer watcher.Close()
 
-	if err := watcher.Add(path); err != nil {
-		return err
+	cfgs := map[string]*ProxyConfig{}
+	loaded := map[string][]byte{}
+	var proxyCfgs []*ProxyConfig
+	for _, id := range sortedConfigIDs(data.Configs) {
+		bb := data.Configs[id]
+		log.Printf("Loading %s from %s provider", id, data.Type)
+		var cfg ProxyConfig
+		if err := cfg.LoadFromBytes(bb); err != nil {
+			return nil, fmt.Errorf("failed loading %s; error %s", id, err)
+		}
+		cfgs[id] = &cfg
+		loaded[id] = bb
+		proxyCfgs = append(proxyCfgs, &cfg)
 	}
 
-	defer close(out)
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return nil
-			}
-			if event.Op&fsnotify.Create == fsnotify.Create {
-				fileInfo, err := os.Lstat(event.Name)
-				if err != nil {
-					log.Printf("%s was created, but we failed to stat it: %v", event.Name, err)
-					continue
-				}
+	go syncProxyConfigs(cfgs, loaded, dataCh, out)
+	return proxyCfgs, nil
+}
 
-				if fileInfo.IsDir() {
-					continue
-				}
+// syncProxyConfigs applies the difference between the loaded configs and the configs of every Data.
+// Only the proxies of added, changed and removed configs are touched, so that the players of all
+// other proxies stay connected. loaded contains the configs that the ProxyConfigs were loaded from.
+func syncProxyConfigs(cfgs map[string]*ProxyConfig, loaded map[string][]byte, dataCh <-chan provider.Data, out chan *ProxyConfig) {
+	for data := range dataCh {
+		for id, cfg := range cfgs {
+			if _, ok := data.Configs[id]; ok {
+				continue
+			}
 
-				// check the type of file that is behind symlinks link
-				if fileInfo.Mode()&os.ModeSymlink == os.ModeSymlink {
-					linkedToDir, err := isLinkedToDir(event.Name)
-					if err != nil {
-						return err
-					}
+			log.Printf("Removing %s from %s provider", id, data.Type)
+			delete(cfgs, id)
+			delete(loaded, id)
+			if cfg.removeCallback != nil {
+				cfg.removeCallback()
+			}
+		}
 
-					if linkedToDir {
-						continue
-					}
-				}
+		for _, id := range sortedConfigIDs(data.Configs) {
+			bb := data.Configs[id]
+			if bytes.Equal(bb, loaded[id]) {
+				continue
+			}
 
-				proxyCfg, err := NewProxyConfigFromPath(event.Name)
-				if err != nil {
-					log.Printf("Failed loading %s; error %s", event.Name, err)
+			cfg, ok := cfgs[id]
+			if !ok {
+				log.Printf("Loading %s from %s provider", id, data.Type)
+				cfg = &ProxyConfig{}
+				if err := cfg.LoadFromBytes(bb); err != nil {
+					log.Printf("Failed loading %s; error %s", id, err)
 					continue
 				}
-				out <- proxyCfg
+				cfgs[id] = cfg
+				loaded[id] = bb
+				out <- cfg
+				continue
 			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return nil
+
+			log.Printf("Updating %s from %s provider", id, data.Type)
+			if err := cfg.LoadFromBytes(bb); err != nil {
+				log.Printf("Failed update on %s; error %s", id, err)
+				continue
 			}
-			log.Printf("Failed watching %s; error %s", path, err)
+			loaded[id] = bb
+			cfg.onConfigUpdate()
 		}
 	}
 }
+
+// sortedConfigIDs returns the IDs of the configs in lexicographic order,
+// so that proxies are always registered in the same order
+func sortedConfigIDs(configs map[string][]byte) []string {
+	ids := make([]string, 0, len(configs))
+	for id := range configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,342 @@
+package infrared
+
+import (
+	"context"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/haveachin/infrared/provider"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type testProvider struct {
+	data   provider.Data
+	dataCh chan<- provider.Data
+}
+
+func (p *testProvider) Provide(dataCh chan<- provider.Data) (provider.Data, error) {
+	p.dataCh = dataCh
+	return p.data, nil
+}
+
+func (p *testProvider) Status() provider.Status {
+	return provider.Status{Type: p.data.Type}
+}
+
+func (p *testProvider) Close() error {
+	return nil
+}
+
+func TestLoadProxyConfigsFromProvider_AppliesDiff(t *testing.T) {
+	prov := &testProvider{
+		data: provider.Data{
+			Type: provider.FileType,
+			Configs: map[string][]byte{
+				"a": []byte(`{"domainName":"a.example.com"}`),
+				"b": []byte(`{"domainName":"b.example.com"}`),
+				"c": []byte(`{"domainName":"c.example.com"}`),
+			},
+		},
+	}
+
+	out := make(chan *ProxyConfig, 1)
+	cfgs, err := LoadProxyConfigsFromProvider(prov, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := map[string]int{}
+	removed := map[string]int{}
+	for _, cfg := range cfgs {
+		domain := cfg.DomainName
+		cfg.changeCallback = func() { changed[domain]++ }
+		cfg.removeCallback = func() { removed[domain]++ }
+	}
+
+	prov.dataCh <- provider.Data{
+		Type: provider.FileType,
+		Configs: map[string][]byte{
+			"a": []byte(`{"domainName":"a.example.com"}`),
+			"b": []byte(`{"domainName":"b.example.com","timeout":500}`),
+			"d": []byte(`{"domainName":"d.example.com"}`),
+		},
+	}
+
+	select {
+	case cfg := <-out:
+		if cfg.DomainName != "d.example.com" {
+			t.Errorf("got added %s; want d.example.com", cfg.DomainName)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("got no added config")
+	}
+
+	// Adding c again signals that the previous changes were applied
+	prov.dataCh <- provider.Data{Type: provider.FileType, Configs: map[string][]byte{
+		"a": []byte(`{"domainName":"a.example.com"}`),
+		"b": []byte(`{"domainName":"b.example.com","timeout":500}`),
+		"c": []byte(`{"domainName":"c.example.com"}`),
+		"d": []byte(`{"domainName":"d.example.com"}`),
+	}}
+	<-out
+
+	if changed["a.example.com"] != 0 {
+		t.Errorf("got %d updates of unchanged config", changed["a.example.com"])
+	}
+	if changed["b.example.com"] != 1 {
+		t.Errorf("got %d updates of changed config; want 1", changed["b.example.com"])
+	}
+	if removed["c.example.com"] != 1 {
+		t.Errorf("got %d removals of removed config; want 1", removed["c.example.com"])
+	}
+}
+
+func TestEffectiveConfigs(t *testing.T) {
+	file := &testProvider{data: provider.Data{Type: provider.FileType, Configs: map[string][]byte{
+		"lobby":  []byte(`{"domainName":"lobby.example.com","proxyTo":":25566"}`),
+		"broken": []byte(`{"timeout":"1s"}`),
+	}}}
+	http := &testProvider{data: provider.Data{Type: provider.HTTPType, Configs: map[string][]byte{
+		"lobby": []byte(`{"proxyTo":":25567"}`),
+	}}}
+
+	composite := provider.NewComposite(provider.Prioritized{Provider: file}, provider.Prioritized{Provider: http})
+	defer composite.Close()
+	if _, err := composite.Provide(make(chan provider.Data)); err != nil {
+		t.Fatal(err)
+	}
+
+	cfgs := EffectiveConfigs(composite)
+	if len(cfgs) != 2 {
+		t.Fatalf("got %d configs; want 2", len(cfgs))
+	}
+
+	broken, lobby := cfgs[0], cfgs[1]
+	if broken.Error == "" || broken.Config != nil {
+		t.Errorf("got %+v; want error", broken)
+	}
+
+	if lobby.Config.ProxyTo != ":25567" || lobby.Config.ListenTo != DefaultProxyConfig().ListenTo {
+		t.Errorf("got proxyTo %s and listenTo %s", lobby.Config.ProxyTo, lobby.Config.ListenTo)
+	}
+	if len(lobby.Sources) != 2 || lobby.Sources[0] != provider.FileType || lobby.Sources[1] != provider.HTTPType {
+		t.Errorf("got sources %v; want [file http]", lobby.Sources)
+	}
+}
+
+func TestProxyConfig_LoadFromBytes_DomainRegexp(t *testing.T) {
+	tt := []struct {
+		domainName string
+		isRegexp   bool
+		expectErr  bool
+	}{
+		{domainName: "mc.example.com"},
+		{domainName: `^(?P<name>\\w+)\\.play\\.net$`, isRegexp: true},
+		{domainName: `^(\\w+\\.play\\.net$`, expectErr: true},
+	}
+
+	for _, tc := range tt {
+		var cfg ProxyConfig
+		err := cfg.LoadFromBytes([]byte(`{"domainName":"` + tc.domainName + `"}`))
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%s: got error %v; want error %t", tc.domainName, err, tc.expectErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		re, err := cfg.DomainRegexp()
+		if err != nil || (re != nil) != tc.isRegexp {
+			t.Errorf("%s: got regexp %v, error %v; want regexp %t", tc.domainName, re, err, tc.isRegexp)
+		}
+	}
+}
+
+func TestProxyConfig_LoadFromBytes_ReplacesBackendWeights(t *testing.T) {
+	var cfg ProxyConfig
+	if err := cfg.LoadFromBytes([]byte(`{"backends":[":25566",":25567"],"backendWeights":{":25566":3}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadFromBytes([]byte(`{"backends":[":25566",":25567"],"backendWeights":{":25567":2}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.BackendWeights) != 1 || cfg.BackendWeights[":25567"] != 2 {
+		t.Errorf("got weights %v; want only :25567", cfg.BackendWeights)
+	}
+}
+
+func TestProxyConfig_LoadFromBytes_MergesNestedDefaults(t *testing.T) {
+	var cfg ProxyConfig
+	if err := cfg.LoadFromBytes([]byte(`{"bedrock":{"address":"geyser.internal:19132"}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Bedrock.Address != "geyser.internal:19132" {
+		t.Errorf("got address %q; want geyser.internal:19132", cfg.Bedrock.Address)
+	}
+	if cfg.Bedrock.ListenTo != BedrockScheme+":19132" || cfg.Bedrock.Status.CacheTTL != 5000 {
+		t.Errorf("got listenTo %q and cache TTL %d; want the defaults", cfg.Bedrock.ListenTo, cfg.Bedrock.Status.CacheTTL)
+	}
+}
+
+func TestProxyConfig_Dialer_HappyEyeballs(t *testing.T) {
+	var cfg ProxyConfig
+	if err := cfg.LoadFromBytes([]byte(`{"proxyTo":"localhost:25565"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	dialer, err := cfg.Dialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dialer.FallbackDelay != 250*time.Millisecond {
+		t.Errorf("got fallback delay %s; want the default of 250ms", dialer.FallbackDelay)
+	}
+
+	// A backend that only listens on IPv4 is reached, even if localhost resolves to ::1 first
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	rconn, err := dialer.Dial(net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rconn.Close()
+}
+
+// serveDualStackDNS answers every A query with 127.0.0.1 and every AAAA query with ::1
+func serveDualStackDNS(pc net.PacketConn) {
+	bb := make([]byte, 512)
+	for {
+		n, addr, err := pc.ReadFrom(bb)
+		if err != nil {
+			return
+		}
+
+		var msg dnsmessage.Message
+		if err := msg.Unpack(bb[:n]); err != nil || len(msg.Questions) == 0 {
+			continue
+		}
+		q := msg.Questions[0]
+		header := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60}
+		msg.Header.Response = true
+		msg.Header.Authoritative = true
+		msg.Questions = msg.Questions[:1]
+		msg.Additionals = nil
+		switch q.Type {
+		case dnsmessage.TypeA:
+			msg.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}}}}
+		case dnsmessage.TypeAAAA:
+			msg.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.AAAAResource{AAAA: [16]byte{15: 1}}}}
+		}
+
+		resp, err := msg.Pack()
+		if err != nil {
+			continue
+		}
+		pc.WriteTo(resp, addr)
+	}
+}
+
+func TestProxyConfig_Dialer_HappyEyeballsFallback(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	dns, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dns.Close()
+	go serveDualStackDNS(dns)
+
+	var cfg ProxyConfig
+	if err := cfg.LoadFromBytes([]byte(`{"proxyTo":"localhost:25565","timeout":5000,"happyEyeballsDelay":100}`)); err != nil {
+		t.Fatal(err)
+	}
+	dialer, err := cfg.Dialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp4", dns.LocalAddr().String())
+		},
+	}
+
+	// The address family that is dialed first is blackholed, so the dial only connects via the fallback
+	blackhole := make(chan struct{})
+	defer close(blackhole)
+	var once sync.Once
+	dialer.Control = func(network, address string, c syscall.RawConn) error {
+		first := false
+		once.Do(func() { first = true })
+		if first {
+			<-blackhole
+		}
+		return nil
+	}
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	start := time.Now()
+	rconn, err := dialer.Dial(net.JoinHostPort("dualstack.infrared.test", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rconn.Close()
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("got a dial of %s; want it to connect via the fallback after the delay of 100ms", elapsed)
+	}
+}
+
+func TestProxyConfig_LoadFromBytes_KeepsConfigOnError(t *testing.T) {
+	var cfg ProxyConfig
+	if err := cfg.LoadFromBytes([]byte(`{"domainName":"^lobby\\.example\\.com$","proxyTo":":25566"}`)); err != nil {
+		t.Fatal(err)
+	}
+	re, err := cfg.DomainRegexp()
+	if err != nil || re == nil {
+		t.Fatalf("got regexp %v and error %v; want the compiled domainName", re, err)
+	}
+
+	if err := cfg.LoadFromBytes([]byte(`{"domainName":"^(lobby","proxyTo":":25567"}`)); err == nil {
+		t.Fatal("got no error; want the invalid domainName to be rejected")
+	}
+
+	if cfg.DomainName != `^lobby\.example\.com$` || cfg.ProxyTo != ":25566" {
+		t.Errorf("got domainName %q and proxyTo %q; want the last valid config", cfg.DomainName, cfg.ProxyTo)
+	}
+	if got, _ := cfg.DomainRegexp(); got != re {
+		t.Errorf("got regexp %v; want the regexp of the last valid config", got)
+	}
+}
+
+func TestProxyConfig_HappyEyeballsDelayZero(t *testing.T) {
+	var cfg ProxyConfig
+	if err := cfg.LoadFromBytes([]byte(`{"proxyTo":"localhost:25565","happyEyeballsDelay":0}`)); err == nil {
+		t.Error("got no error; want a delay of 0 to be rejected")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/haveachin/infrared/protocol"
 	"io"
 	"net"
+	"net/url"
+	"strings"
 )
 
 type PacketWriter interface {
@@ -31,8 +33,10 @@ type Listener struct {
 	net.Listener
 }
 
+// Listen listens on the address. It also accepts network schemes like tcp6://[::]:25565
+// and multiple addresses separated by the ListenAddrSeparator.
 func Listen(addr string) (Listener, error) {
-	l, err := net.Listen("tcp", addr)
+	l, err := listen(addr)
 	return Listener{Listener: l}, err
 }
 
@@ -63,17 +67,42 @@ func wrapConn(c net.Conn) *conn {
 	}
 }
 
+// UnixScheme is the prefix of addresses of Unix domain sockets, like unix:///run/minecraft.sock
+const UnixScheme = "unix://"
+
 type Dialer struct {
 	net.Dialer
+	// UpstreamProxy is the URL of a SOCKS5 or HTTP CONNECT proxy that TCP connections are dialed through
+	UpstreamProxy *url.URL
+	// SocketOptions are the TCP options of the dialed connections
+	SocketOptions SocketOptions
 }
 
-// Dial create a Minecraft connection
+// Dial create a Minecraft connection. Addresses with the UnixScheme dial a Unix domain socket.
 func (d Dialer) Dial(addr string) (Conn, error) {
-	conn, err := d.Dialer.Dial("tcp", addr)
+	network := "tcp"
+	if strings.HasPrefix(addr, UnixScheme) {
+		network = "unix"
+		addr = strings.TrimPrefix(addr, UnixScheme)
+		// A local TCP address like the proxyBind can not dial a socket
+		d.Dialer.LocalAddr = nil
+	}
+
+	var conn net.Conn
+	var err error
+	if d.UpstreamProxy != nil && network == "tcp" {
+		conn, err = d.dialUpstream(addr)
+	} else {
+		conn, err = d.Dialer.Dial(network, addr)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if err := d.SocketOptions.apply(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
 	return wrapConn(conn), nil
 }
 
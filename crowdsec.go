@@ -0,0 +1,467 @@
+package infrared
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// CrowdSecFloodScenario is the scenario of the alerts of IPs that exceed their accept limit
+	CrowdSecFloodScenario = "infrared/handshake-flood"
+	// CrowdSecMalformedScenario is the scenario of the alerts of IPs that were banned for malformed handshakes
+	CrowdSecMalformedScenario = "infrared/invalid-protocol"
+	// DefaultCrowdSecBanDuration is the duration of the decisions of pushed alerts, like the default of CrowdSec
+	DefaultCrowdSecBanDuration = 4 * time.Hour
+
+	crowdSecOrigin = "infrared"
+	// crowdSecRequestTimeout is how long the local API has to answer
+	crowdSecRequestTimeout = 10 * time.Second
+)
+
+var (
+	crowdSecDecisions = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "infrared_crowdsec_decisions",
+		Help: "The number of CrowdSec ban decisions that are applied to incoming connections",
+	})
+	crowdSecAlerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "infrared_crowdsec_alerts",
+		Help: "The total number of alerts that were pushed to CrowdSec",
+	}, []string{"scenario"})
+)
+
+// CrowdSecConfig pulls the ban decisions of a CrowdSec local API with the APIKey of a bouncer every Interval.
+// With the MachineID and the Password of a watcher, the detections of Infrared are pushed as alerts with
+// a ban decision of BanDuration.
+type CrowdSecConfig struct {
+	URL         string
+	APIKey      string
+	MachineID   string
+	Password    string
+	Interval    time.Duration
+	BanDuration time.Duration
+}
+
+func (cfg CrowdSecConfig) enabled() bool {
+	return cfg.URL != ""
+}
+
+func (cfg CrowdSecConfig) pushesAlerts() bool {
+	return cfg.MachineID != ""
+}
+
+func (cfg CrowdSecConfig) validate() error {
+	if !cfg.enabled() {
+		return nil
+	}
+	if _, err := url.Parse(cfg.URL); err != nil {
+		return err
+	}
+	if cfg.APIKey == "" {
+		return errors.New("no API key")
+	}
+	if (cfg.MachineID == "") != (cfg.Password == "") {
+		return errors.New("machine ID and password have to be set together")
+	}
+	if cfg.Interval <= 0 {
+		return errors.New("interval has to be positive")
+	}
+	return nil
+}
+
+type crowdSecDecision struct {
+	Duration string `json:"duration"`
+	Origin   string `json:"origin"`
+	Scenario string `json:"scenario"`
+	Scope    string `json:"scope"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+}
+
+type crowdSecStream struct {
+	New     []crowdSecDecision `json:"new"`
+	Deleted []crowdSecDecision `json:"deleted"`
+}
+
+type crowdSecSource struct {
+	IP    string `json:"ip"`
+	Scope string `json:"scope"`
+	Value string `json:"value"`
+}
+
+type crowdSecMeta struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type crowdSecEvent struct {
+	Timestamp string         `json:"timestamp"`
+	Meta      []crowdSecMeta `json:"meta"`
+}
+
+type crowdSecAlert struct {
+	Capacity        int                `json:"capacity"`
+	Decisions       []crowdSecDecision `json:"decisions"`
+	Events          []crowdSecEvent    `json:"events"`
+	EventsCount     int                `json:"events_count"`
+	Leakspeed       string             `json:"leakspeed"`
+	Message         string             `json:"message"`
+	Scenario        string             `json:"scenario"`
+	ScenarioHash    string             `json:"scenario_hash"`
+	ScenarioVersion string             `json:"scenario_version"`
+	Simulated       bool               `json:"simulated"`
+	Source          crowdSecSource     `json:"source"`
+	StartAt         string             `json:"start_at"`
+	StopAt          string             `json:"stop_at"`
+}
+
+// crowdSecRange is a decision of a whole network
+type crowdSecRange struct {
+	network *net.IPNet
+	until   time.Time
+}
+
+// crowdSecDetection are the detections of an IP in a scenario since alerts were pushed the last time
+type crowdSecDetection struct {
+	ip       string
+	scenario string
+	message  string
+	count    int
+	start    time.Time
+	stop     time.Time
+}
+
+// crowdSecBouncer holds the decisions that were pulled from CrowdSec and the detections that weren't pushed yet
+type crowdSecBouncer struct {
+	mu         sync.RWMutex
+	ips        map[string]time.Time
+	ranges     map[string]crowdSecRange
+	started    bool
+	detections map[string]*crowdSecDetection
+	token      string
+	expire     time.Time
+}
+
+// banned reports whether a decision of CrowdSec bans the IP
+func (b *crowdSecBouncer) banned(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	now := time.Now()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if until, ok := b.ips[ip.String()]; ok && now.Before(until) {
+		return true
+	}
+	for _, r := range b.ranges {
+		if now.Before(r.until) && r.network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply adds the new ban decisions of the stream and removes the deleted ones
+func (b *crowdSecBouncer) apply(stream crowdSecStream, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ips == nil {
+		b.ips = map[string]time.Time{}
+		b.ranges = map[string]crowdSecRange{}
+	}
+
+	for _, d := range stream.Deleted {
+		switch strings.ToLower(d.Scope) {
+		case "ip":
+			if ip := net.ParseIP(d.Value); ip != nil {
+				delete(b.ips, ip.String())
+			}
+		case "range":
+			delete(b.ranges, d.Value)
+		}
+	}
+
+	for _, d := range stream.New {
+		if !strings.EqualFold(d.Type, "ban") {
+			continue
+		}
+		duration, err := time.ParseDuration(d.Duration)
+		if err != nil || duration <= 0 {
+			continue
+		}
+		until := now.Add(duration)
+
+		switch strings.ToLower(d.Scope) {
+		case "ip":
+			if ip := net.ParseIP(d.Value); ip != nil {
+				b.ips[ip.String()] = until
+			}
+		case "range":
+			if _, network, err := net.ParseCIDR(d.Value); err == nil {
+				b.ranges[d.Value] = crowdSecRange{network: network, until: until}
+			}
+		}
+	}
+
+	for key, until := range b.ips {
+		if !now.Before(until) {
+			delete(b.ips, key)
+		}
+	}
+	for key, r := range b.ranges {
+		if !now.Before(r.until) {
+			delete(b.ranges, key)
+		}
+	}
+	crowdSecDecisions.Set(float64(len(b.ips) + len(b.ranges)))
+}
+
+// detect counts a detection of the IP in the scenario until the detections are pushed
+func (b *crowdSecBouncer) detect(ip net.IP, scenario, message string) {
+	if ip == nil {
+		return
+	}
+
+	now := time.Now()
+	key := scenario + " " + ip.String()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.detections == nil {
+		b.detections = map[string]*crowdSecDetection{}
+	}
+
+	detection, ok := b.detections[key]
+	if !ok {
+		detection = &crowdSecDetection{
+			ip:       ip.String(),
+			scenario: scenario,
+			message:  message,
+			start:    now,
+		}
+		b.detections[key] = detection
+	}
+	detection.count++
+	detection.stop = now
+}
+
+// takeDetections returns the detections that weren't pushed yet and forgets them
+func (b *crowdSecBouncer) takeDetections() []crowdSecDetection {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	detections := make([]crowdSecDetection, 0, len(b.detections))
+	for _, detection := range b.detections {
+		detections = append(detections, *detection)
+	}
+	b.detections = nil
+	return detections
+}
+
+// alert returns the alert of the detection with a ban decision of the duration
+func (detection crowdSecDetection) alert(duration time.Duration) crowdSecAlert {
+	source := crowdSecSource{IP: detection.ip, Scope: "Ip", Value: detection.ip}
+	return crowdSecAlert{
+		Capacity: 0,
+		Decisions: []crowdSecDecision{{
+			Duration: duration.String(),
+			Origin:   crowdSecOrigin,
+			Scenario: detection.scenario,
+			Scope:    source.Scope,
+			Type:     "ban",
+			Value:    source.Value,
+		}},
+		Events: []crowdSecEvent{{
+			Timestamp: detection.stop.UTC().Format(time.RFC3339),
+			Meta: []crowdSecMeta{
+				{Key: "source_ip", Value: detection.ip},
+				{Key: "service", Value: "minecraft"},
+			},
+		}},
+		EventsCount:     detection.count,
+		Leakspeed:       "0",
+		Message:         fmt.Sprintf("Ip %s performed '%s' (%d events); %s", detection.ip, detection.scenario, detection.count, detection.message),
+		Scenario:        detection.scenario,
+		ScenarioHash:    "",
+		ScenarioVersion: "",
+		Source:          source,
+		StartAt:         detection.start.UTC().Format(time.RFC3339),
+		StopAt:          detection.stop.UTC().Format(time.RFC3339),
+	}
+}
+
+// reportCrowdSec counts a detection of the IP so that it's pushed as an alert to CrowdSec
+func (gateway *Gateway) reportCrowdSec(ip net.IP, scenario, message string) {
+	if !gateway.CrowdSec.enabled() || !gateway.CrowdSec.pushesAlerts() {
+		return
+	}
+	gateway.crowdSec.detect(ip, scenario, message)
+}
+
+// runCrowdSec pulls the decisions of CrowdSec and pushes the detections every interval
+func (gateway *Gateway) runCrowdSec() {
+	client := &http.Client{Timeout: crowdSecRequestTimeout}
+	ticker := time.NewTicker(gateway.CrowdSec.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := gateway.pullCrowdSecDecisions(client); err != nil {
+			log.Printf("[x] Can't pull CrowdSec decisions; error: %s", err)
+		}
+		if err := gateway.pushCrowdSecAlerts(client); err != nil {
+			log.Printf("[x] Can't push CrowdSec alerts; error: %s", err)
+		}
+		<-ticker.C
+	}
+}
+
+func (gateway *Gateway) crowdSecURL(path string) string {
+	return strings.TrimSuffix(gateway.CrowdSec.URL, "/") + path
+}
+
+// pullCrowdSecDecisions pulls the decision stream of the bouncer. The first pull gets all the active
+// decisions and every later pull only the decisions that were added or deleted since the last one.
+func (gateway *Gateway) pullCrowdSecDecisions(client *http.Client) error {
+	gateway.crowdSec.mu.RLock()
+	startup := !gateway.crowdSec.started
+	gateway.crowdSec.mu.RUnlock()
+
+	req, err := http.NewRequest(http.MethodGet, gateway.crowdSecURL(fmt.Sprintf("/v1/decisions/stream?startup=%t", startup)), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Api-Key", gateway.CrowdSec.APIKey)
+	req.Header.Set("User-Agent", "infrared-bouncer")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("local API responded with %s", res.Status)
+	}
+
+	var stream crowdSecStream
+	if err := json.NewDecoder(res.Body).Decode(&stream); err != nil {
+		return err
+	}
+
+	gateway.crowdSec.apply(stream, time.Now())
+	gateway.crowdSec.mu.Lock()
+	gateway.crowdSec.started = true
+	gateway.crowdSec.mu.Unlock()
+	return nil
+}
+
+// crowdSecToken logs the watcher in, if it has no valid token
+func (gateway *Gateway) crowdSecToken(client *http.Client) (string, error) {
+	gateway.crowdSec.mu.RLock()
+	token, expire := gateway.crowdSec.token, gateway.crowdSec.expire
+	gateway.crowdSec.mu.RUnlock()
+	if token != "" && time.Now().Add(time.Minute).Before(expire) {
+		return token, nil
+	}
+
+	bb, err := json.Marshal(map[string]interface{}{
+		"machine_id": gateway.CrowdSec.MachineID,
+		"password":   gateway.CrowdSec.Password,
+		"scenarios":  []string{CrowdSecFloodScenario, CrowdSecMalformedScenario},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	res, err := client.Post(gateway.crowdSecURL("/v1/watchers/login"), "application/json", bytes.NewReader(bb))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("login responded with %s", res.Status)
+	}
+
+	var login struct {
+		Token  string `json:"token"`
+		Expire string `json:"expire"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&login); err != nil {
+		return "", err
+	}
+	expire, err = time.Parse(time.RFC3339, login.Expire)
+	if err != nil {
+		return "", err
+	}
+
+	gateway.crowdSec.mu.Lock()
+	gateway.crowdSec.token, gateway.crowdSec.expire = login.Token, expire
+	gateway.crowdSec.mu.Unlock()
+	return login.Token, nil
+}
+
+// pushCrowdSecAlerts pushes the detections since the last push as alerts
+func (gateway *Gateway) pushCrowdSecAlerts(client *http.Client) error {
+	if !gateway.CrowdSec.pushesAlerts() {
+		return nil
+	}
+
+	detections := gateway.crowdSec.takeDetections()
+	if len(detections) == 0 {
+		return nil
+	}
+
+	duration := gateway.CrowdSec.BanDuration
+	if duration <= 0 {
+		duration = DefaultCrowdSecBanDuration
+	}
+	alerts := make([]crowdSecAlert, 0, len(detections))
+	for _, detection := range detections {
+		alerts = append(alerts, detection.alert(duration))
+	}
+
+	token, err := gateway.crowdSecToken(client)
+	if err != nil {
+		return err
+	}
+	bb, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, gateway.crowdSecURL("/v1/alerts"), bytes.NewReader(bb))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "infrared-bouncer")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized {
+		gateway.crowdSec.mu.Lock()
+		gateway.crowdSec.token = ""
+		gateway.crowdSec.mu.Unlock()
+	}
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+		return fmt.Errorf("local API responded with %s", res.Status)
+	}
+
+	for _, detection := range detections {
+		crowdSecAlerts.With(prometheus.Labels{"scenario": detection.scenario}).Inc()
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package infrared
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCrowdSecBouncer_Apply(t *testing.T) {
+	var b crowdSecBouncer
+	now := time.Now()
+	b.apply(crowdSecStream{New: []crowdSecDecision{
+		{Duration: "4h", Scope: "Ip", Type: "ban", Value: "203.0.113.5"},
+		{Duration: "4h", Scope: "Range", Type: "ban", Value: "198.51.100.0/24"},
+		{Duration: "4h", Scope: "Ip", Type: "captcha", Value: "203.0.113.6"},
+		{Duration: "-1s", Scope: "Ip", Type: "ban", Value: "203.0.113.7"},
+	}}, now)
+
+	tt := []struct {
+		ip     string
+		banned bool
+	}{
+		{ip: "203.0.113.5", banned: true},
+		{ip: "198.51.100.42", banned: true},
+		{ip: "203.0.113.6", banned: false},
+		{ip: "203.0.113.7", banned: false},
+		{ip: "192.0.2.1", banned: false},
+	}
+	for _, tc := range tt {
+		if banned := b.banned(net.ParseIP(tc.ip)); banned != tc.banned {
+			t.Errorf("%s: got banned %t; want %t", tc.ip, banned, tc.banned)
+		}
+	}
+
+	b.apply(crowdSecStream{Deleted: []crowdSecDecision{
+		{Scope: "Ip", Type: "ban", Value: "203.0.113.5"},
+		{Scope: "Range", Type: "ban", Value: "198.51.100.0/24"},
+	}}, now)
+	if b.banned(net.ParseIP("203.0.113.5")) || b.banned(net.ParseIP("198.51.100.42")) {
+		t.Error("got banned; want the deleted decisions lifted")
+	}
+}
+
+func TestGateway_CrowdSec(t *testing.T) {
+	var (
+		startup string
+		alerts  []crowdSecAlert
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/decisions/stream":
+			if r.Header.Get("X-Api-Key") != "key" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			startup = r.URL.Query().Get("startup")
+			json.NewEncoder(w).Encode(crowdSecStream{New: []crowdSecDecision{
+				{Duration: "1h", Scope: "Ip", Type: "ban", Value: "203.0.113.5"},
+			}})
+		case "/v1/watchers/login":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code":   200,
+				"token":  "token",
+				"expire": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			})
+		case "/v1/alerts":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&alerts)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	gateway := Gateway{CrowdSec: CrowdSecConfig{
+		URL:       server.URL,
+		APIKey:    "key",
+		MachineID: "infrared",
+		Password:  "password",
+		Interval:  time.Second,
+	}}
+	client := server.Client()
+
+	if err := gateway.pullCrowdSecDecisions(client); err != nil {
+		t.Fatal(err)
+	}
+	if startup != "true" {
+		t.Errorf("got startup %q; want the first pull to get all decisions", startup)
+	}
+	if !gateway.addrBanned(&net.TCPAddr{IP: net.ParseIP("203.0.113.5")}) {
+		t.Error("got not banned; want the IP of the decision banned")
+	}
+	if err := gateway.pullCrowdSecDecisions(client); err != nil {
+		t.Fatal(err)
+	}
+	if startup != "false" {
+		t.Errorf("got startup %q; want later pulls to get the changes", startup)
+	}
+
+	ip := net.ParseIP("192.0.2.1")
+	gateway.reportCrowdSec(ip, CrowdSecFloodScenario, "connections over the accept limit")
+	gateway.reportCrowdSec(ip, CrowdSecFloodScenario, "connections over the accept limit")
+	if err := gateway.pushCrowdSecAlerts(client); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts; want 1", len(alerts))
+	}
+	alert := alerts[0]
+	if alert.Scenario != CrowdSecFloodScenario || alert.EventsCount != 2 || alert.Source.Value != "192.0.2.1" {
+		t.Errorf("got alert %+v", alert)
+	}
+	if len(alert.Decisions) != 1 || alert.Decisions[0].Duration != DefaultCrowdSecBanDuration.String() {
+		t.Errorf("got decisions %+v; want one ban of the default duration", alert.Decisions)
+	}
+}
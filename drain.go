@@ -0,0 +1,83 @@
+package infrared
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// DrainConfig controls how a removed proxy drains its players
+type DrainConfig struct {
+	Timeout int    `json:"timeout"`
+	Message string `json:"message"`
+}
+
+// proxyDrain is the state of a removed proxy that waits for its players to leave
+type proxyDrain struct {
+	timer   *time.Timer
+	drained func()
+	once    sync.Once
+}
+
+func (proxy *Proxy) Drain() DrainConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Drain
+}
+
+func (proxy *Proxy) draining() bool {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	return proxy.drain != nil
+}
+
+// startDrain stops the proxy from letting new players in and calls drained when the last player left.
+// Players that are still connected after the drain timeout are disconnected.
+func (proxy *Proxy) startDrain(drained func()) {
+	drain := &proxyDrain{drained: drained}
+	// The timer is set before the drain is published, so finishDrain always sees it
+	if timeout := time.Millisecond * time.Duration(proxy.Drain().Timeout); timeout > 0 {
+		drain.timer = time.AfterFunc(timeout, proxy.disconnectPlayers)
+	}
+
+	proxy.mu.Lock()
+	proxy.drain = drain
+	n := len(proxy.players)
+	proxy.mu.Unlock()
+
+	if n == 0 {
+		proxy.finishDrain()
+		return
+	}
+
+	log.Printf("[i] Draining %d players of %s", n, proxy.UID())
+}
+
+// finishDrain ends the drain of the proxy once all of its players left
+func (proxy *Proxy) finishDrain() {
+	proxy.mu.Lock()
+	drain := proxy.drain
+	proxy.mu.Unlock()
+	if drain == nil {
+		return
+	}
+
+	drain.once.Do(func() {
+		if drain.timer != nil {
+			drain.timer.Stop()
+		}
+		log.Println("[i] Drained all players of", proxy.UID())
+		drain.drained()
+	})
+}
+
+// disconnectPlayers closes the connections of all players of the proxy
+func (proxy *Proxy) disconnectPlayers() {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	log.Printf("[i] Drain timeout of %s is over; disconnecting %d players", proxy.UID(), len(proxy.players))
+	for conn := range proxy.players {
+		conn.Close()
+	}
+}
@@ -0,0 +1,268 @@
+package infrared
+
+import (
+	"log"
+	"net"
+	"sync"
+
+	"github.com/haveachin/infrared/callback"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var backendDialFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "infrared_backend_dial_failures",
+	Help: "The total number of failed dials to the backends of a proxy",
+}, []string{"host"})
+
+// Event is something that happened in the gateway. Subscribers of the EventBus get the typed events,
+// like PlayerJoined, and switch on their types.
+type Event interface {
+	EventName() string
+}
+
+// GatewayStarted is published once the gateway registered all proxies that it started with
+type GatewayStarted struct {
+	ProxyUIDs []string
+}
+
+// ConnectionAccepted is published for every connection that passed the accept limits and bans of its listener
+type ConnectionAccepted struct {
+	RemoteAddr net.Addr
+	ListenTo   string
+}
+
+// PlayerJoined is published once the login of a player is forwarded to the backend of the proxy.
+// Domain is the domain name of the proxy at the time of the join.
+type PlayerJoined struct {
+	Proxy       *Proxy
+	ProxyUID    string
+	Domain      string
+	Username    string
+	RemoteAddr  net.Addr
+	BackendAddr string
+}
+
+// PlayerLeft is published once the connection of a player that joined is closed
+type PlayerLeft struct {
+	Proxy       *Proxy
+	ProxyUID    string
+	Domain      string
+	Username    string
+	RemoteAddr  net.Addr
+	BackendAddr string
+}
+
+// BackendDialFailed is published if a backend couldn't be dialed for a client
+type BackendDialFailed struct {
+	Proxy       *Proxy
+	ProxyUID    string
+	Domain      string
+	BackendAddr string
+	Err         error
+}
+
+// ConfigReloaded is published after the config of a registered proxy changed
+type ConfigReloaded struct {
+	Proxy    *Proxy
+	ProxyUID string
+}
+
+// ConnectionFailed is published if a connection that matched a proxy closed with an error
+type ConnectionFailed struct {
+	Proxy      *Proxy
+	ProxyUID   string
+	RemoteAddr net.Addr
+	Err        error
+}
+
+// BackendDown is published if a backend of a proxy fails its health check
+type BackendDown struct {
+	Proxy       *Proxy
+	ProxyUID    string
+	BackendAddr string
+	Err         error
+}
+
+// BackendUp is published if a backend of a proxy passes its health check again
+type BackendUp struct {
+	Proxy       *Proxy
+	ProxyUID    string
+	BackendAddr string
+}
+
+// ContainerStarted is published before the container of a proxy is started for a client
+type ContainerStarted struct {
+	Proxy    *Proxy
+	ProxyUID string
+}
+
+// ContainerStopped is published before the container of a proxy is stopped after its timeout
+type ContainerStopped struct {
+	Proxy    *Proxy
+	ProxyUID string
+}
+
+func (GatewayStarted) EventName() string     { return "GatewayStarted" }
+func (ConnectionAccepted) EventName() string { return "ConnectionAccepted" }
+func (PlayerJoined) EventName() string       { return "PlayerJoined" }
+func (PlayerLeft) EventName() string         { return "PlayerLeft" }
+func (BackendDialFailed) EventName() string  { return "BackendDialFailed" }
+func (ConfigReloaded) EventName() string     { return "ConfigReloaded" }
+func (ConnectionFailed) EventName() string   { return "ConnectionFailed" }
+func (BackendDown) EventName() string        { return "BackendDown" }
+func (BackendUp) EventName() string          { return "BackendUp" }
+func (ContainerStarted) EventName() string   { return "ContainerStarted" }
+func (ContainerStopped) EventName() string   { return "ContainerStopped" }
+
+// EventBus passes the published events to all subscribers. The subscribers are called one after another on the
+// goroutine of the publisher, so they have to return quickly and start goroutines for slow work.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]func(Event)
+	nextID      int
+}
+
+// Subscribe calls the handler for every published event until the returned function unsubscribes it
+func (bus *EventBus) Subscribe(handler func(Event)) func() {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.subscribers == nil {
+		bus.subscribers = map[int]func(Event){}
+	}
+
+	id := bus.nextID
+	bus.nextID++
+	bus.subscribers[id] = handler
+	return func() {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		delete(bus.subscribers, id)
+	}
+}
+
+// Publish passes the event to all subscribers. Publishing on a nil bus does nothing.
+func (bus *EventBus) Publish(event Event) {
+	if bus == nil {
+		return
+	}
+
+	bus.mu.RLock()
+	handlers := make([]func(Event), 0, len(bus.subscribers))
+	for _, handler := range bus.subscribers {
+		handlers = append(handlers, handler)
+	}
+	bus.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// events returns the event bus of the gateway with the subscribers of the metrics and the callback servers
+func (gateway *Gateway) events() *EventBus {
+	gateway.subscribeEvents.Do(func() {
+		gateway.Events.Subscribe(countEvent)
+		gateway.Events.Subscribe(logCallbackEvent)
+	})
+	return &gateway.Events
+}
+
+// countEvent updates the metrics of the event
+func countEvent(event Event) {
+	switch event := event.(type) {
+	case PlayerJoined:
+		playersConnected.With(prometheus.Labels{"host": event.Domain}).Inc()
+	case PlayerLeft:
+		playersConnected.With(prometheus.Labels{"host": event.Domain}).Dec()
+	case BackendDialFailed:
+		backendDialFailures.With(prometheus.Labels{"host": event.Domain}).Inc()
+	}
+}
+
+// logCallbackEvent queues the event for the callback server of its proxy
+func logCallbackEvent(event Event) {
+	switch event := event.(type) {
+	case PlayerJoined:
+		event.Proxy.queueCallback(callback.PlayerJoinEvent{
+			Username:      event.Username,
+			RemoteAddress: event.RemoteAddr.String(),
+			TargetAddress: event.BackendAddr,
+			ProxyUID:      event.ProxyUID,
+		})
+	case PlayerLeft:
+		event.Proxy.queueCallback(callback.PlayerLeaveEvent{
+			Username:      event.Username,
+			RemoteAddress: event.RemoteAddr.String(),
+			TargetAddress: event.BackendAddr,
+			ProxyUID:      event.ProxyUID,
+		})
+	case ConnectionFailed:
+		event.Proxy.queueCallback(callback.ErrorEvent{
+			Error:    event.Err.Error(),
+			ProxyUID: event.ProxyUID,
+		})
+	case BackendDown:
+		event.Proxy.queueCallback(callback.BackendDownEvent{
+			Address:  event.BackendAddr,
+			Error:    event.Err.Error(),
+			ProxyUID: event.ProxyUID,
+		})
+	case BackendUp:
+		event.Proxy.queueCallback(callback.BackendUpEvent{
+			Address:  event.BackendAddr,
+			ProxyUID: event.ProxyUID,
+		})
+	case ContainerStarted:
+		event.Proxy.queueCallback(callback.ContainerStartEvent{ProxyUID: event.ProxyUID})
+	case ContainerStopped:
+		event.Proxy.queueCallback(callback.ContainerStopEvent{ProxyUID: event.ProxyUID})
+	}
+}
+
+// callbackQueueSize is the number of callback events of a proxy that wait for their POST; more are dropped
+const callbackQueueSize = 64
+
+// queueCallback queues the event for the callback server of the proxy, so the subscribers of the event bus
+// don't wait for the POST. The events of a proxy are posted one after another in order.
+func (proxy *Proxy) queueCallback(event callback.Event) {
+	if !proxy.CallbackLogger().Posts(event) {
+		return
+	}
+
+	proxy.mu.Lock()
+	if proxy.callbacks == nil {
+		proxy.callbacks = make(chan callback.Event, callbackQueueSize)
+		go proxy.postCallbacks(proxy.callbacks)
+	}
+	callbacks := proxy.callbacks
+	proxy.mu.Unlock()
+
+	select {
+	case callbacks <- event:
+	default:
+		log.Printf("[w] Dropping %s callback of %s; too many callbacks are pending", event.EventType(), proxy.UID())
+	}
+}
+
+func (proxy *Proxy) postCallbacks(callbacks <-chan callback.Event) {
+	for event := range callbacks {
+		proxy.logEvent(event)
+	}
+}
+
+// publish publishes the event on the event bus of the gateway that the proxy is registered with.
+// Proxies that aren't registered with a gateway have no bus and pass the event to the metrics and
+// their callback server directly.
+func (proxy *Proxy) publish(event Event) {
+	proxy.mu.Lock()
+	events := proxy.events
+	proxy.mu.Unlock()
+
+	if events == nil {
+		countEvent(event)
+		logCallbackEvent(event)
+		return
+	}
+	events.Publish(event)
+}
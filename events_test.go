@@ -0,0 +1,99 @@
+package infrared
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/haveachin/infrared/callback"
+)
+
+func TestEventBus(t *testing.T) {
+	var bus EventBus
+	var received []string
+	unsubscribe := bus.Subscribe(func(event Event) {
+		received = append(received, event.EventName())
+	})
+
+	bus.Publish(GatewayStarted{})
+	bus.Publish(ConfigReloaded{ProxyUID: "mc.example.com@:25565"})
+	unsubscribe()
+	bus.Publish(GatewayStarted{})
+
+	if len(received) != 2 || received[0] != "GatewayStarted" || received[1] != "ConfigReloaded" {
+		t.Errorf("got events %v; want GatewayStarted and ConfigReloaded before unsubscribing", received)
+	}
+
+	// A proxy that isn't registered with a gateway has no bus
+	var nilBus *EventBus
+	nilBus.Publish(GatewayStarted{})
+}
+
+func TestGateway_Events_SubscribesOnce(t *testing.T) {
+	gateway := Gateway{}
+	gateway.events()
+	gateway.events()
+	if n := len(gateway.Events.subscribers); n != 2 {
+		t.Errorf("got %d subscribers; want the metrics and callback subscribers once", n)
+	}
+}
+
+func TestProxy_Publish(t *testing.T) {
+	gateway := Gateway{}
+	var joined PlayerJoined
+	gateway.Events.Subscribe(func(event Event) {
+		if event, ok := event.(PlayerJoined); ok {
+			joined = event
+		}
+	})
+
+	addr := &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 51234}
+	proxy := &Proxy{Config: &ProxyConfig{DomainName: "mc.example.com"}}
+	proxy.publish(PlayerJoined{Proxy: proxy, Username: "Steve", RemoteAddr: addr})
+	if joined.Username != "" {
+		t.Error("got an event of a proxy that isn't registered; want none")
+	}
+
+	proxy.events = &gateway.Events
+	proxy.publish(PlayerJoined{Proxy: proxy, Username: "Steve", RemoteAddr: addr})
+	if joined.Username != "Steve" {
+		t.Errorf("got username %q; want Steve", joined.Username)
+	}
+}
+
+func TestProxy_Publish_Callbacks(t *testing.T) {
+	posted := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var eventLog callback.EventLog
+		if err := json.NewDecoder(r.Body).Decode(&eventLog); err != nil {
+			t.Error(err)
+		}
+		posted <- eventLog.Event
+	}))
+	defer server.Close()
+
+	// A proxy that isn't registered with a gateway posts its events directly
+	proxy := &Proxy{Config: &ProxyConfig{
+		DomainName: "mc.example.com",
+		CallbackServer: CallbackServerConfig{
+			URL:    server.URL,
+			Events: []string{callback.EventTypeBackendDown, callback.EventTypeBackendUp},
+		},
+	}}
+	proxy.publish(BackendDown{Proxy: proxy, BackendAddr: ":25566", Err: net.ErrClosed})
+	proxy.publish(BackendUp{Proxy: proxy, BackendAddr: ":25566"})
+
+	for _, want := range []string{callback.EventTypeBackendDown, callback.EventTypeBackendUp} {
+		select {
+		case event := <-posted:
+			if event != want {
+				t.Errorf("got %s; want %s", event, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("got no %s callback", want)
+		}
+	}
+}
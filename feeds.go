@@ -0,0 +1,217 @@
+package infrared
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PlainFeedFormat is a feed with one IP or CIDR range per line, like the lists of FireHOL or Spamhaus.
+	// Comments after # or ; and everything after the first field of a line are skipped.
+	PlainFeedFormat = "plain"
+	// AbuseIPDBFeedFormat is a feed in the JSON format of the blacklist endpoint of AbuseIPDB
+	AbuseIPDBFeedFormat = "abuseipdb"
+	// DefaultAbuseIPDBURL is the blacklist endpoint of AbuseIPDB
+	DefaultAbuseIPDBURL = "https://api.abuseipdb.com/api/v2/blacklist"
+
+	// defaultFeedRefresh is how often feeds without a refresh are fetched again
+	defaultFeedRefresh = time.Hour
+	// feedFetchTimeout is how long a feed has to download
+	feedFetchTimeout = time.Minute
+	// maxFeedSize is the size in bytes of the largest feed that is read
+	maxFeedSize = 64 << 20
+)
+
+// FeedConfig is a remote list of IPs and CIDR ranges that is downloaded from the URL every Refresh milliseconds.
+// The APIKey is sent as the Key header that AbuseIPDB expects.
+type FeedConfig struct {
+	URL     string `json:"url"`
+	Format  string `json:"format"`
+	APIKey  string `json:"apiKey"`
+	Refresh int    `json:"refresh"`
+}
+
+func (feed FeedConfig) validate() error {
+	u, err := url.Parse(feed.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s is no HTTP URL", feed.URL)
+	}
+
+	switch feed.Format {
+	case "", PlainFeedFormat, AbuseIPDBFeedFormat:
+	default:
+		return fmt.Errorf("unknown format %q of feed %s", feed.Format, feed.URL)
+	}
+	if feed.Refresh < 0 {
+		return fmt.Errorf("negative refresh of feed %s", feed.URL)
+	}
+	return nil
+}
+
+func (feed FeedConfig) refresh() time.Duration {
+	if feed.Refresh <= 0 {
+		return defaultFeedRefresh
+	}
+	return time.Millisecond * time.Duration(feed.Refresh)
+}
+
+func (feed FeedConfig) key() string {
+	return feed.Format + " " + feed.APIKey + " " + feed.URL
+}
+
+type feedEntry struct {
+	fetched  time.Time
+	fetching bool
+	nets     []*net.IPNet
+}
+
+// feeds caches the ranges of the feeds until they are refreshed
+var feeds = struct {
+	sync.Mutex
+	entries map[string]*feedEntry
+}{entries: map[string]*feedEntry{}}
+
+var feedClient = &http.Client{Timeout: feedFetchTimeout}
+
+// loadFeed returns the ranges of the feed. Feeds are fetched in the background when they are due and
+// until the first fetch finished, a feed has no ranges. If a fetch fails, the last ranges are kept.
+func loadFeed(feed FeedConfig) []*net.IPNet {
+	feeds.Lock()
+	defer feeds.Unlock()
+
+	entry, ok := feeds.entries[feed.key()]
+	if !ok {
+		entry = &feedEntry{}
+		feeds.entries[feed.key()] = entry
+	}
+	if !entry.fetching && time.Since(entry.fetched) >= feed.refresh() {
+		entry.fetching = true
+		go func() {
+			if err := refreshFeed(feed); err != nil {
+				log.Printf("[w] Can't refresh feed %s; error: %s", feed.URL, err)
+			}
+		}()
+	}
+	return entry.nets
+}
+
+// refreshFeed fetches the feed and replaces its cached ranges
+func refreshFeed(feed FeedConfig) error {
+	nets, err := fetchFeed(feed)
+
+	feeds.Lock()
+	defer feeds.Unlock()
+	entry, ok := feeds.entries[feed.key()]
+	if !ok {
+		entry = &feedEntry{}
+		feeds.entries[feed.key()] = entry
+	}
+	entry.fetching = false
+	// Failed fetches are retried after the refresh, too, so a broken feed isn't fetched for every connection
+	entry.fetched = time.Now()
+	if err != nil {
+		return err
+	}
+	entry.nets = nets
+	return nil
+}
+
+func fetchFeed(feed FeedConfig) ([]*net.IPNet, error) {
+	req, err := http.NewRequest(http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if feed.APIKey != "" {
+		req.Header.Set("Key", feed.APIKey)
+	}
+	if feed.Format == AbuseIPDBFeedFormat {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	res, err := feedClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed responded with %s", res.Status)
+	}
+
+	body := io.LimitReader(res.Body, maxFeedSize)
+	if feed.Format == AbuseIPDBFeedFormat {
+		return parseAbuseIPDBFeed(body)
+	}
+	return parsePlainFeed(body)
+}
+
+// parsePlainFeed parses a feed with one IP or CIDR range per line
+func parsePlainFeed(r io.Reader) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry := scanner.Text()
+		if i := strings.IndexAny(entry, "#;"); i >= 0 {
+			entry = entry[:i]
+		}
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+
+		ipNet, err := parseIPNet(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d; %s", line, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, scanner.Err()
+}
+
+// parseAbuseIPDBFeed parses a feed in the JSON format of the blacklist endpoint of AbuseIPDB
+func parseAbuseIPDBFeed(r io.Reader) ([]*net.IPNet, error) {
+	var feed struct {
+		Data []struct {
+			IPAddress string `json:"ipAddress"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, err
+	}
+	if feed.Data == nil {
+		return nil, errors.New("feed has no data")
+	}
+
+	nets := make([]*net.IPNet, 0, len(feed.Data))
+	for _, entry := range feed.Data {
+		ipNet, err := parseIPNet(entry.IPAddress)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func feedsContainIP(feeds []FeedConfig, ip net.IP) bool {
+	for _, feed := range feeds {
+		for _, ipNet := range loadFeed(feed) {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package infrared
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePlainFeed(t *testing.T) {
+	feed := "# FireHOL style\n1.10.16.0/20 ; SBL256894\n\n203.0.113.5\t# scanner\n2001:db8::/32\n"
+	nets, err := parsePlainFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 3 {
+		t.Fatalf("got %d ranges; want 3", len(nets))
+	}
+
+	if _, err := parsePlainFeed(strings.NewReader("<html>\n")); err == nil {
+		t.Error("got no error; want an error for a feed that isn't a list")
+	}
+}
+
+func TestRefreshFeed(t *testing.T) {
+	broken := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if broken {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/plain":
+			fmt.Fprintln(w, "198.51.100.0/24")
+		case "/abuseipdb":
+			if r.Header.Get("Key") != "key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"meta":{"generatedAt":"2026-10-14T00:00:00+00:00"},"data":[{"ipAddress":"192.0.2.7","abuseConfidenceScore":100}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := AccessListConfig{DenyFeeds: []FeedConfig{
+		{URL: server.URL + "/plain", Refresh: 3600000},
+		{URL: server.URL + "/abuseipdb", Format: AbuseIPDBFeedFormat, APIKey: "key", Refresh: 3600000},
+	}}
+	for _, feed := range cfg.DenyFeeds {
+		if err := refreshFeed(feed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tt := []struct {
+		ip      string
+		allowed bool
+	}{
+		{ip: "198.51.100.42", allowed: false},
+		{ip: "192.0.2.7", allowed: false},
+		{ip: "192.0.2.8", allowed: true},
+	}
+	for _, tc := range tt {
+		if allowed := cfg.allows(net.ParseIP(tc.ip)); allowed != tc.allowed {
+			t.Errorf("%s: got allowed %t; want %t", tc.ip, allowed, tc.allowed)
+		}
+	}
+
+	// A failed download keeps the last entries
+	broken = true
+	if err := refreshFeed(cfg.DenyFeeds[0]); err == nil {
+		t.Error("got no error; want an error for a broken feed")
+	}
+	if !feedsContainIP(cfg.DenyFeeds, net.ParseIP("198.51.100.42")) {
+		t.Error("got the entries dropped; want the entries of the last download kept")
+	}
+}
@@ -1,14 +1,17 @@
 package infrared
 
 import (
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
-
-	"github.com/haveachin/infrared/callback"
-	"github.com/haveachin/infrared/protocol/handshaking"
-	"github.com/pires/go-proxyproto"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -23,11 +26,74 @@ var (
 )
 
 type Gateway struct {
-	ReceiveProxyProtocol bool
-	listeners            sync.Map
-	Proxies              sync.Map
-	closed               chan bool
-	wg                   sync.WaitGroup
+	// ReceiveProxyProtocol requires a PROXY protocol header on all listeners and ProxyProtocolListeners
+	// only on the listeners of these listenTo keys, which are addresses or names of listeners.
+	// If ProxyProtocolTrusted isn't empty, only those IPs and CIDR ranges may send the headers.
+	ReceiveProxyProtocol   bool
+	ProxyProtocolListeners []string
+	ProxyProtocolTrusted   []string
+	// Listeners are the addresses of named listeners. The listenTo of a proxy can be
+	// the name of a listener instead of an address to bind the proxy to that listener.
+	Listeners map[string]string
+	// ReusePort opens Acceptors sockets with SO_REUSEPORT for every listen address on Linux. Every socket
+	// has its own accept loop, so the kernel spreads the connections of join floods across the cores.
+	// Acceptors defaults to GOMAXPROCS.
+	ReusePort bool
+	Acceptors int
+	// TLSListeners terminate TLS on the listeners of these listenTo keys with the TLSCertificates, which are
+	// picked by the SNI of the client. Their connections are routed by the SNI instead of the domain of the
+	// handshake and forwarded to the backends in plaintext.
+	TLSListeners    []string
+	TLSCertificates []tls.Certificate
+	tlsConfig       *tls.Config
+	// SocketOptions are the TCP options of all accepted connections and ListenerSocketOptions
+	// override them for the listeners of their listenTo keys.
+	SocketOptions         SocketOptions
+	ListenerSocketOptions map[string]SocketOptions
+	// AcceptLimits drop connections over their limits right after they are accepted.
+	// They limit the address of the connection, which is the one of the load balancer behind a PROXY protocol.
+	AcceptLimits  ConnLimitsConfig
+	acceptLimiter connectionLimiter
+	// Access rejects the clients that it denies on all listeners before anything but a PROXY protocol header is read
+	Access AccessListConfig
+	// GeoIPDatabase is the path of the MaxMind database that the GeoIP configs of the proxies look up
+	GeoIPDatabase string
+	// MaxConnsPerIP rejects new connections of an IP that has that many open connections,
+	// before anything is read. IPv6 addresses are counted per network of the IPv6ConnPrefix.
+	MaxConnsPerIP  int
+	IPv6ConnPrefix int
+	ipConns        ipConnCounter
+	// MalformedBans temporarily ban IPs that keep sending invalid PROXY protocol headers or handshakes.
+	// Banned IPs are closed right after they are accepted.
+	MalformedBans MalformedBanConfig
+	malformedBans strikeCounter
+	// HandshakeTimeout closes connections that didn't send their PROXY protocol header and handshake in time.
+	// LoginTimeout closes connections that didn't finish their status request or login start in time after that.
+	HandshakeTimeout time.Duration
+	LoginTimeout     time.Duration
+	// StrictProtocol rejects clients whose handshake or login start is out of spec before they reach a backend
+	StrictProtocol bool
+	// MaxPendingConns rejects new connections while that many connections didn't reach their backend yet
+	MaxPendingConns int
+	pendingConns    int32
+	// MaxBedrockSessions limits the sessions of every Bedrock listener; 0 uses DefaultMaxBedrockSessions
+	MaxBedrockSessions int
+	// BanExport exports the runtime bans, like the malformed handshake bans, to a file and to commands
+	BanExport BanExportConfig
+	bans      banList
+	// CrowdSec rejects the IPs that CrowdSec bans right after they are accepted and pushes the
+	// IPs over their accept limit and the malformed handshake bans to CrowdSec as alerts
+	CrowdSec  CrowdSecConfig
+	crowdSec  crowdSecBouncer
+	listeners sync.Map
+	Proxies   sync.Map
+	// draining are the removed proxies whose players didn't leave yet
+	draining sync.Map
+	wg       sync.WaitGroup
+	// Events publishes the events of the gateway and its proxies, which the metrics and the callback servers
+	// subscribe to. Programs that embed Infrared can subscribe their own handlers.
+	Events          EventBus
+	subscribeEvents sync.Once
 }
 
 func (gateway *Gateway) ListenAndServe(proxies []*Proxy) error {
@@ -35,7 +101,40 @@ func (gateway *Gateway) ListenAndServe(proxies []*Proxy) error {
 		return errors.New("no proxies in gateway")
 	}
 
-	gateway.closed = make(chan bool, len(proxies))
+	if err := gateway.Access.validate(); err != nil {
+		return fmt.Errorf("invalid access; %s", err)
+	}
+
+	if err := gateway.validateProxyProtocol(); err != nil {
+		return fmt.Errorf("invalid PROXY protocol config; %s", err)
+	}
+
+	if err := gateway.validateSocketOptions(); err != nil {
+		return fmt.Errorf("invalid socket options; %s", err)
+	}
+
+	if err := gateway.validateTLS(); err != nil {
+		return fmt.Errorf("invalid TLS config; %s", err)
+	}
+
+	if err := gateway.BanExport.validate(); err != nil {
+		return fmt.Errorf("invalid ban export; %s", err)
+	}
+	gateway.exportBans()
+	go gateway.expireBans()
+
+	if err := gateway.CrowdSec.validate(); err != nil {
+		return fmt.Errorf("invalid CrowdSec config; %s", err)
+	}
+	if gateway.CrowdSec.enabled() {
+		go gateway.runCrowdSec()
+	}
+
+	if gateway.GeoIPDatabase != "" {
+		if err := LoadGeoIPDatabase(gateway.GeoIPDatabase); err != nil {
+			return fmt.Errorf("could not load GeoIP database; %s", err)
+		}
+	}
 
 	for _, proxy := range proxies {
 		if err := gateway.RegisterProxy(proxy); err != nil {
@@ -44,6 +143,12 @@ func (gateway *Gateway) ListenAndServe(proxies []*Proxy) error {
 		}
 	}
 
+	proxyUIDs := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxyUIDs = append(proxyUIDs, proxy.UID())
+	}
+	gateway.events().Publish(GatewayStarted{ProxyUIDs: proxyUIDs})
+
 	log.Println("All proxies are online")
 	return nil
 }
@@ -69,58 +174,127 @@ func (gateway *Gateway) KeepProcessActive() {
 // Close closes all listeners
 func (gateway *Gateway) Close() {
 	gateway.listeners.Range(func(k, v interface{}) bool {
-		gateway.closed <- true
-		_ = v.(Listener).Close()
-		return false
+		_ = v.(io.Closer).Close()
+		return true
 	})
 }
 
 func (gateway *Gateway) CloseProxy(proxyUID string) {
+	v, ok := gateway.Proxies.Load(proxyUID)
+	if !ok {
+		return
+	}
+	proxy := v.(*Proxy)
+	gateway.closeProxy(proxyUID, proxy.ListenTo(), proxy.BedrockListenTo())
+}
+
+// closeProxy closes the proxy and the listeners of its addresses, if no other proxy uses them.
+// The addresses are passed separately, because the config of the proxy might already listen to other addresses.
+func (gateway *Gateway) closeProxy(proxyUID, listenTo, bedrockListenTo string) {
 	log.Println("Closing proxy with UID", proxyUID)
 	v, ok := gateway.Proxies.LoadAndDelete(proxyUID)
 	if !ok {
 		return
 	}
+	v.(*Proxy).stopHealthCheck()
 	proxiesActive.Dec()
+	gateway.closeListener(listenTo)
+	gateway.closeListener(bedrockListenTo)
+}
+
+// drainProxy closes the proxy like closeProxy, but keeps its listener open until its players left.
+// New logins to a draining proxy get its drain message.
+func (gateway *Gateway) drainProxy(proxyUID, listenTo, bedrockListenTo string) {
+	log.Println("Draining proxy with UID", proxyUID)
+	v, ok := gateway.Proxies.LoadAndDelete(proxyUID)
+	if !ok {
+		return
+	}
 	proxy := v.(*Proxy)
+	proxy.stopHealthCheck()
+	proxiesActive.Dec()
+
+	gateway.draining.Store(proxyUID, proxy)
+	proxy.startDrain(func() {
+		if v, ok := gateway.draining.Load(proxyUID); ok && v == proxy {
+			gateway.draining.Delete(proxyUID)
+		}
+		gateway.closeListener(listenTo)
+		gateway.closeListener(bedrockListenTo)
+	})
+}
 
-	closeListener := true
-	gateway.Proxies.Range(func(k, v interface{}) bool {
-		otherProxy := v.(*Proxy)
-		if proxy.ListenTo() == otherProxy.ListenTo() {
-			closeListener = false
+// closeListener closes the listener of the address, if no proxy uses or drains on it
+func (gateway *Gateway) closeListener(listenTo string) {
+	if listenTo == "" {
+		return
+	}
+
+	inUse := false
+	isOnListener := func(k, v interface{}) bool {
+		proxy := v.(*Proxy)
+		if listenTo == proxy.ListenTo() || listenTo == proxy.BedrockListenTo() {
+			inUse = true
 			return false
 		}
 		return true
-	})
+	}
+	gateway.Proxies.Range(isOnListener)
+	gateway.draining.Range(isOnListener)
 
-	if !closeListener {
+	if inUse {
 		return
 	}
 
-	v, ok = gateway.listeners.Load(proxy.ListenTo())
+	// The listener is removed right away, so that a proxy that registers next creates a new one
+	v, ok := gateway.listeners.LoadAndDelete(listenTo)
 	if !ok {
 		return
 	}
-	v.(Listener).Close()
+	v.(io.Closer).Close()
+}
+
+// listenAddr returns the address of the named listener or listenTo itself, if it is an address
+func (gateway *Gateway) listenAddr(listenTo string) (string, error) {
+	if addr, ok := gateway.Listeners[listenTo]; ok {
+		return addr, nil
+	}
+
+	if !strings.Contains(listenTo, ":") {
+		return "", fmt.Errorf("no listener named %s", listenTo)
+	}
+	return listenTo, nil
 }
 
 func (gateway *Gateway) RegisterProxy(proxy *Proxy) error {
 	// Register new Proxy
 	proxyUID := proxy.UID()
+	listenTo := proxy.ListenTo()
+	bedrockListenTo := proxy.BedrockListenTo()
 	log.Println("Registering proxy with UID", proxyUID)
 	gateway.Proxies.Store(proxyUID, proxy)
 	proxiesActive.Inc()
 
+	proxy.mu.Lock()
+	proxy.events = gateway.events()
+	proxy.mu.Unlock()
+
 	proxy.Config.removeCallback = func() {
-		gateway.CloseProxy(proxyUID)
+		gateway.drainProxy(proxyUID, listenTo, bedrockListenTo)
 	}
 
 	proxy.Config.changeCallback = func() {
-		if proxyUID == proxy.UID() {
+		gateway.events().Publish(ConfigReloaded{Proxy: proxy, ProxyUID: proxy.UID()})
+
+		// Open connections keep the backend they were dialed to; only new connections use the new backends
+		if n := proxy.balancer.connectionsNotTo(proxy.Backends()); n > 0 {
+			log.Printf("[i] %d connections of %s stay on their previous backends until they disconnect", n, proxyUID)
+		}
+
+		if proxyUID == proxy.UID() && bedrockListenTo == proxy.BedrockListenTo() {
 			return
 		}
-		gateway.CloseProxy(proxyUID)
+		gateway.closeProxy(proxyUID, listenTo, bedrockListenTo)
 		if err := gateway.RegisterProxy(proxy); err != nil {
 			log.Println(err)
 		}
@@ -128,23 +302,84 @@ func (gateway *Gateway) RegisterProxy(proxy *Proxy) error {
 
 	playersConnected.WithLabelValues(proxy.DomainName())
 
-	// Check if a gate is already listening to the Proxy address
-	addr := proxy.ListenTo()
-	if _, ok := gateway.listeners.Load(addr); ok {
+	key := proxy.ListenTo()
+	addr, err := gateway.listenAddr(key)
+	if err != nil {
+		return err
+	}
+
+	// Health checks ping backends like Java clients, so they don't work for Bedrock backends
+	if !isBedrockAddr(addr) {
+		proxy.startHealthCheck()
+	}
+
+	if err := gateway.listen(key); err != nil {
+		return err
+	}
+
+	// Proxies with a Bedrock address also get the Bedrock clients on their Bedrock listener
+	if bedrockListenTo != "" {
+		return gateway.listen(bedrockListenTo)
+	}
+	return nil
+}
+
+// listen creates the listener of the key, if there is none yet.
+// Listeners are stored by the listenTo of their proxies, which is either an address or a listener name.
+func (gateway *Gateway) listen(key string) error {
+	if _, ok := gateway.listeners.Load(key); ok {
 		return nil
 	}
 
+	addr, err := gateway.listenAddr(key)
+	if err != nil {
+		return err
+	}
+
+	if isBedrockAddr(addr) {
+		return gateway.listenBedrock(addr, key)
+	}
+	if isQUICAddr(addr) {
+		return gateway.listenQUIC(addr, key)
+	}
+
 	log.Println("Creating listener on", addr)
-	listener, err := Listen(addr)
+	sockets, err := gateway.listenSockets(addr)
+	if err != nil {
+		return err
+	}
+	var listener io.Closer = sockets[0]
+	if len(sockets) > 1 {
+		listener = socketGroup(sockets)
+	}
+	gateway.listeners.Store(key, listener)
+
+	for _, socket := range sockets {
+		gateway.wg.Add(1)
+		go func(socket net.Listener) {
+			if err := gateway.listenAndServe(Listener{Listener: socket}, key); err != nil {
+				log.Printf("Failed to listen on %s; error: %s", addr, err)
+			}
+		}(socket)
+	}
+	return nil
+}
+
+func (gateway *Gateway) listenBedrock(addr, key string) error {
+	log.Println("Creating Bedrock listener on", addr)
+	listener, err := listenBedrock(addr, key)
 	if err != nil {
 		return err
 	}
-	gateway.listeners.Store(addr, listener)
+	if gateway.MaxBedrockSessions > 0 {
+		listener.maxSessions = gateway.MaxBedrockSessions
+	}
+	gateway.listeners.Store(key, listener)
 
 	gateway.wg.Add(1)
 	go func() {
-		if err := gateway.listenAndServe(listener, addr); err != nil {
-			log.Printf("Failed to listen on %s; error: %s", proxy.ListenTo(), err)
+		if err := gateway.serveBedrock(listener); err != nil {
+			log.Printf("Failed to listen on %s; error: %s", addr, err)
 		}
 	}()
 	return nil
@@ -156,20 +391,54 @@ func (gateway *Gateway) listenAndServe(listener Listener, addr string) error {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			// TODO: Refactor this; it feels hacky
-			if err.Error() == "use of closed network connection" {
+			if errors.Is(err, net.ErrClosed) {
 				log.Println("Closing listener on", addr)
-				gateway.listeners.Delete(addr)
 				return nil
 			}
 
 			continue
 		}
 
+		// The options are set first, so a linger of 0 also resets the connections that are dropped
+		if err := gateway.listenerSocketOptions(addr).apply(conn); err != nil {
+			log.Printf("[w] Could not set the socket options of %s; error: %s", conn.RemoteAddr(), err)
+		}
+
+		if ok, limit := gateway.acceptLimiter.allowConnection(conn.RemoteAddr(), gateway.AcceptLimits, acceptStage); !ok {
+			if limit == "ip" {
+				ip, _, _ := splitIPAddr(unmapAddr(conn.RemoteAddr()))
+				gateway.reportCrowdSec(ip, CrowdSecFloodScenario, "connections over the accept limit")
+			}
+			conn.Close()
+			continue
+		}
+
+		if gateway.addrBanned(conn.RemoteAddr()) {
+			conn.Close()
+			continue
+		}
+
+		releaseIPConn, ok := gateway.acquireIPConn(conn.RemoteAddr())
+		if !ok {
+			conn.Close()
+			continue
+		}
+
+		pending, ok := gateway.acquirePending(conn)
+		if !ok {
+			releaseIPConn()
+			conn.Close()
+			continue
+		}
+
+		gateway.events().Publish(ConnectionAccepted{RemoteAddr: conn.RemoteAddr(), ListenTo: addr})
+
 		go func() {
+			defer releaseIPConn()
+			defer pending.release()
 			log.Printf("[>] Incoming %s on listener %s", conn.RemoteAddr(), addr)
 			defer conn.Close()
-			if err := gateway.serve(conn, addr); err != nil {
+			if err := gateway.serve(pending, addr); err != nil {
 				log.Printf("[x] %s closed connection with %s; error: %s", conn.RemoteAddr(), addr, err)
 				return
 			}
@@ -178,40 +447,168 @@ func (gateway *Gateway) listenAndServe(listener Listener, addr string) error {
 	}
 }
 
+// matchProxy returns the proxy of the domain on the address out of the proxies.
+// The matching proxy with the highest priority wins. Proxies of the same priority are ordered by their domains:
+// a proxy with exactly that domain comes first, then the wildcard domains like *.example.com
+// from the most to the least specific one and then the regular expression domains in the order of their UIDs.
+// A proxy with the domain * comes last and catches all domains that match no other proxy.
+func matchProxy(proxies *sync.Map, domain, addr string) (*Proxy, bool) {
+	var match *Proxy
+	for _, proxy := range matchingProxies(proxies, domain, addr) {
+		if match == nil || proxy.Priority() > match.Priority() {
+			match = proxy
+		}
+	}
+	return match, match != nil
+}
+
+// matchingProxies returns all proxies on the address that match the domain, ordered by their domains
+func matchingProxies(proxies *sync.Map, domain, addr string) []*Proxy {
+	var matches []*Proxy
+	domain = strings.ToLower(domain)
+	if v, ok := proxies.Load(proxyUID(domain, addr)); ok {
+		matches = append(matches, v.(*Proxy))
+	}
+
+	for parent := domain; ; {
+		i := strings.IndexByte(parent, '.')
+		if i < 0 || i == len(parent)-1 {
+			break
+		}
+		parent = parent[i+1:]
+
+		if v, ok := proxies.Load(proxyUID("*."+parent, addr)); ok {
+			matches = append(matches, v.(*Proxy))
+		}
+	}
+
+	matches = append(matches, matchRegexpProxies(proxies, domain, addr)...)
+
+	if v, ok := proxies.Load(proxyUID(catchAllDomain, addr)); ok {
+		matches = append(matches, v.(*Proxy))
+	}
+	return matches
+}
+
+func matchRegexpProxies(proxies *sync.Map, domain, addr string) []*Proxy {
+	var candidates []*Proxy
+	proxies.Range(func(k, v interface{}) bool {
+		proxy := v.(*Proxy)
+		if proxy.ListenTo() == addr && isDomainRegexp(proxy.DomainName()) {
+			candidates = append(candidates, proxy)
+		}
+		return true
+	})
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].UID() < candidates[j].UID()
+	})
+
+	var matches []*Proxy
+	for _, proxy := range candidates {
+		re, err := proxy.DomainRegexp()
+		if err != nil {
+			continue
+		}
+		if re.MatchString(domain) {
+			matches = append(matches, proxy)
+		}
+	}
+	return matches
+}
+
+// serveLegacyPing answers the legacy ping with the proxy of its domain. Pings of clients before 1.6
+// have no domain and are answered by the catch-all proxy of the listener.
+func (gateway *Gateway) serveLegacyPing(conn Conn, addr string, connRemoteAddr net.Addr) error {
+	ping, err := peekLegacyPing(conn.Reader())
+	if err != nil {
+		return err
+	}
+
+	proxyUID := proxyUID(ping.Domain, addr)
+	log.Printf("[i] %s sends legacy ping to proxy with UID %s", connRemoteAddr, proxyUID)
+	proxy, ok := matchProxy(&gateway.Proxies, ping.Domain, addr)
+	if !ok {
+		proxy, ok = matchProxy(&gateway.draining, ping.Domain, addr)
+	}
+	if !ok {
+		return errors.New("no proxy with uid " + proxyUID)
+	}
+	return proxy.handleLegacyPing(conn, ping, connRemoteAddr)
+}
+
 func (gateway *Gateway) serve(conn Conn, addr string) error {
-	connRemoteAddr := conn.RemoteAddr()
-	if gateway.ReceiveProxyProtocol {
-		header, err := proxyproto.Read(conn.Reader())
+	if err := setReadTimeout(conn, gateway.HandshakeTimeout); err != nil {
+		return err
+	}
+
+	connRemoteAddr := unmapAddr(conn.RemoteAddr())
+	if gateway.receivesProxyProtocol(addr) {
+		clientAddr, err := gateway.readProxyProtocol(conn, connRemoteAddr)
 		if err != nil {
+			if !errors.Is(err, errUntrustedProxyProtocol) {
+				gateway.strikeMalformed(connRemoteAddr, err)
+			}
 			return err
 		}
-		connRemoteAddr = header.SourceAddr
+		connRemoteAddr = clientAddr
+
+		if gateway.addrBanned(connRemoteAddr) {
+			return errors.New("the IP is banned")
+		}
 	}
 
-	pk, err := conn.PeekPacket()
+	if !gateway.Access.allowsAddr(connRemoteAddr) {
+		return errors.New("denied by the access list")
+	}
+
+	var sni string
+	if gateway.terminatesTLS(addr) {
+		var err error
+		if conn, sni, err = gateway.terminateTLS(conn); err != nil {
+			return err
+		}
+	}
+
+	if isLegacyPing(conn) {
+		return gateway.serveLegacyPing(conn, addr, connRemoteAddr)
+	}
+
+	hs, err := gateway.peekHandshake(conn)
 	if err != nil {
+		gateway.strikeMalformed(connRemoteAddr, err)
 		return err
 	}
 
-	hs, err := handshaking.UnmarshalServerBoundHandshake(pk)
-	if err != nil {
+	if err := setReadTimeout(conn, gateway.LoginTimeout); err != nil {
 		return err
 	}
 
-	proxyUID := proxyUID(hs.ParseServerAddress(), addr)
+	domain := hs.ParseServerAddress()
+	if sni != "" {
+		// Clients of TLS listeners are routed by the SNI, which the launcher or tunnel sets to the server
+		domain = sni
+	}
+	proxyUID := proxyUID(domain, addr)
 
 	log.Printf("[i] %s requests proxy with UID %s", connRemoteAddr, proxyUID)
-	v, ok := gateway.Proxies.Load(proxyUID)
+	proxy, ok := matchProxy(&gateway.Proxies, domain, addr)
+	if !ok {
+		// Removed proxies that still drain their players turn new players away
+		proxy, ok = matchProxy(&gateway.draining, domain, addr)
+	}
 	if !ok {
-		// Client send an invalid address/port; we don't have a v for that address
+		// Client send an invalid address/port; we don't have a proxy for that address
 		return errors.New("no proxy with uid " + proxyUID)
 	}
-	proxy := v.(*Proxy)
+	proxyUID = proxy.UID()
 
 	if err := proxy.handleConn(conn, connRemoteAddr); err != nil {
-		proxy.CallbackLogger().LogEvent(callback.ErrorEvent{
-			Error:    err.Error(),
-			ProxyUID: proxyUID,
+		proxy.publish(ConnectionFailed{
+			Proxy:      proxy,
+			ProxyUID:   proxyUID,
+			RemoteAddr: connRemoteAddr,
+			Err:        err,
 		})
 		return err
 	}
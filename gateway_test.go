@@ -2,11 +2,16 @@ package infrared
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/haveachin/infrared/protocol"
 	"github.com/haveachin/infrared/protocol/handshaking"
@@ -477,6 +482,7 @@ func TestRouting(t *testing.T) {
 	servers := []struct {
 		id      int
 		domain  string
+		proxyTo string
 		portEnd int
 	}{
 		{
@@ -499,6 +505,32 @@ func TestRouting(t *testing.T) {
 			domain:  ".dottedInfrared.",
 			portEnd: 530,
 		},
+		{
+			id:      3,
+			domain:  "*.wildcard.infrared",
+			portEnd: 530,
+		},
+		{
+			id:      4,
+			domain:  "*.deep.wildcard.infrared",
+			portEnd: 530,
+		},
+		{
+			id:      5,
+			domain:  "exact.wildcard.infrared",
+			portEnd: 530,
+		},
+		{
+			id:      6,
+			domain:  `^(?P<port>\d+)\.regex\.infrared$`,
+			proxyTo: ":{{port}}",
+			portEnd: 530,
+		},
+		{
+			id:      7,
+			domain:  "*",
+			portEnd: 531,
+		},
 	}
 
 	tt := []struct {
@@ -549,6 +581,78 @@ func TestRouting(t *testing.T) {
 			expectError:   true,
 			shouldMatch:   false,
 		},
+		{
+			name:          "Wildcard domain",
+			expectedId:    3,
+			requestDomain: "customer.wildcard.infrared",
+			portEnd:       530,
+			expectError:   false,
+			shouldMatch:   true,
+		},
+		{
+			name:          "Wildcard domain with multiple labels",
+			expectedId:    3,
+			requestDomain: "shop.customer.wildcard.infrared",
+			portEnd:       530,
+			expectError:   false,
+			shouldMatch:   true,
+		},
+		{
+			name:          "More specific wildcard domain",
+			expectedId:    4,
+			requestDomain: "customer.deep.wildcard.infrared",
+			portEnd:       530,
+			expectError:   false,
+			shouldMatch:   true,
+		},
+		{
+			name:          "Exact domain before wildcard domain",
+			expectedId:    5,
+			requestDomain: "Exact.Wildcard.Infrared",
+			portEnd:       530,
+			expectError:   false,
+			shouldMatch:   true,
+		},
+		{
+			name:          "Regex domain with capture in proxyTo",
+			expectedId:    6,
+			requestDomain: fmt.Sprintf("%d.regex.infrared", serverPort(basePort+7)), // port of the server with id 6
+			portEnd:       530,
+			expectError:   false,
+			shouldMatch:   true,
+		},
+		{
+			name:          "Regex domain that does not match",
+			expectedId:    6,
+			requestDomain: "other.regex.infrared",
+			portEnd:       530,
+			expectError:   true,
+			shouldMatch:   false,
+		},
+		{
+			name:          "Catch-all domain",
+			expectedId:    7,
+			requestDomain: "unknown.infrared",
+			portEnd:       531,
+			expectError:   false,
+			shouldMatch:   true,
+		},
+		{
+			name:          "Catch-all domain only on its address",
+			expectedId:    7,
+			requestDomain: "unknown.infrared",
+			portEnd:       530,
+			expectError:   true,
+			shouldMatch:   false,
+		},
+		{
+			name:          "Wildcard domain does not match its parent",
+			expectedId:    3,
+			requestDomain: "wildcard.infrared",
+			portEnd:       530,
+			expectError:   true,
+			shouldMatch:   false,
+		},
 	}
 
 	for i, server := range servers {
@@ -559,6 +663,9 @@ func TestRouting(t *testing.T) {
 		serverAddr := serverAddr(port)
 		proxyC.ListenTo = gatewayAddr(server.portEnd)
 		proxyC.ProxyTo = serverAddr
+		if server.proxyTo != "" {
+			proxyC.ProxyTo = server.proxyTo
+		}
 		proxyC.DomainName = server.domain
 		routingConfig = append(routingConfig, proxyC)
 
@@ -630,6 +737,510 @@ func TestRouting(t *testing.T) {
 	}
 }
 
+func TestMatchProxy_Priority(t *testing.T) {
+	var proxies sync.Map
+	for _, config := range []*ProxyConfig{
+		{DomainName: "exact.example.com", ListenTo: ":25565"},
+		{DomainName: "*.example.com", ListenTo: ":25565", Priority: 10},
+		{DomainName: `^.*\.example\.com$`, ListenTo: ":25565", Priority: 10},
+		{DomainName: "other.example.com", ListenTo: ":25565", Priority: 20},
+		{DomainName: "*", ListenTo: ":25565", Priority: 5},
+	} {
+		proxy := &Proxy{Config: config}
+		proxies.Store(proxy.UID(), proxy)
+	}
+
+	tt := []struct {
+		domain   string
+		expected string
+	}{
+		{domain: "exact.example.com", expected: "*.example.com"},
+		{domain: "other.example.com", expected: "other.example.com"},
+		{domain: "example.net", expected: "*"},
+	}
+
+	for _, tc := range tt {
+		proxy, ok := matchProxy(&proxies, tc.domain, ":25565")
+		if !ok {
+			t.Errorf("%s: got no proxy; want the proxy of %s", tc.domain, tc.expected)
+			continue
+		}
+		if proxy.DomainName() != tc.expected {
+			t.Errorf("%s: got the proxy of %s; want the proxy of %s", tc.domain, proxy.DomainName(), tc.expected)
+		}
+	}
+}
+
+func TestCatchAllWithoutBackend(t *testing.T) {
+	portEnd := 532
+	config := &ProxyConfig{
+		DomainName:    "*",
+		ListenTo:      gatewayAddr(portEnd),
+		OfflineStatus: statusPKWithVersion("unknown domain"),
+	}
+
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	receivedVersion, err := statusDial(statusDialConfig{
+		pk:          serverHandshake("unknown.infrared", gatewayPort(portEnd)),
+		gatewayAddr: gatewayAddr(portEnd),
+		dialerPort:  dialerPort(portEnd),
+	})
+	if err != nil {
+		t.Fatalf("%s: %v", err.Message, err.Error)
+	}
+	if receivedVersion != "unknown domain" {
+		t.Errorf("got version %s; want unknown domain", receivedVersion)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	portEnd := 539
+	errorCh := make(chan *testError, 1)
+	statusListen(statusListenerConfig{
+		addr:   serverAddr(portEnd),
+		status: statusPKWithVersion("backend"),
+	}, errorCh)
+
+	config := proxyConfigWithPortEnd(portEnd)
+	config.Maintenance = true
+	config.MaintenanceStatus = statusPKWithVersion("maintenance")
+
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	receivedVersion, err := statusDial(statusDialConfig{
+		pk:          statusHandshakePort(portEnd),
+		gatewayAddr: gatewayAddr(portEnd),
+		dialerPort:  dialerPort(portEnd),
+	})
+	if err != nil {
+		t.Fatalf("%s: %v", err.Message, err.Error)
+	}
+	if receivedVersion != "maintenance" {
+		t.Errorf("got version %s; want maintenance", receivedVersion)
+	}
+}
+
+func TestDisconnectComponent(t *testing.T) {
+	portEnd := 596
+	config := proxyConfigWithPortEnd(portEnd)
+	config.DisconnectComponent = []byte(`{"text":"{{username}} is offline","color":"red"}`)
+
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	conn, err := Dialer{}.Dial(gatewayAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	hs := handshaking.ServerBoundHandshake{
+		ProtocolVersion: 758,
+		ServerAddress:   protocol.String(serverDomain),
+		ServerPort:      protocol.UnsignedShort(gatewayPort(portEnd)),
+		NextState:       handshaking.ServerBoundHandshakeLoginState,
+	}
+	if err := conn.WritePacket(hs.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WritePacket(protocol.MarshalPacket(0x00, protocol.String(`no"tch`))); err != nil {
+		t.Fatal(err)
+	}
+
+	pk, err := conn.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"text":"no\"tch is offline","color":"red"}`
+	if !strings.Contains(string(pk.Data), expected) {
+		t.Errorf("got %q; want a disconnect with %s", pk.Data, expected)
+	}
+}
+
+func TestRejectTransfers(t *testing.T) {
+	portEnd := 537
+	config := proxyConfigWithPortEnd(portEnd)
+	config.Transfers = TransferConfig{
+		Policy:        RejectTransfers,
+		RejectMessage: "no transfers",
+	}
+
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	conn, err := Dialer{}.Dial(gatewayAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	hs := handshaking.ServerBoundHandshake{
+		ProtocolVersion: 766,
+		ServerAddress:   protocol.String(serverDomain),
+		ServerPort:      protocol.UnsignedShort(gatewayPort(portEnd)),
+		NextState:       handshaking.ServerBoundHandshakeTransferState,
+	}
+	if err := conn.WritePacket(hs.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WritePacket(protocol.MarshalPacket(0x00, protocol.String("notch"))); err != nil {
+		t.Fatal(err)
+	}
+
+	pk, err := conn.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(pk.Data), "no transfers") {
+		t.Errorf("got %q; want a disconnect with the reject message", pk.Data)
+	}
+}
+
+func TestSpoofForcedHandshake(t *testing.T) {
+	portEnd := 533
+	listener, err := Listen(serverAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	hsCh := make(chan handshaking.ServerBoundHandshake, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		pk, err := conn.ReadPacket()
+		if err != nil {
+			return
+		}
+		hs, _ := handshaking.UnmarshalServerBoundHandshake(pk)
+		hsCh <- hs
+	}()
+
+	config := proxyConfigWithPortEnd(portEnd)
+	config.SpoofForcedHost = "localhost"
+	config.SpoofForcedPort = 25565
+
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	conn, err := Dialer{}.Dial(gatewayAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := sendHandshake(conn, statusHandshakePort(portEnd)); err != nil {
+		t.Fatalf("%s: %v", err.Message, err.Error)
+	}
+
+	select {
+	case hs := <-hsCh:
+		if hs.ServerAddress != "localhost" || hs.ServerPort != 25565 {
+			t.Errorf("got %s:%d; want localhost:25565", hs.ServerAddress, hs.ServerPort)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("got no handshake")
+	}
+}
+
+func TestBackendHotSwap(t *testing.T) {
+	portEnd := 534
+	oldBackend, err := Listen(serverAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer oldBackend.Close()
+
+	go func() {
+		conn, err := oldBackend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if _, err := conn.ReadPacket(); err != nil {
+			return
+		}
+		io.Copy(conn, conn)
+	}()
+
+	errorCh := make(chan *testError, 1)
+	newBackendAddr := serverAddr(portEnd + 1)
+	statusListen(statusListenerConfig{
+		addr:   newBackendAddr,
+		status: statusPKWithVersion("new backend"),
+	}, errorCh)
+
+	config := proxyConfigWithPortEnd(portEnd)
+	proxy := &Proxy{Config: config}
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe([]*Proxy{proxy}); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	conn, err := Dialer{}.Dial(gatewayAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := sendHandshake(conn, statusHandshakePort(portEnd)); err != nil {
+		t.Fatalf("%s: %v", err.Message, err.Error)
+	}
+
+	echo := func(msg string) {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		bb := make([]byte, len(msg))
+		if _, err := io.ReadFull(conn, bb); err != nil {
+			t.Fatal(err)
+		}
+		if string(bb) != msg {
+			t.Fatalf("got %q; want %q from the old backend", bb, msg)
+		}
+	}
+	echo("before")
+
+	update := fmt.Sprintf(`{"domainName":%q,"listenTo":%q,"proxyTo":%q}`, config.DomainName, config.ListenTo, newBackendAddr)
+	if err := config.LoadFromBytes([]byte(update)); err != nil {
+		t.Fatal(err)
+	}
+	config.onConfigUpdate()
+
+	echo("after")
+
+	receivedVersion, testErr := statusDial(statusDialConfig{
+		pk:          statusHandshakePort(portEnd),
+		gatewayAddr: gatewayAddr(portEnd),
+		dialerPort:  dialerPort(portEnd),
+	})
+	if testErr != nil {
+		t.Fatalf("%s: %v", testErr.Message, testErr.Error)
+	}
+	if receivedVersion != "new backend" {
+		t.Errorf("got version %s; want the new backend", receivedVersion)
+	}
+}
+
+func TestDrainRemovedProxy(t *testing.T) {
+	portEnd := 538
+	backend, err := Listen(serverAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for i := 0; i < 2; i++ {
+			if _, err := conn.ReadPacket(); err != nil {
+				return
+			}
+		}
+		io.Copy(conn, conn)
+	}()
+
+	config := proxyConfigWithPortEnd(portEnd)
+	config.Drain = DrainConfig{Timeout: 200, Message: "draining"}
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	login := func() Conn {
+		conn, err := Dialer{}.Dial(gatewayAddr(portEnd))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hs := handshaking.ServerBoundHandshake{
+			ProtocolVersion: 758,
+			ServerAddress:   protocol.String(serverDomain),
+			ServerPort:      protocol.UnsignedShort(gatewayPort(portEnd)),
+			NextState:       handshaking.ServerBoundHandshakeLoginState,
+		}
+		if err := conn.WritePacket(hs.Marshal()); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.WritePacket(protocol.MarshalPacket(0x00, protocol.String("notch"))); err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	player := login()
+	defer player.Close()
+
+	echo := func(msg string) {
+		if _, err := player.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		bb := make([]byte, len(msg))
+		if _, err := io.ReadFull(player, bb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	echo("before")
+
+	config.removeCallback()
+
+	newPlayer := login()
+	defer newPlayer.Close()
+	pk, err := newPlayer.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(pk.Data), "draining") {
+		t.Errorf("got %q; want a disconnect with the drain message", pk.Data)
+	}
+
+	echo("during")
+
+	player.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := player.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("got %v; want the player to be disconnected after the drain timeout", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := gateway.listeners.Load(config.ListenTo); ok {
+		t.Error("got listener; want it to be closed after the proxy drained")
+	}
+}
+
+func TestProxy_FinishDrain_StopsTimer(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		player, other := net.Pipe()
+		conn := wrapConn(player)
+		proxy := &Proxy{Config: &ProxyConfig{Drain: DrainConfig{Timeout: 60000}}}
+		proxy.addPlayer(conn, "notch")
+
+		drainedCh := make(chan struct{})
+		go proxy.startDrain(func() { close(drainedCh) })
+		for !proxy.draining() {
+			runtime.Gosched()
+		}
+		proxy.removePlayer(conn)
+		proxy.finishDrain()
+
+		<-drainedCh
+		proxy.mu.Lock()
+		timer := proxy.drain.timer
+		proxy.mu.Unlock()
+		if timer.Stop() {
+			t.Fatal("got a running drain timer; want it to be stopped once the proxy drained")
+		}
+		player.Close()
+		other.Close()
+	}
+}
+
+func TestNamedListeners(t *testing.T) {
+	portEnd := 536
+	errorCh := make(chan *testError, 1)
+	statusListen(statusListenerConfig{
+		addr:   serverAddr(portEnd),
+		status: statusPKWithVersion("staging"),
+	}, errorCh)
+
+	config := proxyConfigWithPortEnd(portEnd)
+	config.ListenTo = "staging"
+
+	gateway := Gateway{Listeners: map[string]string{"staging": gatewayAddr(portEnd)}}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	receivedVersion, err := statusDial(statusDialConfig{
+		pk:          statusHandshakePort(portEnd),
+		gatewayAddr: gatewayAddr(portEnd),
+		dialerPort:  dialerPort(portEnd),
+	})
+	if err != nil {
+		t.Fatalf("%s: %v", err.Message, err.Error)
+	}
+	if receivedVersion != "staging" {
+		t.Errorf("got version %s; want staging", receivedVersion)
+	}
+
+	unknown := proxyConfigWithPortEnd(portEnd)
+	unknown.ListenTo = "unknown"
+	if err := gateway.RegisterProxy(&Proxy{Config: unknown}); err == nil {
+		t.Error("got no error; want an error for an unknown listener")
+	}
+}
+
 func TestProxyBind(t *testing.T) {
-	// TODO: Figure out a way to test this
+	var loopback string
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+		}
+	}
+	if loopback == "" {
+		t.Skip("no loopback interface")
+	}
+
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, proxyBind := range []string{"127.0.0.1", loopback} {
+		cfg := &ProxyConfig{ProxyBind: proxyBind, Timeout: 1000}
+		dialer, err := cfg.Dialer()
+		if err != nil {
+			t.Fatalf("%s: %s", proxyBind, err)
+		}
+		rconn, err := dialer.Dial(l.Addr().String())
+		if err != nil {
+			t.Fatalf("%s: %s", proxyBind, err)
+		}
+
+		backend, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ip, _, _ := splitIPAddr(backend.RemoteAddr()); !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Errorf("%s: got connection from %s; want it from 127.0.0.1", proxyBind, ip)
+		}
+		backend.Close()
+		rconn.Close()
+	}
+
+	if _, err := bindIP("no-such-interface"); err == nil {
+		t.Error("got no error; want an error for an unknown interface")
+	}
 }
@@ -0,0 +1,189 @@
+package infrared
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIPConfig allows, denies and routes Java clients by the country of their IP in the GeoIP database.
+// Entries are ISO country codes like DE or continent codes like EU. Denied clients are always rejected and
+// if the allow list isn't empty, only the clients of its countries are allowed. Clients without a known country,
+// like clients with private IPs, are only allowed if the allow list is empty.
+type GeoIPConfig struct {
+	Allow  []string       `json:"allow"`
+	Deny   []string       `json:"deny"`
+	Routes []CountryRoute `json:"routes"`
+}
+
+// CountryRoute sends clients of one of the Countries to its own backend.
+// Countries are ISO country codes like DE or continent codes like EU.
+type CountryRoute struct {
+	Countries []string `json:"countries"`
+	ProxyTo   string   `json:"proxyTo"`
+}
+
+// geoLocation is the country and continent of an IP
+type geoLocation struct {
+	Country   string
+	Continent string
+}
+
+// in reports whether the location is in one of the countries or continents
+func (location geoLocation) in(codes []string) bool {
+	for _, code := range codes {
+		if location.Country != "" && strings.EqualFold(code, location.Country) {
+			return true
+		}
+		if location.Continent != "" && strings.EqualFold(code, location.Continent) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg GeoIPConfig) validate() error {
+	codes := append(append([]string(nil), cfg.Allow...), cfg.Deny...)
+	for i, route := range cfg.Routes {
+		if route.ProxyTo == "" {
+			return fmt.Errorf("route %d has no proxyTo", i)
+		}
+		codes = append(codes, route.Countries...)
+	}
+
+	for _, code := range codes {
+		if len(code) != 2 {
+			return fmt.Errorf("%q is no country or continent code", code)
+		}
+	}
+	return nil
+}
+
+// allows reports whether the client of the location is allowed
+func (cfg GeoIPConfig) allows(location geoLocation) bool {
+	if location.in(cfg.Deny) {
+		return false
+	}
+	return len(cfg.Allow) == 0 || location.in(cfg.Allow)
+}
+
+// enabled reports whether the config needs the location of clients
+func (cfg GeoIPConfig) enabled() bool {
+	return len(cfg.Allow) > 0 || len(cfg.Deny) > 0 || len(cfg.Routes) > 0
+}
+
+// geoIPCheckInterval is how often the GeoIP database is checked for changes
+var geoIPCheckInterval = time.Minute
+
+// geoIP is the GeoIP database of all proxies
+var geoIP = struct {
+	sync.Mutex
+	reader  *maxminddb.Reader
+	path    string
+	modTime time.Time
+	checked time.Time
+}{}
+
+// LoadGeoIPDatabase opens the MaxMind database, like GeoLite2-Country.mmdb, that the GeoIP configs
+// of all proxies look up. The database is opened again when the file changes, like after an update.
+func LoadGeoIPDatabase(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return err
+	}
+
+	geoIP.Lock()
+	defer geoIP.Unlock()
+	if geoIP.reader != nil {
+		geoIP.reader.Close()
+	}
+	geoIP.reader, geoIP.path, geoIP.modTime, geoIP.checked = reader, path, info.ModTime(), time.Now()
+	return nil
+}
+
+// reloadGeoIPDatabase opens the GeoIP database again, if its file changed since it was opened
+func reloadGeoIPDatabase() {
+	geoIP.Lock()
+	path, modTime := geoIP.path, geoIP.modTime
+	if path == "" || time.Since(geoIP.checked) < geoIPCheckInterval {
+		geoIP.Unlock()
+		return
+	}
+	geoIP.checked = time.Now()
+	geoIP.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil || info.ModTime().Equal(modTime) {
+		return
+	}
+
+	if err := LoadGeoIPDatabase(path); err != nil {
+		log.Printf("[w] Keeping the last GeoIP database; error: %s", err)
+		return
+	}
+	log.Println("[i] Reloaded the GeoIP database", path)
+}
+
+// lookupLocation returns the location of the IP in the GeoIP database. It's a variable, so tests don't need a database.
+var lookupLocation = func(ip net.IP) geoLocation {
+	reloadGeoIPDatabase()
+
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		Continent struct {
+			Code string `maxminddb:"code"`
+		} `maxminddb:"continent"`
+	}
+
+	geoIP.Lock()
+	reader := geoIP.reader
+	geoIP.Unlock()
+	if reader == nil {
+		return geoLocation{}
+	}
+
+	if err := reader.Lookup(ip, &record); err != nil {
+		return geoLocation{}
+	}
+	return geoLocation{
+		Country:   record.Country.ISOCode,
+		Continent: record.Continent.Code,
+	}
+}
+
+func (proxy *Proxy) GeoIP() GeoIPConfig {
+	if profile := proxy.protectionProfile(); profile.GeoIP != nil {
+		return *profile.GeoIP
+	}
+
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.GeoIP
+}
+
+// clientLocation returns the location of the client with the address, if the GeoIP config or the localizations
+// of the proxy need it
+func (proxy *Proxy) clientLocation(addr net.Addr) geoLocation {
+	if !proxy.GeoIP().enabled() && len(proxy.Localizations()) == 0 {
+		return geoLocation{}
+	}
+
+	ip, _, ok := splitIPAddr(unmapAddr(addr))
+	if !ok {
+		return geoLocation{}
+	}
+	return lookupLocation(ip)
+}
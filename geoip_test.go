@@ -0,0 +1,90 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+
+	"github.com/haveachin/infrared/protocol/handshaking"
+)
+
+func TestGeoIPConfig_Allows(t *testing.T) {
+	de := geoLocation{Country: "DE", Continent: "EU"}
+	us := geoLocation{Country: "US", Continent: "NA"}
+
+	tt := []struct {
+		name     string
+		geoIP    GeoIPConfig
+		location geoLocation
+		allowed  bool
+	}{
+		{name: "empty", location: de, allowed: true},
+		{name: "denied country", geoIP: GeoIPConfig{Deny: []string{"de"}}, location: de, allowed: false},
+		{name: "denied continent", geoIP: GeoIPConfig{Deny: []string{"EU"}}, location: de, allowed: false},
+		{name: "other country", geoIP: GeoIPConfig{Deny: []string{"DE"}}, location: us, allowed: true},
+		{name: "allowed", geoIP: GeoIPConfig{Allow: []string{"EU"}}, location: de, allowed: true},
+		{name: "not allowed", geoIP: GeoIPConfig{Allow: []string{"EU"}}, location: us, allowed: false},
+		{name: "deny wins", geoIP: GeoIPConfig{Allow: []string{"EU"}, Deny: []string{"DE"}}, location: de, allowed: false},
+		{name: "unknown denied", geoIP: GeoIPConfig{Allow: []string{"EU"}}, allowed: false},
+		{name: "unknown", geoIP: GeoIPConfig{Deny: []string{"EU"}}, allowed: true},
+	}
+
+	for _, tc := range tt {
+		if allowed := tc.geoIP.allows(tc.location); allowed != tc.allowed {
+			t.Errorf("%s: got %t; want %t", tc.name, allowed, tc.allowed)
+		}
+	}
+}
+
+func TestGeoIPConfig_Validate(t *testing.T) {
+	valid := GeoIPConfig{
+		Allow:  []string{"DE", "eu"},
+		Routes: []CountryRoute{{Countries: []string{"AU"}, ProxyTo: ":25566"}},
+	}
+	if err := valid.validate(); err != nil {
+		t.Errorf("got %v; want valid codes", err)
+	}
+	if err := (GeoIPConfig{Deny: []string{"Germany"}}).validate(); err == nil {
+		t.Error("got no error; want an invalid code")
+	}
+	if err := (GeoIPConfig{Routes: []CountryRoute{{Countries: []string{"DE"}}}}).validate(); err == nil {
+		t.Error("got no error; want a route without proxyTo")
+	}
+}
+
+func TestProxy_RouteBackends_Countries(t *testing.T) {
+	defer func(lookup func(net.IP) geoLocation) { lookupLocation = lookup }(lookupLocation)
+	lookupLocation = func(ip net.IP) geoLocation {
+		if ip.Equal(net.ParseIP("203.0.113.5")) {
+			return geoLocation{Country: "DE", Continent: "EU"}
+		}
+		return geoLocation{}
+	}
+
+	proxy := &Proxy{Config: &ProxyConfig{
+		ProxyTo: ":25565",
+		GeoIP: GeoIPConfig{
+			Routes: []CountryRoute{{Countries: []string{"EU"}, ProxyTo: ":25566"}},
+		},
+	}}
+
+	tt := []struct {
+		addr    string
+		backend string
+	}{
+		{addr: "203.0.113.5:50000", backend: ":25566"},
+		{addr: "198.51.100.1:50000", backend: ":25565"},
+	}
+
+	for _, tc := range tt {
+		addr, err := net.ResolveTCPAddr("tcp", tc.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hs := handshaking.ServerBoundHandshake{ProtocolVersion: 766, NextState: handshaking.ServerBoundHandshakeLoginState}
+		backends, _ := proxy.routeBackends(hs, "", proxy.clientLocation(addr))
+		if len(backends) != 1 || backends[0] != tc.backend {
+			t.Errorf("%s: got %v; want [%s]", tc.addr, backends, tc.backend)
+		}
+	}
+}
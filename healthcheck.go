@@ -0,0 +1,205 @@
+package infrared
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+)
+
+// healthCheckPollInterval is how often a proxy without health checks looks for them to be configured
+const healthCheckPollInterval = time.Second
+
+// backendHealth holds the backends of a proxy that failed their last health check
+type backendHealth struct {
+	mu        sync.Mutex
+	unhealthy map[string]bool
+	stop      chan struct{}
+}
+
+func (health *backendHealth) isHealthy(addr string) bool {
+	health.mu.Lock()
+	defer health.mu.Unlock()
+	return !health.unhealthy[addr]
+}
+
+// setHealthy updates the health of the addr and reports whether it changed
+func (health *backendHealth) setHealthy(addr string, healthy bool) bool {
+	health.mu.Lock()
+	defer health.mu.Unlock()
+	if health.unhealthy == nil {
+		health.unhealthy = map[string]bool{}
+	}
+
+	if healthy {
+		if !health.unhealthy[addr] {
+			return false
+		}
+		delete(health.unhealthy, addr)
+		return true
+	}
+
+	if health.unhealthy[addr] {
+		return false
+	}
+	health.unhealthy[addr] = true
+	return true
+}
+
+func (health *backendHealth) reset() {
+	health.mu.Lock()
+	defer health.mu.Unlock()
+	health.unhealthy = nil
+}
+
+// startHealthCheck pings the backends of the proxy in the configured interval until stopHealthCheck is called
+func (proxy *Proxy) startHealthCheck() {
+	proxy.health.mu.Lock()
+	if proxy.health.stop != nil {
+		proxy.health.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	proxy.health.stop = stop
+	proxy.health.mu.Unlock()
+
+	go func() {
+		for {
+			interval := proxy.HealthCheckInterval()
+			if interval <= 0 {
+				proxy.health.reset()
+				interval = healthCheckPollInterval
+			} else {
+				proxy.checkBackends()
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+func (proxy *Proxy) stopHealthCheck() {
+	proxy.health.mu.Lock()
+	defer proxy.health.mu.Unlock()
+	if proxy.health.stop == nil {
+		return
+	}
+	close(proxy.health.stop)
+	proxy.health.stop = nil
+}
+
+func (proxy *Proxy) checkBackends() {
+	timeout := proxy.HealthCheckTimeout()
+	dialer := &Dialer{}
+	if d, err := proxy.Dialer(); err == nil {
+		dialer = d
+	}
+
+	for _, addr := range proxy.Backends() {
+		if strings.Contains(addr, "{{") {
+			// Templated addresses depend on the domain of a connection
+			continue
+		}
+
+		err := pingBackend(*dialer, addr, timeout, proxy.ProxyProtocol())
+		if !proxy.health.setHealthy(addr, err == nil) {
+			continue
+		}
+
+		if err != nil {
+			log.Printf("[w] Backend %s of %s is unhealthy; error: %s", addr, proxy.UID(), err)
+			proxy.publish(BackendDown{
+				Proxy:       proxy,
+				ProxyUID:    proxy.UID(),
+				BackendAddr: addr,
+				Err:         err,
+			})
+			continue
+		}
+
+		log.Printf("[i] Backend %s of %s is healthy again", addr, proxy.UID())
+		proxy.publish(BackendUp{
+			Proxy:       proxy,
+			ProxyUID:    proxy.UID(),
+			BackendAddr: addr,
+		})
+	}
+}
+
+// availableBackends returns the healthy backends of the proxy. If all backends are unhealthy,
+// the failover address is returned or all backends if there is no failover address.
+func (proxy *Proxy) availableBackends() []string {
+	backends := proxy.Backends()
+	healthy := make([]string, 0, len(backends))
+	for _, addr := range backends {
+		if proxy.health.isHealthy(addr) {
+			healthy = append(healthy, addr)
+		}
+	}
+
+	if len(healthy) > 0 {
+		return healthy
+	}
+
+	if failoverTo := proxy.FailoverTo(); failoverTo != "" {
+		return []string{failoverTo}
+	}
+	return backends
+}
+
+// pingBackend sends a server list ping to the addr and waits for its status response.
+// Backends that expect the PROXY protocol get a LOCAL header first.
+func pingBackend(dialer Dialer, addr string, timeout time.Duration, proxyProtocol bool) error {
+	addr, err := resolveBackend(addr)
+	if err != nil {
+		return err
+	}
+
+	dialer.Timeout = timeout
+	rconn, err := dialer.Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer rconn.Close()
+
+	if err := rconn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	if proxyProtocol {
+		if _, err := localProxyProtocolHeader().WriteTo(rconn); err != nil {
+			return err
+		}
+	}
+
+	host, port := "localhost", 25565
+	if !strings.HasPrefix(addr, UnixScheme) {
+		var portStr string
+		host, portStr, err = net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		port, err = strconv.Atoi(portStr)
+		if err != nil {
+			return err
+		}
+	}
+
+	hs := handshaking.ServerBoundHandshake{
+		ProtocolVersion: -1,
+		ServerAddress:   protocol.String(host),
+		ServerPort:      protocol.UnsignedShort(port),
+		NextState:       handshaking.ServerBoundHandshakeStatusState,
+	}
+	_, err = requestStatus(rconn, hs)
+	return err
+}
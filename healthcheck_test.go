@@ -0,0 +1,147 @@
+package infrared
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/pires/go-proxyproto"
+)
+
+func TestPingBackend(t *testing.T) {
+	listener, err := Listen(serverAddr(560))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for i := 0; i < 2; i++ {
+			if _, err := conn.ReadPacket(); err != nil {
+				return
+			}
+		}
+		pk, _ := statusPKWithVersion("healthy").StatusResponsePacket()
+		conn.WritePacket(pk)
+	}()
+
+	if err := pingBackend(Dialer{}, "127.0.0.1"+serverAddr(560), time.Second, false); err != nil {
+		t.Errorf("got error %v; want healthy backend", err)
+	}
+
+	if err := pingBackend(Dialer{}, "127.0.0.1"+serverAddr(561), time.Second, false); err == nil {
+		t.Error("got no error; want unhealthy backend")
+	}
+}
+
+func TestPingBackend_ProxyProtocol(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	headerCh := make(chan *proxyproto.Header, 1)
+	go func() {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		reader := bufio.NewReader(c)
+		header, err := proxyproto.Read(reader)
+		headerCh <- header
+		if err != nil {
+			return
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := protocol.ReadPacket(reader); err != nil {
+				return
+			}
+		}
+		pk, _ := statusPKWithVersion("healthy").StatusResponsePacket()
+		wrapConn(c).WritePacket(pk)
+	}()
+
+	if err := pingBackend(Dialer{}, listener.Addr().String(), time.Second, true); err != nil {
+		t.Errorf("got error %v; want healthy backend", err)
+	}
+	if header := <-headerCh; header == nil || header.Command != proxyproto.LOCAL {
+		t.Errorf("got header %v; want a header with the LOCAL command", header)
+	}
+}
+
+func TestProxy_AvailableBackends(t *testing.T) {
+	proxy := &Proxy{Config: &ProxyConfig{
+		Backends: []string{":25566", ":25567"},
+	}}
+
+	proxy.health.setHealthy(":25566", false)
+	if got := proxy.availableBackends(); len(got) != 1 || got[0] != ":25567" {
+		t.Errorf("got %v; want [:25567]", got)
+	}
+
+	proxy.health.setHealthy(":25567", false)
+	if got := proxy.availableBackends(); len(got) != 2 {
+		t.Errorf("got %v; want all backends without failover", got)
+	}
+
+	proxy.Config.FailoverTo = ":25568"
+	if got := proxy.availableBackends(); len(got) != 1 || got[0] != ":25568" {
+		t.Errorf("got %v; want [:25568]", got)
+	}
+
+	if !proxy.health.setHealthy(":25566", true) {
+		t.Error("got no health change; want healthy again")
+	}
+	if got := proxy.availableBackends(); len(got) != 1 || got[0] != ":25566" {
+		t.Errorf("got %v; want [:25566]", got)
+	}
+}
+
+func TestPingBackend_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "minecraft.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn := wrapConn(c)
+		defer conn.Close()
+
+		for i := 0; i < 2; i++ {
+			if _, err := conn.ReadPacket(); err != nil {
+				return
+			}
+		}
+		pk, _ := statusPKWithVersion("healthy").StatusResponsePacket()
+		conn.WritePacket(pk)
+	}()
+
+	if err := pingBackend(Dialer{}, UnixScheme+path, time.Second, false); err != nil {
+		t.Errorf("got error %v; want healthy backend", err)
+	}
+}
+
+func TestProxyConfig_HealthCheckTimeoutZero(t *testing.T) {
+	var cfg ProxyConfig
+	if err := cfg.LoadFromBytes([]byte(`{"proxyTo":":25566","healthCheck":{"interval":1000,"timeout":0}}`)); err == nil {
+		t.Error("got no error; want a timeout of 0 to be rejected")
+	}
+}
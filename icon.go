@@ -0,0 +1,173 @@
+package infrared
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// iconSize is the width and height of server icons in pixels
+const iconSize = 64
+
+const faviconPrefix = "data:image/png;base64,"
+
+type iconCacheEntry struct {
+	modTime time.Time
+	size    int64
+	favicon string
+}
+
+// iconCache caches the favicons of icon files until the files change and the favicons of base64 icons
+var iconCache = struct {
+	sync.Mutex
+	entries map[string]iconCacheEntry
+}{entries: map[string]iconCacheEntry{}}
+
+// loadIconFile returns the favicon of the PNG file. The file is read again when it changes.
+func loadIconFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	iconCache.Lock()
+	entry, ok := iconCache.entries[path]
+	iconCache.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.favicon, nil
+	}
+
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	favicon, err := encodeFavicon(bb)
+	if err != nil {
+		return "", fmt.Errorf("invalid icon %s; %s", path, err)
+	}
+
+	iconCache.Lock()
+	iconCache.entries[path] = iconCacheEntry{modTime: info.ModTime(), size: info.Size(), favicon: favicon}
+	iconCache.Unlock()
+	return favicon, nil
+}
+
+// parseIcon returns the favicon of a base64 encoded PNG, which can also be a data URI
+func parseIcon(icon string) (string, error) {
+	iconCache.Lock()
+	entry, ok := iconCache.entries[icon]
+	iconCache.Unlock()
+	if ok {
+		return entry.favicon, nil
+	}
+
+	bb, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(icon, faviconPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	favicon, err := encodeFavicon(bb)
+	if err != nil {
+		return "", err
+	}
+
+	iconCache.Lock()
+	iconCache.entries[icon] = iconCacheEntry{favicon: favicon}
+	iconCache.Unlock()
+	return favicon, nil
+}
+
+// encodeFavicon validates the PNG and returns it as a favicon data URI.
+// Icons that aren't 64x64 pixels are resized.
+func encodeFavicon(bb []byte) (string, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(bb))
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.Width != iconSize || cfg.Height != iconSize {
+		img, err := png.Decode(bytes.NewReader(bb))
+		if err != nil {
+			return "", err
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, resizeIcon(img)); err != nil {
+			return "", err
+		}
+		bb = buf.Bytes()
+	}
+
+	return faviconPrefix + base64.StdEncoding.EncodeToString(bb), nil
+}
+
+// resizeIcon scales the image to 64x64 pixels. Every pixel is the average of the pixels of the image that it covers.
+func resizeIcon(img image.Image) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, iconSize, iconSize))
+	if w == 0 || h == 0 {
+		return dst
+	}
+
+	for y := 0; y < iconSize; y++ {
+		y0, y1 := y*h/iconSize, (y+1)*h/iconSize
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < iconSize; x++ {
+			x0, x1 := x*w/iconSize, (x+1)*w/iconSize
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(img.At(bounds.Min.X+sx, bounds.Min.Y+sy)).(color.NRGBA64)
+					r, g, b, a = r+uint64(c.R), g+uint64(c.G), b+uint64(c.B), a+uint64(c.A)
+					n++
+				}
+			}
+			dst.Set(x, y, color.NRGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// favicon returns the favicon of the status from its icon or its icon file
+func (cfg StatusConfig) favicon() (string, error) {
+	switch {
+	case cfg.Icon != "":
+		return parseIcon(cfg.Icon)
+	case cfg.IconPath != "":
+		return loadIconFile(cfg.IconPath)
+	}
+	return "", nil
+}
+
+// validateIcon checks that the base64 icon of the status is a PNG
+func (cfg StatusConfig) validateIcon() error {
+	if cfg.Icon == "" {
+		return nil
+	}
+	if _, err := parseIcon(cfg.Icon); err != nil {
+		return errors.New("icon is no base64 encoded PNG")
+	}
+	return nil
+}
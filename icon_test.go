@@ -0,0 +1,110 @@
+package infrared
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPNG(t *testing.T, size int, c color.Color) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeFavicon(t *testing.T, favicon string) image.Image {
+	if !strings.HasPrefix(favicon, faviconPrefix) {
+		t.Fatalf("got %.30s; want a PNG data URI", favicon)
+	}
+
+	bb, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(favicon, faviconPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(bb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestEncodeFavicon_Resizes(t *testing.T) {
+	for _, size := range []int{16, 64, 128} {
+		favicon, err := encodeFavicon(testPNG(t, size, color.NRGBA{R: 255, A: 255}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		img := decodeFavicon(t, favicon)
+		if img.Bounds().Dx() != iconSize || img.Bounds().Dy() != iconSize {
+			t.Errorf("got %v for a %dx%d icon; want 64x64", img.Bounds(), size, size)
+		}
+		if r, _, _, _ := img.At(10, 10).RGBA(); r>>8 != 255 {
+			t.Errorf("got red %d for a %dx%d icon; want 255", r>>8, size, size)
+		}
+	}
+
+	if _, err := encodeFavicon([]byte("no png")); err == nil {
+		t.Error("got no error; want an invalid PNG")
+	}
+}
+
+func TestParseIcon(t *testing.T) {
+	icon := base64.StdEncoding.EncodeToString(testPNG(t, 64, color.White))
+	for _, i := range []string{icon, faviconPrefix + icon} {
+		if _, err := parseIcon(i); err != nil {
+			t.Errorf("got %v; want a valid icon", err)
+		}
+	}
+
+	if err := (StatusConfig{Icon: "bm8gcG5n"}).validateIcon(); err == nil {
+		t.Error("got no error; want an invalid icon")
+	}
+}
+
+func TestLoadIconFile_Reloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "icon.png")
+	if err := os.WriteFile(path, testPNG(t, 64, color.White), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := loadIconFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, testPNG(t, 32, color.Black), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := loadIconFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("got the same icon; want the changed file to be reloaded")
+	}
+	if r, _, _, _ := decodeFavicon(t, second).At(0, 0).RGBA(); r != 0 {
+		t.Errorf("got red %d; want the black icon", r)
+	}
+}
@@ -0,0 +1,59 @@
+package infrared
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var idleConnsReaped = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "infrared_idle_conns_reaped",
+	Help: "The total number of piped connections that were closed because the client or the backend was idle",
+}, []string{"host", "side"})
+
+// IdleTimeoutConfig closes piped connections, if the client or the backend didn't send anything for their timeout
+// in milliseconds, like after a NAT dropped the connection. Players send keep alive responses every 15 seconds,
+// so a client timeout of 30 seconds only reaps dead connections. A timeout of 0 is disabled.
+type IdleTimeoutConfig struct {
+	Client  int `json:"client"`
+	Backend int `json:"backend"`
+}
+
+func (cfg IdleTimeoutConfig) validate() error {
+	if cfg.Client < 0 || cfg.Backend < 0 {
+		return errors.New("negative timeout")
+	}
+	return nil
+}
+
+func (proxy *Proxy) IdleTimeout() IdleTimeoutConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.IdleTimeout
+}
+
+// pipeIdle pipes the client and the backend until one of them closes the connection or is idle for its timeout
+func (proxy *Proxy) pipeIdle(conn, rconn Conn, host string) {
+	idleTimeout := proxy.IdleTimeout()
+
+	go func() {
+		if pipe(rconn, conn, time.Duration(idleTimeout.Backend)*time.Millisecond) {
+			idleConnsReaped.With(prometheus.Labels{"host": host, "side": "backend"}).Inc()
+			// Closing the client stops the pipe, which waits for the client
+			conn.Close()
+		}
+	}()
+
+	if pipe(conn, rconn, time.Duration(idleTimeout.Client)*time.Millisecond) {
+		idleConnsReaped.With(prometheus.Labels{"host": host, "side": "client"}).Inc()
+	}
+}
+
+// isTimeout reports whether the error is the one of a passed deadline
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,69 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProxy_PipeIdle(t *testing.T) {
+	proxy := &Proxy{Config: &ProxyConfig{
+		IdleTimeout: IdleTimeoutConfig{Client: 100},
+	}}
+
+	c1, c2 := net.Pipe()
+	r1, r2 := net.Pipe()
+	defer c1.Close()
+	defer r1.Close()
+	defer c2.Close()
+	defer r2.Close()
+
+	done := make(chan struct{})
+	go func() {
+		proxy.pipeIdle(wrapConn(c1), wrapConn(r1), "mc.example.com")
+		close(done)
+	}()
+
+	// The backend keeps sending, but the client doesn't answer
+	go func() {
+		for {
+			if _, err := r2.Write([]byte{0}); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	go func() {
+		buffer := make([]byte, 16)
+		for {
+			if _, err := c2.Read(buffer); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the pipe of the idle client is still open")
+	}
+}
+
+func TestPipe_WithoutIdleTimeout(t *testing.T) {
+	c1, c2 := net.Pipe()
+	r1, r2 := net.Pipe()
+	defer r2.Close()
+
+	go func() {
+		c2.Write([]byte("ping"))
+		c2.Close()
+	}()
+	go func() {
+		buffer := make([]byte, 16)
+		r2.Read(buffer)
+	}()
+
+	if idle := pipe(wrapConn(c1), wrapConn(r1), 0); idle {
+		t.Error("got idle; want the pipe to stop because the client closed")
+	}
+}
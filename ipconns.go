@@ -0,0 +1,88 @@
+package infrared
+
+import (
+	"net"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var ipConnsRejected = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "infrared_ip_conns_rejected",
+	Help: "The total number of connections rejected by the maximum of simultaneous connections per IP",
+})
+
+// DefaultIPv6ConnPrefix is the prefix length that IPv6 addresses are aggregated to,
+// because a single client usually gets a whole /64
+const DefaultIPv6ConnPrefix = 64
+
+// ipConnKey returns the key that the connections of the IP are counted under.
+// IPv6 addresses are aggregated to the network of the prefix length.
+func ipConnKey(ip net.IP, ipv6Prefix int) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	if ipv6Prefix <= 0 || ipv6Prefix > 128 {
+		ipv6Prefix = 128
+	}
+
+	mask := net.CIDRMask(ipv6Prefix, 128)
+	network := net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	return network.String()
+}
+
+// ipConnCounter counts the open connections of every IP
+type ipConnCounter struct {
+	mu    sync.Mutex
+	conns map[string]int
+}
+
+// acquire counts a connection of the key and reports whether the key has at most max connections
+func (c *ipConnCounter) acquire(key string, max int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conns == nil {
+		c.conns = map[string]int{}
+	}
+
+	if c.conns[key] >= max {
+		return false
+	}
+	c.conns[key]++
+	return true
+}
+
+// release stops counting a connection of the key
+func (c *ipConnCounter) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conns[key] <= 1 {
+		delete(c.conns, key)
+		return
+	}
+	c.conns[key]--
+}
+
+// acquireIPConn counts the connection from the address against the maximum connections per IP of the gateway.
+// The returned func releases the connection again after it was closed.
+func (gateway *Gateway) acquireIPConn(addr net.Addr) (func(), bool) {
+	ip, _, ok := splitIPAddr(unmapAddr(addr))
+	if gateway.MaxConnsPerIP <= 0 || !ok {
+		return func() {}, true
+	}
+
+	prefix := gateway.IPv6ConnPrefix
+	if prefix == 0 {
+		prefix = DefaultIPv6ConnPrefix
+	}
+
+	key := ipConnKey(ip, prefix)
+	if !gateway.ipConns.acquire(key, gateway.MaxConnsPerIP) {
+		ipConnsRejected.Inc()
+		return nil, false
+	}
+	return func() {
+		gateway.ipConns.release(key)
+	}, true
+}
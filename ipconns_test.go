@@ -0,0 +1,52 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPConnKey(t *testing.T) {
+	tt := []struct {
+		ip     string
+		prefix int
+		key    string
+	}{
+		{ip: "203.0.113.5", prefix: 64, key: "203.0.113.5"},
+		{ip: "::ffff:203.0.113.5", prefix: 64, key: "203.0.113.5"},
+		{ip: "2001:db8:1:2:3:4:5:6", prefix: 64, key: "2001:db8:1:2::/64"},
+		{ip: "2001:db8:1:2:3:4:5:6", prefix: 48, key: "2001:db8:1::/48"},
+		{ip: "2001:db8:1:2:3:4:5:6", prefix: 0, key: "2001:db8:1:2:3:4:5:6/128"},
+	}
+
+	for _, tc := range tt {
+		if key := ipConnKey(net.ParseIP(tc.ip), tc.prefix); key != tc.key {
+			t.Errorf("got key %s of %s/%d; want %s", key, tc.ip, tc.prefix, tc.key)
+		}
+	}
+}
+
+func TestGateway_AcquireIPConn(t *testing.T) {
+	gateway := Gateway{MaxConnsPerIP: 2}
+	addr := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 50000}
+	sameNetwork := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 50001}
+	other := &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 50000}
+
+	release, ok := gateway.acquireIPConn(addr)
+	if !ok {
+		t.Fatal("got the first connection rejected; want it allowed")
+	}
+	if _, ok := gateway.acquireIPConn(sameNetwork); !ok {
+		t.Fatal("got the second connection rejected; want it allowed")
+	}
+	if _, ok := gateway.acquireIPConn(addr); ok {
+		t.Error("got the third connection of the /64 allowed; want it rejected")
+	}
+	if _, ok := gateway.acquireIPConn(other); !ok {
+		t.Error("got the connection of another IP rejected; want it allowed")
+	}
+
+	release()
+	if _, ok := gateway.acquireIPConn(addr); !ok {
+		t.Error("got a connection rejected after another one was closed; want it allowed")
+	}
+}
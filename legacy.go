@@ -0,0 +1,226 @@
+package infrared
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+)
+
+const (
+	// legacyPingID is the first byte of the server list pings of clients before 1.7
+	legacyPingID = 0xFE
+	// legacyKickID is the ID of the kick packet that legacy pings are answered with
+	legacyKickID = 0xFF
+	// legacyPingHost is the channel of the plugin message that 1.6 clients append to their ping
+	legacyPingHost = "MC|PingHost"
+)
+
+// LegacyStatusConfig is the status that Infrared answers the legacy server list pings of clients
+// before 1.7 and of server scanners with. With passthrough, the ping is piped to the backend instead
+// and the status is only sent if the backend can't be reached.
+type LegacyStatusConfig struct {
+	Passthrough    bool   `json:"passthrough"`
+	VersionName    string `json:"versionName"`
+	ProtocolNumber int    `json:"protocolNumber"`
+	MOTD           string `json:"motd"`
+	// PlayersOnline replaces the number of players that are connected through the proxy
+	PlayersOnline int `json:"playersOnline"`
+	MaxPlayers    int `json:"maxPlayers"`
+}
+
+// legacyColorCode matches the color and formatting codes of MOTDs
+var legacyColorCode = regexp.MustCompile("§.?")
+
+// legacyPing is a server list ping in the format of clients before 1.7
+type legacyPing struct {
+	// Beta pings of clients before 1.4 consist only of the ping ID and expect the old response format
+	Beta bool
+	// Domain and Port are only sent by 1.6 clients
+	Domain string
+	Port   int
+}
+
+func (proxy *Proxy) LegacyStatus() LegacyStatusConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.LegacyStatus
+}
+
+// isLegacyPing reports whether the client starts with a legacy ping instead of a handshake
+func isLegacyPing(conn Conn) bool {
+	bb, err := conn.Reader().Peek(1)
+	return err == nil && bb[0] == legacyPingID
+}
+
+// peekLegacyPing parses the legacy ping of the client without reading it, so it can still be piped to the backend.
+// Clients send their whole ping at once, so only the bytes that already arrived are parsed.
+func peekLegacyPing(r *bufio.Reader) (legacyPing, error) {
+	bb, err := r.Peek(r.Buffered())
+	if err != nil {
+		return legacyPing{}, err
+	}
+	if len(bb) == 0 || bb[0] != legacyPingID {
+		return legacyPing{}, errors.New("no legacy ping")
+	}
+	if len(bb) == 1 {
+		return legacyPing{Beta: true}, nil
+	}
+
+	// 1.6 clients append 0xFA, the plugin message with their protocol, hostname and port
+	if len(bb) < 3 || bb[1] != 0x01 || bb[2] != 0xFA {
+		return legacyPing{}, nil
+	}
+	data := bb[3:]
+	channel, data, ok := readLegacyString(data)
+	if !ok || channel != legacyPingHost || len(data) < 3 {
+		return legacyPing{}, nil
+	}
+	// Skip the length of the plugin message and the protocol
+	data = data[3:]
+	domain, data, ok := readLegacyString(data)
+	if !ok || len(data) < 4 {
+		return legacyPing{}, nil
+	}
+
+	return legacyPing{
+		Domain: domain,
+		Port:   int(int32(binary.BigEndian.Uint32(data))),
+	}, nil
+}
+
+// readLegacyString reads a string of legacy packets, which is an UTF-16BE string prefixed with its length
+func readLegacyString(bb []byte) (string, []byte, bool) {
+	if len(bb) < 2 {
+		return "", bb, false
+	}
+	n := int(binary.BigEndian.Uint16(bb))
+	bb = bb[2:]
+	if len(bb) < n*2 {
+		return "", bb, false
+	}
+
+	chars := make([]uint16, n)
+	for i := range chars {
+		chars[i] = binary.BigEndian.Uint16(bb[i*2:])
+	}
+	return string(utf16.Decode(chars)), bb[n*2:], true
+}
+
+// legacyKick returns the kick packet with the reason, which is how legacy pings are answered
+func legacyKick(reason string) []byte {
+	chars := utf16.Encode([]rune(reason))
+	bb := make([]byte, 3, 3+len(chars)*2)
+	bb[0] = legacyKickID
+	binary.BigEndian.PutUint16(bb[1:], uint16(len(chars)))
+	for _, c := range chars {
+		bb = append(bb, byte(c>>8), byte(c))
+	}
+	return bb
+}
+
+// legacyResponse returns the kick reason that answers the ping with the status
+func (cfg LegacyStatusConfig) legacyResponse(ping legacyPing, motd string, playersOnline int) string {
+	if cfg.PlayersOnline != 0 {
+		playersOnline = cfg.PlayersOnline
+	}
+
+	online := strconv.Itoa(playersOnline)
+	max := strconv.Itoa(cfg.MaxPlayers)
+	if ping.Beta {
+		// The fields of the beta format are separated by §, so the MOTD can't have color codes
+		return legacyColorCode.ReplaceAllString(motd, "") + "§" + online + "§" + max
+	}
+
+	return strings.Join([]string{
+		"§1",
+		strconv.Itoa(cfg.ProtocolNumber),
+		cfg.VersionName,
+		motd,
+		online,
+		max,
+	}, "\x00")
+}
+
+// handleLegacyPing answers the legacy ping with the legacy status of the proxy or pipes it to the backend
+func (proxy *Proxy) handleLegacyPing(conn Conn, ping legacyPing, connRemoteAddr net.Addr) error {
+	cfg := proxy.LegacyStatus()
+	// Proxies in maintenance or that drain their players don't touch their backends
+	if cfg.Passthrough && !proxy.Maintenance() && !proxy.draining() {
+		err := proxy.pipeLegacyPing(conn, ping, connRemoteAddr)
+		if err == nil {
+			return nil
+		}
+		log.Printf("[i] Can't pipe legacy ping of %s to the backend of %s; error: %s", connRemoteAddr, proxy.UID(), err)
+	}
+
+	proxy.mu.Lock()
+	playersOnline := len(proxy.players)
+	proxy.mu.Unlock()
+
+	motd, err := renderMOTD(cfg.MOTD, statusRequest{
+		PlayersOnline: playersOnline,
+		Domain:        ping.Domain,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(proxy.Timeout())); err != nil {
+		return err
+	}
+	if _, err := conn.Reader().Discard(conn.Reader().Buffered()); err != nil {
+		return err
+	}
+	_, err = conn.Write(legacyKick(cfg.legacyResponse(ping, motd, playersOnline)))
+	return err
+}
+
+// pipeLegacyPing pipes the legacy ping to the first backend of the proxy until the backend closes the connection
+func (proxy *Proxy) pipeLegacyPing(conn Conn, ping legacyPing, connRemoteAddr net.Addr) error {
+	backends := proxy.Backends()
+	if len(backends) == 0 {
+		return errors.New("no backend")
+	}
+	proxyTo := proxy.expandProxyTo(backends[0], ping.Domain)
+
+	dialer, err := proxy.Dialer()
+	if err != nil {
+		return err
+	}
+
+	rconn, err := dialBackend(dialer, proxyTo)
+	if err != nil {
+		return err
+	}
+	defer rconn.Close()
+
+	if proxy.ProxyProtocol() {
+		// Legacy pings have no protocol version, so the TLVs only have the domain of 1.6 clients
+		hs := handshaking.ServerBoundHandshake{ServerAddress: protocol.String(ping.Domain)}
+		if err := proxy.writeProxyProtocolHeader(conn, rconn, connRemoteAddr, hs); err != nil {
+			return err
+		}
+	}
+
+	deadline := time.Now().Add(proxy.Timeout())
+	if err := rconn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	go pipe(conn, rconn, 0)
+	pipe(rconn, conn, 0)
+	return nil
+}
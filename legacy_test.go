@@ -0,0 +1,135 @@
+package infrared
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"unicode/utf16"
+)
+
+func legacyString(s string) []byte {
+	chars := utf16.Encode([]rune(s))
+	bb := make([]byte, 2, 2+len(chars)*2)
+	binary.BigEndian.PutUint16(bb, uint16(len(chars)))
+	for _, c := range chars {
+		bb = append(bb, byte(c>>8), byte(c))
+	}
+	return bb
+}
+
+// legacyPingOf16 returns the ping of a 1.6 client to the domain and port
+func legacyPingOf16(domain string, port int) []byte {
+	host := legacyString(domain)
+	bb := []byte{legacyPingID, 0x01, 0xFA}
+	bb = append(bb, legacyString(legacyPingHost)...)
+	bb = append(bb, byte(0), byte(len(host)+5), 78)
+	bb = append(bb, host...)
+	bb = append(bb, 0, 0, byte(port>>8), byte(port))
+	return bb
+}
+
+func TestPeekLegacyPing(t *testing.T) {
+	tt := []struct {
+		name string
+		data []byte
+		ping legacyPing
+	}{
+		{
+			name: "Beta",
+			data: []byte{legacyPingID},
+			ping: legacyPing{Beta: true},
+		},
+		{
+			name: "1.4",
+			data: []byte{legacyPingID, 0x01},
+			ping: legacyPing{},
+		},
+		{
+			name: "1.6",
+			data: legacyPingOf16("mc.example.com", 25565),
+			ping: legacyPing{Domain: "mc.example.com", Port: 25565},
+		},
+		{
+			name: "Truncated1.6",
+			data: legacyPingOf16("mc.example.com", 25565)[:20],
+			ping: legacyPing{},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewReader(tc.data))
+			r.Peek(1)
+			ping, err := peekLegacyPing(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ping != tc.ping {
+				t.Errorf("got %+v; want %+v", ping, tc.ping)
+			}
+			if r.Buffered() != len(tc.data) {
+				t.Errorf("got %d buffered bytes; want the ping to stay unread", r.Buffered())
+			}
+		})
+	}
+}
+
+func TestLegacyStatusConfig_LegacyResponse(t *testing.T) {
+	cfg := LegacyStatusConfig{
+		VersionName:    "1.6.4",
+		ProtocolNumber: 78,
+		MaxPlayers:     20,
+	}
+
+	if res := cfg.legacyResponse(legacyPing{}, "§6Hello", 3); res != "§1\x0078\x001.6.4\x00§6Hello\x003\x0020" {
+		t.Errorf("got %q; want the 1.4 format", res)
+	}
+
+	if res := cfg.legacyResponse(legacyPing{Beta: true}, "§6Hello", 3); res != "Hello§3§20" {
+		t.Errorf("got %q; want the beta format without color codes", res)
+	}
+
+	cfg.PlayersOnline = 7
+	if res := cfg.legacyResponse(legacyPing{Beta: true}, "Hello", 3); res != "Hello§7§20" {
+		t.Errorf("got %q; want the players online of the config", res)
+	}
+}
+
+func TestLegacyPing(t *testing.T) {
+	portEnd := 597
+	config := proxyConfigWithPortEnd(portEnd)
+	config.Timeout = 1000
+	config.LegacyStatus = LegacyStatusConfig{
+		VersionName:    "1.6.4",
+		ProtocolNumber: 78,
+		MOTD:           "Hello {{domain}}",
+		MaxPlayers:     20,
+	}
+
+	gateway := Gateway{}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	conn, err := Dialer{}.Dial(gatewayAddr(portEnd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(legacyPingOf16(serverDomain, gatewayPort(portEnd))); err != nil {
+		t.Fatal(err)
+	}
+
+	bb, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := legacyKick("§1\x0078\x001.6.4\x00Hello " + serverDomain + "\x000\x0020")
+	if !bytes.Equal(bb, expected) {
+		t.Errorf("got %q; want %q", bb, expected)
+	}
+}
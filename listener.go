@@ -0,0 +1,200 @@
+package infrared
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/pires/go-proxyproto"
+)
+
+// Network schemes of listen addresses. Addresses without a scheme listen on tcp,
+// which is dual-stack for addresses like :25565 or [::]:25565.
+const (
+	TCP4Scheme = "tcp4://"
+	TCP6Scheme = "tcp6://"
+	TCPScheme  = "tcp://"
+)
+
+// ListenAddrSeparator separates the addresses of a listener that binds to multiple addresses,
+// like tcp4://0.0.0.0:25565+tcp6://[::]:25565
+const ListenAddrSeparator = "+"
+
+// splitNetwork returns the network and the address of a listen address.
+// tcp6 only listens on IPv6, because Go sets IPV6_V6ONLY for it.
+func splitNetwork(addr string) (string, string) {
+	switch {
+	case strings.HasPrefix(addr, TCP4Scheme):
+		return "tcp4", strings.TrimPrefix(addr, TCP4Scheme)
+	case strings.HasPrefix(addr, TCP6Scheme):
+		return "tcp6", strings.TrimPrefix(addr, TCP6Scheme)
+	default:
+		return "tcp", strings.TrimPrefix(addr, TCPScheme)
+	}
+}
+
+// listen listens on all addresses of the listen address
+func listen(addr string) (net.Listener, error) {
+	var listeners []net.Listener
+	for _, a := range strings.Split(addr, ListenAddrSeparator) {
+		l, err := net.Listen(splitNetwork(strings.TrimSpace(a)))
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+
+	if len(listeners) == 1 {
+		return listeners[0], nil
+	}
+	return newMultiListener(listeners), nil
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// multiListener accepts the connections of multiple listeners
+type multiListener struct {
+	listeners []net.Listener
+	accepted  chan acceptResult
+	closed    chan struct{}
+	once      sync.Once
+}
+
+func newMultiListener(listeners []net.Listener) *multiListener {
+	ml := &multiListener{
+		listeners: listeners,
+		accepted:  make(chan acceptResult),
+		closed:    make(chan struct{}),
+	}
+
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			for {
+				conn, err := l.Accept()
+				select {
+				case ml.accepted <- acceptResult{conn: conn, err: err}:
+				case <-ml.closed:
+					if conn != nil {
+						conn.Close()
+					}
+					return
+				}
+			}
+		}(l)
+	}
+	return ml
+}
+
+func (ml *multiListener) Accept() (net.Conn, error) {
+	select {
+	case res := <-ml.accepted:
+		return res.conn, res.err
+	case <-ml.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (ml *multiListener) Close() error {
+	var err error
+	ml.once.Do(func() {
+		close(ml.closed)
+		for _, l := range ml.listeners {
+			if closeErr := l.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	})
+	return err
+}
+
+func (ml *multiListener) Addr() net.Addr {
+	return ml.listeners[0].Addr()
+}
+
+// unmapAddr turns IPv4-mapped IPv6 addresses like [::ffff:1.2.3.4]:25565 of dual-stack listeners
+// into IPv4 addresses, so that IP based features see the same IP for both
+func unmapAddr(addr net.Addr) net.Addr {
+	ip, port, ok := splitIPAddr(addr)
+	if !ok {
+		return addr
+	}
+
+	ip4 := ip.To4()
+	if ip4 == nil || len(ip) == net.IPv4len {
+		return addr
+	}
+	return ipAddr(addr, ip4, port)
+}
+
+// splitIPAddr returns the IP and the port of TCP and UDP addresses
+func splitIPAddr(addr net.Addr) (net.IP, int, bool) {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP, addr.Port, true
+	case *net.UDPAddr:
+		return addr.IP, addr.Port, true
+	}
+	return nil, 0, false
+}
+
+// ipAddr returns an address of the same network as addr with the IP and the port
+func ipAddr(addr net.Addr, ip net.IP, port int) net.Addr {
+	if _, ok := addr.(*net.UDPAddr); ok {
+		return &net.UDPAddr{IP: ip, Port: port}
+	}
+	return &net.TCPAddr{IP: ip, Port: port}
+}
+
+// localProxyProtocolHeader creates the PROXY protocol header of connections that Infrared dials on its own, like
+// health checks, which have no client
+func localProxyProtocolHeader() *proxyproto.Header {
+	return &proxyproto.Header{
+		Version:           2,
+		Command:           proxyproto.LOCAL,
+		TransportProtocol: proxyproto.UNSPEC,
+	}
+}
+
+// proxyProtocolHeader creates a PROXY protocol header from the source to the destination. Both addresses have
+// to be of the same transport and family, so the source gets the transport of the destination and an IPv4
+// address is mapped to IPv6 if the other one is IPv6.
+func proxyProtocolHeader(source, destination net.Addr) *proxyproto.Header {
+	header := &proxyproto.Header{
+		Version:           2,
+		Command:           proxyproto.PROXY,
+		TransportProtocol: proxyproto.TCPv4,
+		SourceAddr:        unmapAddr(source),
+		DestinationAddr:   unmapAddr(destination),
+	}
+
+	srcIP, srcPort, srcOK := splitIPAddr(header.SourceAddr)
+	dstIP, dstPort, dstOK := splitIPAddr(header.DestinationAddr)
+	if !srcOK || !dstOK {
+		return header
+	}
+
+	// The transport is the one of the backend, because clients of QUIC listeners have UDP addresses
+	_, udp := destination.(*net.UDPAddr)
+	if udp {
+		header.TransportProtocol = proxyproto.UDPv4
+	}
+	header.SourceAddr = ipAddr(destination, srcIP, srcPort)
+
+	if srcIP.To4() != nil && dstIP.To4() != nil {
+		return header
+	}
+
+	header.TransportProtocol = proxyproto.TCPv6
+	if udp {
+		header.TransportProtocol = proxyproto.UDPv6
+	}
+	header.SourceAddr = ipAddr(destination, srcIP.To16(), srcPort)
+	header.DestinationAddr = ipAddr(destination, dstIP.To16(), dstPort)
+	return header
+}
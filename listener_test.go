@@ -0,0 +1,109 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pires/go-proxyproto"
+)
+
+func TestListen_MultipleAddrs(t *testing.T) {
+	l, err := Listen("tcp4://127.0.0.1:0" + ListenAddrSeparator + "tcp://127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ml, ok := l.Listener.(*multiListener)
+	if !ok {
+		t.Fatalf("got %T; want a multiListener", l.Listener)
+	}
+
+	for _, sub := range ml.listeners {
+		go func(addr string) {
+			if conn, err := net.Dial("tcp", addr); err == nil {
+				defer conn.Close()
+				conn.Write([]byte{0})
+			}
+		}(sub.Addr().String())
+
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Accept(); err == nil {
+		t.Error("got no error; want the listener to be closed")
+	}
+}
+
+func TestSplitNetwork(t *testing.T) {
+	tt := []struct {
+		addr    string
+		network string
+		host    string
+	}{
+		{addr: ":25565", network: "tcp", host: ":25565"},
+		{addr: "tcp://:25565", network: "tcp", host: ":25565"},
+		{addr: "tcp4://0.0.0.0:25565", network: "tcp4", host: "0.0.0.0:25565"},
+		{addr: "tcp6://[::]:25565", network: "tcp6", host: "[::]:25565"},
+	}
+
+	for _, tc := range tt {
+		network, host := splitNetwork(tc.addr)
+		if network != tc.network || host != tc.host {
+			t.Errorf("%s: got %s %s; want %s %s", tc.addr, network, host, tc.network, tc.host)
+		}
+	}
+}
+
+func TestUnmapAddr(t *testing.T) {
+	mapped := &net.TCPAddr{IP: net.ParseIP("::ffff:1.2.3.4"), Port: 25565}
+	if got := unmapAddr(mapped).(*net.TCPAddr); len(got.IP) != net.IPv4len || got.String() != "1.2.3.4:25565" {
+		t.Errorf("got %s; want 1.2.3.4:25565", got)
+	}
+
+	v6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 25565}
+	if got := unmapAddr(v6); got != v6 {
+		t.Errorf("got %s; want %s unchanged", got, v6)
+	}
+}
+
+func TestProxyProtocolHeader(t *testing.T) {
+	v4 := &net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 40000}
+	mapped := &net.TCPAddr{IP: net.ParseIP("::ffff:10.0.0.1"), Port: 25565}
+	v6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 25565}
+
+	if header := proxyProtocolHeader(v4, mapped); header.TransportProtocol != proxyproto.TCPv4 {
+		t.Errorf("got %v; want TCPv4 for IPv4 and IPv4-mapped addresses", header.TransportProtocol)
+	}
+
+	udp := &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 40000}
+	udp6 := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 19132}
+	if header := proxyProtocolHeader(udp, udp6); header.TransportProtocol != proxyproto.UDPv6 {
+		t.Errorf("got %v; want UDPv6 for UDP addresses", header.TransportProtocol)
+	}
+
+	header := proxyProtocolHeader(v4, v6)
+	if header.TransportProtocol != proxyproto.TCPv6 {
+		t.Errorf("got %v; want TCPv6 for an IPv6 destination", header.TransportProtocol)
+	}
+	if _, err := header.Format(); err != nil {
+		t.Errorf("got error %v; want a valid header", err)
+	}
+
+	// Clients of QUIC listeners have UDP addresses and are proxied to TCP backends
+	for _, dst := range []net.Addr{v4, v6} {
+		header := proxyProtocolHeader(udp, dst)
+		if _, ok := header.SourceAddr.(*net.TCPAddr); !ok {
+			t.Errorf("got source %T; want a TCP address for the TCP destination %s", header.SourceAddr, dst)
+		}
+		if _, err := header.Format(); err != nil {
+			t.Errorf("got error %v; want a valid header from a UDP source to %s", err, dst)
+		}
+	}
+}
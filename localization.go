@@ -0,0 +1,66 @@
+package infrared
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Localization replaces the messages and MOTDs of a proxy for the clients of the countries, which are ISO country
+// codes like DE or continent codes like EU. Empty messages keep the ones of the proxy.
+// The MOTD replaces the MOTDs of the online and offline status and the MaintenanceMOTD the ones of the
+// maintenance status.
+type Localization struct {
+	Countries                 []string `json:"countries"`
+	DisconnectMessage         string   `json:"disconnectMessage"`
+	MaintenanceMessage        string   `json:"maintenanceMessage"`
+	UnsupportedVersionMessage string   `json:"unsupportedVersionMessage"`
+	MOTD                      string   `json:"motd"`
+	MaintenanceMOTD           string   `json:"maintenanceMotd"`
+}
+
+func (l Localization) validate() error {
+	if len(l.Countries) == 0 {
+		return errors.New("no countries")
+	}
+	for _, code := range l.Countries {
+		if len(code) != 2 {
+			return fmt.Errorf("%q is no country or continent code", code)
+		}
+	}
+	return nil
+}
+
+// localizedMessage returns the message of the localization, if it has one, and otherwise the message
+func localizedMessage(localized, message string) string {
+	if localized != "" {
+		return localized
+	}
+	return message
+}
+
+// localizedStatus replaces the MOTDs of the status with the MOTD of the localization, if it has one
+func localizedStatus(statusCfg StatusConfig, motd string) StatusConfig {
+	if motd == "" {
+		return statusCfg
+	}
+	statusCfg.MOTD = motd
+	statusCfg.MOTDs = nil
+	return statusCfg
+}
+
+func (proxy *Proxy) Localizations() []Localization {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Localizations
+}
+
+// localization returns the first localization of the location. Clients without a matching
+// localization get an empty one, which keeps all messages of the proxy.
+func (proxy *Proxy) localization(location geoLocation) Localization {
+	for _, l := range proxy.Localizations() {
+		if location.in(l.Countries) {
+			return l
+		}
+	}
+	return Localization{}
+}
@@ -0,0 +1,78 @@
+package infrared
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/status"
+)
+
+func TestProxy_Localization(t *testing.T) {
+	defer func(lookup func(net.IP) geoLocation) { lookupLocation = lookup }(lookupLocation)
+	lookupLocation = func(ip net.IP) geoLocation {
+		switch {
+		case ip.Equal(net.ParseIP("203.0.113.5")):
+			return geoLocation{Country: "DE", Continent: "EU"}
+		case ip.Equal(net.ParseIP("203.0.113.6")):
+			return geoLocation{Country: "AT", Continent: "EU"}
+		}
+		return geoLocation{}
+	}
+
+	proxy := &Proxy{Config: &ProxyConfig{
+		Localizations: []Localization{
+			{Countries: []string{"DE"}, DisconnectMessage: "Server ist offline"},
+			{Countries: []string{"EU"}, DisconnectMessage: "Server is offline in Europe"},
+		},
+	}}
+
+	tt := []struct {
+		addr    string
+		message string
+	}{
+		{addr: "203.0.113.5:50000", message: "Server ist offline"},
+		{addr: "203.0.113.6:50000", message: "Server is offline in Europe"},
+		{addr: "198.51.100.1:50000", message: ""},
+	}
+
+	for _, tc := range tt {
+		addr, err := net.ResolveTCPAddr("tcp", tc.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message := proxy.localization(proxy.clientLocation(addr)).DisconnectMessage; message != tc.message {
+			t.Errorf("%s: got %q; want %q", tc.addr, message, tc.message)
+		}
+	}
+}
+
+func TestProxy_HandleStatusRequest_Localized(t *testing.T) {
+	proxy := &Proxy{Config: &ProxyConfig{
+		OfflineStatus: StatusConfig{MOTD: "Server is offline"},
+	}}
+	statusReq := statusRequest{localization: Localization{MOTD: "Server ist offline"}}
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	go proxy.handleStatusRequest(wrapConn(c1), false, statusReq)
+
+	client := wrapConn(c2)
+	if err := client.WritePacket(status.ServerBoundRequest{}.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	pk, err := client.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response protocol.String
+	if err := pk.Scan(&response); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(response), "Server ist offline") {
+		t.Errorf("got %s; want the localized MOTD", response)
+	}
+}
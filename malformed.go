@@ -0,0 +1,150 @@
+package infrared
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	malformedHandshakes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "infrared_malformed_handshakes",
+		Help: "The total number of connections that sent an invalid PROXY protocol header or handshake",
+	})
+	malformedBanned = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "infrared_malformed_bans",
+		Help: "The total number of IPs that were temporarily banned for malformed handshakes",
+	})
+)
+
+// MalformedBanConfig bans an IP for BanDuration after it sent Threshold malformed handshakes.
+// Every Decay forgives one malformed handshake, so clients with a rare broken connection aren't banned.
+// A threshold of 0 is disabled.
+type MalformedBanConfig struct {
+	Threshold   int
+	Decay       time.Duration
+	BanDuration time.Duration
+}
+
+// errInvalidNextState is the error of handshakes that request neither a status nor a login
+var errInvalidNextState = errors.New("invalid next state of handshake")
+
+type strikeEntry struct {
+	strikes int
+	last    time.Time
+}
+
+// strikeCounter counts the decaying strikes of every IP
+type strikeCounter struct {
+	mu        sync.Mutex
+	entries   map[string]*strikeEntry
+	lastSweep time.Time
+}
+
+// decay forgives the strikes of the entry since its last strike
+func (e *strikeEntry) decay(now time.Time, cfg MalformedBanConfig) {
+	if cfg.Decay <= 0 || e.strikes == 0 {
+		e.last = now
+		return
+	}
+
+	forgiven := int(now.Sub(e.last) / cfg.Decay)
+	if forgiven >= e.strikes {
+		e.strikes, e.last = 0, now
+		return
+	}
+	e.strikes -= forgiven
+	e.last = e.last.Add(time.Duration(forgiven) * cfg.Decay)
+}
+
+// strike counts a strike of the IP and reports whether the IP reached the threshold.
+// The strikes of an IP start over after it reached the threshold.
+func (c *strikeCounter) strike(ip net.IP, cfg MalformedBanConfig) bool {
+	if cfg.Threshold <= 0 || ip == nil {
+		return false
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]*strikeEntry{}
+		c.lastSweep = now
+	}
+
+	if now.Sub(c.lastSweep) > requestLimiterSweepInterval {
+		c.sweep(now, cfg)
+	}
+
+	key := ip.String()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &strikeEntry{last: now}
+		c.entries[key] = entry
+	}
+
+	entry.decay(now, cfg)
+	entry.strikes++
+	if entry.strikes < cfg.Threshold {
+		return false
+	}
+
+	delete(c.entries, key)
+	return true
+}
+
+// sweep forgets the IPs whose strikes decayed
+func (c *strikeCounter) sweep(now time.Time, cfg MalformedBanConfig) {
+	for key, entry := range c.entries {
+		entry.decay(now, cfg)
+		if entry.strikes == 0 || cfg.Decay <= 0 {
+			delete(c.entries, key)
+		}
+	}
+	c.lastSweep = now
+}
+
+// isMalformed reports whether the error of reading the PROXY protocol header or the handshake
+// means that the client sent garbage, instead of just closing the connection
+func isMalformed(err error) bool {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return false
+	}
+	var netErr net.Error
+	return !errors.As(err, &netErr)
+}
+
+// validateNextState returns an error if the handshake requests neither a status nor a login
+func validateNextState(hs handshaking.ServerBoundHandshake) error {
+	if hs.IsStatusRequest() || hs.IsLoginRequest() {
+		return nil
+	}
+	return errInvalidNextState
+}
+
+// addrBanned reports whether the IP of the address is banned by the gateway or a decision of CrowdSec
+func (gateway *Gateway) addrBanned(addr net.Addr) bool {
+	ip, _, _ := splitIPAddr(unmapAddr(addr))
+	return gateway.bans.banned(ip) || gateway.crowdSec.banned(ip)
+}
+
+// strikeMalformed counts the error as a strike of the address, if the client sent a malformed handshake
+func (gateway *Gateway) strikeMalformed(addr net.Addr, err error) {
+	if !isMalformed(err) {
+		return
+	}
+	malformedHandshakes.Inc()
+
+	ip, _, _ := splitIPAddr(unmapAddr(addr))
+	if gateway.malformedBans.strike(ip, gateway.MalformedBans) {
+		malformedBanned.Inc()
+		gateway.ban(ip, gateway.MalformedBans.BanDuration, "malformed handshakes")
+		gateway.reportCrowdSec(ip, CrowdSecMalformedScenario, "malformed handshakes")
+	}
+}
@@ -0,0 +1,104 @@
+package infrared
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+)
+
+func TestStrikeCounter_Strike(t *testing.T) {
+	cfg := MalformedBanConfig{Threshold: 3, Decay: time.Hour, BanDuration: time.Hour}
+	ip := net.ParseIP("203.0.113.5")
+
+	var c strikeCounter
+	for i := 0; i < 2; i++ {
+		if c.strike(ip, cfg) {
+			t.Fatalf("strike %d: got banned; want strikes below the threshold", i+1)
+		}
+	}
+	if c.strike(net.ParseIP("198.51.100.1"), cfg) {
+		t.Error("other ip: got banned; want strikes per ip")
+	}
+
+	if !c.strike(ip, cfg) {
+		t.Fatal("got not banned; want banned at the threshold")
+	}
+	if c.strike(ip, cfg) {
+		t.Error("got banned; want the strikes to start over after a ban")
+	}
+}
+
+func TestGateway_StrikeMalformed(t *testing.T) {
+	gateway := Gateway{MalformedBans: MalformedBanConfig{Threshold: 2, Decay: time.Hour, BanDuration: time.Hour}}
+	addr := &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 50000}
+
+	gateway.strikeMalformed(addr, io.EOF)
+	gateway.strikeMalformed(addr, errInvalidNextState)
+	if gateway.addrBanned(addr) {
+		t.Fatal("got banned; want closed connections not to count")
+	}
+
+	gateway.strikeMalformed(addr, errInvalidNextState)
+	if !gateway.addrBanned(addr) {
+		t.Error("got not banned; want banned after two malformed handshakes")
+	}
+}
+
+func TestStrikeCounter_Decay(t *testing.T) {
+	cfg := MalformedBanConfig{Threshold: 2, Decay: time.Millisecond, BanDuration: time.Hour}
+	ip := net.ParseIP("203.0.113.5")
+
+	var c strikeCounter
+	c.strike(ip, cfg)
+	time.Sleep(5 * time.Millisecond)
+	if c.strike(ip, cfg) {
+		t.Error("got banned; want the first strike to have decayed")
+	}
+}
+
+func TestStrikeCounter_Disabled(t *testing.T) {
+	var c strikeCounter
+	ip := net.ParseIP("203.0.113.5")
+	for i := 0; i < 10; i++ {
+		if c.strike(ip, MalformedBanConfig{}) {
+			t.Fatal("got banned; want disabled bans")
+		}
+	}
+}
+
+func TestIsMalformed(t *testing.T) {
+	tt := []struct {
+		err       error
+		malformed bool
+	}{
+		{err: io.EOF, malformed: false},
+		{err: net.ErrClosed, malformed: false},
+		{err: os.ErrDeadlineExceeded, malformed: false},
+		{err: errInvalidNextState, malformed: true},
+		{err: errors.New("VarInt is too big"), malformed: true},
+	}
+
+	for _, tc := range tt {
+		if malformed := isMalformed(tc.err); malformed != tc.malformed {
+			t.Errorf("%v: got %t; want %t", tc.err, malformed, tc.malformed)
+		}
+	}
+}
+
+func TestValidateNextState(t *testing.T) {
+	for _, nextState := range []protocol.Byte{1, 2, 3} {
+		hs := handshaking.ServerBoundHandshake{NextState: nextState}
+		if err := validateNextState(hs); err != nil {
+			t.Errorf("next state %d: got %v; want valid", nextState, err)
+		}
+	}
+	if err := validateNextState(handshaking.ServerBoundHandshake{NextState: 7}); err == nil {
+		t.Error("next state 7: got no error; want invalid")
+	}
+}
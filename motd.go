@@ -0,0 +1,105 @@
+package infrared
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ScheduledMOTD is one of the MOTDs of a status. A MOTD with a cron schedule like "* * * * 6,0"
+// is shown during every minute that matches the schedule, a MOTD without one takes part in the rotation.
+type ScheduledMOTD struct {
+	MOTD     string `json:"motd"`
+	Schedule string `json:"schedule"`
+}
+
+// active reports whether the minute of now matches the schedule of the MOTD
+func (m ScheduledMOTD) active(now time.Time) bool {
+	schedule, err := cron.ParseStandard(m.Schedule)
+	if err != nil {
+		return false
+	}
+
+	minute := now.Truncate(time.Minute)
+	return schedule.Next(minute.Add(-time.Second)).Equal(minute)
+}
+
+// selectMOTD returns the MOTD of the status at the time. Scheduled MOTDs that are active come first,
+// then the MOTDs without a schedule and then the motd of the status. Multiple candidates rotate by the rotation count.
+func (cfg StatusConfig) selectMOTD(now time.Time, rotation uint32) string {
+	var scheduled, unscheduled []string
+	for _, m := range cfg.MOTDs {
+		if m.Schedule == "" {
+			unscheduled = append(unscheduled, m.MOTD)
+		} else if m.active(now) {
+			scheduled = append(scheduled, m.MOTD)
+		}
+	}
+
+	candidates := scheduled
+	if len(candidates) == 0 {
+		candidates = unscheduled
+	}
+	if len(candidates) == 0 {
+		return cfg.MOTD
+	}
+	return candidates[rotation%uint32(len(candidates))]
+}
+
+// validateMOTDs checks the templates and schedules of all MOTDs of the status
+func (cfg StatusConfig) validateMOTDs() error {
+	if _, err := motdTemplate(cfg.MOTD, statusRequest{}); err != nil {
+		return err
+	}
+
+	for _, m := range cfg.MOTDs {
+		if _, err := motdTemplate(m.MOTD, statusRequest{}); err != nil {
+			return err
+		}
+		if m.Schedule == "" {
+			continue
+		}
+		if _, err := cron.ParseStandard(m.Schedule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// motdTemplate parses the MOTD as a Go template with functions for the placeholders
+func motdTemplate(motd string, statusReq statusRequest) (*template.Template, error) {
+	return template.New("motd").Funcs(template.FuncMap{
+		"playersOnline": func() int {
+			return statusReq.PlayersOnline
+		},
+		"domain": func() string {
+			return statusReq.Domain
+		},
+		"backendLatency": func() int64 {
+			return statusReq.BackendLatency.Milliseconds()
+		},
+		"time": func(layout string) string {
+			return time.Now().Format(layout)
+		},
+	}).Parse(motd)
+}
+
+// renderMOTD replaces the placeholders of the MOTD. MOTDs without placeholders are returned as they are.
+func renderMOTD(motd string, statusReq statusRequest) (string, error) {
+	if !strings.Contains(motd, "{{") {
+		return motd, nil
+	}
+
+	tmpl, err := motdTemplate(motd, statusReq)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, nil); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
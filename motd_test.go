@@ -0,0 +1,74 @@
+package infrared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderMOTD(t *testing.T) {
+	statusReq := statusRequest{
+		PlayersOnline:  12,
+		Domain:         "mc.example.com",
+		BackendLatency: time.Millisecond * 35,
+	}
+
+	tt := []struct {
+		motd     string
+		expected string
+	}{
+		{motd: "Powered by Infrared", expected: "Powered by Infrared"},
+		{motd: "{{playersOnline}} players on {{domain}}", expected: "12 players on mc.example.com"},
+		{motd: "Ping: {{backendLatency}}ms", expected: "Ping: 35ms"},
+		{motd: `{{time "2006"}}`, expected: time.Now().Format("2006")},
+	}
+
+	for _, tc := range tt {
+		motd, err := renderMOTD(tc.motd, statusReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if motd != tc.expected {
+			t.Errorf("got %q; want %q", motd, tc.expected)
+		}
+	}
+
+	if _, err := renderMOTD("{{unknown}}", statusReq); err == nil {
+		t.Error("got no error; want an error for an unknown placeholder")
+	}
+}
+
+func TestStatusConfig_SelectMOTD(t *testing.T) {
+	cfg := StatusConfig{
+		MOTD: "default",
+		MOTDs: []ScheduledMOTD{
+			{MOTD: "first"},
+			{MOTD: "second"},
+			{MOTD: "weekend", Schedule: "* * * * 6,0"},
+		},
+	}
+	saturday := time.Date(2024, 6, 1, 12, 30, 15, 0, time.Local)
+	monday := time.Date(2024, 6, 3, 12, 30, 15, 0, time.Local)
+
+	if motd := cfg.selectMOTD(saturday, 0); motd != "weekend" {
+		t.Errorf("got %q on saturday; want weekend", motd)
+	}
+
+	for i, expected := range []string{"first", "second", "first"} {
+		if motd := cfg.selectMOTD(monday, uint32(i)); motd != expected {
+			t.Errorf("got %q for rotation %d; want %q", motd, i, expected)
+		}
+	}
+
+	if motd := (StatusConfig{MOTD: "default"}).selectMOTD(monday, 3); motd != "default" {
+		t.Errorf("got %q; want the default motd", motd)
+	}
+}
+
+func TestStatusConfig_ValidateMOTDs(t *testing.T) {
+	if err := (StatusConfig{MOTDs: []ScheduledMOTD{{MOTD: "event", Schedule: "0 18 24 12 *"}}}).validateMOTDs(); err != nil {
+		t.Errorf("got %v; want a valid schedule", err)
+	}
+	if err := (StatusConfig{MOTDs: []ScheduledMOTD{{MOTD: "event", Schedule: "every day"}}}).validateMOTDs(); err == nil {
+		t.Error("got no error; want an invalid schedule")
+	}
+}
@@ -0,0 +1,72 @@
+package infrared
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	pendingConnsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "infrared_pending_conns",
+		Help: "The number of connections that didn't finish their handshake and login yet",
+	})
+	pendingConnsRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "infrared_pending_conns_rejected",
+		Help: "The total number of connections rejected by the maximum of pending connections",
+	})
+)
+
+// pendingConn is a connection that didn't reach its backend yet. It counts against the
+// maximum pending connections of the gateway until it's established or closed.
+type pendingConn struct {
+	Conn
+	once    sync.Once
+	pending *int32
+}
+
+// release stops counting the connection as pending
+func (c *pendingConn) release() {
+	c.once.Do(func() {
+		atomic.AddInt32(c.pending, -1)
+		pendingConnsGauge.Dec()
+	})
+}
+
+// establish lifts the read deadline of the handshake and login and stops counting the connection as pending
+func (c *pendingConn) establish() error {
+	c.release()
+	return c.SetReadDeadline(time.Time{})
+}
+
+// establish marks the connection as established right before it's piped to its backend
+func establish(conn Conn) error {
+	if c, ok := conn.(*pendingConn); ok {
+		return c.establish()
+	}
+	return nil
+}
+
+// acquirePending counts the connection as pending and reports whether the gateway has room for it
+func (gateway *Gateway) acquirePending(conn Conn) (*pendingConn, bool) {
+	n := atomic.AddInt32(&gateway.pendingConns, 1)
+	if gateway.MaxPendingConns > 0 && n > int32(gateway.MaxPendingConns) {
+		atomic.AddInt32(&gateway.pendingConns, -1)
+		pendingConnsRejected.Inc()
+		return nil, false
+	}
+
+	pendingConnsGauge.Inc()
+	return &pendingConn{Conn: conn, pending: &gateway.pendingConns}, true
+}
+
+// setReadTimeout sets the read deadline of the connection to the timeout from now. A timeout of 0 is disabled.
+func setReadTimeout(conn Conn, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+	return conn.SetReadDeadline(time.Now().Add(timeout))
+}
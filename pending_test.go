@@ -0,0 +1,57 @@
+package infrared
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGateway_AcquirePending(t *testing.T) {
+	gateway := Gateway{MaxPendingConns: 1}
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	pending, ok := gateway.acquirePending(wrapConn(c1))
+	if !ok {
+		t.Fatal("got rejected; want the first connection accepted")
+	}
+	if _, ok := gateway.acquirePending(wrapConn(c2)); ok {
+		t.Fatal("got accepted; want the second connection rejected")
+	}
+
+	if err := establish(pending); err != nil {
+		t.Fatal(err)
+	}
+	pending.release()
+	if _, ok := gateway.acquirePending(wrapConn(c2)); !ok {
+		t.Error("got rejected; want room after the first connection was established")
+	}
+}
+
+func TestGateway_HandshakeTimeout(t *testing.T) {
+	gateway := Gateway{HandshakeTimeout: 10 * time.Millisecond}
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	pending, _ := gateway.acquirePending(wrapConn(c1))
+	defer pending.release()
+
+	// The client never sends its handshake
+	done := make(chan error)
+	go func() {
+		done <- gateway.serve(pending, ":25565")
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("got %v; want a deadline error", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("got no timeout; want the connection closed after the handshake timeout")
+	}
+}
@@ -0,0 +1,135 @@
+package infrared
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+)
+
+// Ping modes of proxies. By default Infrared answers the pings after the status responses that it sends itself,
+// while the pings of statuses that are piped from the backend are answered by the backend.
+const (
+	// LocalPing answers every ping in Infrared, so clients see the latency to the proxy
+	LocalPing = "local"
+	// BackendPing forwards every ping to the backend, so clients see the latency to the backend
+	BackendPing = "backend"
+)
+
+// statusRequest holds the details of a status request that shape its response,
+// like the values of the placeholders in its MOTD
+type statusRequest struct {
+	PlayersOnline  int
+	Domain         string
+	BackendLatency time.Duration
+	ClientProtocol int
+	// forwardPing sends the ping of the client to the backend and returns its pong.
+	// Without it the ping is answered by Infrared.
+	forwardPing func(ping protocol.Packet) (protocol.Packet, error)
+	// localization replaces the MOTDs of the statuses that Infrared answers for the country of the client
+	localization Localization
+}
+
+// newStatusRequest returns the status request of the client of the handshake
+func (proxy *Proxy) newStatusRequest(hs handshaking.ServerBoundHandshake) statusRequest {
+	proxy.mu.Lock()
+	playersOnline := len(proxy.players)
+	proxy.mu.Unlock()
+
+	return statusRequest{
+		PlayersOnline:  playersOnline,
+		Domain:         hs.ParseServerAddress(),
+		ClientProtocol: int(hs.ProtocolVersion),
+	}
+}
+
+// PingMode returns who answers the pings of clients
+func (proxy *Proxy) PingMode() string {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.PingMode
+}
+
+// pong returns the answer to the ping of the client
+func (statusReq statusRequest) pong(ping protocol.Packet) protocol.Packet {
+	if statusReq.forwardPing == nil {
+		return ping
+	}
+
+	pong, err := statusReq.forwardPing(ping)
+	if err != nil {
+		return ping
+	}
+	return pong
+}
+
+// forwardPingTo forwards pings through the open connection to the backend
+func (proxy *Proxy) forwardPingTo(rconn Conn, hs handshaking.ServerBoundHandshake) func(protocol.Packet) (protocol.Packet, error) {
+	return func(ping protocol.Packet) (protocol.Packet, error) {
+		if err := rconn.SetDeadline(time.Now().Add(proxy.Timeout())); err != nil {
+			return ping, err
+		}
+		if err := rconn.WritePacket(proxy.spoofHandshake(hs).Marshal()); err != nil {
+			return ping, err
+		}
+		if err := rconn.WritePacket(ping); err != nil {
+			return ping, err
+		}
+		return rconn.ReadPacket()
+	}
+}
+
+// dialPing connects to the backend in the background while the status is answered, so that the
+// latency of the forwarded ping doesn't include the time it takes to connect.
+// The returned close func closes the connection once it is established.
+func (proxy *Proxy) dialPing(conn Conn, proxyTo string, connRemoteAddr net.Addr, hs handshaking.ServerBoundHandshake) (func(protocol.Packet) (protocol.Packet, error), func()) {
+	type dialResult struct {
+		rconn Conn
+		err   error
+	}
+
+	dialed := make(chan dialResult, 1)
+	go func() {
+		dialer, err := proxy.Dialer()
+		if err != nil {
+			dialed <- dialResult{err: err}
+			return
+		}
+		rconn, err := dialBackend(dialer, proxyTo)
+		if err == nil && proxy.ProxyProtocol() {
+			if err = proxy.writeProxyProtocolHeader(conn, rconn, connRemoteAddr, hs); err != nil {
+				rconn.Close()
+				rconn = nil
+			}
+		}
+		dialed <- dialResult{rconn: rconn, err: err}
+	}()
+
+	var once sync.Once
+	var res dialResult
+	result := func() dialResult {
+		once.Do(func() {
+			res = <-dialed
+		})
+		return res
+	}
+
+	forward := func(ping protocol.Packet) (protocol.Packet, error) {
+		res := result()
+		if res.err != nil {
+			return ping, res.err
+		}
+		return proxy.forwardPingTo(res.rconn, hs)(ping)
+	}
+
+	closePing := func() {
+		go func() {
+			if res := result(); res.rconn != nil {
+				res.rconn.Close()
+			}
+		}()
+	}
+	return forward, closePing
+}
@@ -0,0 +1,47 @@
+package infrared
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/haveachin/infrared/protocol"
+)
+
+func TestStatusRequest_Pong(t *testing.T) {
+	ping := protocol.Packet{ID: 0x01, Data: []byte{0, 0, 0, 0, 0, 0, 0, 42}}
+	backendPong := protocol.Packet{ID: 0x01, Data: []byte{0, 0, 0, 0, 0, 0, 0, 43}}
+
+	tt := []struct {
+		name        string
+		forwardPing func(protocol.Packet) (protocol.Packet, error)
+		pong        protocol.Packet
+	}{
+		{
+			name: "Local",
+			pong: ping,
+		},
+		{
+			name: "Forwarded",
+			forwardPing: func(protocol.Packet) (protocol.Packet, error) {
+				return backendPong, nil
+			},
+			pong: backendPong,
+		},
+		{
+			name: "BackendDoesNotAnswer",
+			forwardPing: func(ping protocol.Packet) (protocol.Packet, error) {
+				return ping, errors.New("timeout")
+			},
+			pong: ping,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			statusReq := statusRequest{forwardPing: tc.forwardPing}
+			if pong := statusReq.pong(ping); string(pong.Data) != string(tc.pong.Data) {
+				t.Errorf("got pong %v; want %v", pong.Data, tc.pong.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package infrared
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/haveachin/infrared/provider"
+)
+
+// ProtectionProfile bundles the bot protection settings of proxies under a name, like "normal" or "under-attack".
+// Every setting that a profile has replaces the setting of the proxies that use the profile. Fields that the
+// setting of a profile leaves out have their defaults, not the values of the proxy.
+type ProtectionProfile struct {
+	RateLimits         *RateLimitsConfig     `json:"rateLimits,omitempty"`
+	HandshakeLimits    *ConnLimitsConfig     `json:"handshakeLimits,omitempty"`
+	RejoinVerification *RejoinConfig         `json:"rejoinVerification,omitempty"`
+	Authentication     *AuthConfig           `json:"authentication,omitempty"`
+	UsernameFilter     *UsernameFilterConfig `json:"usernameFilter,omitempty"`
+	GeoIP              *GeoIPConfig          `json:"geoIP,omitempty"`
+}
+
+// protection holds the profiles of the gateway and the profile that all proxies use instead of their own
+var protection = struct {
+	sync.RWMutex
+	profiles map[string]ProtectionProfile
+	override string
+}{}
+
+// parseProtectionProfile parses the settings of a profile on top of their defaults
+func parseProtectionProfile(fields map[string]json.RawMessage) (ProtectionProfile, error) {
+	defaults := DefaultProxyConfig()
+	var profile ProtectionProfile
+	for key, raw := range fields {
+		var err error
+		switch key {
+		case "rateLimits":
+			cfg := defaults.RateLimits
+			err = json.Unmarshal(raw, &cfg)
+			profile.RateLimits = &cfg
+		case "handshakeLimits":
+			cfg := defaults.HandshakeLimits
+			err = json.Unmarshal(raw, &cfg)
+			profile.HandshakeLimits = &cfg
+		case "rejoinVerification":
+			cfg := defaults.RejoinVerification
+			if err = json.Unmarshal(raw, &cfg); err == nil {
+				err = cfg.validate()
+			}
+			profile.RejoinVerification = &cfg
+		case "authentication":
+			cfg := defaults.Authentication
+			if err = json.Unmarshal(raw, &cfg); err == nil {
+				err = cfg.validate()
+			}
+			profile.Authentication = &cfg
+		case "usernameFilter":
+			cfg := defaults.UsernameFilter
+			if err = json.Unmarshal(raw, &cfg); err == nil {
+				err = cfg.validate()
+			}
+			profile.UsernameFilter = &cfg
+		case "geoIP":
+			cfg := defaults.GeoIP
+			if err = json.Unmarshal(raw, &cfg); err == nil {
+				err = cfg.validate()
+			}
+			profile.GeoIP = &cfg
+		default:
+			return profile, fmt.Errorf("unknown setting %s", key)
+		}
+		if err != nil {
+			return profile, fmt.Errorf("invalid %s; %s", key, err)
+		}
+	}
+	return profile, nil
+}
+
+// LoadProtectionProfiles loads the protection profiles from a config file with an object of profiles by their name
+func LoadProtectionProfiles(path string) (map[string]ProtectionProfile, error) {
+	bb, err := provider.ReadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(bb, &raw); err != nil {
+		return nil, err
+	}
+
+	profiles := make(map[string]ProtectionProfile, len(raw))
+	for name, fields := range raw {
+		profile, err := parseProtectionProfile(fields)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile %s; %s", name, err)
+		}
+		profiles[name] = profile
+	}
+	return profiles, nil
+}
+
+// SetProtectionProfiles sets the profiles that proxies can use
+func SetProtectionProfiles(profiles map[string]ProtectionProfile) {
+	protection.Lock()
+	defer protection.Unlock()
+	protection.profiles = profiles
+	if _, ok := profiles[protection.override]; !ok {
+		protection.override = ""
+	}
+}
+
+// ProtectionProfileNames returns the sorted names of the profiles
+func ProtectionProfileNames() []string {
+	protection.RLock()
+	defer protection.RUnlock()
+	names := make([]string, 0, len(protection.profiles))
+	for name := range protection.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetProtectionOverride makes all proxies use the profile instead of their own until the override is set
+// to the empty name again. It's the panic button for attacks on many proxies.
+func SetProtectionOverride(name string) error {
+	protection.Lock()
+	defer protection.Unlock()
+	if _, ok := protection.profiles[name]; name != "" && !ok {
+		return fmt.Errorf("unknown protection profile %s", name)
+	}
+	protection.override = name
+	if name == "" {
+		log.Println("[i] Lifting the protection profile override")
+	} else {
+		log.Printf("[i] Switching all proxies to the protection profile %s", name)
+	}
+	return nil
+}
+
+// ProtectionOverride returns the name of the profile that all proxies use instead of their own
+func ProtectionOverride() string {
+	protection.RLock()
+	defer protection.RUnlock()
+	return protection.override
+}
+
+// validateProtectionProfile checks that the profile exists, if any profiles are loaded
+func validateProtectionProfile(name string) error {
+	protection.RLock()
+	defer protection.RUnlock()
+	if name == "" || protection.profiles == nil {
+		return nil
+	}
+	if _, ok := protection.profiles[name]; !ok {
+		return fmt.Errorf("unknown profile %s", name)
+	}
+	return nil
+}
+
+// ActiveProtectionProfile returns the name of the profile that the proxy uses, which is the override of all proxies
+// or the profile of its config
+func (proxy *Proxy) ActiveProtectionProfile() string {
+	if override := ProtectionOverride(); override != "" {
+		return override
+	}
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.ProtectionProfile
+}
+
+// protectionProfile returns the profile that the proxy uses. Proxies without a profile get an empty one.
+func (proxy *Proxy) protectionProfile() ProtectionProfile {
+	name := proxy.ActiveProtectionProfile()
+	if name == "" {
+		return ProtectionProfile{}
+	}
+	protection.RLock()
+	defer protection.RUnlock()
+	return protection.profiles[name]
+}
@@ -0,0 +1,71 @@
+package infrared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProtectionProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yml")
+	profiles := `
+normal: {}
+under-attack:
+  rateLimits:
+    login:
+      requests: 3
+  rejoinVerification:
+    mode: always
+`
+	if err := os.WriteFile(path, []byte(profiles), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadProtectionProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetProtectionProfiles(loaded)
+	defer SetProtectionProfiles(nil)
+
+	proxy := &Proxy{Config: &ProxyConfig{
+		ProtectionProfile: "normal",
+		RateLimits:        RateLimitsConfig{Login: RateLimitConfig{Requests: 10, Window: 500}},
+		UsernameFilter:    UsernameFilterConfig{MinLength: 3},
+	}}
+	if limits := proxy.RateLimits(); limits.Login.Requests != 10 {
+		t.Errorf("got %d login requests; want the 10 of the proxy", limits.Login.Requests)
+	}
+
+	if err := SetProtectionOverride("under-attack"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetProtectionOverride("")
+
+	// The fields that the profile leaves out have their defaults
+	limits := proxy.RateLimits()
+	if limits.Login.Requests != 3 || limits.Login.Window != DefaultProxyConfig().RateLimits.Login.Window {
+		t.Errorf("got login limit %+v; want 3 requests in the default window", limits.Login)
+	}
+	if mode := proxy.RejoinVerification().Mode; mode != RejoinAlways {
+		t.Errorf("got rejoin mode %q; want %q", mode, RejoinAlways)
+	}
+	if filter := proxy.UsernameFilter(); filter.MinLength != 3 {
+		t.Errorf("got username filter %+v; want the filter of the proxy", filter)
+	}
+
+	if err := SetProtectionOverride("unknown"); err == nil {
+		t.Error("got no error; want an error for an unknown profile")
+	}
+}
+
+func TestLoadProtectionProfiles_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte(`{"strict": {"maintenance": true}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadProtectionProfiles(path); err == nil {
+		t.Error("got no error; want an error for a setting that profiles don't have")
+	}
+}
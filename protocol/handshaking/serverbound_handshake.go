@@ -11,8 +11,9 @@ import (
 const (
 	ServerBoundHandshakePacketID byte = 0x00
 
-	ServerBoundHandshakeStatusState = protocol.Byte(1)
-	ServerBoundHandshakeLoginState  = protocol.Byte(2)
+	ServerBoundHandshakeStatusState   = protocol.Byte(1)
+	ServerBoundHandshakeLoginState    = protocol.Byte(2)
+	ServerBoundHandshakeTransferState = protocol.Byte(3)
 
 	ForgeSeparator  = "\x00"
 	RealIPSeparator = "///"
@@ -58,8 +59,15 @@ func (pk ServerBoundHandshake) IsStatusRequest() bool {
 	return pk.NextState == ServerBoundHandshakeStatusState
 }
 
+// IsLoginRequest reports whether the client wants to log in.
+// Clients that were transferred by another server (1.20.5+) log in too.
 func (pk ServerBoundHandshake) IsLoginRequest() bool {
-	return pk.NextState == ServerBoundHandshakeLoginState
+	return pk.NextState == ServerBoundHandshakeLoginState || pk.IsTransferRequest()
+}
+
+// IsTransferRequest reports whether the client was transferred by another server (1.20.5+)
+func (pk ServerBoundHandshake) IsTransferRequest() bool {
+	return pk.NextState == ServerBoundHandshakeTransferState
 }
 
 func (pk ServerBoundHandshake) IsForgeAddress() bool {
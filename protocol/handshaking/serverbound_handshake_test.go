@@ -147,6 +147,12 @@ func TestServerBoundHandshake_IsLoginRequest(t *testing.T) {
 			},
 			result: true,
 		},
+		{
+			handshake: ServerBoundHandshake{
+				NextState: ServerBoundHandshakeTransferState,
+			},
+			result: true,
+		},
 	}
 
 	for _, tc := range tt {
@@ -156,6 +162,32 @@ func TestServerBoundHandshake_IsLoginRequest(t *testing.T) {
 	}
 }
 
+func TestServerBoundHandshake_IsTransferRequest(t *testing.T) {
+	tt := []struct {
+		handshake ServerBoundHandshake
+		result    bool
+	}{
+		{
+			handshake: ServerBoundHandshake{
+				NextState: ServerBoundHandshakeLoginState,
+			},
+			result: false,
+		},
+		{
+			handshake: ServerBoundHandshake{
+				NextState: ServerBoundHandshakeTransferState,
+			},
+			result: true,
+		},
+	}
+
+	for _, tc := range tt {
+		if tc.handshake.IsTransferRequest() != tc.result {
+			t.Fail()
+		}
+	}
+}
+
 func TestServerBoundHandshake_IsForgeAddress(t *testing.T) {
 	tt := []struct {
 		addr   string
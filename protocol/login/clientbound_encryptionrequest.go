@@ -0,0 +1,36 @@
+package login
+
+import (
+	"github.com/haveachin/infrared/protocol"
+)
+
+const (
+	ClientBoundEncryptionRequestPacketID byte = 0x01
+
+	// ShouldAuthenticateVersion is the first protocol version (1.20.5) whose encryption request has ShouldAuthenticate
+	ShouldAuthenticateVersion = protocol.VarInt(766)
+)
+
+type ClientBoundEncryptionRequest struct {
+	ServerID           protocol.String
+	PublicKey          protocol.ByteArray
+	VerifyToken        protocol.ByteArray
+	ShouldAuthenticate protocol.Boolean
+}
+
+// Marshal encodes the packet in the format of the protocol version
+func (pk ClientBoundEncryptionRequest) Marshal(protocolVersion protocol.VarInt) protocol.Packet {
+	fields := []protocol.FieldEncoder{
+		pk.ServerID,
+		pk.PublicKey,
+		pk.VerifyToken,
+	}
+	if protocolVersion >= ShouldAuthenticateVersion {
+		fields = append(fields, pk.ShouldAuthenticate)
+	}
+
+	return protocol.MarshalPacket(
+		ClientBoundEncryptionRequestPacketID,
+		fields...,
+	)
+}
@@ -0,0 +1,36 @@
+package login
+
+import (
+	"bytes"
+	"github.com/haveachin/infrared/protocol"
+	"testing"
+)
+
+func TestClientBoundEncryptionRequest_Marshal(t *testing.T) {
+	pk := ClientBoundEncryptionRequest{
+		ServerID:           protocol.String(""),
+		PublicKey:          protocol.ByteArray{0x01, 0x02},
+		VerifyToken:        protocol.ByteArray{0x03},
+		ShouldAuthenticate: true,
+	}
+
+	tt := []struct {
+		protocolVersion protocol.VarInt
+		data            []byte
+	}{
+		{protocolVersion: 765, data: []byte{0x00, 0x02, 0x01, 0x02, 0x01, 0x03}},
+		{protocolVersion: 766, data: []byte{0x00, 0x02, 0x01, 0x02, 0x01, 0x03, 0x01}},
+	}
+
+	for _, tc := range tt {
+		marshaled := pk.Marshal(tc.protocolVersion)
+
+		if marshaled.ID != ClientBoundEncryptionRequestPacketID {
+			t.Error("invalid packet id")
+		}
+
+		if !bytes.Equal(marshaled.Data, tc.data) {
+			t.Errorf("protocol %d: got: %v; want: %v", tc.protocolVersion, marshaled.Data, tc.data)
+		}
+	}
+}
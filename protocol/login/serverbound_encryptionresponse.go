@@ -0,0 +1,56 @@
+package login
+
+import (
+	"bytes"
+	"github.com/haveachin/infrared/protocol"
+)
+
+const (
+	ServerBoundEncryptionResponsePacketID byte = 0x01
+
+	// Clients of 1.19 up to 1.19.2 can sign the verify token with their profile key instead of sending it
+	signedVerifyTokenMinVersion = protocol.VarInt(759)
+	signedVerifyTokenMaxVersion = protocol.VarInt(760)
+)
+
+type ServerBoundEncryptionResponse struct {
+	SharedSecret protocol.ByteArray
+	// VerifyToken is empty if the client signed the verify token instead
+	VerifyToken protocol.ByteArray
+	Salt        protocol.Long
+	Signature   protocol.ByteArray
+}
+
+// UnmarshalServerBoundEncryptionResponse decodes the packet in the format of the protocol version
+func UnmarshalServerBoundEncryptionResponse(packet protocol.Packet, protocolVersion protocol.VarInt) (ServerBoundEncryptionResponse, error) {
+	var pk ServerBoundEncryptionResponse
+
+	if packet.ID != ServerBoundEncryptionResponsePacketID {
+		return pk, protocol.ErrInvalidPacketID
+	}
+
+	if protocolVersion < signedVerifyTokenMinVersion || protocolVersion > signedVerifyTokenMaxVersion {
+		if err := packet.Scan(&pk.SharedSecret, &pk.VerifyToken); err != nil {
+			return pk, err
+		}
+		return pk, nil
+	}
+
+	r := bytes.NewReader(packet.Data)
+	var hasVerifyToken protocol.Boolean
+	if err := protocol.ScanFields(r, &pk.SharedSecret, &hasVerifyToken); err != nil {
+		return pk, err
+	}
+	if hasVerifyToken {
+		if err := protocol.ScanFields(r, &pk.VerifyToken); err != nil {
+			return pk, err
+		}
+		return pk, nil
+	}
+
+	// Without a verify token, the salt and the signature follow instead
+	if err := protocol.ScanFields(r, &pk.Salt, &pk.Signature); err != nil {
+		return pk, err
+	}
+	return pk, nil
+}
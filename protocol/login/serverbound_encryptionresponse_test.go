@@ -0,0 +1,62 @@
+package login
+
+import (
+	"bytes"
+	"github.com/haveachin/infrared/protocol"
+	"testing"
+)
+
+func TestUnmarshalServerBoundEncryptionResponse(t *testing.T) {
+	tt := []struct {
+		protocolVersion protocol.VarInt
+		packet          protocol.Packet
+		sharedSecret    []byte
+		verifyToken     []byte
+		signature       []byte
+	}{
+		{
+			protocolVersion: 47,
+			packet:          protocol.Packet{ID: 0x01, Data: []byte{0x02, 0x0a, 0x0b, 0x01, 0x0c}},
+			sharedSecret:    []byte{0x0a, 0x0b},
+			verifyToken:     []byte{0x0c},
+		},
+		{
+			protocolVersion: 759,
+			packet:          protocol.Packet{ID: 0x01, Data: []byte{0x02, 0x0a, 0x0b, 0x01, 0x01, 0x0c}},
+			sharedSecret:    []byte{0x0a, 0x0b},
+			verifyToken:     []byte{0x0c},
+		},
+		{
+			protocolVersion: 760,
+			packet: protocol.Packet{ID: 0x01, Data: []byte{
+				0x02, 0x0a, 0x0b, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+				0x01, 0x0d,
+			}},
+			sharedSecret: []byte{0x0a, 0x0b},
+			signature:    []byte{0x0d},
+		},
+	}
+
+	for _, tc := range tt {
+		pk, err := UnmarshalServerBoundEncryptionResponse(tc.packet, tc.protocolVersion)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if !bytes.Equal(pk.SharedSecret, tc.sharedSecret) {
+			t.Errorf("protocol %d: got shared secret %v; want: %v", tc.protocolVersion, pk.SharedSecret, tc.sharedSecret)
+		}
+		if !bytes.Equal(pk.VerifyToken, tc.verifyToken) {
+			t.Errorf("protocol %d: got verify token %v; want: %v", tc.protocolVersion, pk.VerifyToken, tc.verifyToken)
+		}
+		if !bytes.Equal(pk.Signature, tc.signature) {
+			t.Errorf("protocol %d: got signature %v; want: %v", tc.protocolVersion, pk.Signature, tc.signature)
+		}
+	}
+
+	if _, err := UnmarshalServerBoundEncryptionResponse(protocol.Packet{ID: 0x00}, 47); err != protocol.ErrInvalidPacketID {
+		t.Errorf("got %v; want %v", err, protocol.ErrInvalidPacketID)
+	}
+}
@@ -0,0 +1,71 @@
+package raknet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+)
+
+const (
+	OpenConnectionRequest1PacketID byte = 0x05
+	OpenConnectionRequest2PacketID byte = 0x07
+)
+
+// OpenConnectionRequest2 is the second packet of the handshake of a RakNet connection.
+// ServerAddress is the address that the client connects to.
+type OpenConnectionRequest2 struct {
+	ServerAddress *net.UDPAddr
+	MTU           uint16
+	ClientGUID    int64
+}
+
+func (pk OpenConnectionRequest2) Marshal() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(OpenConnectionRequest2PacketID)
+	buf.Write(Magic)
+	writeAddress(buf, pk.ServerAddress)
+	binary.Write(buf, binary.BigEndian, pk.MTU)
+	binary.Write(buf, binary.BigEndian, pk.ClientGUID)
+	return buf.Bytes()
+}
+
+func UnmarshalOpenConnectionRequest2(b []byte) (OpenConnectionRequest2, error) {
+	var pk OpenConnectionRequest2
+	r := bytes.NewReader(b)
+
+	if _, err := readID(r, OpenConnectionRequest2PacketID); err != nil {
+		return pk, err
+	}
+	if err := readMagic(r); err != nil {
+		return pk, err
+	}
+
+	addr, err := readAddress(r)
+	if err != nil {
+		return pk, err
+	}
+	pk.ServerAddress = addr
+
+	if err := binary.Read(r, binary.BigEndian, &pk.MTU); err != nil {
+		return pk, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &pk.ClientGUID); err != nil {
+		return pk, err
+	}
+	return pk, nil
+}
+
+// IsOfflineMessage reports whether the packet is one of the unconnected messages
+// that clients send before their RakNet connection is established
+func IsOfflineMessage(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+
+	switch b[0] {
+	case UnconnectedPingPacketID, UnconnectedPingOpenConnectionsPacketID,
+		OpenConnectionRequest1PacketID, OpenConnectionRequest2PacketID:
+		return bytes.Contains(b, Magic)
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package raknet
+
+import (
+	"net"
+	"testing"
+)
+
+func TestOpenConnectionRequest2(t *testing.T) {
+	tt := []string{"203.0.113.5:19132", "[2001:db8::1]:19133"}
+
+	for _, addr := range tt {
+		serverAddr, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pk := OpenConnectionRequest2{ServerAddress: serverAddr, MTU: 1400, ClientGUID: 99}
+		unmarshaled, err := UnmarshalOpenConnectionRequest2(pk.Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if unmarshaled.ServerAddress.String() != addr || unmarshaled.MTU != pk.MTU || unmarshaled.ClientGUID != pk.ClientGUID {
+			t.Errorf("got %+v; want %+v", unmarshaled, pk)
+		}
+	}
+}
+
+func TestIsOfflineMessage(t *testing.T) {
+	if IsOfflineMessage([]byte{0x84, 0x00, 0x00, 0x00}) {
+		t.Error("got offline message for a frame set")
+	}
+	if IsOfflineMessage(nil) {
+		t.Error("got offline message for an empty packet")
+	}
+}
@@ -0,0 +1,101 @@
+package raknet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/haveachin/infrared/protocol"
+)
+
+// Magic is the sequence of bytes that every offline RakNet message contains
+var Magic = []byte{0x00, 0xff, 0xff, 0x00, 0xfe, 0xfe, 0xfe, 0xfe, 0xfd, 0xfd, 0xfd, 0xfd, 0x12, 0x34, 0x56, 0x78}
+
+var (
+	ErrInvalidMagic   = errors.New("invalid magic")
+	ErrInvalidAddress = errors.New("invalid address")
+)
+
+// readID reads the packet ID and checks that it is one of the ids
+func readID(r *bytes.Reader, ids ...byte) (byte, error) {
+	id, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, validID := range ids {
+		if id == validID {
+			return id, nil
+		}
+	}
+	return 0, protocol.ErrInvalidPacketID
+}
+
+func readMagic(r *bytes.Reader) error {
+	magic := make([]byte, len(Magic))
+	if _, err := r.Read(magic); err != nil {
+		return err
+	}
+	if !bytes.Equal(magic, Magic) {
+		return ErrInvalidMagic
+	}
+	return nil
+}
+
+// readAddress reads an address in the RakNet format. The bytes of IPv4 addresses are inverted.
+func readAddress(r *bytes.Reader) (*net.UDPAddr, error) {
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch version {
+	case 4:
+		ip := make(net.IP, net.IPv4len)
+		if _, err := r.Read(ip); err != nil {
+			return nil, err
+		}
+		for i := range ip {
+			ip[i] = ^ip[i]
+		}
+
+		var port uint16
+		if err := binary.Read(r, binary.BigEndian, &port); err != nil {
+			return nil, err
+		}
+		return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+	case 6:
+		var header struct {
+			Family   uint16
+			Port     uint16
+			FlowInfo uint32
+			IP       [net.IPv6len]byte
+			ScopeID  uint32
+		}
+		// The address family is little endian, but it isn't needed
+		if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+			return nil, err
+		}
+		return &net.UDPAddr{IP: net.IP(header.IP[:]), Port: int(header.Port)}, nil
+	}
+	return nil, ErrInvalidAddress
+}
+
+func writeAddress(buf *bytes.Buffer, addr *net.UDPAddr) {
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		buf.WriteByte(4)
+		for _, b := range ip4 {
+			buf.WriteByte(^b)
+		}
+		binary.Write(buf, binary.BigEndian, uint16(addr.Port))
+		return
+	}
+
+	buf.WriteByte(6)
+	binary.Write(buf, binary.LittleEndian, uint16(23))
+	binary.Write(buf, binary.BigEndian, uint16(addr.Port))
+	binary.Write(buf, binary.BigEndian, uint32(0))
+	buf.Write(addr.IP.To16())
+	binary.Write(buf, binary.BigEndian, uint32(0))
+}
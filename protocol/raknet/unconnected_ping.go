@@ -0,0 +1,45 @@
+package raknet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	UnconnectedPingPacketID                byte = 0x01
+	UnconnectedPingOpenConnectionsPacketID byte = 0x02
+)
+
+// UnconnectedPing is sent by clients that want to show the server in their server list
+type UnconnectedPing struct {
+	SendTimestamp int64
+	ClientGUID    int64
+}
+
+func (pk UnconnectedPing) Marshal() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(UnconnectedPingPacketID)
+	binary.Write(buf, binary.BigEndian, pk.SendTimestamp)
+	buf.Write(Magic)
+	binary.Write(buf, binary.BigEndian, pk.ClientGUID)
+	return buf.Bytes()
+}
+
+func UnmarshalUnconnectedPing(b []byte) (UnconnectedPing, error) {
+	var pk UnconnectedPing
+	r := bytes.NewReader(b)
+
+	if _, err := readID(r, UnconnectedPingPacketID, UnconnectedPingOpenConnectionsPacketID); err != nil {
+		return pk, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &pk.SendTimestamp); err != nil {
+		return pk, err
+	}
+	if err := readMagic(r); err != nil {
+		return pk, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &pk.ClientGUID); err != nil {
+		return pk, err
+	}
+	return pk, nil
+}
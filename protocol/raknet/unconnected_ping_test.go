@@ -0,0 +1,27 @@
+package raknet
+
+import (
+	"testing"
+)
+
+func TestUnconnectedPing(t *testing.T) {
+	pk := UnconnectedPing{SendTimestamp: 1234, ClientGUID: -42}
+
+	b := pk.Marshal()
+	if !IsOfflineMessage(b) {
+		t.Error("got no offline message")
+	}
+
+	unmarshaled, err := UnmarshalUnconnectedPing(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unmarshaled != pk {
+		t.Errorf("got %+v; want %+v", unmarshaled, pk)
+	}
+
+	b[10] = 0
+	if _, err := UnmarshalUnconnectedPing(b); err != ErrInvalidMagic {
+		t.Errorf("got %v; want %v", err, ErrInvalidMagic)
+	}
+}
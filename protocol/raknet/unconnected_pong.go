@@ -0,0 +1,124 @@
+package raknet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const UnconnectedPongPacketID byte = 0x1c
+
+// UnconnectedPong is the answer to an UnconnectedPing. The ServerID describes the server
+// for the server list of the client.
+type UnconnectedPong struct {
+	SendTimestamp int64
+	ServerGUID    int64
+	ServerID      string
+}
+
+func (pk UnconnectedPong) Marshal() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(UnconnectedPongPacketID)
+	binary.Write(buf, binary.BigEndian, pk.SendTimestamp)
+	binary.Write(buf, binary.BigEndian, pk.ServerGUID)
+	buf.Write(Magic)
+	binary.Write(buf, binary.BigEndian, uint16(len(pk.ServerID)))
+	buf.WriteString(pk.ServerID)
+	return buf.Bytes()
+}
+
+func UnmarshalUnconnectedPong(b []byte) (UnconnectedPong, error) {
+	var pk UnconnectedPong
+	r := bytes.NewReader(b)
+
+	if _, err := readID(r, UnconnectedPongPacketID); err != nil {
+		return pk, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &pk.SendTimestamp); err != nil {
+		return pk, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &pk.ServerGUID); err != nil {
+		return pk, err
+	}
+	if err := readMagic(r); err != nil {
+		return pk, err
+	}
+
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return pk, err
+	}
+	serverID := make([]byte, length)
+	if _, err := r.Read(serverID); err != nil {
+		return pk, err
+	}
+	pk.ServerID = string(serverID)
+	return pk, nil
+}
+
+// ServerID is the parsed server ID of a Bedrock server, like
+// MCPE;Dedicated Server;594;1.20.0;0;10;13253860892328930865;Bedrock level;Survival;1;19132;19133;
+type ServerID struct {
+	Edition         string
+	MOTD            string
+	ProtocolVersion int
+	Version         string
+	PlayersOnline   int
+	MaxPlayers      int
+	ServerUniqueID  string
+	SubMOTD         string
+	GameMode        string
+	GameModeID      int
+	PortV4          int
+	PortV6          int
+}
+
+func ParseServerID(s string) (ServerID, error) {
+	fields := strings.Split(s, ";")
+	if len(fields) < 6 {
+		return ServerID{}, fmt.Errorf("server id has %d instead of at least 6 fields", len(fields))
+	}
+
+	// Only the first six fields are sent by every server
+	for len(fields) < 12 {
+		fields = append(fields, "")
+	}
+
+	atoi := func(s string) int {
+		i, _ := strconv.Atoi(s)
+		return i
+	}
+	return ServerID{
+		Edition:         fields[0],
+		MOTD:            fields[1],
+		ProtocolVersion: atoi(fields[2]),
+		Version:         fields[3],
+		PlayersOnline:   atoi(fields[4]),
+		MaxPlayers:      atoi(fields[5]),
+		ServerUniqueID:  fields[6],
+		SubMOTD:         fields[7],
+		GameMode:        fields[8],
+		GameModeID:      atoi(fields[9]),
+		PortV4:          atoi(fields[10]),
+		PortV6:          atoi(fields[11]),
+	}, nil
+}
+
+func (id ServerID) String() string {
+	return fmt.Sprintf("%s;%s;%d;%s;%d;%d;%s;%s;%s;%d;%d;%d;",
+		id.Edition,
+		id.MOTD,
+		id.ProtocolVersion,
+		id.Version,
+		id.PlayersOnline,
+		id.MaxPlayers,
+		id.ServerUniqueID,
+		id.SubMOTD,
+		id.GameMode,
+		id.GameModeID,
+		id.PortV4,
+		id.PortV6,
+	)
+}
@@ -0,0 +1,53 @@
+package raknet
+
+import (
+	"testing"
+)
+
+func TestUnconnectedPong(t *testing.T) {
+	pk := UnconnectedPong{
+		SendTimestamp: 1234,
+		ServerGUID:    5678,
+		ServerID:      "MCPE;Dedicated Server;594;1.20.0;0;10;13253860892328930865;Bedrock level;Survival;1;19132;19133;",
+	}
+
+	unmarshaled, err := UnmarshalUnconnectedPong(pk.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unmarshaled != pk {
+		t.Errorf("got %+v; want %+v", unmarshaled, pk)
+	}
+}
+
+func TestParseServerID(t *testing.T) {
+	tt := []struct {
+		serverID string
+		expected string
+		err      bool
+	}{
+		{
+			serverID: "MCPE;Dedicated Server;594;1.20.0;0;10;13253860892328930865;Bedrock level;Survival;1;19132;19133;",
+			expected: "MCPE;Dedicated Server;594;1.20.0;0;10;13253860892328930865;Bedrock level;Survival;1;19132;19133;",
+		},
+		{
+			serverID: "MCPE;Old Server;137;1.2.0;3;20",
+			expected: "MCPE;Old Server;137;1.2.0;3;20;;;;0;0;0;",
+		},
+		{
+			serverID: "MCPE;Broken",
+			err:      true,
+		},
+	}
+
+	for _, tc := range tt {
+		id, err := ParseServerID(tc.serverID)
+		if (err != nil) != tc.err {
+			t.Errorf("%s: got error %v; want error %t", tc.serverID, err, tc.err)
+			continue
+		}
+		if err == nil && id.String() != tc.expected {
+			t.Errorf("got %s; want %s", id, tc.expected)
+		}
+	}
+}
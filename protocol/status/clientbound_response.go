@@ -1,6 +1,8 @@
 package status
 
 import (
+	"encoding/json"
+
 	"github.com/haveachin/infrared/protocol"
 )
 
@@ -57,5 +59,6 @@ type PlayerSampleJSON struct {
 }
 
 type DescriptionJSON struct {
-	Text string `json:"text"`
+	Text  string            `json:"text"`
+	Extra []json.RawMessage `json:"extra,omitempty"`
 }
@@ -0,0 +1,287 @@
+package provider
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+)
+
+// Prioritized is a Provider with a priority. Configs of providers with a higher
+// priority override configs of providers with a lower priority.
+//
+// If Namespace is set, the IDs of all configs of the Provider are prefixed with
+// "<namespace>:", so that they are never merged with configs of other providers.
+type Prioritized struct {
+	Provider
+	Priority  int
+	Namespace string
+}
+
+// Composite merges the configs of multiple providers into one Data.
+//
+// The merge order is deterministic: Providers are merged in ascending order of their
+// priority and providers with the same priority in the order they were given in.
+// If multiple providers supply a config with the same ID, the JSON objects are merged
+// deeply and the fields of later providers override the fields of earlier ones.
+// Values that are no JSON objects are replaced as a whole.
+//
+// After merging, every proxy (domainName and listenTo) is only defined by one config.
+// If multiple configs define the same proxy, the config that was merged last wins
+// and the other configs are dropped.
+//
+// Merged Data is only sent if its Checksum differs from the last sent Data.
+type Composite struct {
+	Providers []Prioritized
+
+	mu       sync.Mutex
+	data     []Data
+	checksum string
+	closed   chan bool
+	once     sync.Once
+	// overrides are the proxies of the last merge that are defined by multiple configs
+	overrides map[override]bool
+}
+
+// override is a config that overrides the proxy of another config
+type override struct {
+	id, proxy, owner string
+}
+
+// NewComposite creates a new Composite of the providers
+func NewComposite(providers ...Prioritized) *Composite {
+	sorted := make([]Prioritized, len(providers))
+	copy(sorted, providers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	return &Composite{
+		Providers: sorted,
+		data:      make([]Data, len(sorted)),
+		closed:    make(chan bool),
+	}
+}
+
+func (p *Composite) Provide(dataCh chan<- Data) (Data, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, prov := range p.Providers {
+		innerCh := make(chan Data)
+		data, err := prov.Provide(innerCh)
+		if err != nil {
+			// Stops the forwarders of the providers that already provided
+			p.once.Do(func() {
+				close(p.closed)
+			})
+			for _, prov := range p.Providers[:i] {
+				prov.Close()
+			}
+			return Data{}, err
+		}
+		p.data[i] = data
+
+		go p.forward(i, innerCh, dataCh)
+	}
+
+	merged := p.merge()
+	p.checksum = merged.Checksum()
+	return merged, nil
+}
+
+// Status returns the combined Status of all providers: the most recent load and error
+// and whether any Provider is still watching for changes
+func (p *Composite) Status() Status {
+	status := Status{Type: CompositeType}
+	for _, s := range p.Statuses() {
+		if s.LastLoad.After(status.LastLoad) {
+			status.LastLoad = s.LastLoad
+		}
+		if s.LastErrorTime.After(status.LastErrorTime) {
+			status.LastError = s.LastError
+			status.LastErrorTime = s.LastErrorTime
+		}
+		status.Watching = status.Watching || s.Watching
+	}
+	return status
+}
+
+// Current returns the current merged Data of all providers
+func (p *Composite) Current() Data {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.merge()
+}
+
+// Sources returns the types of the providers that supply each config ID in merge order,
+// so that the provider of a merged value can be found
+func (p *Composite) Sources() map[string][]Type {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sources := map[string][]Type{}
+	for i, data := range p.data {
+		for id := range data.Configs {
+			if ns := p.Providers[i].Namespace; ns != "" {
+				id = ns + ":" + id
+			}
+			sources[id] = append(sources[id], data.Type)
+		}
+	}
+	return sources
+}
+
+// Statuses returns the Status of every Provider in merge order
+func (p *Composite) Statuses() []Status {
+	statuses := make([]Status, len(p.Providers))
+	for i, prov := range p.Providers {
+		statuses[i] = prov.Status()
+	}
+	return statuses
+}
+
+func (p *Composite) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+
+	var lastErr error
+	for _, prov := range p.Providers {
+		if err := prov.Close(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (p *Composite) forward(i int, innerCh <-chan Data, dataCh chan<- Data) {
+	for {
+		select {
+		case data := <-innerCh:
+			p.mu.Lock()
+			p.data[i] = data
+			merged := p.merge()
+			// Touched files and redundant polls must not cause a reload
+			checksum := merged.Checksum()
+			unchanged := checksum == p.checksum
+			p.checksum = checksum
+			p.mu.Unlock()
+
+			if unchanged {
+				continue
+			}
+
+			// select picks randomly if the composite is closed while dataCh is ready
+			select {
+			case <-p.closed:
+				return
+			default:
+			}
+
+			select {
+			case dataCh <- merged:
+			case <-p.closed:
+				return
+			}
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+func (p *Composite) merge() Data {
+	configs := map[string][]byte{}
+	var ids []string
+	for i, data := range p.data {
+		for _, id := range sortedIDs(data.Configs) {
+			cfg := data.Configs[id]
+			if ns := p.Providers[i].Namespace; ns != "" {
+				id = ns + ":" + id
+			}
+
+			if base, ok := configs[id]; ok {
+				cfg = mergeJSON(base, cfg)
+			} else {
+				ids = append(ids, id)
+			}
+			configs[id] = cfg
+		}
+	}
+
+	// Resolve proxies that are defined by multiple configs
+	owners := map[string]string{}
+	overrides := map[override]bool{}
+	for _, id := range ids {
+		key, ok := proxyKey(configs[id])
+		if !ok {
+			continue
+		}
+
+		if owner, ok := owners[key]; ok {
+			// Merges run on every change, so only new overrides are logged
+			o := override{id: id, proxy: key, owner: owner}
+			if !p.overrides[o] {
+				log.Printf("Config %s overrides the proxy %s of config %s", id, key, owner)
+			}
+			overrides[o] = true
+			delete(configs, owner)
+		}
+		owners[key] = id
+	}
+	p.overrides = overrides
+
+	return Data{
+		Type:    CompositeType,
+		Configs: configs,
+	}
+}
+
+// mergeJSON merges the JSON object override deeply into the JSON object base.
+// If one of them is no JSON object, override is returned.
+func mergeJSON(base, override []byte) []byte {
+	var baseObj, overrideObj map[string]interface{}
+	if json.Unmarshal(base, &baseObj) != nil || json.Unmarshal(override, &overrideObj) != nil {
+		return override
+	}
+
+	merged, err := json.Marshal(mergeObjects(baseObj, overrideObj))
+	if err != nil {
+		return override
+	}
+	return merged
+}
+
+func mergeObjects(base, override map[string]interface{}) map[string]interface{} {
+	for k, v := range override {
+		baseValue, baseIsObj := base[k].(map[string]interface{})
+		overrideValue, overrideIsObj := v.(map[string]interface{})
+		if baseIsObj && overrideIsObj {
+			base[k] = mergeObjects(baseValue, overrideValue)
+			continue
+		}
+		base[k] = v
+	}
+	return base
+}
+
+// proxyKey returns the domainName and listenTo of a config that identify its proxy
+func proxyKey(cfg []byte) (string, bool) {
+	var proxy struct {
+		DomainName json.RawMessage `json:"domainName"`
+		ListenTo   string          `json:"listenTo"`
+	}
+	if err := json.Unmarshal(cfg, &proxy); err != nil || proxy.DomainName == nil {
+		return "", false
+	}
+	return string(proxy.DomainName) + "@" + proxy.ListenTo, true
+}
+
+func sortedIDs(configs map[string][]byte) []string {
+	ids := make([]string, 0, len(configs))
+	for id := range configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
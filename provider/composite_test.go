@@ -0,0 +1,217 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type channelProvider struct {
+	data   Data
+	dataCh chan<- Data
+	ready  chan bool
+	err    error
+}
+
+func (p *channelProvider) Provide(dataCh chan<- Data) (Data, error) {
+	p.dataCh = dataCh
+	close(p.ready)
+	return p.data, p.err
+}
+
+func (p *channelProvider) Status() Status {
+	return Status{Type: p.data.Type}
+}
+
+func (p *channelProvider) Close() error {
+	return nil
+}
+
+func TestComposite_Provide(t *testing.T) {
+	low := &channelProvider{
+		data: Data{Type: FileType, Configs: map[string][]byte{
+			"lobby":    []byte(`{"domainName":"lobby.example.com","proxyTo":":25566","offlineStatus":{"motd":"Offline","versionName":"1.17"}}`),
+			"survival": []byte(`{"domainName":"survival.example.com"}`),
+		}},
+		ready: make(chan bool),
+	}
+	high := &channelProvider{
+		data: Data{Type: EnvType, Configs: map[string][]byte{
+			"lobby": []byte(`{"proxyTo":":25567","offlineStatus":{"motd":"Maintenance"}}`),
+		}},
+		ready: make(chan bool),
+	}
+
+	// The order of the arguments must not matter
+	p := NewComposite(Prioritized{Provider: high, Priority: 10}, Prioritized{Provider: low})
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	data, err := p.Provide(dataCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Type != CompositeType {
+		t.Errorf("got type %s; want %s", data.Type, CompositeType)
+	}
+
+	want := `{"domainName":"lobby.example.com","offlineStatus":{"motd":"Maintenance","versionName":"1.17"},"proxyTo":":25567"}`
+	if string(data.Configs["lobby"]) != want {
+		t.Errorf("got config %s; want %s", data.Configs["lobby"], want)
+	}
+
+	if string(data.Configs["survival"]) != `{"domainName":"survival.example.com"}` {
+		t.Errorf("got config %s", data.Configs["survival"])
+	}
+
+	<-high.ready
+	high.dataCh <- Data{Type: EnvType}
+
+	select {
+	case data := <-dataCh:
+		if string(data.Configs["lobby"]) != string(low.data.Configs["lobby"]) {
+			t.Errorf("got config %s; want %s", data.Configs["lobby"], low.data.Configs["lobby"])
+		}
+	case <-time.After(time.Second):
+		t.Error("got no data after a provider changed")
+	}
+}
+
+func TestComposite_ProvideNamespace(t *testing.T) {
+	local := staticProvider{data: Data{Type: FileType, Configs: map[string][]byte{
+		"lobby": []byte(`{"domainName":"lobby.example.com","proxyTo":":25566"}`),
+	}}}
+	remote := staticProvider{data: Data{Type: HTTPType, Configs: map[string][]byte{
+		"lobby":    []byte(`{"proxyTo":":25567"}`),
+		"survival": []byte(`{"domainName":"lobby.example.com","proxyTo":":25568"}`),
+	}}}
+
+	p := NewComposite(
+		Prioritized{Provider: remote, Namespace: "http"},
+		Prioritized{Provider: local, Priority: 10},
+	)
+	defer p.Close()
+
+	data, err := p.Provide(make(chan Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Configs) != 2 {
+		t.Fatalf("got %d configs; want 2", len(data.Configs))
+	}
+
+	// The namespaced config must not be merged into the local one
+	if string(data.Configs["http:lobby"]) != `{"proxyTo":":25567"}` {
+		t.Errorf("got config %s", data.Configs["http:lobby"])
+	}
+
+	// The local config has the higher priority and wins the proxy
+	if _, ok := data.Configs["http:survival"]; ok {
+		t.Error("got overridden config http:survival")
+	}
+
+	if string(data.Configs["lobby"]) != `{"domainName":"lobby.example.com","proxyTo":":25566"}` {
+		t.Errorf("got config %s", data.Configs["lobby"])
+	}
+}
+
+func TestComposite_ProvideSkipsUnchanged(t *testing.T) {
+	prov := &channelProvider{
+		data: Data{Type: FileType, Configs: map[string][]byte{
+			"lobby": []byte(`{"domainName":"lobby.example.com"}`),
+		}},
+		ready: make(chan bool),
+	}
+
+	p := NewComposite(Prioritized{Provider: prov})
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	if _, err := p.Provide(dataCh); err != nil {
+		t.Fatal(err)
+	}
+
+	<-prov.ready
+	prov.dataCh <- prov.data
+
+	changed := Data{Type: FileType, Configs: map[string][]byte{
+		"lobby": []byte(`{"domainName":"lobby.example.com","proxyTo":":25566"}`),
+	}}
+	prov.dataCh <- changed
+
+	select {
+	case data := <-dataCh:
+		if data.Checksum() != changed.Checksum() {
+			t.Errorf("got configs %v; want %v", data.Configs, changed.Configs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("got no data after the configs changed")
+	}
+}
+
+func TestComposite_ProvideFails(t *testing.T) {
+	low := &channelProvider{data: Data{Type: FileType}, ready: make(chan bool)}
+	high := &channelProvider{ready: make(chan bool), err: errors.New("unreachable")}
+
+	p := NewComposite(Prioritized{Provider: low}, Prioritized{Provider: high, Priority: 10})
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	if _, err := p.Provide(dataCh); err == nil {
+		t.Fatal("got no error; want the error of the failed provider")
+	}
+
+	go func() {
+		select {
+		case low.dataCh <- Data{Type: FileType, Configs: map[string][]byte{"lobby": []byte(`{}`)}}:
+		case <-time.After(time.Second):
+		}
+	}()
+
+	select {
+	case <-dataCh:
+		t.Error("got data forwarded; want the forwarders to stop after Provide failed")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestComposite_LogsNewOverrides(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	p := NewComposite(Prioritized{Provider: staticProvider{data: Data{Type: FileType, Configs: map[string][]byte{
+		"lobby":  []byte(`{"domainName":"lobby.example.com"}`),
+		"lobby2": []byte(`{"domainName":"lobby.example.com"}`),
+	}}}})
+	defer p.Close()
+
+	if _, err := p.Provide(make(chan Data)); err != nil {
+		t.Fatal(err)
+	}
+	p.Current()
+	p.Current()
+
+	if n := strings.Count(buf.String(), "overrides the proxy"); n != 1 {
+		t.Errorf("got %d override logs; want 1", n)
+	}
+}
+
+func TestData_Checksum(t *testing.T) {
+	a := Data{Type: FileType, Configs: map[string][]byte{"a": []byte(`{}`), "b": []byte(`{"proxyTo":":25566"}`)}}
+	b := Data{Type: HTTPType, Configs: map[string][]byte{"b": []byte(`{"proxyTo":":25566"}`), "a": []byte(`{}`)}}
+	if a.Checksum() != b.Checksum() {
+		t.Error("got different checksums for the same configs")
+	}
+
+	c := Data{Configs: map[string][]byte{"a{}b": []byte(`{"proxyTo":":25566"}`)}}
+	if a.Checksum() == c.Checksum() {
+		t.Error("got the same checksum for different configs")
+	}
+}
@@ -0,0 +1,132 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package configsource
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ConfigSourceClient is the client API for ConfigSource service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ConfigSourceClient interface {
+	// StreamConfig streams config snapshots for as long as the stream is open.
+	// The first snapshot on every stream has to be a full snapshot.
+	StreamConfig(ctx context.Context, in *StreamConfigRequest, opts ...grpc.CallOption) (ConfigSource_StreamConfigClient, error)
+}
+
+type configSourceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConfigSourceClient(cc grpc.ClientConnInterface) ConfigSourceClient {
+	return &configSourceClient{cc}
+}
+
+func (c *configSourceClient) StreamConfig(ctx context.Context, in *StreamConfigRequest, opts ...grpc.CallOption) (ConfigSource_StreamConfigClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ConfigSource_ServiceDesc.Streams[0], "/infrared.configsource.v1.ConfigSource/StreamConfig", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &configSourceStreamConfigClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ConfigSource_StreamConfigClient interface {
+	Recv() (*ConfigSnapshot, error)
+	grpc.ClientStream
+}
+
+type configSourceStreamConfigClient struct {
+	grpc.ClientStream
+}
+
+func (x *configSourceStreamConfigClient) Recv() (*ConfigSnapshot, error) {
+	m := new(ConfigSnapshot)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ConfigSourceServer is the server API for ConfigSource service.
+// All implementations must embed UnimplementedConfigSourceServer
+// for forward compatibility
+type ConfigSourceServer interface {
+	// StreamConfig streams config snapshots for as long as the stream is open.
+	// The first snapshot on every stream has to be a full snapshot.
+	StreamConfig(*StreamConfigRequest, ConfigSource_StreamConfigServer) error
+	mustEmbedUnimplementedConfigSourceServer()
+}
+
+// UnimplementedConfigSourceServer must be embedded to have forward compatible implementations.
+type UnimplementedConfigSourceServer struct {
+}
+
+func (UnimplementedConfigSourceServer) StreamConfig(*StreamConfigRequest, ConfigSource_StreamConfigServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamConfig not implemented")
+}
+func (UnimplementedConfigSourceServer) mustEmbedUnimplementedConfigSourceServer() {}
+
+// UnsafeConfigSourceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ConfigSourceServer will
+// result in compilation errors.
+type UnsafeConfigSourceServer interface {
+	mustEmbedUnimplementedConfigSourceServer()
+}
+
+func RegisterConfigSourceServer(s grpc.ServiceRegistrar, srv ConfigSourceServer) {
+	s.RegisterService(&ConfigSource_ServiceDesc, srv)
+}
+
+func _ConfigSource_StreamConfig_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamConfigRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConfigSourceServer).StreamConfig(m, &configSourceStreamConfigServer{stream})
+}
+
+type ConfigSource_StreamConfigServer interface {
+	Send(*ConfigSnapshot) error
+	grpc.ServerStream
+}
+
+type configSourceStreamConfigServer struct {
+	grpc.ServerStream
+}
+
+func (x *configSourceStreamConfigServer) Send(m *ConfigSnapshot) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// ConfigSource_ServiceDesc is the grpc.ServiceDesc for ConfigSource service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ConfigSource_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "infrared.configsource.v1.ConfigSource",
+	HandlerType: (*ConfigSourceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamConfig",
+			Handler:       _ConfigSource_StreamConfig_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "configsource.proto",
+}
@@ -0,0 +1,4 @@
+// Package configsource contains the gRPC service that pushes proxy configs to Infrared
+package configsource
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative configsource.proto
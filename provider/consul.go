@@ -0,0 +1,237 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const consulServiceKey = "consulService"
+
+// Consul reads proxy configs from all keys under a prefix of the Consul KV store
+// and uses blocking queries to get notified about changes.
+// The ID of a config is its key without the prefix.
+//
+// A config can set the "consulService" field to the name of a Consul service.
+// Its "proxyTo" address is then populated with the address of a healthy instance of that service.
+type Consul struct {
+	Address string
+	Prefix  string
+	Token   string
+	// WaitTime is the maximum duration of a blocking query.
+	// Service addresses are refreshed at least once per WaitTime.
+	WaitTime time.Duration
+	Client   *http.Client
+
+	*status
+	index  string
+	last   map[string][]byte
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewConsul creates a new Consul provider for the given agent address, like "http://127.0.0.1:8500"
+func NewConsul(address, prefix, token string) *Consul {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Consul{
+		Address:  strings.TrimSuffix(address, "/"),
+		Prefix:   strings.TrimPrefix(prefix, "/"),
+		Token:    token,
+		WaitTime: time.Second * 30,
+		Client:   http.DefaultClient,
+		status:   newStatus(ConsulType),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+type consulKeyValue struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+func (p *Consul) Provide(dataCh chan<- Data) (Data, error) {
+	configs, err := p.load()
+	if err != nil {
+		return Data{}, err
+	}
+	p.last = configs
+	p.loaded()
+
+	go p.watch(dataCh)
+	return Data{
+		Type:    ConsulType,
+		Configs: configs,
+	}, nil
+}
+
+func (p *Consul) Close() error {
+	p.cancel()
+	return nil
+}
+
+func (p *Consul) watch(dataCh chan<- Data) {
+	p.watching(true)
+	defer p.watching(false)
+
+	for {
+		configs, err := p.load()
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Printf("Failed watching Consul on %s; error: %s", p.Address, err)
+			p.failed(err)
+			select {
+			case <-time.After(time.Second * 5):
+			case <-p.ctx.Done():
+				return
+			}
+			continue
+		}
+		p.loaded()
+
+		if reflect.DeepEqual(configs, p.last) {
+			continue
+		}
+		p.last = configs
+
+		select {
+		case dataCh <- Data{Type: ConsulType, Configs: configs}:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// load reads all keys under the prefix. If the provider already loaded them before,
+// it blocks until they change or the WaitTime passed.
+func (p *Consul) load() (map[string][]byte, error) {
+	query := url.Values{}
+	query.Set("recurse", "true")
+	if p.index != "" {
+		query.Set("index", p.index)
+		query.Set("wait", fmt.Sprintf("%ds", int(p.WaitTime.Seconds())))
+	}
+
+	var kvs []consulKeyValue
+	index, err := p.get("/v1/kv/"+p.Prefix, query, &kvs)
+	if err != nil {
+		return nil, err
+	}
+
+	// An index that goes backwards means that Consul was reset
+	if oldIndex, err := strconv.ParseUint(p.index, 10, 64); err == nil {
+		if newIndex, err := strconv.ParseUint(index, 10, 64); err == nil && newIndex < oldIndex {
+			index = ""
+		}
+	}
+	p.index = index
+
+	configs := map[string][]byte{}
+	for _, kv := range kvs {
+		if strings.HasSuffix(kv.Key, "/") {
+			continue
+		}
+
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimPrefix(kv.Key, p.Prefix)
+		value, err = p.resolveService(value)
+		if err != nil {
+			log.Printf("Failed resolving Consul service for %s; error: %s", id, err)
+			p.failed(err)
+		}
+		configs[id] = value
+	}
+
+	return configs, nil
+}
+
+// resolveService populates the "proxyTo" field of a config with a healthy
+// instance of its "consulService"
+func (p *Consul) resolveService(cfg []byte) ([]byte, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(cfg, &fields); err != nil {
+		return cfg, nil
+	}
+
+	service, ok := fields[consulServiceKey].(string)
+	if !ok || service == "" {
+		return cfg, nil
+	}
+
+	var entries []consulServiceEntry
+	query := url.Values{}
+	query.Set("passing", "true")
+	if _, err := p.get("/v1/health/service/"+url.PathEscape(service), query, &entries); err != nil {
+		return cfg, err
+	}
+
+	if len(entries) == 0 {
+		return cfg, fmt.Errorf("service %s has no healthy instances", service)
+	}
+
+	entry := entries[0]
+	host := entry.Service.Address
+	if host == "" {
+		host = entry.Node.Address
+	}
+	fields["proxyTo"] = net.JoinHostPort(host, strconv.Itoa(entry.Service.Port))
+	delete(fields, consulServiceKey)
+
+	return json.Marshal(fields)
+}
+
+func (p *Consul) get(path string, query url.Values, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, p.Address+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if p.Token != "" {
+		req.Header.Set("X-Consul-Token", p.Token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	index := resp.Header.Get("X-Consul-Index")
+
+	// Consul responds with not found if there are no keys under the prefix
+	if resp.StatusCode == http.StatusNotFound {
+		return index, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return index, json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConsul_Provide(t *testing.T) {
+	b64 := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/kv/infrared/":
+			if r.URL.Query().Get("index") == "" {
+				w.Header().Set("X-Consul-Index", "5")
+				fmt.Fprintf(w, `[{"Key":"infrared/","Value":null},{"Key":"infrared/lobby","Value":"%s"}]`,
+					b64(`{"domainName":"lobby.example.com","consulService":"lobby"}`))
+				return
+			}
+			w.Header().Set("X-Consul-Index", "6")
+			w.Write([]byte(`[]`))
+		case "/v1/health/service/lobby":
+			w.Write([]byte(`[{"Node":{"Address":"10.0.0.1"},"Service":{"Address":"","Port":25566}}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := NewConsul(server.URL, "infrared/", "")
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	data, err := p.Provide(dataCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(data.Configs["lobby"], &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg["proxyTo"] != "10.0.0.1:25566" {
+		t.Errorf("got proxyTo %v; want 10.0.0.1:25566", cfg["proxyTo"])
+	}
+
+	select {
+	case data := <-dataCh:
+		if len(data.Configs) != 0 {
+			t.Errorf("got %d configs; want 0", len(data.Configs))
+		}
+	case <-time.After(time.Second):
+		t.Error("got no data after the keys changed")
+	}
+}
@@ -0,0 +1,245 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DNSRecordPrefix is prepended to a domain to get the name of its TXT records
+const DNSRecordPrefix = "_infrared."
+
+// DNS resolves proxy configs from the TXT records of "_infrared.<domain>" for a list of domains.
+// A record is either the address to proxy to, like "mc1.example.net:25565", or a space separated
+// list of config fields, like "proxyTo=mc1.example.net:25565 listenTo=:25566".
+// The domain of the record is the domainName of its config.
+// Records are resolved again after their TTL ran out.
+//
+// The ID of a config is its domain. If a domain has multiple records, the ID is "<domain>/<index>".
+type DNS struct {
+	Domains []string
+	// Resolver is the address of the DNS server, like "1.1.1.1:53".
+	// The first nameserver of /etc/resolv.conf is used if it's empty.
+	Resolver string
+	// MinInterval and MaxInterval bound the refresh interval that is derived from the TTLs
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	Client      *dns.Client
+
+	*status
+	last   map[string][]byte
+	closed chan bool
+	once   sync.Once
+}
+
+// NewDNS creates a new DNS provider for the domains
+func NewDNS(domains []string, resolver string) *DNS {
+	return &DNS{
+		Domains:     domains,
+		Resolver:    resolver,
+		MinInterval: time.Second * 10,
+		MaxInterval: time.Hour,
+		Client:      &dns.Client{Timeout: time.Second * 5},
+		status:      newStatus(DNSType),
+		closed:      make(chan bool),
+	}
+}
+
+func (p *DNS) Provide(dataCh chan<- Data) (Data, error) {
+	if p.Resolver == "" {
+		cfg, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return Data{}, err
+		}
+		if len(cfg.Servers) == 0 {
+			return Data{}, fmt.Errorf("no nameserver configured")
+		}
+		p.Resolver = cfg.Servers[0] + ":" + cfg.Port
+	}
+
+	configs, ttl, err := p.resolve()
+	if err != nil {
+		return Data{}, err
+	}
+	p.last = configs
+	p.loaded()
+
+	go p.refresh(ttl, dataCh)
+	return Data{
+		Type:    DNSType,
+		Configs: configs,
+	}, nil
+}
+
+func (p *DNS) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+	return nil
+}
+
+func (p *DNS) refresh(ttl time.Duration, dataCh chan<- Data) {
+	p.watching(true)
+	defer p.watching(false)
+
+	for {
+		select {
+		case <-time.After(p.interval(ttl)):
+		case <-p.closed:
+			return
+		}
+
+		configs, newTTL, err := p.resolve()
+		if err != nil {
+			log.Printf("Failed resolving TXT records on %s; error: %s", p.Resolver, err)
+			p.failed(err)
+			continue
+		}
+		p.loaded()
+		ttl = newTTL
+
+		if reflect.DeepEqual(configs, p.last) {
+			continue
+		}
+		p.last = configs
+
+		select {
+		case dataCh <- Data{Type: DNSType, Configs: configs}:
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+func (p *DNS) interval(ttl time.Duration) time.Duration {
+	if ttl < p.MinInterval {
+		return p.MinInterval
+	}
+	if ttl > p.MaxInterval {
+		return p.MaxInterval
+	}
+	return ttl
+}
+
+// resolve looks up the records of all domains and returns their configs and the lowest TTL
+func (p *DNS) resolve() (map[string][]byte, time.Duration, error) {
+	configs := map[string][]byte{}
+	ttl := p.MaxInterval
+
+	for _, domain := range p.Domains {
+		domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+		if domain == "" {
+			continue
+		}
+
+		records, recordTTL, err := p.lookup(domain)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s; %s", domain, err)
+		}
+		if recordTTL < ttl {
+			ttl = recordTTL
+		}
+
+		for i, record := range records {
+			cfg, err := dnsRecordConfig(domain, record)
+			if err != nil {
+				log.Printf("Failed parsing TXT record of %s; error: %s", domain, err)
+				p.failed(fmt.Errorf("failed parsing TXT record of %s; %s", domain, err))
+				continue
+			}
+
+			id := domain
+			if len(records) > 1 {
+				id = fmt.Sprintf("%s/%d", domain, i)
+			}
+			configs[id] = cfg
+		}
+	}
+
+	return configs, ttl, nil
+}
+
+// lookup returns the sorted TXT records of a domain and their lowest TTL
+func (p *DNS) lookup(domain string) ([]string, time.Duration, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(DNSRecordPrefix+domain), dns.TypeTXT)
+
+	resp, _, err := p.Client.Exchange(msg, p.Resolver)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.Rcode == dns.RcodeNameError {
+		// Missing records are cached as long as the SOA record says
+		for _, ns := range resp.Ns {
+			if soa, ok := ns.(*dns.SOA); ok {
+				ttl := soa.Minttl
+				if soa.Hdr.Ttl < ttl {
+					ttl = soa.Hdr.Ttl
+				}
+				return nil, time.Duration(ttl) * time.Second, nil
+			}
+		}
+		return nil, p.MinInterval, nil
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, 0, fmt.Errorf("unexpected response code %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	var records []string
+	ttl := p.MaxInterval
+	for _, answer := range resp.Answer {
+		txt, ok := answer.(*dns.TXT)
+		if !ok {
+			continue
+		}
+
+		records = append(records, strings.Join(txt.Txt, ""))
+		if recordTTL := time.Duration(txt.Hdr.Ttl) * time.Second; recordTTL < ttl {
+			ttl = recordTTL
+		}
+	}
+	sort.Strings(records)
+
+	return records, ttl, nil
+}
+
+// dnsRecordConfig creates the config of a TXT record
+func dnsRecordConfig(domain, record string) ([]byte, error) {
+	fields := map[string]interface{}{
+		"domainName": domain,
+	}
+
+	record = strings.TrimSpace(record)
+	if !strings.Contains(record, "=") {
+		if record == "" {
+			return nil, fmt.Errorf("empty record")
+		}
+		fields["proxyTo"] = record
+		return json.Marshal(fields)
+	}
+
+	for _, pair := range strings.Fields(record) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid field %q", pair)
+		}
+
+		// Values like true or 25 keep their type
+		var value interface{}
+		if err := json.Unmarshal([]byte(kv[1]), &value); err != nil {
+			value = kv[1]
+		}
+		fields[kv[0]] = value
+	}
+
+	return json.Marshal(fields)
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNS_Provide(t *testing.T) {
+	records := map[string][]string{
+		"_infrared.play.example.com.":  {"mc1.example.net:25565"},
+		"_infrared.lobby.example.com.": {"proxyTo=lobby.example.net:25565 listenTo=:25566 proxyProtocol=true"},
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &dns.Server{
+		PacketConn: conn,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			resp := new(dns.Msg)
+			resp.SetReply(r)
+			name := r.Question[0].Name
+			txts, ok := records[name]
+			if !ok {
+				resp.Rcode = dns.RcodeNameError
+			}
+			for _, txt := range txts {
+				resp.Answer = append(resp.Answer, &dns.TXT{
+					Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300},
+					Txt: []string{txt},
+				})
+			}
+			w.WriteMsg(resp)
+		}),
+	}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	p := NewDNS([]string{"play.example.com", "lobby.example.com", "unknown.example.com"}, conn.LocalAddr().String())
+	defer p.Close()
+
+	data, err := p.Provide(make(chan Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Configs) != 2 {
+		t.Fatalf("got %d configs; want 2", len(data.Configs))
+	}
+
+	want := `{"domainName":"play.example.com","proxyTo":"mc1.example.net:25565"}`
+	if string(data.Configs["play.example.com"]) != want {
+		t.Errorf("got config %s; want %s", data.Configs["play.example.com"], want)
+	}
+
+	want = `{"domainName":"lobby.example.com","listenTo":":25566","proxyProtocol":true,"proxyTo":"lobby.example.net:25565"}`
+	if string(data.Configs["lobby.example.com"]) != want {
+		t.Errorf("got config %s; want %s", data.Configs["lobby.example.com"], want)
+	}
+
+	_, ttl, err := p.resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.interval(ttl) != p.MinInterval {
+		t.Errorf("got interval %s; want the minimum interval for a missing record", p.interval(ttl))
+	}
+
+	if p.interval(time.Minute*5) != time.Minute*5 {
+		t.Errorf("got interval %s; want 5m", p.interval(time.Minute*5))
+	}
+}
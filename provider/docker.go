@@ -0,0 +1,245 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+const (
+	dockerLabelPrefix   = "infrared."
+	dockerLabelDomain   = dockerLabelPrefix + "domain"
+	dockerLabelPort     = dockerLabelPrefix + "port"
+	dockerLabelNetwork  = dockerLabelPrefix + "network"
+	dockerLabelListenTo = dockerLabelPrefix + "listenTo"
+)
+
+// Docker discovers proxy configs from the labels of running Docker containers
+// and keeps them up to date by subscribing to the Docker events API.
+// The ID of a config is the name of its container.
+//
+// Every container with an "infrared.domain" label becomes a proxy that proxies to the
+// IP of the container on the port of its "infrared.port" label (default 25565).
+// All other "infrared.<field>" labels are set as fields of the proxy config.
+type Docker struct {
+	*status
+	client *client.Client
+	last   map[string][]byte
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewDocker creates a new Docker provider that connects to the Docker daemon
+// that is configured in the environment
+func NewDocker() (*Docker, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Docker{
+		status: newStatus(DockerType),
+		client: cli,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+func (p *Docker) Provide(dataCh chan<- Data) (Data, error) {
+	configs, err := p.load()
+	if err != nil {
+		return Data{}, err
+	}
+	p.last = configs
+	p.loaded()
+
+	go p.watch(dataCh)
+	return Data{
+		Type:    DockerType,
+		Configs: configs,
+	}, nil
+}
+
+func (p *Docker) Close() error {
+	p.cancel()
+	return p.client.Close()
+}
+
+func (p *Docker) watch(dataCh chan<- Data) {
+	for {
+		err := p.watchOnce(dataCh)
+		if p.ctx.Err() != nil {
+			return
+		}
+		log.Println("Stopped watching Docker events; error:", err)
+		p.failed(err)
+
+		select {
+		case <-time.After(time.Second * 5):
+		case <-p.ctx.Done():
+			return
+		}
+
+		// Events might have been missed in the meantime
+		p.reload(dataCh)
+	}
+}
+
+func (p *Docker) watchOnce(dataCh chan<- Data) error {
+	messages, errs := p.client.Events(p.ctx, types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", "container"),
+			filters.Arg("label", dockerLabelDomain),
+		),
+	})
+
+	p.watching(true)
+	defer p.watching(false)
+	for {
+		select {
+		case msg := <-messages:
+			switch msg.Action {
+			case "start", "die", "stop", "destroy", "pause", "unpause":
+				p.reload(dataCh)
+			}
+		case err := <-errs:
+			return err
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
+	}
+}
+
+// reload lists all containers again and sends the configs if they changed
+func (p *Docker) reload(dataCh chan<- Data) {
+	configs, err := p.load()
+	if err != nil {
+		log.Println("Failed listing Docker containers; error:", err)
+		p.failed(err)
+		return
+	}
+	p.loaded()
+
+	if reflect.DeepEqual(configs, p.last) {
+		return
+	}
+	p.last = configs
+
+	select {
+	case dataCh <- Data{Type: DockerType, Configs: configs}:
+	case <-p.ctx.Done():
+	}
+}
+
+func (p *Docker) load() (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, time.Second*10)
+	defer cancel()
+
+	containers, err := p.client.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("status", "running"),
+			filters.Arg("label", dockerLabelDomain),
+		),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	configs := map[string][]byte{}
+	for _, container := range containers {
+		if len(container.Names) == 0 {
+			continue
+		}
+		id := strings.TrimPrefix(container.Names[0], "/")
+
+		cfg, err := dockerContainerConfig(container)
+		if err != nil {
+			log.Printf("Failed creating config for container %s; error: %s", id, err)
+			p.failed(err)
+			continue
+		}
+		configs[id] = cfg
+	}
+
+	return configs, nil
+}
+
+func dockerContainerConfig(container types.Container) ([]byte, error) {
+	fields := map[string]interface{}{}
+	for label, value := range container.Labels {
+		if !strings.HasPrefix(label, dockerLabelPrefix) {
+			continue
+		}
+
+		switch label {
+		case dockerLabelDomain, dockerLabelPort, dockerLabelNetwork:
+			continue
+		}
+
+		// Labels are strings; take the JSON value if it is one
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			v = value
+		}
+		fields[strings.TrimPrefix(label, dockerLabelPrefix)] = v
+	}
+
+	ip, err := dockerContainerIP(container, container.Labels[dockerLabelNetwork])
+	if err != nil {
+		return nil, err
+	}
+
+	port := container.Labels[dockerLabelPort]
+	if port == "" {
+		port = "25565"
+	}
+
+	fields["domainName"] = container.Labels[dockerLabelDomain]
+	fields["proxyTo"] = net.JoinHostPort(ip, port)
+	if listenTo, ok := container.Labels[dockerLabelListenTo]; ok {
+		fields["listenTo"] = listenTo
+	}
+
+	return json.Marshal(fields)
+}
+
+func dockerContainerIP(container types.Container, network string) (string, error) {
+	if container.NetworkSettings == nil || len(container.NetworkSettings.Networks) == 0 {
+		return "", fmt.Errorf("container is not connected to any network")
+	}
+
+	if network != "" {
+		settings, ok := container.NetworkSettings.Networks[network]
+		if !ok || settings.IPAddress == "" {
+			return "", fmt.Errorf("container has no IP in network %s", network)
+		}
+		return settings.IPAddress, nil
+	}
+
+	// Pick the first network in alphabetical order so that the IP does not change randomly
+	var networks []string
+	for name := range container.NetworkSettings.Networks {
+		networks = append(networks, name)
+	}
+	sort.Strings(networks)
+
+	for _, name := range networks {
+		settings := container.NetworkSettings.Networks[name]
+		if settings != nil && settings.IPAddress != "" {
+			return settings.IPAddress, nil
+		}
+	}
+
+	return "", fmt.Errorf("container has no IP address")
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestDockerContainerConfig(t *testing.T) {
+	tt := []struct {
+		name      string
+		container types.Container
+		proxyTo   string
+		expectErr bool
+	}{
+		{
+			name: "DefaultPort",
+			container: types.Container{
+				Labels: map[string]string{
+					dockerLabelDomain: "lobby.example.com",
+				},
+				NetworkSettings: &types.SummaryNetworkSettings{
+					Networks: map[string]*network.EndpointSettings{
+						"bridge": {IPAddress: "172.17.0.2"},
+					},
+				},
+			},
+			proxyTo: "172.17.0.2:25565",
+		},
+		{
+			name: "NetworkAndPort",
+			container: types.Container{
+				Labels: map[string]string{
+					dockerLabelDomain:  "lobby.example.com",
+					dockerLabelPort:    "25566",
+					dockerLabelNetwork: "minecraft",
+				},
+				NetworkSettings: &types.SummaryNetworkSettings{
+					Networks: map[string]*network.EndpointSettings{
+						"bridge":    {IPAddress: "172.17.0.2"},
+						"minecraft": {IPAddress: "172.18.0.2"},
+					},
+				},
+			},
+			proxyTo: "172.18.0.2:25566",
+		},
+		{
+			name: "NoNetwork",
+			container: types.Container{
+				Labels: map[string]string{
+					dockerLabelDomain: "lobby.example.com",
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			bb, err := dockerContainerConfig(tc.container)
+			if tc.expectErr {
+				if err == nil {
+					t.Fail()
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var cfg map[string]interface{}
+			if err := json.Unmarshal(bb, &cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg["domainName"] != "lobby.example.com" {
+				t.Errorf("got domainName %v", cfg["domainName"])
+			}
+
+			if cfg["proxyTo"] != tc.proxyTo {
+				t.Errorf("got proxyTo %v; want %s", cfg["proxyTo"], tc.proxyTo)
+			}
+		})
+	}
+}
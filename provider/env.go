@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Env builds proxy configs from environment variables of the form
+// <Prefix><ID>_<FIELD>[_<FIELD>|_<INDEX>]...=<value>, like
+// INFRARED_PROXIES_LOBBY_DOMAINNAME=mc.example.com or
+// INFRARED_PROXIES_LOBBY_CALLBACKSERVER_EVENTS_0=PlayerJoin.
+// Field names are matched case-insensitively against the JSON names of the Template
+// and values are converted to the type of their field. The ID of a config is its lowercased ID segment.
+type Env struct {
+	Prefix   string
+	Template interface{}
+	Environ  func() []string
+
+	*status
+}
+
+// NewEnv creates a new Env provider. The fields of template define which variables are valid.
+func NewEnv(prefix string, template interface{}) *Env {
+	return &Env{
+		Prefix:   prefix,
+		Template: template,
+		Environ:  os.Environ,
+		status:   newStatus(EnvType),
+	}
+}
+
+// Provide returns the configs of the environment. Since the environment of a process
+// does not change, no Data is ever sent to dataCh.
+func (p *Env) Provide(dataCh chan<- Data) (Data, error) {
+	t := reflect.TypeOf(p.Template)
+	values := map[string]interface{}{}
+
+	for _, env := range p.Environ() {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], p.Prefix) {
+			continue
+		}
+
+		segments := strings.Split(strings.TrimPrefix(kv[0], p.Prefix), "_")
+		if len(segments) < 2 || segments[0] == "" {
+			return Data{}, fmt.Errorf("%s does not name a config and field", kv[0])
+		}
+
+		id := strings.ToLower(segments[0])
+		value, err := envValue(values[id], t, segments[1:], kv[1])
+		if err != nil {
+			return Data{}, fmt.Errorf("invalid %s; %s", kv[0], err)
+		}
+		values[id] = value
+	}
+
+	configs := make(map[string][]byte, len(values))
+	for id, value := range values {
+		bb, err := json.Marshal(value)
+		if err != nil {
+			return Data{}, err
+		}
+		configs[id] = bb
+	}
+	p.loaded()
+
+	return Data{
+		Type:    EnvType,
+		Configs: configs,
+	}, nil
+}
+
+func (p *Env) Close() error {
+	return nil
+}
+
+// envValue sets raw at the path of segments inside of current, which is a value of type t
+func envValue(current interface{}, t reflect.Type, segments []string, raw string) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if len(segments) == 0 {
+			return envJSON(raw)
+		}
+
+		name, fieldType, ok := envField(t, segments[0])
+		if !ok {
+			return nil, fmt.Errorf("unknown field %s", segments[0])
+		}
+
+		fields, _ := current.(map[string]interface{})
+		if fields == nil {
+			fields = map[string]interface{}{}
+		}
+
+		value, err := envValue(fields[name], fieldType, segments[1:], raw)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = value
+		return fields, nil
+	case reflect.Slice, reflect.Array:
+		if len(segments) == 0 {
+			return envJSON(raw)
+		}
+
+		index, err := strconv.Atoi(segments[0])
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid index %s", segments[0])
+		}
+
+		elements, _ := current.([]interface{})
+		for len(elements) <= index {
+			elements = append(elements, nil)
+		}
+
+		elements[index], err = envValue(elements[index], t.Elem(), segments[1:], raw)
+		if err != nil {
+			return nil, err
+		}
+		return elements, nil
+	}
+
+	if len(segments) > 0 {
+		return nil, fmt.Errorf("unknown field %s", segments[0])
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(raw, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(raw, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(raw, 64)
+	case reflect.String:
+		return raw, nil
+	}
+
+	return envJSON(raw)
+}
+
+// envField finds the JSON name and type of the struct field which name matches segment case-insensitively
+func envField(t reflect.Type, segment string) (string, reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		if strings.EqualFold(tag, segment) {
+			return tag, field.Type, true
+		}
+	}
+	return "", nil, false
+}
+
+func envJSON(raw string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, fmt.Errorf("value is not valid JSON; %s", err)
+	}
+	return v, nil
+}
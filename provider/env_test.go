@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"testing"
+)
+
+type envTestConfig struct {
+	DomainName string `json:"domainName"`
+	Timeout    int    `json:"timeout"`
+	RealIP     bool   `json:"realIp"`
+	Status     struct {
+		MOTD    string `json:"motd"`
+		Samples []struct {
+			Name string `json:"name"`
+		} `json:"samples"`
+	} `json:"status"`
+	Events []string `json:"events"`
+}
+
+func TestEnv_Provide(t *testing.T) {
+	tt := []struct {
+		name      string
+		environ   []string
+		configs   map[string]string
+		expectErr bool
+	}{
+		{
+			name: "Fields",
+			environ: []string{
+				"PATH=/bin",
+				"INFRARED_PROXIES_LOBBY_DOMAINNAME=mc.example.com",
+				"INFRARED_PROXIES_LOBBY_TIMEOUT=500",
+				"INFRARED_PROXIES_LOBBY_REALIP=true",
+				"INFRARED_PROXIES_SURVIVAL_STATUS_MOTD=Hello",
+			},
+			configs: map[string]string{
+				"lobby":    `{"domainName":"mc.example.com","realIp":true,"timeout":500}`,
+				"survival": `{"status":{"motd":"Hello"}}`,
+			},
+		},
+		{
+			name: "Arrays",
+			environ: []string{
+				"INFRARED_PROXIES_LOBBY_EVENTS_1=PlayerLeave",
+				"INFRARED_PROXIES_LOBBY_EVENTS_0=PlayerJoin",
+				"INFRARED_PROXIES_LOBBY_STATUS_SAMPLES_0_NAME=notch",
+			},
+			configs: map[string]string{
+				"lobby": `{"events":["PlayerJoin","PlayerLeave"],"status":{"samples":[{"name":"notch"}]}}`,
+			},
+		},
+		{
+			name: "JSONValue",
+			environ: []string{
+				`INFRARED_PROXIES_LOBBY_EVENTS=["PlayerJoin"]`,
+			},
+			configs: map[string]string{
+				"lobby": `{"events":["PlayerJoin"]}`,
+			},
+		},
+		{
+			name: "UnknownField",
+			environ: []string{
+				"INFRARED_PROXIES_LOBBY_DOMAIN=mc.example.com",
+			},
+			expectErr: true,
+		},
+		{
+			name: "InvalidType",
+			environ: []string{
+				"INFRARED_PROXIES_LOBBY_TIMEOUT=long",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewEnv("INFRARED_PROXIES_", envTestConfig{})
+			p.Environ = func() []string {
+				return tc.environ
+			}
+
+			data, err := p.Provide(make(chan Data))
+			if tc.expectErr {
+				if err == nil {
+					t.Fail()
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(data.Configs) != len(tc.configs) {
+				t.Fatalf("got %d configs; want %d", len(data.Configs), len(tc.configs))
+			}
+
+			for id, cfg := range tc.configs {
+				if string(data.Configs[id]) != cfg {
+					t.Errorf("got config %s for %s; want %s", data.Configs[id], id, cfg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Etcd reads proxy configs from all keys under a prefix of an etcd v3 cluster
+// and watches them for changes. It talks to the gRPC gateway of etcd via its JSON API.
+// The ID of a config is its key without the prefix.
+type Etcd struct {
+	Endpoint string
+	Prefix   string
+	Client   *http.Client
+
+	*status
+	mu       sync.Mutex
+	configs  map[string][]byte
+	revision int64
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewEtcd creates a new Etcd provider for the given endpoint, like "http://127.0.0.1:2379"
+func NewEtcd(endpoint, prefix string) *Etcd {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Etcd{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Prefix:   prefix,
+		Client:   http.DefaultClient,
+		status:   newStatus(EtcdType),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+type etcdKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type etcdHeader struct {
+	Revision string `json:"revision"`
+}
+
+type etcdRangeResponse struct {
+	Header etcdHeader     `json:"header"`
+	Kvs    []etcdKeyValue `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Header   etcdHeader `json:"header"`
+		Canceled bool       `json:"canceled"`
+		Events   []struct {
+			Type string       `json:"type"`
+			Kv   etcdKeyValue `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *Etcd) Provide(dataCh chan<- Data) (Data, error) {
+	if err := p.load(); err != nil {
+		return Data{}, err
+	}
+
+	go p.watch(dataCh)
+	return p.data(), nil
+}
+
+func (p *Etcd) Close() error {
+	p.cancel()
+	return nil
+}
+
+func (p *Etcd) data() Data {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	configs := make(map[string][]byte, len(p.configs))
+	for id, cfg := range p.configs {
+		configs[id] = cfg
+	}
+
+	return Data{
+		Type:    EtcdType,
+		Configs: configs,
+	}
+}
+
+// load reads all keys under the prefix
+func (p *Etcd) load() error {
+	var resp etcdRangeResponse
+	if err := p.post("/v3/kv/range", p.keyRange(), &resp); err != nil {
+		return err
+	}
+
+	revision, err := strconv.ParseInt(resp.Header.Revision, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid revision %q; error: %s", resp.Header.Revision, err)
+	}
+
+	configs := map[string][]byte{}
+	for _, kv := range resp.Kvs {
+		id, value, err := p.decodeKeyValue(kv)
+		if err != nil {
+			return err
+		}
+		configs[id] = value
+	}
+
+	p.mu.Lock()
+	p.configs = configs
+	p.revision = revision
+	p.mu.Unlock()
+	p.loaded()
+	return nil
+}
+
+func (p *Etcd) watch(dataCh chan<- Data) {
+	for {
+		err := p.watchOnce(dataCh)
+		if p.ctx.Err() != nil {
+			return
+		}
+		log.Printf("Stopped watching etcd on %s; error: %s", p.Endpoint, err)
+		p.failed(err)
+
+		select {
+		case <-time.After(time.Second * 5):
+		case <-p.ctx.Done():
+			return
+		}
+
+		// The watch might have missed events; reload everything
+		if err := p.load(); err != nil {
+			log.Printf("Failed reloading etcd on %s; error: %s", p.Endpoint, err)
+			p.failed(err)
+			continue
+		}
+
+		select {
+		case dataCh <- p.data():
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Etcd) watchOnce(dataCh chan<- Data) error {
+	p.mu.Lock()
+	startRevision := p.revision + 1
+	p.mu.Unlock()
+
+	createRequest := p.keyRange()
+	createRequest["start_revision"] = strconv.FormatInt(startRevision, 10)
+	bb, err := json.Marshal(map[string]interface{}{
+		"create_request": createRequest,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodPost, p.Endpoint+"/v3/watch", bytes.NewReader(bb))
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	p.watching(true)
+	defer p.watching(false)
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var watchResp etcdWatchResponse
+		if err := decoder.Decode(&watchResp); err != nil {
+			return err
+		}
+
+		if watchResp.Error != nil {
+			return fmt.Errorf("watch failed; %s", watchResp.Error.Message)
+		}
+
+		if watchResp.Result.Canceled {
+			return fmt.Errorf("watch was canceled")
+		}
+
+		if len(watchResp.Result.Events) == 0 {
+			continue
+		}
+
+		p.mu.Lock()
+		for _, event := range watchResp.Result.Events {
+			id, value, err := p.decodeKeyValue(event.Kv)
+			if err != nil {
+				log.Printf("Failed decoding etcd event; error: %s", err)
+				continue
+			}
+
+			if event.Type == "DELETE" {
+				delete(p.configs, id)
+			} else {
+				p.configs[id] = value
+			}
+		}
+		if revision, err := strconv.ParseInt(watchResp.Result.Header.Revision, 10, 64); err == nil {
+			p.revision = revision
+		}
+		p.mu.Unlock()
+		p.loaded()
+
+		select {
+		case dataCh <- p.data():
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
+	}
+}
+
+func (p *Etcd) post(path string, body interface{}, v interface{}) error {
+	bb, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodPost, p.Endpoint+path, bytes.NewReader(bb))
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// keyRange returns the base64 encoded key range that covers all keys with the prefix
+func (p *Etcd) keyRange() map[string]interface{} {
+	return map[string]interface{}{
+		"key":       base64.StdEncoding.EncodeToString([]byte(p.Prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(prefixRangeEnd([]byte(p.Prefix))),
+	}
+}
+
+func (p *Etcd) decodeKeyValue(kv etcdKeyValue) (string, []byte, error) {
+	key, err := base64.StdEncoding.DecodeString(kv.Key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	value, err := base64.StdEncoding.DecodeString(kv.Value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return strings.TrimPrefix(string(key), p.Prefix), value, nil
+}
+
+// prefixRangeEnd returns the smallest key that is greater than all keys with the prefix
+func prefixRangeEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// The prefix only consists of 0xff bytes; use the whole key space
+	return []byte{0}
+}
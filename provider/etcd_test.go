@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPrefixRangeEnd(t *testing.T) {
+	tt := []struct {
+		prefix []byte
+		end    []byte
+	}{
+		{
+			prefix: []byte("/infrared/"),
+			end:    []byte("/infrared0"),
+		},
+		{
+			prefix: []byte{'a', 0xff},
+			end:    []byte{'b'},
+		},
+		{
+			prefix: []byte{0xff, 0xff},
+			end:    []byte{0},
+		},
+	}
+
+	for _, tc := range tt {
+		if end := prefixRangeEnd(tc.prefix); !bytes.Equal(end, tc.end) {
+			t.Errorf("got %v; want %v", end, tc.end)
+		}
+	}
+}
+
+func TestEtcd_Provide(t *testing.T) {
+	b64 := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			fmt.Fprintf(w, `{"header":{"revision":"7"},"kvs":[{"key":"%s","value":"%s"}]}`,
+				b64("/infrared/lobby"), b64(`{"domainName":"lobby.example.com"}`))
+		case "/v3/watch":
+			w.(http.Flusher).Flush()
+			fmt.Fprintf(w, `{"result":{"header":{"revision":"8"},"created":true}}`)
+			fmt.Fprintf(w, `{"result":{"header":{"revision":"9"},"events":[{"kv":{"key":"%s","value":"%s"}},{"type":"DELETE","kv":{"key":"%s"}}]}}`,
+				b64("/infrared/survival"), b64(`{"domainName":"survival.example.com"}`), b64("/infrared/lobby"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := NewEtcd(server.URL, "/infrared/")
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	data, err := p.Provide(dataCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data.Configs["lobby"]) != `{"domainName":"lobby.example.com"}` {
+		t.Errorf("got configs %v", data.Configs)
+	}
+
+	select {
+	case data := <-dataCh:
+		if len(data.Configs) != 1 || string(data.Configs["survival"]) != `{"domainName":"survival.example.com"}` {
+			t.Errorf("got configs %v", data.Configs)
+		}
+	case <-time.After(time.Second):
+		t.Error("got no data after the watch event")
+	}
+}
@@ -0,0 +1,360 @@
+package provider
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// File reads every file in a directory as a proxy config and watches the directory for changes.
+// The ID of a config is the path of its file.
+type File struct {
+	Directory string
+	Recursive bool
+	// Debounce is the time without changes after which the directory is read again
+	Debounce time.Duration
+	// Include are glob patterns of the files that are read. If it is empty, all files are read.
+	Include []string
+	// Exclude are glob patterns of the files that are skipped, even if they are included.
+	// Patterns with a trailing slash, like ".git/", skip whole directories.
+	Exclude []string
+
+	*status
+	watcher *fsnotify.Watcher
+	last    map[string][]byte
+	closed  chan bool
+	once    sync.Once
+	// files are the configs of every file that was parsed, which files that fail to parse keep
+	files map[string]map[string][]byte
+}
+
+// DefaultFileDebounce is the default Debounce of the File provider
+const DefaultFileDebounce = 100 * time.Millisecond
+
+// DefaultFileExclude are the default Exclude patterns of the File provider,
+// which skip hidden files like editor swap files and backup files
+var DefaultFileExclude = []string{".*", "*~", "*.bak"}
+
+// NewFile creates a new File provider for the directory
+func NewFile(directory string, recursive bool) *File {
+	return &File{
+		Directory: directory,
+		Recursive: recursive,
+		Debounce:  DefaultFileDebounce,
+		Exclude:   DefaultFileExclude,
+		status:    newStatus(FileType),
+		closed:    make(chan bool),
+	}
+}
+
+func (p *File) Provide(dataCh chan<- Data) (Data, error) {
+	configs, err := p.readConfigs()
+	if err != nil {
+		return Data{}, err
+	}
+	p.last = configs
+	p.loaded()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return Data{}, err
+	}
+	p.watcher = watcher
+
+	if err := p.addWatches(); err != nil {
+		watcher.Close()
+		return Data{}, err
+	}
+
+	go func() {
+		defer watcher.Close()
+		p.watching(true)
+		defer p.watching(false)
+		log.Printf("Starting to watch %s", p.Directory)
+		p.watch(dataCh)
+		log.Printf("Stopping to watch %s", p.Directory)
+	}()
+
+	return Data{
+		Type:    FileType,
+		Configs: configs,
+	}, nil
+}
+
+func (p *File) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+	return nil
+}
+
+func (p *File) addWatches() error {
+	if !p.Recursive {
+		return p.watcher.Add(p.Directory)
+	}
+
+	return filepath.WalkDir(p.Directory, func(path string, dir fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !dir.IsDir() {
+			return nil
+		}
+
+		return p.watcher.Add(path)
+	})
+}
+
+func (p *File) watch(dataCh chan<- Data) {
+	// Text editors and tools like rsync cause bursts of events for a single change,
+	// so the directory is only read again after no event occurred for the debounce
+	debounce := time.NewTimer(p.Debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-debounce.C:
+			p.reload(dataCh)
+		case event, ok := <-p.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if p.Recursive && event.Op&fsnotify.Create == fsnotify.Create {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := p.watcher.Add(event.Name); err != nil {
+						log.Printf("Failed watching %s; error %s", event.Name, err)
+						p.failed(err)
+					}
+				}
+			}
+
+			if event.Op&fsnotify.Chmod != event.Op {
+				if !debounce.Stop() {
+					select {
+					case <-debounce.C:
+					default:
+					}
+				}
+				debounce.Reset(p.Debounce)
+			}
+		case err, ok := <-p.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Failed watching %s; error %s", p.Directory, err)
+			p.failed(err)
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+func (p *File) reload(dataCh chan<- Data) {
+	configs, err := p.readConfigs()
+	if err != nil {
+		log.Printf("Failed reading %s; error %s", p.Directory, err)
+		p.failed(err)
+		return
+	}
+	p.loaded()
+
+	if reflect.DeepEqual(configs, p.last) {
+		return
+	}
+	p.last = configs
+
+	select {
+	case dataCh <- Data{Type: FileType, Configs: configs}:
+	case <-p.closed:
+	}
+}
+
+func (p *File) readConfigs() (map[string][]byte, error) {
+	filePaths, err := ReadFilePaths(p.Directory, p.Recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := map[string][]byte{}
+	files := map[string]map[string][]byte{}
+	for _, filePath := range filePaths {
+		if !p.matches(filePath) {
+			continue
+		}
+
+		fileConfigs, err := ReadConfigs(filePath)
+		if err != nil {
+			// The file might have been removed in the meantime
+			if os.IsNotExist(err) {
+				continue
+			}
+			if _, ok := err.(*os.PathError); ok {
+				return nil, err
+			}
+			// A typo must not look like a removed file, so the file keeps the configs that it had
+			log.Printf("Failed parsing %s, keeping its last configs; error: %s", filePath, err)
+			p.failed(fmt.Errorf("failed parsing %s; %s", filePath, err))
+			fileConfigs = p.files[filePath]
+		}
+		files[filePath] = fileConfigs
+		for id, bb := range fileConfigs {
+			configs[id] = bb
+		}
+	}
+
+	p.files = files
+	return configs, nil
+}
+
+// matches reports whether the file is included and not excluded. Patterns with
+// a slash are matched against the path relative to the directory, all other
+// patterns are matched against the name of the file and its parent directories.
+func (p *File) matches(filePath string) bool {
+	rel, err := filepath.Rel(p.Directory, filePath)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	segments := strings.Split(rel, "/")
+
+	for _, pattern := range p.Exclude {
+		dirOnly := strings.HasSuffix(pattern, "/")
+		pattern = strings.TrimSuffix(pattern, "/")
+
+		for i := range segments {
+			if dirOnly && i == len(segments)-1 {
+				break
+			}
+
+			name := segments[i]
+			if strings.Contains(pattern, "/") {
+				name = strings.Join(segments[:i+1], "/")
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return false
+			}
+		}
+	}
+
+	if len(p.Include) == 0 {
+		return true
+	}
+
+	for _, pattern := range p.Include {
+		name := segments[len(segments)-1]
+		if strings.Contains(pattern, "/") {
+			name = rel
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadFilePaths returns the paths of all files in a directory that are no directories
+// or symlinks to directories in lexicographic order
+func ReadFilePaths(path string, recursive bool) ([]string, error) {
+	read := readFilePaths
+	if recursive {
+		read = readFilePathsRecursively
+	}
+
+	filePaths, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(filePaths)
+	return filePaths, nil
+}
+
+func readFilePathsRecursively(path string) ([]string, error) {
+	var filePaths []string
+
+	err := filepath.WalkDir(path, func(path string, dir fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if dir.IsDir() {
+			return nil
+		}
+
+		// check the type of file that is behind symlinks link
+		if dir.Type()&os.ModeSymlink == os.ModeSymlink {
+			linkedToDir, err := isLinkedToDir(path)
+			if err != nil {
+				return err
+			}
+
+			if linkedToDir {
+				return nil
+			}
+		}
+
+		filePaths = append(filePaths, path)
+		return nil
+	})
+
+	return filePaths, err
+}
+
+func readFilePaths(path string) ([]string, error) {
+	var filePaths []string
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		fullPathFile := filepath.Join(path, file.Name())
+
+		// check the type of file that is behind symlinks link
+		if file.Mode()&os.ModeSymlink == os.ModeSymlink {
+			linkedToDir, err := isLinkedToDir(fullPathFile)
+			if err != nil {
+				return nil, err
+			}
+
+			if linkedToDir {
+				continue
+			}
+		}
+
+		filePaths = append(filePaths, fullPathFile)
+	}
+
+	return filePaths, err
+}
+
+func isLinkedToDir(path string) (bool, error) {
+	linkedFile, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+
+	linkedFileInfo, err := os.Lstat(linkedFile)
+	if err != nil {
+		return false, err
+	}
+
+	return linkedFileInfo.IsDir(), nil
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFile_DebouncesBursts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "proxy.json")
+	if err := ioutil.WriteFile(path, []byte(`{"domainName":"mc.example.com"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewFile(dir, false)
+	p.Debounce = 200 * time.Millisecond
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	if _, err := p.Provide(dataCh); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every write changes the config, so every reload would be sent
+	for i := 0; i < 10; i++ {
+		cfg := fmt.Sprintf(`{"domainName":"mc.example.com","timeout":%d}`, i)
+		if err := ioutil.WriteFile(path, []byte(cfg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	select {
+	case data := <-dataCh:
+		want := `{"domainName":"mc.example.com","timeout":9}`
+		if got := string(data.Configs[path]); got != want {
+			t.Errorf("got %s; want %s", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("got no reload")
+	}
+
+	select {
+	case data := <-dataCh:
+		t.Errorf("got second reload %v", data)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestFile_KeepsConfigsOfInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "proxy.yml")
+	if err := ioutil.WriteFile(path, []byte("domainName: mc.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewFile(dir, false)
+	data, err := p.Provide(make(chan Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	want := string(data.Configs[path])
+
+	// A typo in a file that was loaded before keeps its config
+	if err := ioutil.WriteFile(path, []byte("domainName: [mc.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configs, err := p.readConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(configs[path]); got != want {
+		t.Errorf("got %q; want the last config %q", got, want)
+	}
+	if p.Status().LastError == "" {
+		t.Error("got no error in the status; want the parsing error")
+	}
+
+	// A new file with a typo has no config to keep
+	invalid := filepath.Join(dir, "invalid.yml")
+	if err := ioutil.WriteFile(invalid, []byte("domainName: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configs, err = p.readConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := configs[invalid]; ok || len(configs) != 1 {
+		t.Errorf("got configs %v; want only the config of %s", configs, path)
+	}
+}
+
+func TestFile_Matches(t *testing.T) {
+	p := NewFile("configs", true)
+	p.Include = []string{"*.json", "*.toml"}
+	p.Exclude = append(p.Exclude, "archive/", "staging/old/*")
+
+	tt := []struct {
+		path string
+		want bool
+	}{
+		{path: "configs/proxy.json", want: true},
+		{path: "configs/customers/proxy.toml", want: true},
+		{path: "configs/proxy.yml", want: false},
+		{path: "configs/.proxy.json.swp", want: false},
+		{path: "configs/proxy.json~", want: false},
+		{path: "configs/proxy.json.bak", want: false},
+		{path: "configs/.git/config.json", want: false},
+		{path: "configs/archive/proxy.json", want: false},
+		{path: "configs/customers/archive/proxy.json", want: false},
+		{path: "configs/staging/old/proxy.json", want: false},
+		{path: "configs/staging/proxy.json", want: true},
+	}
+
+	for _, tc := range tt {
+		if got := p.matches(tc.path); got != tc.want {
+			t.Errorf("%s: got %v; want %v", tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"sigs.k8s.io/yaml"
+)
+
+// HCLProxyBlock is the name of the blocks that define proxies in HCL config files
+const HCLProxyBlock = "proxy"
+
+// ReadConfigFile reads a config file and returns its JSON representation.
+// The format of the file is detected by its extension; see ConvertToJSON.
+// Environment variables in the file are expanded before it is parsed and included files are merged into it.
+func ReadConfigFile(path string) ([]byte, error) {
+	return readIncludedConfig(path, nil)
+}
+
+// readConfigFile reads a config file and returns its content and the extension of its format.
+// Files that are encrypted with age or SOPS are decrypted and environment variables are expanded.
+func readConfigFile(path string) ([]byte, string, error) {
+	bb, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ext := filepath.Ext(path)
+	if strings.ToLower(ext) == AgeExtension {
+		bb, err = decryptAge(bytes.NewReader(bb))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decrypt with age; %s", err)
+		}
+		ext = filepath.Ext(strings.TrimSuffix(path, ext))
+	}
+
+	cfg, ok, err := decryptSOPS(bb, ext)
+	if err != nil {
+		return nil, "", err
+	}
+	if ok {
+		bb, ext = cfg, ".json"
+	}
+
+	return ExpandEnv(bb), ext, nil
+}
+
+// ReadConfigs reads all configs of a config file. Most files contain one config whose ID is the path.
+// HCL files can also define multiple configs with proxy blocks, whose IDs are "<path>#<name>".
+// Included files are merged into every config; see ReadConfigFile.
+func ReadConfigs(path string) (map[string][]byte, error) {
+	bb, ext, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgs := map[string][]byte{}
+	if strings.ToLower(ext) == ".hcl" {
+		cfgs, err = convertHCL(bb)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cfg, err := ConvertToJSON(bb, ext)
+		if err != nil {
+			return nil, err
+		}
+		cfgs[""] = cfg
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := map[string][]byte{}
+	for name, cfg := range cfgs {
+		cfg, err := resolveIncludes(cfg, path, []string{absPath})
+		if err != nil {
+			return nil, err
+		}
+
+		id := path
+		if name != "" {
+			id += "#" + name
+		}
+		configs[id] = cfg
+	}
+	return configs, nil
+}
+
+// ConvertToJSON converts a config in the format of the file extension ext to JSON.
+// Supported are ".jsonc", ".toml", ".hcl", ".yml" and ".yaml". Every other extension is treated as JSON
+// and returned as it is. HCL configs with proxy blocks can not be converted, use ReadConfigs instead.
+func ConvertToJSON(bb []byte, ext string) ([]byte, error) {
+	switch strings.ToLower(ext) {
+	case ".jsonc":
+		return stripJSONComments(bb), nil
+	case ".hcl":
+		cfgs, err := convertHCL(bb)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, ok := cfgs[""]
+		if !ok || len(cfgs) > 1 {
+			return nil, fmt.Errorf("%s blocks define multiple configs", HCLProxyBlock)
+		}
+		return cfg, nil
+	case ".toml":
+		var v map[string]interface{}
+		if err := toml.Unmarshal(bb, &v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	case ".yml", ".yaml":
+		return yaml.YAMLToJSON(bb)
+	default:
+		return bb, nil
+	}
+}
+
+// convertHCL converts an HCL document to JSON configs. If the document has proxy blocks,
+// like `proxy "lobby" { ... }`, every block is a config named after its label and all
+// attributes outside of the blocks are the defaults of every block.
+// Otherwise, the whole document is one config with an empty name.
+func convertHCL(bb []byte) (cfgs map[string][]byte, err error) {
+	file, err := hcl.ParseBytes(bb)
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected HCL root %T", file.Node)
+	}
+
+	// Literals of unsupported types panic
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid HCL value; %v", r)
+		}
+	}()
+
+	doc := hclObject(list)
+	proxies, ok := doc[HCLProxyBlock].(map[string]interface{})
+	if !ok {
+		cfg, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"": cfg}, nil
+	}
+	delete(doc, HCLProxyBlock)
+
+	cfgs = map[string][]byte{}
+	for name, proxy := range proxies {
+		fields, ok := proxy.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s %q is no block", HCLProxyBlock, name)
+		}
+
+		defaults, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		cfgs[name] = mergeJSON(defaults, cfg)
+	}
+	return cfgs, nil
+}
+
+// hclObject converts the items of an HCL object to a map. Blocks with labels,
+// like `a "b" { ... }`, become nested maps, so that every block is an object.
+func hclObject(list *ast.ObjectList) map[string]interface{} {
+	obj := map[string]interface{}{}
+	for _, item := range list.Items {
+		current := obj
+		for i, key := range item.Keys {
+			name := fmt.Sprint(key.Token.Value())
+			if i == len(item.Keys)-1 {
+				value := hclValue(item.Val)
+				// Repeated blocks are merged
+				existing, existingIsObj := current[name].(map[string]interface{})
+				valueObj, valueIsObj := value.(map[string]interface{})
+				if existingIsObj && valueIsObj {
+					value = mergeObjects(existing, valueObj)
+				}
+				current[name] = value
+				break
+			}
+
+			next, ok := current[name].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				current[name] = next
+			}
+			current = next
+		}
+	}
+	return obj
+}
+
+func hclValue(node ast.Node) interface{} {
+	switch n := node.(type) {
+	case *ast.ObjectType:
+		return hclObject(n.List)
+	case *ast.ListType:
+		values := make([]interface{}, 0, len(n.List))
+		for _, element := range n.List {
+			values = append(values, hclValue(element))
+		}
+		return values
+	case *ast.LiteralType:
+		return n.Token.Value()
+	default:
+		panic(fmt.Sprintf("unsupported node %T", node))
+	}
+}
@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertToJSON(t *testing.T) {
+	tt := []struct {
+		ext  string
+		in   string
+		want string
+	}{
+		{
+			ext:  ".json",
+			in:   `{"domainName":"mc.example.com"}`,
+			want: `{"domainName":"mc.example.com"}`,
+		},
+		{
+			ext: ".toml",
+			in: `domainName = "mc.example.com"
+proxyTo = ":25566"
+
+[offlineStatus]
+motd = "Offline"
+maxPlayers = 20`,
+			want: `{"domainName":"mc.example.com","offlineStatus":{"maxPlayers":20,"motd":"Offline"},"proxyTo":":25566"}`,
+		},
+		{
+			ext: ".hcl",
+			in: `domainName = "mc.example.com"
+disconnectMessage = <<EOF
+Offline
+EOF
+
+offlineStatus {
+  maxPlayers = 20
+}
+
+callbackServer {
+  events = ["PlayerJoin"]
+}`,
+			want: `{"callbackServer":{"events":["PlayerJoin"]},"disconnectMessage":"Offline\n","domainName":"mc.example.com","offlineStatus":{"maxPlayers":20}}`,
+		},
+		{
+			ext: ".YAML",
+			in: `domainName: mc.example.com
+callbackServer:
+  events:
+    - PlayerJoin`,
+			want: `{"callbackServer":{"events":["PlayerJoin"]},"domainName":"mc.example.com"}`,
+		},
+	}
+
+	for _, tc := range tt {
+		got, err := ConvertToJSON([]byte(tc.in), tc.ext)
+		if err != nil {
+			t.Errorf("%s: %s", tc.ext, err)
+			continue
+		}
+
+		if string(got) != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.ext, got, tc.want)
+		}
+	}
+
+	if _, err := ConvertToJSON([]byte(`domainName = `), ".toml"); err == nil {
+		t.Error("got no error for invalid TOML")
+	}
+}
+
+func TestReadConfigs_HCLProxyBlocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "example.hcl")
+	hcl := `listenTo = ":25565"
+
+offlineStatus {
+  motd = "Offline"
+}
+
+proxy "lobby" {
+  domainName = "lobby.example.com"
+}
+
+proxy "survival" {
+  domainName = "survival.example.com"
+
+  offlineStatus {
+    maxPlayers = 50
+  }
+}`
+	if err := ioutil.WriteFile(path, []byte(hcl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err := ReadConfigs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		path + "#lobby":    `{"domainName":"lobby.example.com","listenTo":":25565","offlineStatus":{"motd":"Offline"}}`,
+		path + "#survival": `{"domainName":"survival.example.com","listenTo":":25565","offlineStatus":{"maxPlayers":50,"motd":"Offline"}}`,
+	}
+	if len(configs) != len(want) {
+		t.Fatalf("got %d configs; want %d", len(configs), len(want))
+	}
+	for id, cfg := range want {
+		if string(configs[id]) != cfg {
+			t.Errorf("%s: got %s; want %s", id, configs[id], cfg)
+		}
+	}
+
+	if _, err := ConvertToJSON([]byte(hcl), ".hcl"); err == nil {
+		t.Error("got no error for multiple configs")
+	}
+}
+
+func TestConvertToJSON_JSONC(t *testing.T) {
+	in := `{
+  // Customer: Example Corp
+  "domainName": "mc.example.com", /* "proxyTo": ":25566", */
+  "disconnectMessage": "See https://example.com//* \"/*\"",
+  "callbackServer": {"events": ["PlayerJoin",],},
+}`
+
+	got, err := ConvertToJSON([]byte(in), ".jsonc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, got); err != nil {
+		t.Fatalf("got invalid JSON %s; error: %s", got, err)
+	}
+
+	want := `{"domainName":"mc.example.com","disconnectMessage":"See https://example.com//* \"/*\"","callbackServer":{"events":["PlayerJoin"]}}`
+	if compact.String() != want {
+		t.Errorf("got %s; want %s", compact.String(), want)
+	}
+
+	// Lines must stay the same for validation errors
+	if bytes.Count(got, []byte("\n")) != strings.Count(in, "\n") {
+		t.Errorf("got %d lines; want %d", bytes.Count(got, []byte("\n")), strings.Count(in, "\n"))
+	}
+}
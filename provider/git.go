@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Git clones a git repository, pulls it in an interval and reads all files
+// in a directory of the repository as proxy configs. Data is only sent if the HEAD commit changed.
+// The ID of a config is the path of its file relative to the directory.
+// It requires the git binary to be installed.
+type Git struct {
+	Repository string
+	Branch     string
+	// Directory is the directory inside of the repository that contains the configs
+	Directory string
+	// WorkDir is the local directory the repository is cloned into
+	WorkDir  string
+	Interval time.Duration
+
+	*status
+	head   string
+	closed chan bool
+	once   sync.Once
+	// files are the configs of every file that was parsed, which files that fail to parse keep
+	files map[string]map[string][]byte
+}
+
+// NewGit creates a new Git provider
+func NewGit(repository, branch, directory, workDir string, interval time.Duration) *Git {
+	return &Git{
+		Repository: repository,
+		Branch:     branch,
+		Directory:  directory,
+		WorkDir:    workDir,
+		Interval:   interval,
+		status:     newStatus(GitType),
+		closed:     make(chan bool),
+	}
+}
+
+func (p *Git) Provide(dataCh chan<- Data) (Data, error) {
+	if err := validateInterval(p.Interval); err != nil {
+		return Data{}, err
+	}
+
+	data, _, err := p.pull()
+	if err != nil {
+		return Data{}, err
+	}
+	p.loaded()
+
+	go p.poll(dataCh)
+	return data, nil
+}
+
+func (p *Git) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+	return nil
+}
+
+func (p *Git) poll(dataCh chan<- Data) {
+	p.watching(true)
+	defer p.watching(false)
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			data, modified, err := p.pull()
+			if err != nil {
+				log.Printf("Failed pulling %s; error: %s", p.Repository, err)
+				p.failed(err)
+				continue
+			}
+			p.loaded()
+
+			if !modified {
+				continue
+			}
+
+			select {
+			case dataCh <- data:
+			case <-p.closed:
+				return
+			}
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+// pull updates the local clone of the repository and reads the configs.
+// It reports false if the HEAD commit did not change since the last pull.
+func (p *Git) pull() (Data, bool, error) {
+	if err := p.update(); err != nil {
+		return Data{}, false, err
+	}
+
+	head, err := p.git("rev-parse", "HEAD")
+	if err != nil {
+		return Data{}, false, err
+	}
+
+	if head == p.head {
+		return Data{}, false, nil
+	}
+
+	configs, err := p.readConfigs()
+	if err != nil {
+		return Data{}, false, err
+	}
+
+	log.Printf("Loaded commit %s of %s", head, p.Repository)
+	p.head = head
+	return Data{
+		Type:    GitType,
+		Configs: configs,
+	}, true, nil
+}
+
+func (p *Git) update() error {
+	if _, err := os.Stat(filepath.Join(p.WorkDir, ".git")); os.IsNotExist(err) {
+		args := []string{"clone", "--depth", "1"}
+		if p.Branch != "" {
+			args = append(args, "--branch", p.Branch)
+		}
+		args = append(args, p.Repository, p.WorkDir)
+		_, err := runGit("", args...)
+		return err
+	}
+
+	ref := p.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	if _, err := p.git("fetch", "--depth", "1", "origin", ref); err != nil {
+		return err
+	}
+
+	_, err := p.git("reset", "--hard", "FETCH_HEAD")
+	return err
+}
+
+func (p *Git) readConfigs() (map[string][]byte, error) {
+	dir := filepath.Join(p.WorkDir, p.Directory)
+	configs := map[string][]byte{}
+	files := map[string]map[string][]byte{}
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		fileConfigs, err := ReadConfigs(path)
+		if err != nil {
+			if _, ok := err.(*os.PathError); ok {
+				return err
+			}
+			// A typo must not look like a removed file, so the file keeps the configs of the last commit
+			log.Printf("Failed parsing %s, keeping its last configs; error: %s", path, err)
+			p.failed(fmt.Errorf("failed parsing %s; %s", path, err))
+			files[rel] = p.files[rel]
+		} else {
+			files[rel] = map[string][]byte{}
+			for id, bb := range fileConfigs {
+				files[rel][rel+strings.TrimPrefix(id, path)] = bb
+			}
+		}
+
+		for id, bb := range files[rel] {
+			configs[id] = bb
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.files = files
+	return configs, nil
+}
+
+func (p *Git) git(args ...string) (string, error) {
+	return runGit(p.WorkDir, args...)
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed; %s: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGit_Provide(t *testing.T) {
+	repo, err := ioutil.TempDir("", "infrared-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repo)
+
+	workDir, err := ioutil.TempDir("", "infrared-clone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workDir)
+
+	commit := func(name, content string) {
+		path := filepath.Join(repo, "configs", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := runGit(repo, "add", "-A"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := runGit(repo, "-c", "user.name=infrared", "-c", "user.email=infrared@example.com", "commit", "-m", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := runGit(repo, "init"); err != nil {
+		t.Skip("git is not available;", err)
+	}
+	commit("lobby.json", `{"domainName":"lobby.example.com"}`)
+
+	p := NewGit(repo, "", "configs", filepath.Join(workDir, "clone"), time.Minute)
+	defer p.Close()
+
+	data, err := p.Provide(make(chan Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data.Configs["lobby.json"]) != `{"domainName":"lobby.example.com"}` {
+		t.Errorf("got configs %v", data.Configs)
+	}
+
+	if _, modified, err := p.pull(); err != nil || modified {
+		t.Errorf("got modified %v and error %v without a new commit", modified, err)
+	}
+
+	commit("survival/survival.json", `{"domainName":"survival.example.com"}`)
+
+	data, modified, err := p.pull()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !modified || len(data.Configs) != 2 {
+		t.Errorf("got modified %v with %d configs after a new commit", modified, len(data.Configs))
+	}
+
+	if _, ok := data.Configs["survival/survival.json"]; !ok {
+		t.Errorf("got configs %v", data.Configs)
+	}
+
+	commit("survival/survival.yml", "domainName: survival.example.com\n")
+	if data, _, err = p.pull(); err != nil {
+		t.Fatal(err)
+	}
+	want := string(data.Configs["survival/survival.yml"])
+
+	// A commit with a typo keeps the config of the file
+	commit("survival/survival.yml", "domainName: [survival.example.com\n")
+	data, modified, err = p.pull()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data.Configs["survival/survival.yml"]); !modified || got != want {
+		t.Errorf("got modified %v with config %q; want the last config %q", modified, got, want)
+	}
+}
+
+func TestGit_ProvideInvalidInterval(t *testing.T) {
+	p := NewGit("/nonexistent", "master", "", t.TempDir(), -time.Minute)
+	defer p.Close()
+
+	if _, err := p.Provide(make(chan Data)); err == nil || !strings.Contains(err.Error(), "not positive") {
+		t.Errorf("got error %v; want a negative interval to be rejected", err)
+	}
+}
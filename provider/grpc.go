@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/haveachin/infrared/provider/configsource"
+	"google.golang.org/grpc"
+)
+
+// GRPC receives proxy configs from a ConfigSource gRPC service over a long-lived stream.
+// The service sends full or incremental snapshots. When the stream breaks, GRPC reconnects
+// with an increasing delay and expects a full snapshot again.
+type GRPC struct {
+	Address     string
+	NodeID      string
+	DialOptions []grpc.DialOption
+
+	*status
+	conn    *grpc.ClientConn
+	configs map[string][]byte
+	ctx     context.Context
+	cancel  context.CancelFunc
+	once    sync.Once
+}
+
+// NewGRPC creates a new GRPC provider that connects to the ConfigSource at address
+func NewGRPC(address, nodeID string, opts ...grpc.DialOption) *GRPC {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &GRPC{
+		Address:     address,
+		NodeID:      nodeID,
+		DialOptions: opts,
+		configs:     map[string][]byte{},
+		status:      newStatus(GRPCType),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+func (p *GRPC) Provide(dataCh chan<- Data) (Data, error) {
+	conn, err := grpc.Dial(p.Address, p.DialOptions...)
+	if err != nil {
+		return Data{}, err
+	}
+	p.conn = conn
+
+	stream, err := p.openStream()
+	if err != nil {
+		conn.Close()
+		return Data{}, err
+	}
+
+	if err := p.receive(stream); err != nil {
+		conn.Close()
+		return Data{}, err
+	}
+	p.loaded()
+
+	go p.stream(stream, dataCh)
+	return p.data(), nil
+}
+
+func (p *GRPC) Close() error {
+	p.once.Do(p.cancel)
+	if p.conn == nil {
+		return nil
+	}
+	return p.conn.Close()
+}
+
+func (p *GRPC) openStream() (configsource.ConfigSource_StreamConfigClient, error) {
+	client := configsource.NewConfigSourceClient(p.conn)
+	return client.StreamConfig(p.ctx, &configsource.StreamConfigRequest{NodeId: p.NodeID})
+}
+
+func (p *GRPC) stream(stream configsource.ConfigSource_StreamConfigClient, dataCh chan<- Data) {
+	backoff := time.Second
+
+	for {
+		p.watching(stream != nil)
+		for stream != nil {
+			if err := p.receive(stream); err != nil {
+				if p.ctx.Err() != nil {
+					return
+				}
+				log.Printf("Config stream from %s broke; error: %s", p.Address, err)
+				p.failed(err)
+				break
+			}
+			p.loaded()
+			backoff = time.Second
+
+			select {
+			case dataCh <- p.data():
+			case <-p.ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-p.ctx.Done():
+			return
+		}
+
+		if backoff < time.Second*30 {
+			backoff *= 2
+		}
+
+		var err error
+		stream, err = p.openStream()
+		if err != nil {
+			log.Printf("Failed opening config stream from %s; error: %s", p.Address, err)
+			p.failed(err)
+		}
+	}
+}
+
+// receive applies the next snapshot of the stream
+func (p *GRPC) receive(stream configsource.ConfigSource_StreamConfigClient) error {
+	snapshot, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	if snapshot.Full {
+		p.configs = map[string][]byte{}
+	} else {
+		for _, id := range snapshot.RemovedIds {
+			delete(p.configs, id)
+		}
+	}
+
+	for id, cfg := range snapshot.Configs {
+		p.configs[id] = cfg
+	}
+
+	return nil
+}
+
+func (p *GRPC) data() Data {
+	configs := make(map[string][]byte, len(p.configs))
+	for id, cfg := range p.configs {
+		configs[id] = cfg
+	}
+
+	return Data{
+		Type:    GRPCType,
+		Configs: configs,
+	}
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/haveachin/infrared/provider/configsource"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testConfigSource struct {
+	configsource.UnimplementedConfigSourceServer
+	snapshots chan *configsource.ConfigSnapshot
+}
+
+func (s testConfigSource) StreamConfig(req *configsource.StreamConfigRequest, stream configsource.ConfigSource_StreamConfigServer) error {
+	for {
+		select {
+		case snapshot := <-s.snapshots:
+			if err := stream.Send(snapshot); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func TestGRPC_Provide(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	source := testConfigSource{snapshots: make(chan *configsource.ConfigSnapshot, 1)}
+	server := grpc.NewServer()
+	configsource.RegisterConfigSourceServer(server, source)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	source.snapshots <- &configsource.ConfigSnapshot{
+		Full: true,
+		Configs: map[string][]byte{
+			"lobby":    []byte(`{"domainName":"lobby.example.com"}`),
+			"survival": []byte(`{"domainName":"survival.example.com"}`),
+		},
+	}
+
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}
+	p := NewGRPC("bufnet", "test", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	data, err := p.Provide(dataCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Type != GRPCType {
+		t.Errorf("got type %s; want %s", data.Type, GRPCType)
+	}
+
+	if len(data.Configs) != 2 {
+		t.Fatalf("got %d configs; want 2", len(data.Configs))
+	}
+
+	source.snapshots <- &configsource.ConfigSnapshot{
+		Configs:    map[string][]byte{"lobby": []byte(`{"domainName":"mc.example.com"}`)},
+		RemovedIds: []string{"survival"},
+	}
+
+	select {
+	case data := <-dataCh:
+		if len(data.Configs) != 1 {
+			t.Fatalf("got %d configs; want 1", len(data.Configs))
+		}
+
+		if string(data.Configs["lobby"]) != `{"domainName":"mc.example.com"}` {
+			t.Errorf("got config %s", data.Configs["lobby"])
+		}
+	case <-time.After(time.Second):
+		t.Error("got no data after an incremental snapshot")
+	}
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTP polls proxy configs from a remote HTTP(S) endpoint.
+// The endpoint has to respond with a JSON object that maps an ID to a proxy config.
+// ETag and Last-Modified headers are honored so that unchanged configs are not sent again.
+type HTTP struct {
+	URL      string
+	Interval time.Duration
+	Client   *http.Client
+
+	*status
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	closed       chan bool
+	closeOnce    sync.Once
+}
+
+// NewHTTP creates a new HTTP provider that polls url every interval
+func NewHTTP(url string, interval time.Duration) *HTTP {
+	return &HTTP{
+		URL:      url,
+		Interval: interval,
+		Client:   pollClient,
+		status:   newStatus(HTTPType),
+		closed:   make(chan bool),
+	}
+}
+
+func (p *HTTP) Provide(dataCh chan<- Data) (Data, error) {
+	if err := validateInterval(p.Interval); err != nil {
+		return Data{}, err
+	}
+
+	data, _, err := p.fetch()
+	if err != nil {
+		return Data{}, err
+	}
+	p.loaded()
+
+	go p.poll(dataCh)
+	return data, nil
+}
+
+func (p *HTTP) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+	})
+	return nil
+}
+
+func (p *HTTP) poll(dataCh chan<- Data) {
+	p.watching(true)
+	defer p.watching(false)
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			data, modified, err := p.fetch()
+			if err != nil {
+				log.Printf("Failed polling %s; error: %s", p.URL, err)
+				p.failed(err)
+				continue
+			}
+			p.loaded()
+
+			if !modified {
+				continue
+			}
+
+			select {
+			case dataCh <- data:
+			case <-p.closed:
+				return
+			}
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+// fetch requests the configs from the endpoint. It reports false if the
+// endpoint responded that the configs did not change since the last fetch.
+func (p *HTTP) fetch() (Data, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	req, err := http.NewRequest(http.MethodGet, p.URL, nil)
+	if err != nil {
+		return Data{}, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.etag != "" {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+	if p.lastModified != "" {
+		req.Header.Set("If-Modified-Since", p.lastModified)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = pollClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Data{}, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return Data{}, false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Data{}, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	bb, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Data{}, false, err
+	}
+
+	var rawCfgs map[string]json.RawMessage
+	if err := json.Unmarshal(bb, &rawCfgs); err != nil {
+		return Data{}, false, err
+	}
+
+	configs := make(map[string][]byte, len(rawCfgs))
+	for id, rawCfg := range rawCfgs {
+		configs[id] = rawCfg
+	}
+
+	p.etag = resp.Header.Get("ETag")
+	p.lastModified = resp.Header.Get("Last-Modified")
+
+	return Data{
+		Type:    HTTPType,
+		Configs: configs,
+	}, true, nil
+}
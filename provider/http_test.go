@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTP_Provide(t *testing.T) {
+	body := `{"lobby":{"domainName":"lobby.example.com","proxyTo":":25566"}}`
+	etag := `"v1"`
+	mu := sync.Mutex{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	p := NewHTTP(server.URL, time.Millisecond*10)
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	data, err := p.Provide(dataCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Type != HTTPType {
+		t.Errorf("got type %s; want %s", data.Type, HTTPType)
+	}
+
+	if status := p.Status(); status.LastLoad.IsZero() || status.LastError != "" {
+		t.Errorf("got status %+v after a successful load", status)
+	}
+
+	if len(data.Configs) != 1 {
+		t.Fatalf("got %d configs; want 1", len(data.Configs))
+	}
+
+	if string(data.Configs["lobby"]) != `{"domainName":"lobby.example.com","proxyTo":":25566"}` {
+		t.Errorf("got config %s", data.Configs["lobby"])
+	}
+
+	select {
+	case <-dataCh:
+		t.Error("got data although the config did not change")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	mu.Lock()
+	etag = `"v2"`
+	body = `{}`
+	mu.Unlock()
+
+	select {
+	case data := <-dataCh:
+		if len(data.Configs) != 0 {
+			t.Errorf("got %d configs; want 0", len(data.Configs))
+		}
+	case <-time.After(time.Second):
+		t.Error("got no data after the config changed")
+	}
+}
+
+func TestHTTP_ProvideError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	p := NewHTTP(server.URL, time.Second)
+	defer p.Close()
+
+	if _, err := p.Provide(make(chan Data)); err == nil {
+		t.Fail()
+	}
+}
+
+func TestHTTP_ProvideInvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		p := NewHTTP("http://127.0.0.1:1", interval)
+		if _, err := p.Provide(make(chan Data)); err == nil || !strings.Contains(err.Error(), "not positive") {
+			t.Errorf("got error %v; want the interval %s to be rejected", err, interval)
+		}
+		p.Close()
+	}
+}
+
+func TestHTTP_ClientTimeout(t *testing.T) {
+	if p := NewHTTP("http://127.0.0.1:1", time.Second); p.Client.Timeout <= 0 {
+		t.Error("got a client without timeout; want hung endpoints to time out")
+	}
+}
@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// IncludeKey is the config key that lists the files that a config includes
+const IncludeKey = "include"
+
+// resolveIncludes merges the files included by a config into it. The include key is
+// either a path or a list of paths, which can be glob patterns and are relative to
+// the directory of the including file. Included files are merged in order and the
+// config itself overrides all of them. stack contains the paths of the files that
+// are currently being included to detect cycles.
+func resolveIncludes(cfg []byte, path string, stack []string) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(cfg, &obj); err != nil {
+		return cfg, nil
+	}
+
+	rawPatterns, ok := obj[IncludeKey]
+	if !ok {
+		return cfg, nil
+	}
+	delete(obj, IncludeKey)
+
+	patterns, err := includePatterns(rawPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	own, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := map[string]interface{}{}
+	origins := map[string]string{}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q; %s", pattern, err)
+		}
+		if matches == nil {
+			return nil, fmt.Errorf("include %q matches no files", pattern)
+		}
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			included, err := readIncludedConfig(match, stack)
+			if err != nil {
+				return nil, err
+			}
+
+			var includedObj map[string]interface{}
+			if err := json.Unmarshal(included, &includedObj); err != nil {
+				return nil, fmt.Errorf("included %s is no JSON object", match)
+			}
+			mergeIncluded(merged, includedObj, "", match, origins)
+		}
+	}
+
+	base, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return mergeJSON(base, own), nil
+}
+
+// mergeIncluded merges the included config of file deeply into base like mergeObjects.
+// If two included files set the same key to different values, a warning with both
+// files is logged, since only the order of the includes decides which value is used.
+// origins maps the paths of all merged values to the file that set them.
+func mergeIncluded(base, included map[string]interface{}, prefix, file string, origins map[string]string) {
+	for _, key := range sortedKeys(included) {
+		value := included[key]
+		path := prefix + "/" + key
+
+		baseObj, baseIsObj := base[key].(map[string]interface{})
+		includedObj, includedIsObj := value.(map[string]interface{})
+		if baseIsObj && includedIsObj {
+			mergeIncluded(baseObj, includedObj, path, file, origins)
+			continue
+		}
+
+		if existing, ok := base[key]; ok && !reflect.DeepEqual(existing, value) {
+			log.Printf("Included %s overrides %s of included %s", file, path, originOf(origins, path))
+		}
+		base[key] = value
+		origins[path] = file
+	}
+}
+
+// originOf returns the file that set the value at path or one of its parents
+func originOf(origins map[string]string, path string) string {
+	for ; path != ""; path = path[:strings.LastIndex(path, "/")] {
+		if origin, ok := origins[path]; ok {
+			return origin
+		}
+	}
+	return ""
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func readIncludedConfig(path string, stack []string) ([]byte, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, p := range stack {
+		if p == absPath {
+			cycle := append(stack[i:], absPath)
+			return nil, fmt.Errorf("include cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	bb, ext, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := ConvertToJSON(bb, ext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse included %s; %s", path, err)
+	}
+
+	return resolveIncludes(cfg, path, append(stack[:len(stack):len(stack)], absPath))
+}
+
+func includePatterns(raw json.RawMessage) ([]string, error) {
+	var pattern string
+	if err := json.Unmarshal(raw, &pattern); err == nil {
+		return []string{pattern}, nil
+	}
+
+	var patterns []string
+	if err := json.Unmarshal(raw, &patterns); err != nil {
+		return nil, fmt.Errorf("%s has to be a path or a list of paths", IncludeKey)
+	}
+	return patterns, nil
+}
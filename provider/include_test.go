@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadConfigFile_Include(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"defaults-a.json": `{"listenTo":":25565","offlineStatus":{"motd":"Offline"}}`,
+		"defaults-b.yml":  "offlineStatus:\n  maxPlayers: 20\n",
+		"base.toml":       "include = \"defaults-*\"\nproxyTo = \"base:25565\"\n",
+		"proxy.json":      `{"include":["base.toml"],"domainName":"mc.example.com","offlineStatus":{"motd":"Maintenance"}}`,
+	})
+
+	got, err := ReadConfigFile(filepath.Join(dir, "proxy.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"domainName":"mc.example.com","listenTo":":25565","offlineStatus":{"maxPlayers":20,"motd":"Maintenance"},"proxyTo":"base:25565"}`
+	if string(got) != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
+
+func TestReadConfigFile_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"a.json": `{"include":"b.json"}`,
+		"b.json": `{"include":"a.json"}`,
+	})
+
+	_, err := ReadConfigFile(filepath.Join(dir, "a.json"))
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("got %v; want include cycle error", err)
+	}
+}
+
+func TestReadConfigFile_IncludeNoMatches(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"proxy.json": `{"include":"missing/*.json"}`,
+	})
+
+	if _, err := ReadConfigFile(filepath.Join(dir, "proxy.json")); err == nil {
+		t.Error("got no error for include without matches")
+	}
+}
+
+func TestReadConfigFile_IncludeConflict(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"a.json":     `{"listenTo":":25565","offlineStatus":{"motd":"A"}}`,
+		"b.json":     `{"listenTo":":25565","offlineStatus":{"motd":"B"}}`,
+		"proxy.json": `{"include":["b.json","a.json"]}`,
+	})
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	got, err := ReadConfigFile(filepath.Join(dir, "proxy.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"listenTo":":25565","offlineStatus":{"motd":"A"}}`
+	if string(got) != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	wantLog := fmt.Sprintf("Included %s overrides /offlineStatus/motd of included %s",
+		filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json"))
+	if !strings.Contains(logs.String(), wantLog) || strings.Contains(logs.String(), "listenTo") {
+		t.Errorf("got logs %q; want %q", logs.String(), wantLog)
+	}
+}
@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"regexp"
+)
+
+var envVarPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv replaces ${VAR} and ${VAR:-default} in a config with the value of the
+// environment variable VAR. The default is used if VAR is unset or empty.
+// Variables without a default that are unset are replaced by an empty string.
+// $${VAR} escapes the expansion and is replaced by ${VAR}.
+//
+// The values of variables are escaped like the content of a JSON string, so quotes, backslashes and newlines
+// in them can't end a string early or add keys. The escapes are the same in the double quoted strings of all
+// config formats.
+func ExpandEnv(bb []byte) []byte {
+	return envVarPattern.ReplaceAllFunc(bb, func(match []byte) []byte {
+		if match[1] == '$' {
+			return match[1:]
+		}
+
+		groups := envVarPattern.FindSubmatch(match)
+		if value := os.Getenv(string(groups[1])); value != "" {
+			return escapeEnvValue(value)
+		}
+		return groups[3]
+	})
+}
+
+// escapeEnvValue escapes the value like the content of a JSON string without the quotes
+func escapeEnvValue(value string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return []byte(value)
+	}
+	// Encode writes the quoted string and a newline
+	bb := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	return bb[1 : len(bb)-1]
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("INFRARED_TEST_BIND", ":25566")
+	os.Setenv("INFRARED_TEST_EMPTY", "")
+	os.Setenv("INFRARED_TEST_SECRET", "p\"a\\ss\n\",\"admin\":\"x")
+	defer os.Unsetenv("INFRARED_TEST_BIND")
+	defer os.Unsetenv("INFRARED_TEST_EMPTY")
+	defer os.Unsetenv("INFRARED_TEST_SECRET")
+
+	tt := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   `{"listenTo":"${INFRARED_TEST_BIND}"}`,
+			want: `{"listenTo":":25566"}`,
+		},
+		{
+			in:   `{"listenTo":"${INFRARED_TEST_BIND:-:25565}"}`,
+			want: `{"listenTo":":25566"}`,
+		},
+		{
+			in:   `{"listenTo":"${INFRARED_TEST_EMPTY:-:25565}"}`,
+			want: `{"listenTo":":25565"}`,
+		},
+		{
+			in:   `{"listenTo":"${INFRARED_TEST_UNSET:-:25565}","proxyTo":"${INFRARED_TEST_UNSET}"}`,
+			want: `{"listenTo":":25565","proxyTo":""}`,
+		},
+		{
+			in:   `{"motd":"$${INFRARED_TEST_BIND} costs $5"}`,
+			want: `{"motd":"${INFRARED_TEST_BIND} costs $5"}`,
+		},
+		{
+			in:   `{"token":"${INFRARED_TEST_SECRET}"}`,
+			want: `{"token":"p\"a\\ss\n\",\"admin\":\"x"}`,
+		},
+	}
+
+	for _, tc := range tt {
+		if got := string(ExpandEnv([]byte(tc.in))); got != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestExpandEnv_Formats(t *testing.T) {
+	secret := "p\"a\\ss\n\",\"admin\":\"x"
+	os.Setenv("INFRARED_TEST_SECRET", secret)
+	defer os.Unsetenv("INFRARED_TEST_SECRET")
+
+	// Values with quotes, backslashes and newlines stay one string in every format
+	tt := map[string]string{
+		".json": `{"token":"${INFRARED_TEST_SECRET}"}`,
+		".yml":  `token: "${INFRARED_TEST_SECRET}"`,
+		".toml": `token = "${INFRARED_TEST_SECRET}"`,
+		".hcl":  `token = "${INFRARED_TEST_SECRET}"`,
+	}
+	for ext, in := range tt {
+		bb, err := ConvertToJSON(ExpandEnv([]byte(in)), ext)
+		if err != nil {
+			t.Errorf("%s: got error %v; want a valid config", ext, err)
+			continue
+		}
+
+		var cfg map[string]interface{}
+		if err := json.Unmarshal(bb, &cfg); err != nil {
+			t.Errorf("%s: got error %v; want JSON", ext, err)
+			continue
+		}
+		if len(cfg) != 1 || cfg["token"] != secret {
+			t.Errorf("%s: got %v; want only the token %q", ext, cfg, secret)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package provider
+
+import "bytes"
+
+// stripJSONComments converts JSON with comments to JSON. Line comments (//),
+// block comments (/* */) and trailing commas are replaced by spaces, so that the
+// lines and columns of all values stay the same.
+func stripJSONComments(bb []byte) []byte {
+	out := make([]byte, len(bb))
+	copy(out, bb)
+
+	// Index of the last comma that might be trailing or -1
+	comma := -1
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			comma = -1
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := len(out)
+			if n := bytes.Index(out[i+2:], []byte("*/")); n >= 0 {
+				end = i + 2 + n + 2
+			}
+			for ; i < end; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		case c == ',':
+			comma = i
+		case c == '}' || c == ']':
+			if comma >= 0 {
+				out[comma] = ' '
+			}
+			comma = -1
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			comma = -1
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,381 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	kubernetesServiceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// KubernetesCRDGroupVersion is the API group and version of the ProxyConfig CRD
+	KubernetesCRDGroupVersion = "infrared.dev/v1alpha1"
+)
+
+// errKubernetesGone is the error of watches whose resource version is too old, so all objects have to be listed again
+var errKubernetesGone = errors.New("resource version is too old")
+
+// KubernetesResource is the kind of Kubernetes object that proxy configs are read from
+type KubernetesResource string
+
+const (
+	// KubernetesConfigMaps reads every data entry of the selected ConfigMaps as a proxy config
+	KubernetesConfigMaps KubernetesResource = "configmaps"
+	// KubernetesProxyConfigs reads the spec of every selected ProxyConfig custom resource as a proxy config
+	KubernetesProxyConfigs KubernetesResource = "proxyconfigs"
+)
+
+// Kubernetes lists and watches ConfigMaps or ProxyConfig custom resources of a namespace
+// through the Kubernetes API. The ID of a config is "<namespace>/<name>" for custom resources
+// and "<namespace>/<name>/<key>" for ConfigMap entries.
+type Kubernetes struct {
+	APIServer     string
+	Token         string
+	Namespace     string
+	LabelSelector string
+	Resource      KubernetesResource
+	Client        *http.Client
+
+	*status
+	mu              sync.Mutex
+	objects         map[string]map[string][]byte
+	resourceVersion string
+	ctx             context.Context
+	cancel          context.CancelFunc
+}
+
+// NewKubernetesInCluster creates a new Kubernetes provider that authenticates with the
+// service account of the pod it runs in. An empty namespace defaults to the namespace of the pod.
+func NewKubernetesInCluster(namespace, labelSelector string, resource KubernetesResource) (*Kubernetes, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running inside of a Kubernetes cluster")
+	}
+
+	token, err := ioutil.ReadFile(kubernetesServiceAccountPath + "/token")
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := ioutil.ReadFile(kubernetesServiceAccountPath + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account certificate")
+	}
+
+	if namespace == "" {
+		bb, err := ioutil.ReadFile(kubernetesServiceAccountPath + "/namespace")
+		if err != nil {
+			return nil, err
+		}
+		namespace = strings.TrimSpace(string(bb))
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: certPool},
+		},
+	}
+
+	return NewKubernetes("https://"+net.JoinHostPort(host, port), strings.TrimSpace(string(token)), namespace, labelSelector, resource, client), nil
+}
+
+// NewKubernetes creates a new Kubernetes provider for the given API server
+func NewKubernetes(apiServer, token, namespace, labelSelector string, resource KubernetesResource, client *http.Client) *Kubernetes {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Kubernetes{
+		APIServer:     strings.TrimSuffix(apiServer, "/"),
+		Token:         token,
+		Namespace:     namespace,
+		LabelSelector: labelSelector,
+		Resource:      resource,
+		Client:        client,
+		status:        newStatus(KubernetesType),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+type kubernetesObject struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+	Spec json.RawMessage   `json:"spec"`
+}
+
+type kubernetesList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []kubernetesObject `json:"items"`
+}
+
+type kubernetesWatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// kubernetesStatus is the object of ERROR watch events
+type kubernetesStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (p *Kubernetes) Provide(dataCh chan<- Data) (Data, error) {
+	if err := p.list(); err != nil {
+		return Data{}, err
+	}
+
+	go p.watch(dataCh)
+	return p.data(), nil
+}
+
+func (p *Kubernetes) Close() error {
+	p.cancel()
+	return nil
+}
+
+func (p *Kubernetes) data() Data {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	configs := map[string][]byte{}
+	for _, objectCfgs := range p.objects {
+		for id, cfg := range objectCfgs {
+			configs[id] = cfg
+		}
+	}
+
+	return Data{
+		Type:    KubernetesType,
+		Configs: configs,
+	}
+}
+
+// configs extracts all proxy configs of an object
+func (p *Kubernetes) configs(obj kubernetesObject) map[string][]byte {
+	objectID := obj.Metadata.Namespace + "/" + obj.Metadata.Name
+	configs := map[string][]byte{}
+
+	if p.Resource == KubernetesProxyConfigs {
+		if len(obj.Spec) > 0 {
+			configs[objectID] = obj.Spec
+		}
+		return configs
+	}
+
+	for key, value := range obj.Data {
+		configs[objectID+"/"+key] = []byte(value)
+	}
+	return configs
+}
+
+func (p *Kubernetes) resourcePath() string {
+	if p.Resource == KubernetesProxyConfigs {
+		return fmt.Sprintf("/apis/%s/namespaces/%s/%s", KubernetesCRDGroupVersion, p.Namespace, p.Resource)
+	}
+	return fmt.Sprintf("/api/v1/namespaces/%s/%s", p.Namespace, p.Resource)
+}
+
+func (p *Kubernetes) request(query url.Values) (*http.Response, error) {
+	if p.LabelSelector != "" {
+		query.Set("labelSelector", p.LabelSelector)
+	}
+
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, p.APIServer+p.resourcePath()+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusGone {
+		resp.Body.Close()
+		return nil, errKubernetesGone
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (p *Kubernetes) list() error {
+	resp, err := p.request(url.Values{})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var list kubernetesList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return err
+	}
+
+	objects := map[string]map[string][]byte{}
+	for _, obj := range list.Items {
+		objects[obj.Metadata.Namespace+"/"+obj.Metadata.Name] = p.configs(obj)
+	}
+
+	p.mu.Lock()
+	p.objects = objects
+	p.resourceVersion = list.Metadata.ResourceVersion
+	p.mu.Unlock()
+	p.loaded()
+	return nil
+}
+
+// watch watches the objects until the provider is closed. Watches that the API server ends continue at the last
+// resource version, while watches whose resource version is too old or that failed list all objects again.
+func (p *Kubernetes) watch(dataCh chan<- Data) {
+	relist := false
+	for {
+		if relist {
+			if !p.relist(dataCh) {
+				return
+			}
+		}
+
+		err := p.watchOnce(dataCh)
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case errors.Is(err, errKubernetesGone):
+			// The API server doesn't have the changes since the resource version anymore
+			log.Printf("Resource version of Kubernetes %s expired; listing them again", p.Resource)
+			relist = true
+			continue
+		case errors.Is(err, io.EOF):
+			relist = false
+		default:
+			log.Printf("Stopped watching Kubernetes %s; error: %s", p.Resource, err)
+			p.failed(err)
+			relist = true
+		}
+
+		select {
+		case <-time.After(time.Second):
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// relist lists all objects again and sends their configs. It reports false if the provider was closed.
+func (p *Kubernetes) relist(dataCh chan<- Data) bool {
+	for {
+		err := p.list()
+		if err == nil {
+			break
+		}
+		if p.ctx.Err() != nil {
+			return false
+		}
+		log.Printf("Failed listing Kubernetes %s; error: %s", p.Resource, err)
+		p.failed(err)
+
+		select {
+		case <-time.After(time.Second):
+		case <-p.ctx.Done():
+			return false
+		}
+	}
+
+	select {
+	case dataCh <- p.data():
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+func (p *Kubernetes) watchOnce(dataCh chan<- Data) error {
+	p.mu.Lock()
+	query := url.Values{}
+	query.Set("watch", "true")
+	query.Set("resourceVersion", p.resourceVersion)
+	// Bookmarks keep the resource version current while nothing changes, so it expires less often
+	query.Set("allowWatchBookmarks", "true")
+	p.mu.Unlock()
+
+	resp, err := p.request(query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	p.watching(true)
+	defer p.watching(false)
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event kubernetesWatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			return err
+		}
+
+		if event.Type == "ERROR" {
+			var status kubernetesStatus
+			if err := json.Unmarshal(event.Object, &status); err == nil && status.Code == http.StatusGone {
+				return errKubernetesGone
+			}
+			return fmt.Errorf("watch failed; %s", event.Object)
+		}
+
+		var obj kubernetesObject
+		if err := json.Unmarshal(event.Object, &obj); err != nil {
+			return err
+		}
+
+		objectID := obj.Metadata.Namespace + "/" + obj.Metadata.Name
+		p.mu.Lock()
+		p.resourceVersion = obj.Metadata.ResourceVersion
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			p.objects[objectID] = p.configs(obj)
+		case "DELETED":
+			delete(p.objects, objectID)
+		default:
+			// BOOKMARK events only move the resource version
+			p.mu.Unlock()
+			continue
+		}
+		p.mu.Unlock()
+		p.loaded()
+
+		select {
+		case dataCh <- p.data():
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKubernetes_Provide(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/minecraft/configmaps" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Query().Get("labelSelector") != "infrared=proxy" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if r.URL.Query().Get("watch") != "true" {
+			w.Write([]byte(`{"metadata":{"resourceVersion":"10"},"items":[
+				{"metadata":{"name":"lobby","namespace":"minecraft"},"data":{"proxy.json":"{\"domainName\":\"lobby.example.com\"}"}}
+			]}`))
+			return
+		}
+
+		if r.URL.Query().Get("resourceVersion") != "10" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+
+		w.Write([]byte(`{"type":"DELETED","object":{"metadata":{"name":"lobby","namespace":"minecraft","resourceVersion":"11"}}}`))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	p := NewKubernetes(server.URL, "", "minecraft", "infrared=proxy", KubernetesConfigMaps, http.DefaultClient)
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	data, err := p.Provide(dataCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data.Configs["minecraft/lobby/proxy.json"]) != `{"domainName":"lobby.example.com"}` {
+		t.Errorf("got configs %v", data.Configs)
+	}
+
+	select {
+	case data := <-dataCh:
+		if len(data.Configs) != 0 {
+			t.Errorf("got %d configs; want 0", len(data.Configs))
+		}
+	case <-time.After(time.Second):
+		t.Error("got no data after the watch event")
+	}
+}
+
+func TestKubernetes_WatchExpired(t *testing.T) {
+	var lists, watches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "true" {
+			if atomic.AddInt32(&lists, 1) == 1 {
+				w.Write([]byte(`{"metadata":{"resourceVersion":"10"},"items":[]}`))
+				return
+			}
+			w.Write([]byte(`{"metadata":{"resourceVersion":"20"},"items":[
+				{"metadata":{"name":"lobby","namespace":"minecraft"},"data":{"proxy.json":"{}"}}
+			]}`))
+			return
+		}
+
+		switch atomic.AddInt32(&watches, 1) {
+		case 1:
+			// The API server ended the watch, which continues at the same resource version
+		case 2:
+			if r.URL.Query().Get("resourceVersion") != "10" {
+				t.Errorf("got resource version %s; want the watch to continue at 10", r.URL.Query().Get("resourceVersion"))
+			}
+			w.Write([]byte(`{"type":"ERROR","object":{"kind":"Status","code":410,"message":"too old resource version"}}`))
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	p := NewKubernetes(server.URL, "", "minecraft", "", KubernetesConfigMaps, http.DefaultClient)
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	if _, err := p.Provide(dataCh); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case data := <-dataCh:
+		if len(data.Configs) != 1 {
+			t.Errorf("got configs %v; want the configs of the new list", data.Configs)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("got no data after the resource version expired")
+	}
+
+	if n := atomic.LoadInt32(&lists); n != 2 {
+		t.Errorf("got %d lists; want 2, one at the start and one after the resource version expired", n)
+	}
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATS reads proxy configs from all keys of a NATS JetStream key-value bucket
+// and watches the bucket for changes. The ID of a config is its key.
+type NATS struct {
+	URL    string
+	Bucket string
+
+	*status
+	conn    *nats.Conn
+	configs map[string][]byte
+	closed  chan bool
+	once    sync.Once
+}
+
+// NewNATS creates a new NATS provider for the bucket on the server at url, like "nats://127.0.0.1:4222"
+func NewNATS(url, bucket string) *NATS {
+	return &NATS{
+		URL:    url,
+		Bucket: bucket,
+		status: newStatus(NATSType),
+		closed: make(chan bool),
+	}
+}
+
+func (p *NATS) Provide(dataCh chan<- Data) (Data, error) {
+	conn, err := nats.Connect(p.URL, nats.MaxReconnects(-1))
+	if err != nil {
+		return Data{}, err
+	}
+	p.conn = conn
+
+	watcher, err := p.watch()
+	if err != nil {
+		conn.Close()
+		return Data{}, err
+	}
+	p.loaded()
+
+	go p.update(watcher, dataCh)
+	return p.data(), nil
+}
+
+func (p *NATS) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+		if p.conn != nil {
+			p.conn.Close()
+		}
+	})
+	return nil
+}
+
+// watch starts watching the bucket and reads all initial values
+func (p *NATS) watch() (nats.KeyWatcher, error) {
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	kv, err := js.KeyValue(p.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := kv.WatchAll()
+	if err != nil {
+		return nil, err
+	}
+
+	p.configs = map[string][]byte{}
+	for entry := range watcher.Updates() {
+		// A nil entry marks the end of the initial values
+		if entry == nil {
+			return watcher, nil
+		}
+		p.apply(entry)
+	}
+
+	return nil, errors.New("watcher stopped before all initial values were received")
+}
+
+func (p *NATS) update(watcher nats.KeyWatcher, dataCh chan<- Data) {
+	for {
+		p.watching(true)
+		for entry := range watcher.Updates() {
+			if entry == nil {
+				continue
+			}
+			p.apply(entry)
+			p.loaded()
+
+			select {
+			case dataCh <- p.data():
+			case <-p.closed:
+				watcher.Stop()
+				return
+			}
+		}
+		p.watching(false)
+
+		select {
+		case <-p.closed:
+			return
+		default:
+		}
+		log.Printf("Stopped watching NATS bucket %s", p.Bucket)
+
+		for {
+			select {
+			case <-time.After(time.Second * 5):
+			case <-p.closed:
+				return
+			}
+
+			var err error
+			watcher, err = p.watch()
+			if err == nil {
+				break
+			}
+			log.Printf("Failed watching NATS bucket %s; error: %s", p.Bucket, err)
+			p.failed(err)
+		}
+		p.loaded()
+
+		// Updates might have been missed in the meantime
+		select {
+		case dataCh <- p.data():
+		case <-p.closed:
+			watcher.Stop()
+			return
+		}
+	}
+}
+
+func (p *NATS) apply(entry nats.KeyValueEntry) {
+	switch entry.Operation() {
+	case nats.KeyValueDelete, nats.KeyValuePurge:
+		delete(p.configs, entry.Key())
+	default:
+		p.configs[entry.Key()] = entry.Value()
+	}
+}
+
+func (p *NATS) data() Data {
+	configs := make(map[string][]byte, len(p.configs))
+	for id, cfg := range p.configs {
+		configs[id] = cfg
+	}
+
+	return Data{
+		Type:    NATSType,
+		Configs: configs,
+	}
+}
@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Pipe reads config snapshots from stdin or a named pipe. Every line is a JSON object
+// that maps a unique ID to a proxy config and replaces all configs of the previous line.
+// A named pipe is opened again when its writer closes it.
+type Pipe struct {
+	// Path is the path of the named pipe or "-" for stdin
+	Path string
+
+	*status
+	closed chan bool
+	once   sync.Once
+}
+
+// NewPipe creates a new Pipe provider that reads from the path or stdin if the path is "-"
+func NewPipe(path string) *Pipe {
+	return &Pipe{
+		Path:   path,
+		status: newStatus(PipeType),
+		closed: make(chan bool),
+	}
+}
+
+// Provide returns no configs, because there might never be a first line.
+// Every line is sent to dataCh as it is read.
+func (p *Pipe) Provide(dataCh chan<- Data) (Data, error) {
+	if p.Path == "-" {
+		go p.read(os.Stdin, dataCh)
+		return Data{Type: PipeType}, nil
+	}
+
+	// Opening a named pipe blocks until it has a writer
+	go func() {
+		for {
+			file, err := os.Open(p.Path)
+			if err != nil {
+				log.Printf("Failed opening %s; error: %s", p.Path, err)
+				p.failed(err)
+				select {
+				case <-time.After(time.Second):
+					continue
+				case <-p.closed:
+					return
+				}
+			}
+
+			p.read(file, dataCh)
+			file.Close()
+
+			select {
+			case <-p.closed:
+				return
+			default:
+			}
+		}
+	}()
+
+	return Data{Type: PipeType}, nil
+}
+
+func (p *Pipe) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+	return nil
+}
+
+func (p *Pipe) read(r io.Reader, dataCh chan<- Data) {
+	p.watching(true)
+	defer p.watching(false)
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			p.send(line, dataCh)
+		}
+
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			log.Printf("Failed reading %s; error: %s", p.Path, err)
+			p.failed(err)
+			return
+		}
+	}
+}
+
+func (p *Pipe) send(line []byte, dataCh chan<- Data) {
+	var configs map[string]json.RawMessage
+	if err := json.Unmarshal(line, &configs); err != nil {
+		log.Printf("Failed parsing snapshot from %s; error: %s", p.Path, err)
+		p.failed(err)
+		return
+	}
+	p.loaded()
+
+	data := Data{
+		Type:    PipeType,
+		Configs: make(map[string][]byte, len(configs)),
+	}
+	for id, cfg := range configs {
+		data.Configs[id] = cfg
+	}
+
+	select {
+	case dataCh <- data:
+	case <-p.closed:
+	}
+}
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPipe_read(t *testing.T) {
+	input := `{"lobby":{"domainName":"lobby.example.com"}}
+invalid
+
+{"lobby":{"domainName":"mc.example.com"},"survival":{"domainName":"survival.example.com"}}`
+
+	p := NewPipe("-")
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	go func() {
+		p.read(strings.NewReader(input), dataCh)
+		close(dataCh)
+	}()
+
+	var snapshots []Data
+	for data := range dataCh {
+		snapshots = append(snapshots, data)
+	}
+
+	if len(snapshots) != 2 {
+		t.Fatalf("got %d snapshots; want 2", len(snapshots))
+	}
+
+	if string(snapshots[0].Configs["lobby"]) != `{"domainName":"lobby.example.com"}` {
+		t.Errorf("got config %s", snapshots[0].Configs["lobby"])
+	}
+
+	if len(snapshots[1].Configs) != 2 {
+		t.Errorf("got %d configs; want 2", len(snapshots[1].Configs))
+	}
+
+	if string(snapshots[1].Configs["lobby"]) != `{"domainName":"mc.example.com"}` {
+		t.Errorf("got config %s", snapshots[1].Configs["lobby"])
+	}
+}
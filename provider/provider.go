@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// pollTimeout is the time that a polled endpoint has to answer, so a hung endpoint doesn't stall polling
+const pollTimeout = 30 * time.Second
+
+// pollClient is the default client of the providers that poll HTTP endpoints
+var pollClient = &http.Client{Timeout: pollTimeout}
+
+// validateInterval checks the interval of a polling Provider, since tickers panic with non-positive intervals
+func validateInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval %s is not positive", interval)
+	}
+	return nil
+}
+
+// Type is the kind of source a Provider reads its configs from
+type Type string
+
+const (
+	HTTPType       Type = "http"
+	EtcdType       Type = "etcd"
+	ConsulType     Type = "consul"
+	KubernetesType Type = "kubernetes"
+	DockerType     Type = "docker"
+	RedisType      Type = "redis"
+	S3Type         Type = "s3"
+	GitType        Type = "git"
+	SQLType        Type = "sql"
+	EnvType        Type = "env"
+	FileType       Type = "file"
+	ZooKeeperType  Type = "zookeeper"
+	GRPCType       Type = "grpc"
+	PipeType       Type = "pipe"
+	CompositeType  Type = "composite"
+	NATSType       Type = "nats"
+	DNSType        Type = "dns"
+)
+
+// Data is a snapshot of all proxy configs that a Provider currently supplies.
+// Configs maps an ID, that is unique within the Provider, to the raw JSON of a proxy config.
+type Data struct {
+	Type    Type
+	Configs map[string][]byte
+}
+
+// Checksum returns the SHA-256 hash of all configs, which is equal for Data with
+// the same configs regardless of their Type
+func (d Data) Checksum() string {
+	hash := sha256.New()
+	for _, id := range sortedIDs(d.Configs) {
+		// The lengths prevent different configs from resulting in the same input
+		fmt.Fprintf(hash, "%d:%s%d:", len(id), id, len(d.Configs[id]))
+		hash.Write(d.Configs[id])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Provider supplies proxy configs from an arbitrary source and keeps them up to date
+type Provider interface {
+	// Provide returns the current Data and then sends every changed Data
+	// to dataCh until the Provider is closed
+	Provide(dataCh chan<- Data) (Data, error)
+	// Status returns the current health of the Provider
+	Status() Status
+	Close() error
+}
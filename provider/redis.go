@@ -0,0 +1,270 @@
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Redis reads proxy configs from the fields of a Redis hash and reloads them
+// every time a message is published to a channel. The ID of a config is its field name.
+type Redis struct {
+	Address  string
+	Password string
+	DB       int
+	Key      string
+	Channel  string
+
+	*status
+	mu     sync.Mutex
+	conn   *redisConn
+	closed chan bool
+	once   sync.Once
+}
+
+// NewRedis creates a new Redis provider that reads the hash key and subscribes to channel
+func NewRedis(address, password string, db int, key, channel string) *Redis {
+	return &Redis{
+		Address:  address,
+		Password: password,
+		DB:       db,
+		Key:      key,
+		Channel:  channel,
+		status:   newStatus(RedisType),
+		closed:   make(chan bool),
+	}
+}
+
+func (p *Redis) Provide(dataCh chan<- Data) (Data, error) {
+	configs, err := p.load()
+	if err != nil {
+		return Data{}, err
+	}
+	p.loaded()
+
+	go p.subscribe(dataCh)
+	return Data{
+		Type:    RedisType,
+		Configs: configs,
+	}, nil
+}
+
+func (p *Redis) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		return p.conn.Close()
+	}
+	return nil
+}
+
+func (p *Redis) load() (map[string][]byte, error) {
+	conn, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	reply, err := conn.do("HGETALL", p.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values)%2 != 0 {
+		return nil, fmt.Errorf("unexpected HGETALL reply %v", reply)
+	}
+
+	configs := map[string][]byte{}
+	for i := 0; i < len(values); i += 2 {
+		field, _ := values[i].([]byte)
+		value, _ := values[i+1].([]byte)
+		configs[string(field)] = value
+	}
+	return configs, nil
+}
+
+func (p *Redis) subscribe(dataCh chan<- Data) {
+	for {
+		err := p.subscribeOnce(dataCh)
+		select {
+		case <-p.closed:
+			return
+		default:
+		}
+		log.Printf("Stopped subscribing to Redis on %s; error: %s", p.Address, err)
+		p.failed(err)
+
+		select {
+		case <-time.After(time.Second * 5):
+		case <-p.closed:
+			return
+		}
+
+		// Messages might have been missed while we were disconnected
+		p.reload(dataCh)
+	}
+}
+
+func (p *Redis) subscribeOnce(dataCh chan<- Data) error {
+	conn, err := p.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	p.mu.Lock()
+	p.conn = conn
+	p.mu.Unlock()
+
+	if err := conn.send("SUBSCRIBE", p.Channel); err != nil {
+		return err
+	}
+
+	p.watching(true)
+	defer p.watching(false)
+
+	for {
+		reply, err := conn.receive()
+		if err != nil {
+			return err
+		}
+
+		msg, ok := reply.([]interface{})
+		if !ok || len(msg) < 1 {
+			continue
+		}
+
+		if kind, _ := msg[0].([]byte); string(kind) == "message" {
+			p.reload(dataCh)
+		}
+	}
+}
+
+func (p *Redis) reload(dataCh chan<- Data) {
+	configs, err := p.load()
+	if err != nil {
+		log.Printf("Failed reloading %s from Redis; error: %s", p.Key, err)
+		p.failed(err)
+		return
+	}
+	p.loaded()
+
+	select {
+	case dataCh <- Data{Type: RedisType, Configs: configs}:
+	case <-p.closed:
+	}
+}
+
+func (p *Redis) dial() (*redisConn, error) {
+	c, err := net.DialTimeout("tcp", p.Address, time.Second*10)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{
+		Conn: c,
+		r:    bufio.NewReader(c),
+	}
+
+	if p.Password != "" {
+		if _, err := conn.do("AUTH", p.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if p.DB != 0 {
+		if _, err := conn.do("SELECT", strconv.Itoa(p.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// redisConn is a minimal client of the Redis serialization protocol (RESP)
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+func (c *redisConn) send(args ...string) error {
+	cmd := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		cmd += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := io.WriteString(c.Conn, cmd)
+	return err
+}
+
+// receive reads a reply. Bulk strings are returned as []byte, arrays as []interface{}
+// and integers as int64. Redis errors are returned as error.
+func (c *redisConn) receive() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("invalid redis reply")
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, errors.New(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		bb := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, bb); err != nil {
+			return nil, err
+		}
+		return bb[:n], nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = c.receive(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("unknown redis reply type %q", kind)
+}
@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers HGETALL with the given fields and publishes
+// a single message to every subscriber
+func fakeRedis(t *testing.T, fields ...string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(c net.Conn) {
+				defer c.Close()
+				conn := &redisConn{Conn: c, r: bufio.NewReader(c)}
+				for {
+					reply, err := conn.receive()
+					if err != nil {
+						return
+					}
+					args := reply.([]interface{})
+					switch strings.ToUpper(string(args[0].([]byte))) {
+					case "HGETALL":
+						fmt.Fprintf(c, "*%d\r\n", len(fields))
+						for _, field := range fields {
+							fmt.Fprintf(c, "$%d\r\n%s\r\n", len(field), field)
+						}
+					case "SUBSCRIBE":
+						fmt.Fprintf(c, "*3\r\n$9\r\nsubscribe\r\n$8\r\ninfrared\r\n:1\r\n")
+						fmt.Fprintf(c, "*3\r\n$7\r\nmessage\r\n$8\r\ninfrared\r\n$6\r\nreload\r\n")
+					default:
+						fmt.Fprintf(c, "-ERR unknown command\r\n")
+					}
+				}
+			}(c)
+		}
+	}()
+
+	return l
+}
+
+func TestRedis_Provide(t *testing.T) {
+	l := fakeRedis(t, "lobby", `{"domainName":"lobby.example.com"}`)
+	defer l.Close()
+
+	p := NewRedis(l.Addr().String(), "", 0, "infrared:proxies", "infrared")
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	data, err := p.Provide(dataCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data.Configs["lobby"]) != `{"domainName":"lobby.example.com"}` {
+		t.Errorf("got configs %v", data.Configs)
+	}
+
+	select {
+	case data := <-dataCh:
+		if len(data.Configs) != 1 {
+			t.Errorf("got %d configs; want 1", len(data.Configs))
+		}
+	case <-time.After(time.Second):
+		t.Error("got no data after the reload message")
+	}
+}
+
+func TestRedis_ProvideError(t *testing.T) {
+	l := fakeRedis(t)
+	defer l.Close()
+
+	p := NewRedis(l.Addr().String(), "secret", 0, "infrared:proxies", "infrared")
+	defer p.Close()
+
+	if _, err := p.Provide(make(chan Data)); err == nil {
+		t.Fail()
+	}
+}
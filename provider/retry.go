@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RetryPolicy defines how often and how long to wait before something that failed is tried again.
+// The backoff doubles after every attempt up to MaxBackoff.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts; zero or less retries forever
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction of the backoff that is randomized, like 0.2 for ±20%
+	Jitter float64
+}
+
+// DefaultRetryPolicy tries five times over roughly fifteen seconds
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:       5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Second * 30,
+	Jitter:         0.2,
+}
+
+// Backoff returns how long to wait after the given failed attempt, starting at 1
+func (r RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := r.InitialBackoff
+	for i := 1; i < attempt && backoff < r.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+
+	if r.Jitter > 0 {
+		jitter := float64(backoff) * r.Jitter
+		backoff += time.Duration(jitter * (rand.Float64()*2 - 1))
+	}
+	return backoff
+}
+
+// Do calls fn until it succeeds, the attempts are used up or closed is closed.
+// Every failed attempt is logged with the name. It returns the last error.
+func (r RetryPolicy) Do(name string, closed <-chan bool, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if r.Attempts > 0 && attempt >= r.Attempts {
+			log.Printf("Failed %s; attempt: %d/%d, error: %s", name, attempt, r.Attempts, err)
+			return err
+		}
+
+		backoff := r.Backoff(attempt)
+		log.Printf("Failed %s; attempt: %d/%d, retrying in: %s, error: %s", name, attempt, r.Attempts, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-closed:
+			return err
+		}
+	}
+}
+
+// Retry retries providing the configs of a Provider that failed,
+// for example because its remote source is not reachable yet
+type Retry struct {
+	Provider
+	Policy RetryPolicy
+
+	closed chan bool
+	once   sync.Once
+}
+
+// NewRetry creates a new Retry provider that provides prov with the policy
+func NewRetry(prov Provider, policy RetryPolicy) *Retry {
+	return &Retry{
+		Provider: prov,
+		Policy:   policy,
+		closed:   make(chan bool),
+	}
+}
+
+func (p *Retry) Provide(dataCh chan<- Data) (Data, error) {
+	var data Data
+	name := "loading configs from " + string(p.Provider.Status().Type) + " provider"
+	err := p.Policy.Do(name, p.closed, func() error {
+		var err error
+		data, err = p.Provider.Provide(dataCh)
+		return err
+	})
+	return data, err
+}
+
+func (p *Retry) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+	return p.Provider.Close()
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+	}
+
+	tt := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: time.Second * 2},
+		{attempt: 3, want: time.Second * 4},
+		{attempt: 4, want: time.Second * 5},
+		{attempt: 100, want: time.Second * 5},
+	}
+
+	for _, tc := range tt {
+		if got := policy.Backoff(tc.attempt); got != tc.want {
+			t.Errorf("attempt %d: got backoff %s; want %s", tc.attempt, got, tc.want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(1); got < time.Second/2 || got > time.Second*3/2 {
+			t.Fatalf("got backoff %s outside of the jitter", got)
+		}
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	policy := RetryPolicy{
+		Attempts:       3,
+		InitialBackoff: time.Millisecond,
+	}
+
+	attempts := 0
+	err := policy.Do("testing", nil, func() error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("got error %s; want none", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("got %d attempts; want 2", attempts)
+	}
+
+	attempts = 0
+	err = policy.Do("testing", nil, func() error {
+		attempts++
+		return errors.New("permanent")
+	})
+	if err == nil {
+		t.Error("got no error after all attempts failed")
+	}
+
+	if attempts != 3 {
+		t.Errorf("got %d attempts; want 3", attempts)
+	}
+}
@@ -0,0 +1,315 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3 polls proxy configs from all objects under a prefix of an S3 compatible bucket,
+// like AWS S3, MinIO or Cloudflare R2. Buckets are addressed in path-style.
+// Objects are only downloaded again if their ETag changed.
+// The ID of a config is its object key without the prefix.
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Interval  time.Duration
+	Client    *http.Client
+
+	*status
+	objects map[string]s3Object
+	closed  chan bool
+	once    sync.Once
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	body         []byte
+}
+
+type s3ListBucketResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+// NewS3 creates a new S3 provider. Anonymous requests are sent if no access key is given.
+func NewS3(endpoint, region, bucket, prefix, accessKey, secretKey string, interval time.Duration) *S3 {
+	return &S3{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		Prefix:    prefix,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Interval:  interval,
+		Client:    pollClient,
+		status:    newStatus(S3Type),
+		closed:    make(chan bool),
+	}
+}
+
+func (p *S3) Provide(dataCh chan<- Data) (Data, error) {
+	if err := validateInterval(p.Interval); err != nil {
+		return Data{}, err
+	}
+
+	data, _, err := p.fetch()
+	if err != nil {
+		return Data{}, err
+	}
+	p.loaded()
+
+	go p.poll(dataCh)
+	return data, nil
+}
+
+func (p *S3) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+	return nil
+}
+
+func (p *S3) poll(dataCh chan<- Data) {
+	p.watching(true)
+	defer p.watching(false)
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			data, modified, err := p.fetch()
+			if err != nil {
+				log.Printf("Failed polling bucket %s; error: %s", p.Bucket, err)
+				p.failed(err)
+				continue
+			}
+			p.loaded()
+
+			if !modified {
+				continue
+			}
+
+			select {
+			case dataCh <- data:
+			case <-p.closed:
+				return
+			}
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+// fetch lists all objects and downloads the ones that changed since the last fetch.
+// It reports false if no object was added, changed or removed.
+func (p *S3) fetch() (Data, bool, error) {
+	listed, err := p.list()
+	if err != nil {
+		return Data{}, false, err
+	}
+
+	modified := false
+	objects := make(map[string]s3Object, len(listed))
+	for _, obj := range listed {
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+
+		old, ok := p.objects[obj.Key]
+		if ok && old.ETag == obj.ETag && old.LastModified == obj.LastModified {
+			objects[obj.Key] = old
+			continue
+		}
+
+		obj.body, err = p.get(obj.Key)
+		if err != nil {
+			return Data{}, false, err
+		}
+		objects[obj.Key] = obj
+		modified = true
+	}
+
+	if len(objects) != len(p.objects) {
+		modified = true
+	}
+	p.objects = objects
+
+	configs := make(map[string][]byte, len(objects))
+	for key, obj := range objects {
+		configs[strings.TrimPrefix(key, p.Prefix)] = obj.body
+	}
+
+	return Data{
+		Type:    S3Type,
+		Configs: configs,
+	}, modified, nil
+}
+
+func (p *S3) list() ([]s3Object, error) {
+	var objects []s3Object
+	continuationToken := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", p.Prefix)
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		bb, err := p.request("/"+p.Bucket, query)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListBucketResult
+		if err := xml.Unmarshal(bb, &result); err != nil {
+			return nil, err
+		}
+		objects = append(objects, result.Contents...)
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+func (p *S3) get(key string) ([]byte, error) {
+	return p.request("/"+p.Bucket+"/"+key, url.Values{})
+}
+
+func (p *S3) request(path string, query url.Values) ([]byte, error) {
+	rawQuery := s3CanonicalQuery(query)
+	req, err := http.NewRequest(http.MethodGet, p.Endpoint+s3EscapePath(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = rawQuery
+
+	if p.AccessKey != "" {
+		p.sign(req, time.Now().UTC())
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bb, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s; %s", resp.Status, bb)
+	}
+
+	return bb, nil
+}
+
+// sign adds an AWS Signature Version 4 to a request without body
+func (p *S3) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", s3EmptyPayloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": s3EmptyPayloadHash,
+		"x-amz-date":           amzDate,
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3EmptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + p.Region + "/s3/aws4_request"
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	key := s3HMAC([]byte("AWS4"+p.SecretKey), date)
+	key = s3HMAC(key, p.Region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+	signature := hex.EncodeToString(s3HMAC(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		p.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape URI encodes a string like AWS expects it for signatures
+func s3Escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			params = append(params, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(params, "&")
+}
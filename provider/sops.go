@@ -0,0 +1,482 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v2"
+)
+
+// AgeExtension is the extension of config files that are encrypted with age as a whole,
+// like "proxy.json.age"
+const AgeExtension = ".age"
+
+var (
+	ageIdentitiesMu sync.RWMutex
+	ageIdentities   []age.Identity
+)
+
+// AddAgeIdentities parses the age identities in r, like the content of an age key file,
+// and uses them to decrypt age- and SOPS-encrypted config files
+func AddAgeIdentities(r io.Reader) error {
+	identities, err := age.ParseIdentities(r)
+	if err != nil {
+		return err
+	}
+
+	ageIdentitiesMu.Lock()
+	ageIdentities = append(ageIdentities, identities...)
+	ageIdentitiesMu.Unlock()
+	return nil
+}
+
+func decryptAge(r io.Reader) ([]byte, error) {
+	ageIdentitiesMu.RLock()
+	identities := ageIdentities
+	ageIdentitiesMu.RUnlock()
+
+	if len(identities) == 0 {
+		return nil, errors.New("no age identities to decrypt with")
+	}
+
+	// The payload might be ASCII armored
+	buf := &bytes.Buffer{}
+	if _, err := io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(buf.Bytes()), []byte(armor.Header)) {
+		r = armor.NewReader(bytes.NewReader(bytes.TrimSpace(buf.Bytes())))
+	} else {
+		r = buf
+	}
+
+	plain, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(plain)
+}
+
+// sopsKey is the key of the SOPS metadata in encrypted files
+const sopsKey = "sops"
+
+// sopsItem is a key and its value of an object in a SOPS file. SOPS files
+// are kept in their order, because it is part of their message authentication code.
+type sopsItem struct {
+	Key   string
+	Value interface{}
+}
+
+type sopsMetadata struct {
+	Age []struct {
+		Enc string `json:"enc"`
+	} `json:"age"`
+	KeyGroups []struct {
+		Age []struct {
+			Enc string `json:"enc"`
+		} `json:"age"`
+	} `json:"key_groups"`
+	LastModified      string `json:"lastmodified"`
+	MAC               string `json:"mac"`
+	MACOnlyEncrypted  bool   `json:"mac_only_encrypted"`
+	UnencryptedSuffix string `json:"unencrypted_suffix"`
+	EncryptedSuffix   string `json:"encrypted_suffix"`
+	UnencryptedRegex  string `json:"unencrypted_regex"`
+	EncryptedRegex    string `json:"encrypted_regex"`
+}
+
+// decryptSOPS decrypts a JSON or YAML config file that was encrypted by SOPS with age
+// and returns it as JSON. ok is false if the file is not encrypted by SOPS.
+func decryptSOPS(bb []byte, ext string) (cfg []byte, ok bool, err error) {
+	var items []sopsItem
+	switch strings.ToLower(ext) {
+	case ".json", ".jsonc":
+		items, err = orderedJSON(stripJSONComments(bb))
+	case ".yml", ".yaml":
+		items, err = orderedYAML(bb)
+	default:
+		return nil, false, nil
+	}
+	// Invalid files are reported when they are parsed
+	if err != nil {
+		return nil, false, nil
+	}
+
+	var rawMetadata interface{}
+	for i, item := range items {
+		if item.Key == sopsKey {
+			rawMetadata = item.Value
+			items = append(items[:i:i], items[i+1:]...)
+			break
+		}
+	}
+	if rawMetadata == nil {
+		return nil, false, nil
+	}
+
+	var metadata sopsMetadata
+	metadataJSON, err := json.Marshal(plainValue(rawMetadata))
+	if err != nil {
+		return nil, true, err
+	}
+	if err := json.Unmarshal(metadataJSON, &metadata); err != nil || metadata.MAC == "" {
+		return nil, false, nil
+	}
+
+	dataKey, err := metadata.dataKey()
+	if err != nil {
+		return nil, true, err
+	}
+
+	d := sopsDecrypter{
+		metadata: metadata,
+		dataKey:  dataKey,
+		hash:     sha512.New(),
+	}
+	plain, err := d.decryptItems(items, nil)
+	if err != nil {
+		return nil, true, err
+	}
+
+	mac, err := decryptSOPSValue(metadata.MAC, dataKey, metadata.LastModified)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to decrypt SOPS MAC; %s", err)
+	}
+	if mac != fmt.Sprintf("%X", d.hash.Sum(nil)) {
+		return nil, true, errors.New("SOPS MAC mismatch; the file was modified")
+	}
+
+	cfg, err = json.Marshal(plainValue(plain))
+	return cfg, true, err
+}
+
+func (m sopsMetadata) dataKey() ([]byte, error) {
+	encs := make([]string, 0, len(m.Age))
+	for _, key := range m.Age {
+		encs = append(encs, key.Enc)
+	}
+	for _, group := range m.KeyGroups {
+		for _, key := range group.Age {
+			encs = append(encs, key.Enc)
+		}
+	}
+
+	if len(encs) == 0 {
+		return nil, errors.New("SOPS file has no age recipients")
+	}
+
+	var lastErr error
+	for _, enc := range encs {
+		key, err := decryptAge(strings.NewReader(enc))
+		if err == nil {
+			return key, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("failed to decrypt SOPS data key; %s", lastErr)
+}
+
+type sopsDecrypter struct {
+	metadata sopsMetadata
+	dataKey  []byte
+	hash     hash.Hash
+}
+
+func (d sopsDecrypter) decryptItems(items []sopsItem, path []string) ([]sopsItem, error) {
+	plain := make([]sopsItem, len(items))
+	for i, item := range items {
+		value, err := d.decryptValue(item.Value, append(path[:len(path):len(path)], item.Key))
+		if err != nil {
+			return nil, err
+		}
+		// The suffixes only control the encryption and are no fields of the config
+		key := strings.TrimSuffix(item.Key, d.suffix())
+		plain[i] = sopsItem{Key: key, Value: value}
+	}
+	return plain, nil
+}
+
+func (d sopsDecrypter) decryptValue(value interface{}, path []string) (interface{}, error) {
+	switch v := value.(type) {
+	case []sopsItem:
+		return d.decryptItems(v, path)
+	case []interface{}:
+		plain := make([]interface{}, len(v))
+		for i, element := range v {
+			value, err := d.decryptValue(element, path)
+			if err != nil {
+				return nil, err
+			}
+			plain[i] = value
+		}
+		return plain, nil
+	case nil:
+		return nil, nil
+	}
+
+	encrypted := d.encrypted(path)
+	if encrypted {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of %s is not encrypted", strings.Join(path, "."))
+		}
+
+		var err error
+		value, err = decryptSOPSTypedValue(s, d.dataKey, strings.Join(path, ":")+":")
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s; %s", strings.Join(path, "."), err)
+		}
+	}
+
+	if encrypted || !d.metadata.MACOnlyEncrypted {
+		d.hash.Write(sopsBytes(value))
+	}
+	return value, nil
+}
+
+func (d sopsDecrypter) encrypted(path []string) bool {
+	m := d.metadata
+	switch {
+	case m.EncryptedSuffix != "":
+		return anyPathKey(path, func(key string) bool { return strings.HasSuffix(key, m.EncryptedSuffix) })
+	case m.EncryptedRegex != "":
+		return anyPathKey(path, func(key string) bool { ok, _ := regexp.MatchString(m.EncryptedRegex, key); return ok })
+	case m.UnencryptedRegex != "":
+		return !anyPathKey(path, func(key string) bool { ok, _ := regexp.MatchString(m.UnencryptedRegex, key); return ok })
+	}
+
+	return !anyPathKey(path, func(key string) bool { return strings.HasSuffix(key, d.suffix()) })
+}
+
+// suffix returns the suffix of the keys that are encrypted or unencrypted, if the file uses one
+func (d sopsDecrypter) suffix() string {
+	m := d.metadata
+	switch {
+	case m.EncryptedSuffix != "":
+		return m.EncryptedSuffix
+	case m.EncryptedRegex != "" || m.UnencryptedRegex != "":
+		return ""
+	case m.UnencryptedSuffix != "":
+		return m.UnencryptedSuffix
+	default:
+		return "_unencrypted"
+	}
+}
+
+func anyPathKey(path []string, match func(key string) bool) bool {
+	for _, key := range path {
+		if match(key) {
+			return true
+		}
+	}
+	return false
+}
+
+var sopsValuePattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]`)
+
+func decryptSOPSValue(value string, key []byte, additionalData string) (string, error) {
+	plain, err := decryptSOPSTypedValue(value, key, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return string(sopsBytes(plain)), nil
+}
+
+func decryptSOPSTypedValue(value string, key []byte, additionalData string) (interface{}, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	matches := sopsValuePattern.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, errors.New("invalid SOPS value")
+	}
+
+	var parts [3][]byte
+	for i := range parts {
+		part, err := base64.StdEncoding.DecodeString(matches[i+1])
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = part
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, err
+	}
+
+	switch matches[4] {
+	case "str":
+		return string(plain), nil
+	case "int":
+		return strconv.Atoi(string(plain))
+	case "float":
+		return strconv.ParseFloat(string(plain), 64)
+	case "bool":
+		return strconv.ParseBool(string(plain))
+	case "bytes":
+		return string(plain), nil
+	default:
+		return nil, fmt.Errorf("unsupported SOPS type %s", matches[4])
+	}
+}
+
+// sopsBytes converts a value to the bytes that SOPS hashes for its MAC
+func sopsBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return []byte(v)
+	case int:
+		return []byte(strconv.Itoa(v))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		if v {
+			return []byte("True")
+		}
+		return []byte("False")
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// plainValue converts ordered objects to maps, so that they can be marshalled
+func plainValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []sopsItem:
+		obj := make(map[string]interface{}, len(v))
+		for _, item := range v {
+			obj[item.Key] = plainValue(item.Value)
+		}
+		return obj
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			values[i] = plainValue(element)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+func orderedJSON(bb []byte) ([]sopsItem, error) {
+	dec := json.NewDecoder(bytes.NewReader(bb))
+	value, err := orderedJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := value.([]sopsItem)
+	if !ok {
+		return nil, errors.New("config is no JSON object")
+	}
+	return items, nil
+}
+
+func orderedJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		items := []sopsItem{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := orderedJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, sopsItem{Key: fmt.Sprint(key), Value: value})
+		}
+		_, err := dec.Token()
+		return items, err
+	case json.Delim('['):
+		values := []interface{}{}
+		for dec.More() {
+			value, err := orderedJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		_, err := dec.Token()
+		return values, err
+	default:
+		return tok, nil
+	}
+}
+
+func orderedYAML(bb []byte) ([]sopsItem, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(bb, &doc); err != nil {
+		return nil, err
+	}
+
+	value, err := orderedYAMLValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	return value.([]sopsItem), nil
+}
+
+func orderedYAMLValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		items := make([]sopsItem, len(v))
+		for i, item := range v {
+			key, ok := item.Key.(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported YAML key %v", item.Key)
+			}
+			value, err := orderedYAMLValue(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = sopsItem{Key: key, Value: value}
+		}
+		return items, nil
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			value, err := orderedYAMLValue(element)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	default:
+		return v, nil
+	}
+}
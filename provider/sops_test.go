@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The files in testdata were encrypted with sops and age for the key in testdata/age-key.txt
+func addTestAgeIdentities(t *testing.T) {
+	key, err := os.Open(filepath.Join("testdata", "age-key.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Close()
+
+	ageIdentitiesMu.Lock()
+	ageIdentities = nil
+	ageIdentitiesMu.Unlock()
+	if err := AddAgeIdentities(key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadConfigFile_Encrypted(t *testing.T) {
+	addTestAgeIdentities(t)
+
+	tt := []struct {
+		file string
+		want string
+	}{
+		{
+			file: "proxy.sops.json",
+			want: `{"callbackServer":{"events":["PlayerJoin","PlayerLeave"],"url":"https://hooks.example.com/secret"},"domainName":"mc.example.com","offlineStatus":{"maxPlayers":20,"motd":"Offline"},"proxyTo":":25566","realIp":true,"timeout":1000}`,
+		},
+		{
+			file: "proxy.sops.yml",
+			want: `{"callbackServer":{"events":["PlayerJoin"],"url":"https://hooks.example.com/secret"},"domainName":"mc.example.com","timeout":1000}`,
+		},
+	}
+
+	for _, tc := range tt {
+		got, err := ReadConfigFile(filepath.Join("testdata", tc.file))
+		if err != nil {
+			t.Errorf("%s: %s", tc.file, err)
+			continue
+		}
+
+		if string(got) != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.file, got, tc.want)
+		}
+	}
+
+	got, err := ReadConfigFile(filepath.Join("testdata", "proxy.json.age"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(got, []byte(`"url": "https://hooks.example.com/secret"`)) {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestReadConfigFile_SOPSModified(t *testing.T) {
+	addTestAgeIdentities(t)
+
+	bb, err := ioutil.ReadFile(filepath.Join("testdata", "proxy.sops.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "proxy.json")
+	modified := strings.Replace(string(bb), `"motd_unencrypted": "Offline"`, `"motd_unencrypted": "Online"`, 1)
+	if err := ioutil.WriteFile(path, []byte(modified), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadConfigFile(path); err == nil || !strings.Contains(err.Error(), "MAC") {
+		t.Errorf("got %v; want MAC mismatch", err)
+	}
+}
+
+func TestReadConfigFile_EncryptedWithoutIdentity(t *testing.T) {
+	ageIdentitiesMu.Lock()
+	ageIdentities = nil
+	ageIdentitiesMu.Unlock()
+
+	if _, err := ReadConfigFile(filepath.Join("testdata", "proxy.sops.json")); err == nil {
+		t.Error("got no error without age identities")
+	}
+}
@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var sqlIdentifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// SQL reads proxy configs from the rows of a database table with an "id" and a "config" column.
+// The config column holds the JSON of a proxy config and the id column is the ID of the config.
+// The table is polled in an interval. For Postgres, a NOTIFY on the notify channel triggers
+// an immediate reload as well.
+//
+// The driver of the database has to be registered, like "postgres" or "mysql".
+type SQL struct {
+	Driver        string
+	DSN           string
+	Table         string
+	Interval      time.Duration
+	NotifyChannel string
+
+	*status
+	db     *sql.DB
+	last   map[string][]byte
+	closed chan bool
+	once   sync.Once
+}
+
+// NewSQL creates a new SQL provider
+func NewSQL(driver, dsn, table string, interval time.Duration, notifyChannel string) *SQL {
+	return &SQL{
+		Driver:        driver,
+		DSN:           dsn,
+		Table:         table,
+		Interval:      interval,
+		NotifyChannel: notifyChannel,
+		status:        newStatus(SQLType),
+		closed:        make(chan bool),
+	}
+}
+
+func (p *SQL) Provide(dataCh chan<- Data) (Data, error) {
+	if err := validateInterval(p.Interval); err != nil {
+		return Data{}, err
+	}
+	if !sqlIdentifierRegex.MatchString(p.Table) {
+		return Data{}, fmt.Errorf("invalid table name %q", p.Table)
+	}
+
+	db, err := sql.Open(p.Driver, p.DSN)
+	if err != nil {
+		return Data{}, err
+	}
+	p.db = db
+
+	configs, err := p.load()
+	if err != nil {
+		db.Close()
+		return Data{}, err
+	}
+	p.last = configs
+	p.loaded()
+
+	go p.poll(dataCh)
+	return Data{
+		Type:    SQLType,
+		Configs: configs,
+	}, nil
+}
+
+func (p *SQL) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+
+	if p.db == nil {
+		return nil
+	}
+	return p.db.Close()
+}
+
+func (p *SQL) poll(dataCh chan<- Data) {
+	p.watching(true)
+	defer p.watching(false)
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	var notifications <-chan *pq.Notification
+	if p.Driver == "postgres" && p.NotifyChannel != "" {
+		listener := pq.NewListener(p.DSN, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Println("Failed listening to Postgres notifications; error:", err)
+				p.failed(err)
+			}
+		})
+		defer listener.Close()
+
+		if err := listener.Listen(p.NotifyChannel); err != nil {
+			log.Printf("Failed listening to %s; error: %s", p.NotifyChannel, err)
+			p.failed(err)
+		} else {
+			notifications = listener.Notify
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-notifications:
+		case <-p.closed:
+			return
+		}
+
+		configs, err := p.load()
+		if err != nil {
+			log.Printf("Failed polling table %s; error: %s", p.Table, err)
+			p.failed(err)
+			continue
+		}
+		p.loaded()
+
+		if reflect.DeepEqual(configs, p.last) {
+			continue
+		}
+		p.last = configs
+
+		select {
+		case dataCh <- Data{Type: SQLType, Configs: configs}:
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+func (p *SQL) load() (map[string][]byte, error) {
+	rows, err := p.db.Query(fmt.Sprintf("SELECT id, config FROM %s", p.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	configs := map[string][]byte{}
+	for rows.Next() {
+		var id string
+		var cfg []byte
+		if err := rows.Scan(&id, &cfg); err != nil {
+			return nil, err
+		}
+		configs[id] = cfg
+	}
+
+	return configs, rows.Err()
+}
@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+// Status reports the health of a Provider, so that a node that silently
+// stopped receiving config updates can be detected
+type Status struct {
+	Type Type `json:"type"`
+	// LastLoad is the time of the last successful load of the configs
+	LastLoad time.Time `json:"lastLoad"`
+	// LastError is the last error that occurred while loading or watching
+	LastError     string    `json:"lastError,omitempty"`
+	LastErrorTime time.Time `json:"lastErrorTime"`
+	// Watching reports whether the Provider is still watching for changes
+	Watching bool `json:"watching"`
+}
+
+// status records the Status of a Provider and is embedded by every Provider
+type status struct {
+	mu     sync.Mutex
+	status Status
+}
+
+func newStatus(typ Type) *status {
+	return &status{status: Status{Type: typ}}
+}
+
+// Status returns the current Status of the Provider
+func (s *status) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *status) loaded() {
+	s.mu.Lock()
+	s.status.LastLoad = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *status) failed(err error) {
+	s.mu.Lock()
+	s.status.LastError = err.Error()
+	s.status.LastErrorTime = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *status) watching(watching bool) {
+	s.mu.Lock()
+	s.status.Watching = watching
+	s.mu.Unlock()
+}
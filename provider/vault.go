@@ -0,0 +1,353 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultReferencePrefix marks a string value in a config as a reference to a Vault secret.
+// A reference has the form "vault:<path>#<key>", like "vault:secret/data/infrared#callbackUrl".
+const VaultReferencePrefix = "vault:"
+
+// Vault resolves references to HashiCorp Vault secrets in the configs of another Provider.
+// Secrets are renewed or read again when their lease runs out and if a secret
+// changed, the configs are resolved again and sent as new Data.
+// Both KV version 1 and 2 secret engines are supported.
+type Vault struct {
+	Provider Provider
+	Address  string
+	Token    string
+	// RefreshInterval is the interval in which secrets without a lease are read again
+	RefreshInterval time.Duration
+	Client          *http.Client
+
+	*status
+	mu      sync.Mutex
+	innerCh chan Data
+	raw     Data
+	secrets map[string]*vaultSecret
+	closed  chan bool
+	once    sync.Once
+}
+
+type vaultSecret struct {
+	data      map[string]interface{}
+	leaseID   string
+	renewable bool
+	refreshAt time.Time
+}
+
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Errors        []string               `json:"errors"`
+}
+
+// NewVault creates a new Vault provider that resolves the references in the configs of prov
+func NewVault(prov Provider, address, token string) *Vault {
+	return &Vault{
+		Provider:        prov,
+		Address:         strings.TrimSuffix(address, "/"),
+		Token:           token,
+		RefreshInterval: time.Minute * 5,
+		Client:          pollClient,
+		status:          newStatus(prov.Status().Type),
+		secrets:         map[string]*vaultSecret{},
+		closed:          make(chan bool),
+	}
+}
+
+// Provide provides the wrapped Provider only once. If resolving the secrets failed,
+// calling Provide again only resolves the secrets again.
+func (p *Vault) Provide(dataCh chan<- Data) (Data, error) {
+	if err := validateInterval(p.RefreshInterval); err != nil {
+		return Data{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.innerCh == nil {
+		innerCh := make(chan Data)
+		data, err := p.Provider.Provide(innerCh)
+		if err != nil {
+			return Data{}, err
+		}
+		p.innerCh = innerCh
+		p.raw = data
+	}
+
+	resolved, err := p.resolve(p.raw)
+	if err != nil {
+		return Data{}, err
+	}
+
+	go p.run(p.innerCh, dataCh)
+	return resolved, nil
+}
+
+// Status returns the Status of the wrapped Provider including errors of resolving secrets
+func (p *Vault) Status() Status {
+	status := p.Provider.Status()
+	own := p.status.Status()
+	if own.LastErrorTime.After(status.LastErrorTime) {
+		status.LastError = own.LastError
+		status.LastErrorTime = own.LastErrorTime
+	}
+	return status
+}
+
+func (p *Vault) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+	return p.Provider.Close()
+}
+
+func (p *Vault) run(innerCh <-chan Data, dataCh chan<- Data) {
+	interval := time.Second
+	if p.RefreshInterval < interval {
+		interval = p.RefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var resolved Data
+		var err error
+
+		select {
+		case data := <-innerCh:
+			p.mu.Lock()
+			p.raw = data
+			resolved, err = p.resolve(data)
+			p.mu.Unlock()
+		case <-ticker.C:
+			p.mu.Lock()
+			if !p.refreshSecrets() {
+				p.mu.Unlock()
+				continue
+			}
+			log.Println("Vault secrets changed; resolving configs again")
+			resolved, err = p.resolve(p.raw)
+			p.mu.Unlock()
+		case <-p.closed:
+			return
+		}
+
+		if err != nil {
+			log.Println("Failed resolving Vault secrets; error:", err)
+			p.failed(err)
+			continue
+		}
+
+		select {
+		case dataCh <- resolved:
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+// refreshSecrets renews or reads all secrets again whose lease runs out.
+// It reports true if any secret changed.
+func (p *Vault) refreshSecrets() bool {
+	changed := false
+	now := time.Now()
+
+	for path, secret := range p.secrets {
+		if now.Before(secret.refreshAt) {
+			continue
+		}
+
+		if secret.renewable && secret.leaseID != "" {
+			err := p.renew(secret)
+			if err == nil {
+				continue
+			}
+			log.Printf("Failed renewing lease of %s; error: %s", path, err)
+			p.failed(err)
+		}
+
+		newSecret, err := p.read(path)
+		if err != nil {
+			log.Printf("Failed reading Vault secret %s; error: %s", path, err)
+			p.failed(err)
+			secret.refreshAt = now.Add(p.RefreshInterval)
+			continue
+		}
+
+		if !reflect.DeepEqual(secret.data, newSecret.data) {
+			changed = true
+		}
+		p.secrets[path] = newSecret
+	}
+
+	return changed
+}
+
+func (p *Vault) resolve(data Data) (Data, error) {
+	configs := make(map[string][]byte, len(data.Configs))
+	for id, cfg := range data.Configs {
+		resolved, err := p.resolveConfig(cfg)
+		if err != nil {
+			return Data{}, fmt.Errorf("%s; %s", id, err)
+		}
+		configs[id] = resolved
+	}
+
+	return Data{
+		Type:    data.Type,
+		Configs: configs,
+	}, nil
+}
+
+func (p *Vault) resolveConfig(cfg []byte) ([]byte, error) {
+	if !bytes.Contains(cfg, []byte(VaultReferencePrefix)) {
+		return cfg, nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(cfg, &v); err != nil {
+		// Invalid configs are reported when they are loaded
+		return cfg, nil
+	}
+
+	v, err := p.resolveValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+func (p *Vault) resolveValue(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, field := range value {
+			resolved, err := p.resolveValue(field)
+			if err != nil {
+				return nil, err
+			}
+			value[k] = resolved
+		}
+	case []interface{}:
+		for i, element := range value {
+			resolved, err := p.resolveValue(element)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = resolved
+		}
+	case string:
+		if strings.HasPrefix(value, VaultReferencePrefix) {
+			return p.secretValue(strings.TrimPrefix(value, VaultReferencePrefix))
+		}
+	}
+	return v, nil
+}
+
+func (p *Vault) secretValue(reference string) (interface{}, error) {
+	parts := strings.SplitN(reference, "#", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("vault reference %q has no key", reference)
+	}
+	path, key := parts[0], parts[1]
+
+	secret, ok := p.secrets[path]
+	if !ok {
+		var err error
+		secret, err = p.read(path)
+		if err != nil {
+			return nil, err
+		}
+		p.secrets[path] = secret
+	}
+
+	value, ok := secret.data[key]
+	if !ok {
+		return nil, fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+	return value, nil
+}
+
+func (p *Vault) read(path string) (*vaultSecret, error) {
+	var resp vaultResponse
+	if err := p.request(http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), nil, &resp); err != nil {
+		return nil, fmt.Errorf("reading %s failed; %s", path, err)
+	}
+
+	data := resp.Data
+	// KV version 2 wraps the secret in another data object
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+
+	return &vaultSecret{
+		data:      data,
+		leaseID:   resp.LeaseID,
+		renewable: resp.Renewable,
+		refreshAt: p.refreshAt(resp.LeaseDuration),
+	}, nil
+}
+
+func (p *Vault) renew(secret *vaultSecret) error {
+	var resp vaultResponse
+	body := map[string]string{"lease_id": secret.leaseID}
+	if err := p.request(http.MethodPut, "/v1/sys/leases/renew", body, &resp); err != nil {
+		return err
+	}
+
+	secret.refreshAt = p.refreshAt(resp.LeaseDuration)
+	return nil
+}
+
+// refreshAt returns when a secret with the given lease duration in seconds should be refreshed
+func (p *Vault) refreshAt(leaseDuration int) time.Time {
+	if leaseDuration <= 0 {
+		return time.Now().Add(p.RefreshInterval)
+	}
+	// Refresh after half of the lease, so that there is time left for retries
+	return time.Now().Add(time.Duration(leaseDuration) * time.Second / 2)
+}
+
+func (p *Vault) request(method, path string, body interface{}, v interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, p.Address+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil && resp.StatusCode == http.StatusOK {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticProvider struct {
+	data Data
+}
+
+func (p staticProvider) Provide(dataCh chan<- Data) (Data, error) {
+	return p.data, nil
+}
+
+func (p staticProvider) Status() Status {
+	return Status{Type: p.data.Type}
+}
+
+func (p staticProvider) Close() error {
+	return nil
+}
+
+func TestVault_Provide(t *testing.T) {
+	url := "https://example.com/v1"
+	mu := sync.Mutex{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		if r.URL.Path != "/v1/secret/data/infrared" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"callbackUrl": url},
+				"metadata": map[string]interface{}{"version": 1},
+			},
+		})
+	}))
+	defer server.Close()
+
+	inner := staticProvider{data: Data{
+		Type: FileType,
+		Configs: map[string][]byte{
+			"lobby": []byte(`{"domainName":"lobby.example.com","callbackServer":{"url":"vault:secret/data/infrared#callbackUrl"}}`),
+			"plain": []byte(`{"domainName":"plain.example.com"}`),
+		},
+	}}
+
+	p := NewVault(inner, server.URL, "token")
+	p.RefreshInterval = time.Millisecond * 10
+	defer p.Close()
+
+	dataCh := make(chan Data)
+	data, err := p.Provide(dataCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Type != FileType {
+		t.Errorf("got type %s; want %s", data.Type, FileType)
+	}
+
+	want := `{"callbackServer":{"url":"https://example.com/v1"},"domainName":"lobby.example.com"}`
+	if string(data.Configs["lobby"]) != want {
+		t.Errorf("got config %s; want %s", data.Configs["lobby"], want)
+	}
+
+	if string(data.Configs["plain"]) != `{"domainName":"plain.example.com"}` {
+		t.Errorf("got config %s", data.Configs["plain"])
+	}
+
+	mu.Lock()
+	url = "https://example.com/v2"
+	mu.Unlock()
+
+	select {
+	case data := <-dataCh:
+		want := `{"callbackServer":{"url":"https://example.com/v2"},"domainName":"lobby.example.com"}`
+		if string(data.Configs["lobby"]) != want {
+			t.Errorf("got config %s; want %s", data.Configs["lobby"], want)
+		}
+	case <-time.After(time.Second):
+		t.Error("got no data after the secret changed")
+	}
+}
+
+func TestVault_ProvideMissingKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"password":"secret"}}`))
+	}))
+	defer server.Close()
+
+	inner := staticProvider{data: Data{
+		Type: FileType,
+		Configs: map[string][]byte{
+			"lobby": []byte(`{"callbackServer":{"url":"vault:secret/infrared#callbackUrl"}}`),
+		},
+	}}
+
+	p := NewVault(inner, server.URL, "token")
+	defer p.Close()
+
+	if _, err := p.Provide(make(chan Data)); err == nil {
+		t.Error("got no error for a missing key")
+	}
+}
+
+func TestVault_ProvideInvalidRefreshInterval(t *testing.T) {
+	p := NewVault(staticProvider{data: Data{Type: FileType}}, "http://127.0.0.1:1", "token")
+	p.RefreshInterval = 0
+	defer p.Close()
+
+	if _, err := p.Provide(make(chan Data)); err == nil || !strings.Contains(err.Error(), "not positive") {
+		t.Errorf("got error %v; want a refresh interval of 0 to be rejected", err)
+	}
+}
@@ -0,0 +1,217 @@
+package provider
+
+import (
+	"log"
+	"path"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// ZooKeeper reads proxy configs from the children of a znode. The data of every child
+// is the JSON of a proxy config and the name of the child is the ID of the config.
+// Changes are picked up live through znode watches.
+type ZooKeeper struct {
+	Servers        []string
+	Path           string
+	SessionTimeout time.Duration
+
+	*status
+	conn            *zk.Conn
+	events          chan zk.Event
+	watchedChildren bool
+	watchedNodes    map[string]bool
+	last            map[string][]byte
+	closed          chan bool
+	once            sync.Once
+}
+
+// NewZooKeeper creates a new ZooKeeper provider for the children of the znode at path
+func NewZooKeeper(servers []string, path string) *ZooKeeper {
+	return &ZooKeeper{
+		Servers:        servers,
+		Path:           path,
+		SessionTimeout: time.Second * 10,
+		events:         make(chan zk.Event),
+		watchedNodes:   map[string]bool{},
+		status:         newStatus(ZooKeeperType),
+		closed:         make(chan bool),
+	}
+}
+
+func (p *ZooKeeper) Provide(dataCh chan<- Data) (Data, error) {
+	conn, sessionEvents, err := zk.Connect(p.Servers, p.SessionTimeout, zk.WithLogInfo(false))
+	if err != nil {
+		return Data{}, err
+	}
+	p.conn = conn
+
+	if err := p.awaitSession(sessionEvents); err != nil {
+		conn.Close()
+		return Data{}, err
+	}
+
+	configs, err := p.load()
+	if err != nil {
+		conn.Close()
+		return Data{}, err
+	}
+	p.last = configs
+	p.loaded()
+
+	go p.watch(dataCh)
+	return Data{
+		Type:    ZooKeeperType,
+		Configs: configs,
+	}, nil
+}
+
+func (p *ZooKeeper) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+		if p.conn != nil {
+			p.conn.Close()
+		}
+	})
+	return nil
+}
+
+func (p *ZooKeeper) awaitSession(sessionEvents <-chan zk.Event) error {
+	timeout := time.After(p.SessionTimeout)
+	for {
+		select {
+		case event := <-sessionEvents:
+			if event.State == zk.StateHasSession {
+				return nil
+			}
+			if event.State == zk.StateAuthFailed {
+				return zk.ErrAuthFailed
+			}
+		case <-timeout:
+			return zk.ErrNoServer
+		}
+	}
+}
+
+func (p *ZooKeeper) watch(dataCh chan<- Data) {
+	p.watching(true)
+	defer p.watching(false)
+
+	for {
+		select {
+		case event := <-p.events:
+			switch event.Type {
+			case zk.EventNotWatching:
+				// The session expired and all watches are gone
+				p.watchedChildren = false
+				p.watchedNodes = map[string]bool{}
+			case zk.EventNodeChildrenChanged:
+				p.watchedChildren = false
+			default:
+				delete(p.watchedNodes, event.Path)
+			}
+		case <-p.closed:
+			return
+		}
+
+		configs, err := p.load()
+		if err != nil {
+			log.Printf("Failed loading znode %s; error: %s", p.Path, err)
+			p.failed(err)
+			// Retry once the connection is back
+			p.watchedChildren = false
+			p.watchedNodes = map[string]bool{}
+			select {
+			case <-time.After(time.Second):
+				p.forward(zk.Event{Type: zk.EventNotWatching})
+				continue
+			case <-p.closed:
+				return
+			}
+		}
+		p.loaded()
+
+		if reflect.DeepEqual(configs, p.last) {
+			continue
+		}
+		p.last = configs
+
+		select {
+		case dataCh <- Data{Type: ZooKeeperType, Configs: configs}:
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+// load reads the data of all children and sets a watch on every znode that is not watched yet
+func (p *ZooKeeper) load() (map[string][]byte, error) {
+	var children []string
+	if p.watchedChildren {
+		var err error
+		children, _, err = p.conn.Children(p.Path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var ch <-chan zk.Event
+		var err error
+		children, _, ch, err = p.conn.ChildrenW(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		p.watchedChildren = true
+		go p.forwardWatch(ch)
+	}
+
+	configs := map[string][]byte{}
+	for _, child := range children {
+		nodePath := path.Join(p.Path, child)
+
+		var bb []byte
+		var err error
+		if p.watchedNodes[nodePath] {
+			bb, _, err = p.conn.Get(nodePath)
+		} else {
+			var ch <-chan zk.Event
+			bb, _, ch, err = p.conn.GetW(nodePath)
+			if err == nil {
+				p.watchedNodes[nodePath] = true
+				go p.forwardWatch(ch)
+			}
+		}
+
+		if err == zk.ErrNoNode {
+			// The child has been removed in the meantime
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(bb) == 0 {
+			continue
+		}
+		configs[child] = bb
+	}
+
+	return configs, nil
+}
+
+func (p *ZooKeeper) forwardWatch(ch <-chan zk.Event) {
+	select {
+	case event := <-ch:
+		p.forward(event)
+	case <-p.closed:
+	}
+}
+
+func (p *ZooKeeper) forward(event zk.Event) {
+	go func() {
+		select {
+		case p.events <- event:
+		case <-p.closed:
+		}
+	}()
+}
@@ -1,11 +1,14 @@
 package infrared
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/haveachin/infrared/callback"
@@ -13,7 +16,6 @@ import (
 	"github.com/haveachin/infrared/protocol"
 	"github.com/haveachin/infrared/protocol/handshaking"
 	"github.com/haveachin/infrared/protocol/login"
-	"github.com/pires/go-proxyproto"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -25,6 +27,9 @@ var (
 	}, []string{"host"})
 )
 
+// catchAllDomain is the domain of a proxy that handles all domains that no other proxy handles
+const catchAllDomain = "*"
+
 func proxyUID(domain, addr string) string {
 	return fmt.Sprintf("%s@%s", strings.ToLower(domain), addr)
 }
@@ -34,7 +39,19 @@ type Proxy struct {
 
 	cancelTimeoutFunc func()
 	players           map[Conn]string
-	mu                sync.Mutex
+	balancer          loadBalancer
+	health            backendHealth
+	drain             *proxyDrain
+	limiter           requestLimiter
+	handshakeLimiter  connectionLimiter
+	rejoin            rejoinVerifier
+	// motdRotation counts the status responses of the proxy to rotate its MOTDs
+	motdRotation uint32
+	// events is the event bus of the gateway that the proxy is registered with
+	events *EventBus
+	// callbacks are the events that wait for their POST to the callback server
+	callbacks chan callback.Event
+	mu        sync.Mutex
 }
 
 func (proxy *Proxy) Process() process.Process {
@@ -91,6 +108,122 @@ func (proxy *Proxy) ProxyTo() string {
 	return proxy.Config.ProxyTo
 }
 
+func (proxy *Proxy) DomainRegexp() (*regexp.Regexp, error) {
+	proxy.Config.Lock()
+	defer proxy.Config.Unlock()
+	return proxy.Config.DomainRegexp()
+}
+
+// Priority returns the priority of the proxy over other proxies that match the same domain
+func (proxy *Proxy) Priority() int {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Priority
+}
+
+// Backends returns the addresses that the proxy balances its connections over
+func (proxy *Proxy) Backends() []string {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.BackendAddrs()
+}
+
+func (proxy *Proxy) BackendWeights() map[string]int {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.BackendWeights
+}
+
+func (proxy *Proxy) LoadBalancer() string {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.LoadBalancer
+}
+
+func (proxy *Proxy) SessionAffinity() SessionAffinityConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.SessionAffinity
+}
+
+// sessionKey returns the key of the player's session by the configured session affinity
+// or an empty string if the proxy has no session affinity
+func (proxy *Proxy) sessionKey(conn Conn, hs handshaking.ServerBoundHandshake, connRemoteAddr net.Addr) string {
+	switch proxy.SessionAffinity().Key {
+	case IPSessionKey:
+		host, _, err := net.SplitHostPort(connRemoteAddr.String())
+		if err != nil {
+			return connRemoteAddr.String()
+		}
+		return host
+	case UsernameSessionKey:
+		return strings.ToLower(peekUsername(conn, hs))
+	}
+	return ""
+}
+
+// peekUsername returns the username of a login request or an empty string for status requests.
+// The login start is peeked, so that it is still forwarded to the backend.
+func peekUsername(conn Conn, hs handshaking.ServerBoundHandshake) string {
+	if !hs.IsLoginRequest() {
+		return ""
+	}
+
+	pk, err := conn.PeekPacket()
+	if err != nil {
+		return ""
+	}
+	ls, err := login.UnmarshalServerBoundLoginStart(pk)
+	if err != nil {
+		return ""
+	}
+	return string(ls.Name)
+}
+
+func (proxy *Proxy) FailoverTo() string {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.FailoverTo
+}
+
+func (proxy *Proxy) HealthCheckInterval() time.Duration {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return time.Millisecond * time.Duration(proxy.Config.HealthCheck.Interval)
+}
+
+func (proxy *Proxy) HealthCheckTimeout() time.Duration {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return time.Millisecond * time.Duration(proxy.Config.HealthCheck.Timeout)
+}
+
+// expandProxyTo returns the backend address that connections to the domain are proxied to.
+// If the domain name of the proxy is a regular expression, the {{name}} and {{1}} placeholders
+// in the address are replaced with the named and numbered capture groups of the domain.
+func (proxy *Proxy) expandProxyTo(proxyTo, domain string) string {
+	re, err := proxy.DomainRegexp()
+	if err != nil || re == nil {
+		return proxyTo
+	}
+
+	match := re.FindStringSubmatch(strings.ToLower(domain))
+	if match == nil {
+		return proxyTo
+	}
+
+	for i, name := range re.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+		proxyTo = strings.Replace(proxyTo, fmt.Sprintf("{{%d}}", i), match[i], -1)
+		if name != "" {
+			proxyTo = strings.Replace(proxyTo, fmt.Sprintf("{{%s}}", name), match[i], -1)
+		}
+	}
+	return proxyTo
+}
+
 func (proxy *Proxy) Dialer() (*Dialer, error) {
 	proxy.Config.RLock()
 	defer proxy.Config.RUnlock()
@@ -103,6 +236,12 @@ func (proxy *Proxy) DisconnectMessage() string {
 	return proxy.Config.DisconnectMessage
 }
 
+func (proxy *Proxy) DisconnectComponent() json.RawMessage {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.DisconnectComponent
+}
+
 func (proxy *Proxy) IsOnlineStatusConfigured() bool {
 	proxy.Config.Lock()
 	defer proxy.Config.Unlock()
@@ -121,6 +260,18 @@ func (proxy *Proxy) OfflineStatusPacket() (protocol.Packet, error) {
 	return proxy.Config.OfflineStatus.StatusResponsePacket()
 }
 
+func (proxy *Proxy) Maintenance() bool {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Maintenance
+}
+
+func (proxy *Proxy) MaintenanceMessage() string {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.MaintenanceMessage
+}
+
 func (proxy *Proxy) Timeout() time.Duration {
 	proxy.Config.RLock()
 	defer proxy.Config.RUnlock()
@@ -139,6 +290,24 @@ func (proxy *Proxy) SpoofForcedHost() string {
 	return proxy.Config.SpoofForcedHost
 }
 
+func (proxy *Proxy) SpoofForcedPort() int {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.SpoofForcedPort
+}
+
+// spoofHandshake returns the handshake with the spoofed forced host and port of the proxy
+func (proxy *Proxy) spoofHandshake(hs handshaking.ServerBoundHandshake) handshaking.ServerBoundHandshake {
+	if spoofForcedHost := proxy.SpoofForcedHost(); spoofForcedHost != "" {
+		hs.ServerAddress = protocol.String(spoofForcedHost)
+	}
+
+	if spoofForcedPort := proxy.SpoofForcedPort(); spoofForcedPort != 0 {
+		hs.ServerPort = protocol.UnsignedShort(spoofForcedPort)
+	}
+	return hs
+}
+
 func (proxy *Proxy) ProxyProtocol() bool {
 	proxy.Config.RLock()
 	defer proxy.Config.RUnlock()
@@ -151,6 +320,20 @@ func (proxy *Proxy) RealIP() bool {
 	return proxy.Config.RealIP
 }
 
+// Access returns the access list of the Java clients of the proxy
+func (proxy *Proxy) Access() AccessListConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Access
+}
+
+// TextFormat returns the format of the MOTDs and disconnect messages of the proxy
+func (proxy *Proxy) TextFormat() string {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.TextFormat
+}
+
 func (proxy *Proxy) CallbackLogger() callback.Logger {
 	proxy.Config.RLock()
 	defer proxy.Config.RUnlock()
@@ -201,49 +384,160 @@ func (proxy *Proxy) handleConn(conn Conn, connRemoteAddr net.Addr) error {
 		return err
 	}
 
+	if !proxy.Access().allowsAddr(connRemoteAddr) {
+		log.Printf("[i] %s is denied by the access list of %s", connRemoteAddr, proxy.UID())
+		return nil
+	}
+
+	location := proxy.clientLocation(connRemoteAddr)
+	if !proxy.GeoIP().allows(location) {
+		log.Printf("[i] %s from country %q is denied by the GeoIP config of %s", connRemoteAddr, location.Country, proxy.UID())
+		return nil
+	}
+
+	if ok, _ := proxy.handshakeLimiter.allowConnection(connRemoteAddr, proxy.HandshakeLimits(), handshakeStage); !ok {
+		return nil
+	}
+
+	if !proxy.allowRequest(hs, connRemoteAddr) {
+		if hs.IsStatusRequest() {
+			log.Printf("[i] Rate limiting status requests of %s on %s", connRemoteAddr, proxy.UID())
+			return nil
+		}
+		log.Printf("[i] Rate limiting logins of %s on %s", connRemoteAddr, proxy.UID())
+		return proxy.handleLoginRequest(conn, proxy.RateLimits().Message)
+	}
+
+	if filter := proxy.UsernameFilter(); hs.IsLoginRequest() && filter.enabled() {
+		if username := peekUsername(conn, hs); !filter.allows(username) {
+			log.Printf("[i] Rejecting username %q of %s on %s", username, connRemoteAddr, proxy.UID())
+			return proxy.handleLoginRequest(conn, filter.Message)
+		}
+	}
+
+	if hs.IsLoginRequest() && !proxy.verifyRejoin(connRemoteAddr) {
+		log.Printf("[i] Asking %s to reconnect to %s to verify it", connRemoteAddr, proxy.UID())
+		return proxy.handleLoginRequest(conn, proxy.RejoinVerification().Message)
+	}
+
 	proxyDomain := proxy.DomainName()
-	proxyTo := proxy.ProxyTo()
+	statusReq := proxy.newStatusRequest(hs)
+	statusReq.localization = proxy.localization(location)
+	if proxy.draining() {
+		if hs.IsStatusRequest() {
+			return proxy.handleStatusRequest(conn, false, statusReq)
+		}
+		return proxy.handleLoginRequest(conn, proxy.Drain().Message)
+	}
+
+	if proxy.Maintenance() {
+		// Proxies in maintenance don't touch their backends, so they can be stopped or be offline
+		if hs.IsStatusRequest() {
+			return proxy.handleMaintenanceStatusRequest(conn, statusReq)
+		}
+		return proxy.handleLoginRequest(conn, localizedMessage(statusReq.localization.MaintenanceMessage, proxy.MaintenanceMessage()))
+	}
+
+	if hs.IsTransferRequest() {
+		if transfers := proxy.Transfers(); transfers.Policy == RejectTransfers {
+			log.Printf("[i] Rejecting transferred client %s on %s", connRemoteAddr, proxy.UID())
+			return proxy.handleLoginRequest(conn, transfers.RejectMessage)
+		}
+	}
+
+	backends, supported := proxy.routeBackends(hs, peekUsername(conn, hs), location)
+	if !supported {
+		if hs.IsStatusRequest() {
+			return proxy.handleStatusRequest(conn, false, statusReq)
+		}
+		return proxy.handleLoginRequest(conn, localizedMessage(statusReq.localization.UnsupportedVersionMessage, proxy.UnsupportedVersionMessage()))
+	}
+
+	backend := proxy.balancer.pickSession(
+		proxy.LoadBalancer(),
+		backends,
+		proxy.BackendWeights(),
+		proxy.sessionKey(conn, hs, connRemoteAddr),
+		time.Millisecond*time.Duration(proxy.SessionAffinity().Timeout),
+	)
+	defer proxy.balancer.release(backend)
+	proxyTo := proxy.expandProxyTo(backend, hs.ParseServerAddress())
 	proxyUID := proxy.UID()
 
+	if proxyTo == "" {
+		// A proxy without a backend, like a catch-all proxy for unknown domains,
+		// answers every client with its offline status and disconnect message
+		if hs.IsStatusRequest() {
+			return proxy.handleStatusRequest(conn, false, statusReq)
+		}
+		return proxy.handleOfflineLoginRequest(conn, statusReq.localization)
+	}
+
+	// Backend responses are only read if they are cached, overridden or their pings are answered by Infrared,
+	// otherwise they are piped to the client
+	if hs.IsStatusRequest() && !proxy.IsOnlineStatusConfigured() &&
+		(proxy.StatusCacheTTL() > 0 || proxy.StatusOverride().enabled() || proxy.PingMode() == LocalPing) {
+		return proxy.handleCachedStatusRequest(conn, proxyTo, connRemoteAddr, hs, statusReq)
+	}
+
+	var profile gameProfile
+	if auth := proxy.Authentication(); auth.Enabled && hs.IsLoginRequest() {
+		profile, err = proxy.authenticate(conn, hs, connRemoteAddr)
+		if err != nil {
+			log.Printf("[i] Can't authenticate %s on %s; error: %s", connRemoteAddr, proxyUID, err)
+			return proxy.handleLoginRequest(conn, auth.Message)
+		}
+	}
+
 	dialer, err := proxy.Dialer()
 	if err != nil {
 		return err
 	}
 
-	rconn, err := dialer.Dial(proxyTo)
+	dialStart := time.Now()
+	var rconn Conn
+	if proxy.Transparent() {
+		rconn, err = dialTransparent(dialer, proxyTo, connRemoteAddr)
+	} else {
+		rconn, err = dialBackend(dialer, proxyTo)
+	}
 	if err != nil {
 		log.Printf("[i] %s did not respond to ping; is the target offline?", proxyTo)
+		proxy.publish(BackendDialFailed{
+			Proxy:       proxy,
+			ProxyUID:    proxyUID,
+			Domain:      proxyDomain,
+			BackendAddr: proxyTo,
+			Err:         err,
+		})
 		if hs.IsStatusRequest() {
-			return proxy.handleStatusRequest(conn, false)
+			return proxy.handleStatusRequest(conn, false, statusReq)
 		}
 		if err := proxy.startProcessIfNotRunning(); err != nil {
-			return err
+			log.Printf("[x] Can't start the process of %s; error: %s", proxy.UID(), err)
 		}
 		proxy.timeoutProcess()
-		return proxy.handleLoginRequest(conn)
+		return proxy.handleOfflineLoginRequest(conn, statusReq.localization)
 	}
 	defer rconn.Close()
 
 	if hs.IsStatusRequest() && proxy.IsOnlineStatusConfigured() {
-		return proxy.handleStatusRequest(conn, true)
+		statusReq.BackendLatency = time.Since(dialStart)
+		if proxy.PingMode() == BackendPing {
+			statusReq.forwardPing = proxy.forwardPingTo(rconn, hs)
+		}
+		return proxy.handleStatusRequest(conn, true, statusReq)
 	}
 
-	spoofForcedHost := proxy.SpoofForcedHost()
-	if spoofForcedHost != "" {
-		hs.ServerAddress = protocol.String(spoofForcedHost)
+	// The TLVs of the PROXY protocol header have the domain that the client requested
+	clientHs := hs
+	if spoofedHs := proxy.spoofHandshake(hs); spoofedHs != hs {
+		hs = spoofedHs
 		pk = hs.Marshal()
 	}
 
 	if proxy.ProxyProtocol() {
-		header := &proxyproto.Header{
-			Version:           2,
-			Command:           proxyproto.PROXY,
-			TransportProtocol: proxyproto.TCPv4,
-			SourceAddr:        connRemoteAddr,
-			DestinationAddr:   rconn.RemoteAddr(),
-		}
-
-		if _, err = header.WriteTo(rconn); err != nil {
+		if err := proxy.writeProxyProtocolHeader(conn, rconn, connRemoteAddr, clientHs); err != nil {
 			return err
 		}
 	}
@@ -253,6 +547,15 @@ func (proxy *Proxy) handleConn(conn Conn, connRemoteAddr net.Addr) error {
 		pk = hs.Marshal()
 	}
 
+	if profile.ID != "" && proxy.Authentication().Forwarding == BungeeCordForwarding {
+		addr, err := bungeeCordAddress(hs, connRemoteAddr, profile)
+		if err != nil {
+			return err
+		}
+		hs.ServerAddress = protocol.String(addr)
+		pk = hs.Marshal()
+	}
+
 	if err := rconn.WritePacket(pk); err != nil {
 		return err
 	}
@@ -266,50 +569,92 @@ func (proxy *Proxy) handleConn(conn Conn, connRemoteAddr net.Addr) error {
 			return err
 		}
 		proxy.addPlayer(conn, username)
-		proxy.logEvent(callback.PlayerJoinEvent{
-			Username:      username,
-			RemoteAddress: connRemoteAddr.String(),
-			TargetAddress: proxyTo,
-			ProxyUID:      proxyUID,
+		proxy.publish(PlayerJoined{
+			Proxy:       proxy,
+			ProxyUID:    proxyUID,
+			Domain:      proxyDomain,
+			Username:    username,
+			RemoteAddr:  connRemoteAddr,
+			BackendAddr: proxyTo,
 		})
-		playersConnected.With(prometheus.Labels{"host": proxyDomain}).Inc()
 		connected = true
 	}
 
-	go pipe(rconn, conn)
-	pipe(conn, rconn)
+	if err := establish(conn); err != nil {
+		return err
+	}
+
+	proxy.pipeIdle(conn, rconn, proxyDomain)
 
 	if connected {
-		proxy.logEvent(callback.PlayerLeaveEvent{
-			Username:      username,
-			RemoteAddress: connRemoteAddr.String(),
-			TargetAddress: proxyTo,
-			ProxyUID:      proxyUID,
+		proxy.publish(PlayerLeft{
+			Proxy:       proxy,
+			ProxyUID:    proxyUID,
+			Domain:      proxyDomain,
+			Username:    username,
+			RemoteAddr:  connRemoteAddr,
+			BackendAddr: proxyTo,
 		})
-		playersConnected.With(prometheus.Labels{"host": proxyDomain}).Dec()
 	}
 
 	remainingPlayers := proxy.removePlayer(conn)
 	if remainingPlayers <= 0 {
 		proxy.timeoutProcess()
+		proxy.finishDrain()
 	}
 	return nil
 }
 
-func pipe(src, dst Conn) {
+// dialBackend resolves the backend address and dials it
+func dialBackend(dialer *Dialer, addr string) (Conn, error) {
+	addr, err := resolveBackend(addr)
+	if err != nil {
+		return nil, err
+	}
+	return dialer.Dial(addr)
+}
+
+// writeProxyProtocolHeader sends the PROXY protocol header of the client to the backend
+// with the TLVs of the original handshake of the client
+func (proxy *Proxy) writeProxyProtocolHeader(conn, rconn Conn, connRemoteAddr net.Addr, hs handshaking.ServerBoundHandshake) error {
+	destinationAddr := rconn.RemoteAddr()
+	if _, ok := destinationAddr.(*net.TCPAddr); !ok {
+		// Backends on a Unix domain socket have no TCP address, so the listener address is used
+		destinationAddr = conn.LocalAddr()
+	}
+
+	header := proxyProtocolHeader(connRemoteAddr, destinationAddr)
+	if tlvs := proxy.ProxyProtocolTLVs().tlvs(proxy.DomainName(), hs); len(tlvs) > 0 {
+		if err := header.SetTLVs(tlvs); err != nil {
+			return err
+		}
+	}
+	_, err := header.WriteTo(rconn)
+	return err
+}
+
+// pipe copies the data of src to dst and reports whether it stopped, because src didn't send anything for the
+// idle timeout. An idle timeout of 0 keeps the deadlines of src.
+func pipe(src, dst Conn, idleTimeout time.Duration) bool {
 	buffer := make([]byte, 0xffff)
 
 	for {
+		if idleTimeout > 0 {
+			if err := src.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+				return false
+			}
+		}
+
 		n, err := src.Read(buffer)
 		if err != nil {
-			return
+			return idleTimeout > 0 && isTimeout(err)
 		}
 
 		data := buffer[:n]
 
 		_, err = dst.Write(data)
 		if err != nil {
-			return
+			return false
 		}
 	}
 }
@@ -329,7 +674,7 @@ func (proxy *Proxy) startProcessIfNotRunning() error {
 	}
 
 	log.Println("[i] Starting container for", proxy.UID())
-	proxy.logEvent(callback.ContainerStartEvent{ProxyUID: proxy.UID()})
+	proxy.publish(ContainerStarted{Proxy: proxy, ProxyUID: proxy.UID()})
 	return proxy.Process().Start()
 }
 
@@ -347,7 +692,7 @@ func (proxy *Proxy) timeoutProcess() {
 	log.Printf("[i] Starting container timeout %s on %s", proxy.DockerTimeout(), proxy.UID())
 	timer := time.AfterFunc(proxy.DockerTimeout(), func() {
 		log.Println("[i] Stopping container on", proxy.UID())
-		proxy.logEvent(callback.ContainerStopEvent{ProxyUID: proxy.UID()})
+		proxy.publish(ContainerStopped{Proxy: proxy, ProxyUID: proxy.UID()})
 		if err := proxy.Process().Stop(); err != nil {
 			log.Printf("[w] Failed to stop the container for %s; error: %s", proxy.UID(), err)
 		}
@@ -384,19 +729,64 @@ func (proxy *Proxy) sniffUsername(conn, rconn Conn, connRemoteAddr net.Addr) (st
 	return string(ls.Name), nil
 }
 
-func (proxy *Proxy) handleLoginRequest(conn Conn) error {
-	packet, err := conn.ReadPacket()
+// handleLoginRequest disconnects the client with the message
+func (proxy *Proxy) handleLoginRequest(conn Conn, message string) error {
+	templates, err := proxy.loginTemplates(conn)
 	if err != nil {
 		return err
 	}
 
-	loginStart, err := login.UnmarshalServerBoundLoginStart(packet)
+	textFormat := proxy.TextFormat()
+	for key, value := range templates {
+		message = strings.Replace(message, fmt.Sprintf("{{%s}}", key), escapeText(value, textFormat), -1)
+	}
+
+	return conn.WritePacket(login.ClientBoundDisconnect{
+		Reason: formatChat(message, textFormat),
+	}.Marshal())
+}
+
+// handleOfflineLoginRequest disconnects the client of an offline backend with the
+// disconnect component of the proxy or with its disconnect message, if it has no component
+func (proxy *Proxy) handleOfflineLoginRequest(conn Conn, localization Localization) error {
+	component := proxy.DisconnectComponent()
+	if len(component) == 0 || localization.DisconnectMessage != "" {
+		return proxy.handleLoginRequest(conn, localizedMessage(localization.DisconnectMessage, proxy.DisconnectMessage()))
+	}
+
+	templates, err := proxy.loginTemplates(conn)
 	if err != nil {
 		return err
 	}
 
-	message := proxy.DisconnectMessage()
-	templates := map[string]string{
+	reason := string(component)
+	for key, value := range templates {
+		// The values are inserted into JSON strings of the component
+		escaped, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		reason = strings.Replace(reason, fmt.Sprintf("{{%s}}", key), string(escaped[1:len(escaped)-1]), -1)
+	}
+
+	return conn.WritePacket(login.ClientBoundDisconnect{
+		Reason: protocol.Chat(reason),
+	}.Marshal())
+}
+
+// loginTemplates reads the login start of the client and returns the values of the placeholders of disconnect messages
+func (proxy *Proxy) loginTemplates(conn Conn) (map[string]string, error) {
+	packet, err := conn.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+
+	loginStart, err := login.UnmarshalServerBoundLoginStart(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
 		"username":      string(loginStart.Name),
 		"now":           time.Now().Format(time.RFC822),
 		"remoteAddress": conn.LocalAddr().String(),
@@ -404,37 +794,46 @@ func (proxy *Proxy) handleLoginRequest(conn Conn) error {
 		"domain":        proxy.DomainName(),
 		"proxyTo":       proxy.ProxyTo(),
 		"listenTo":      proxy.ListenTo(),
+	}, nil
+}
+
+func (proxy *Proxy) handleStatusRequest(conn Conn, online bool, statusReq statusRequest) error {
+	proxy.Config.RLock()
+	statusCfg := proxy.Config.OfflineStatus
+	if online {
+		statusCfg = proxy.Config.OnlineStatus
 	}
+	proxy.Config.RUnlock()
+	statusCfg = localizedStatus(statusCfg, statusReq.localization.MOTD)
 
-	for key, value := range templates {
-		message = strings.Replace(message, fmt.Sprintf("{{%s}}", key), value, -1)
+	responsePk, err := statusCfg.templatedResponsePacket(statusReq, atomic.AddUint32(&proxy.motdRotation, 1)-1, proxy.TextFormat())
+	if err != nil {
+		return err
 	}
+	return proxy.respondOverriddenStatus(conn, responsePk, statusReq)
+}
 
-	return conn.WritePacket(login.ClientBoundDisconnect{
-		Reason: protocol.Chat(fmt.Sprintf("{\"text\":\"%s\"}", message)),
-	}.Marshal())
+func (proxy *Proxy) handleMaintenanceStatusRequest(conn Conn, statusReq statusRequest) error {
+	proxy.Config.RLock()
+	statusCfg := proxy.Config.MaintenanceStatus
+	proxy.Config.RUnlock()
+	statusCfg = localizedStatus(statusCfg, statusReq.localization.MaintenanceMOTD)
+
+	responsePk, err := statusCfg.templatedResponsePacket(statusReq, atomic.AddUint32(&proxy.motdRotation, 1)-1, proxy.TextFormat())
+	if err != nil {
+		return err
+	}
+	return proxy.respondOverriddenStatus(conn, responsePk, statusReq)
 }
 
-func (proxy *Proxy) handleStatusRequest(conn Conn, online bool) error {
+// respondStatus answers the status request of the client with the status response and its ping with the pong
+func respondStatus(conn Conn, responsePk protocol.Packet, pong func(ping protocol.Packet) protocol.Packet) error {
 	// Read the request packet and send status response back
 	_, err := conn.ReadPacket()
 	if err != nil {
 		return err
 	}
 
-	var responsePk protocol.Packet
-	if online {
-		responsePk, err = proxy.OnlineStatusPacket()
-		if err != nil {
-			return err
-		}
-	} else {
-		responsePk, err = proxy.OfflineStatusPacket()
-		if err != nil {
-			return err
-		}
-	}
-
 	if err := conn.WritePacket(responsePk); err != nil {
 		return err
 	}
@@ -444,5 +843,5 @@ func (proxy *Proxy) handleStatusRequest(conn Conn, online bool) error {
 		return err
 	}
 
-	return conn.WritePacket(pingPk)
+	return conn.WritePacket(pong(pingPk))
 }
@@ -0,0 +1,148 @@
+package infrared
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/pires/go-proxyproto"
+)
+
+// errUntrustedProxyProtocol is the error of connections that aren't from a trusted PROXY protocol source
+var errUntrustedProxyProtocol = errors.New("PROXY protocol header from an untrusted address")
+
+// receivesProxyProtocol reports whether the connections of the listener have to start with a PROXY protocol header
+func (gateway *Gateway) receivesProxyProtocol(listenTo string) bool {
+	if gateway.ReceiveProxyProtocol {
+		return true
+	}
+	for _, key := range gateway.ProxyProtocolListeners {
+		if key == listenTo {
+			return true
+		}
+	}
+	return false
+}
+
+func (gateway *Gateway) validateProxyProtocol() error {
+	for _, entry := range gateway.ProxyProtocolTrusted {
+		if _, err := parseIPNet(entry); err != nil {
+			return err
+		}
+	}
+	for _, key := range gateway.ProxyProtocolListeners {
+		if _, err := gateway.listenAddr(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trustsProxyProtocol reports whether the address may send PROXY protocol headers.
+// Without trusted sources every address is trusted.
+func (gateway *Gateway) trustsProxyProtocol(addr net.Addr) bool {
+	if len(gateway.ProxyProtocolTrusted) == 0 {
+		return true
+	}
+	ip, _, ok := splitIPAddr(unmapAddr(addr))
+	return ok && containsIP(gateway.ProxyProtocolTrusted, ip)
+}
+
+// readProxyProtocol reads the PROXY protocol header of version 1 or 2 of the connection and returns the address of
+// the client in it. Headers of the LOCAL command, like the health checks of load balancers, keep the address of
+// the connection.
+func (gateway *Gateway) readProxyProtocol(conn Conn, connRemoteAddr net.Addr) (net.Addr, error) {
+	if !gateway.trustsProxyProtocol(connRemoteAddr) {
+		return nil, errUntrustedProxyProtocol
+	}
+
+	header, err := proxyproto.Read(conn.Reader())
+	if err != nil {
+		return nil, err
+	}
+	if header.Command.IsLocal() {
+		return connRemoteAddr, nil
+	}
+
+	switch header.TransportProtocol {
+	case proxyproto.TCPv4, proxyproto.TCPv6:
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol transport %#x", byte(header.TransportProtocol))
+	}
+	return unmapAddr(header.SourceAddr), nil
+}
+
+// The types of the TLVs that Infrared adds to the PROXY protocol headers to backends.
+// The requested domain has the registered authority type and the others are of the custom range.
+const (
+	ProxyProtocolTLVDomain          = 0xE0
+	ProxyProtocolTLVProtocolVersion = 0xE1
+)
+
+// ProxyTLVsConfig adds TLVs with the metadata of the client to the PROXY protocol v2 headers to backends
+type ProxyTLVsConfig struct {
+	// Authority sends the domain that the client requested as PP2_TYPE_AUTHORITY
+	Authority bool `json:"authority"`
+	// Domain sends the domain name of the proxy that matched the client as ProxyProtocolTLVDomain
+	Domain bool `json:"domain"`
+	// ProtocolVersion sends the protocol version of the client in decimal as ProxyProtocolTLVProtocolVersion
+	ProtocolVersion bool        `json:"protocolVersion"`
+	Custom          []CustomTLV `json:"custom"`
+}
+
+// CustomTLV is a TLV with a fixed value, like the name of the network for backend plugins
+type CustomTLV struct {
+	Type  int    `json:"type"`
+	Value string `json:"value"`
+}
+
+func (cfg ProxyTLVsConfig) validate() error {
+	for _, tlv := range cfg.Custom {
+		if tlv.Type <= ProxyProtocolTLVProtocolVersion || tlv.Type > int(proxyproto.PP2_TYPE_MAX_CUSTOM) {
+			return fmt.Errorf("custom type %#x is not between %#x and %#x", tlv.Type,
+				ProxyProtocolTLVProtocolVersion+1, byte(proxyproto.PP2_TYPE_MAX_CUSTOM))
+		}
+		if len(tlv.Value) > 0xffff {
+			return fmt.Errorf("value of type %#x is too long", tlv.Type)
+		}
+	}
+	return nil
+}
+
+// tlvs returns the TLVs of the client with the handshake, which matched the proxy of the domain name
+func (cfg ProxyTLVsConfig) tlvs(domainName string, hs handshaking.ServerBoundHandshake) []proxyproto.TLV {
+	var tlvs []proxyproto.TLV
+	if cfg.Authority {
+		tlvs = append(tlvs, proxyproto.TLV{
+			Type:  proxyproto.PP2_TYPE_AUTHORITY,
+			Value: []byte(hs.ParseServerAddress()),
+		})
+	}
+	if cfg.Domain {
+		tlvs = append(tlvs, proxyproto.TLV{
+			Type:  ProxyProtocolTLVDomain,
+			Value: []byte(domainName),
+		})
+	}
+	if cfg.ProtocolVersion && hs.ProtocolVersion != 0 {
+		tlvs = append(tlvs, proxyproto.TLV{
+			Type:  ProxyProtocolTLVProtocolVersion,
+			Value: []byte(strconv.Itoa(int(hs.ProtocolVersion))),
+		})
+	}
+	for _, tlv := range cfg.Custom {
+		tlvs = append(tlvs, proxyproto.TLV{
+			Type:  proxyproto.PP2Type(tlv.Type),
+			Value: []byte(tlv.Value),
+		})
+	}
+	return tlvs
+}
+
+func (proxy *Proxy) ProxyProtocolTLVs() ProxyTLVsConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.ProxyProtocolTLVs
+}
@@ -0,0 +1,160 @@
+package infrared
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/pires/go-proxyproto"
+)
+
+func TestGateway_ReadProxyProtocol(t *testing.T) {
+	lb := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 40000}
+	client := &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 51234}
+	server := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 25565}
+
+	header := func(version byte, command proxyproto.ProtocolVersionAndCommand) []byte {
+		h := proxyproto.Header{
+			Version:           version,
+			Command:           command,
+			TransportProtocol: proxyproto.TCPv4,
+			SourceAddr:        client,
+			DestinationAddr:   server,
+		}
+		bb, err := h.Format()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bb
+	}
+
+	tt := []struct {
+		name    string
+		trusted []string
+		bb      []byte
+		addr    string
+		err     error
+	}{
+		{name: "v1", bb: header(1, proxyproto.PROXY), addr: client.String()},
+		{name: "v2", bb: header(2, proxyproto.PROXY), addr: client.String()},
+		{name: "local", bb: header(2, proxyproto.LOCAL), addr: lb.String()},
+		{name: "trusted", trusted: []string{"192.0.2.0/24"}, bb: header(2, proxyproto.PROXY), addr: client.String()},
+		{name: "untrusted", trusted: []string{"198.51.100.0/24"}, bb: header(2, proxyproto.PROXY), err: errUntrustedProxyProtocol},
+	}
+
+	for _, tc := range tt {
+		gateway := Gateway{ProxyProtocolTrusted: tc.trusted}
+		c1, c2 := net.Pipe()
+		go func() {
+			c2.Write(tc.bb)
+			c2.Close()
+		}()
+
+		addr, err := gateway.readProxyProtocol(wrapConn(c1), lb)
+		c1.Close()
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%s: got %v; want %v", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tc.name, err)
+			continue
+		}
+		if addr.String() != tc.addr {
+			t.Errorf("%s: got %s; want %s", tc.name, addr, tc.addr)
+		}
+	}
+}
+
+func TestGateway_ReceivesProxyProtocol(t *testing.T) {
+	gateway := Gateway{
+		Listeners:              map[string]string{"shield": ":25566"},
+		ProxyProtocolListeners: []string{"shield"},
+	}
+	if !gateway.receivesProxyProtocol("shield") {
+		t.Error("got false; want the listener to require the PROXY protocol")
+	}
+	if gateway.receivesProxyProtocol(":25565") {
+		t.Error("got true; want other listeners without the PROXY protocol")
+	}
+
+	gateway.ReceiveProxyProtocol = true
+	if !gateway.receivesProxyProtocol(":25565") {
+		t.Error("got false; want all listeners to require the PROXY protocol")
+	}
+}
+
+func TestProxy_WriteProxyProtocolHeader_TLVs(t *testing.T) {
+	proxy := &Proxy{Config: &ProxyConfig{
+		DomainName: "*.example.com",
+		ProxyProtocolTLVs: ProxyTLVsConfig{
+			Authority:       true,
+			Domain:          true,
+			ProtocolVersion: true,
+			Custom:          []CustomTLV{{Type: 0xE5, Value: "lobby"}},
+		},
+	}}
+	hs := handshaking.ServerBoundHandshake{ProtocolVersion: 763, ServerAddress: "mc.example.com"}
+	client := &net.TCPAddr{IP: net.ParseIP("203.0.113.5"), Port: 51234}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := proxy.writeProxyProtocolHeader(wrapConn(c), wrapConn(c), client, hs); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	header, err := proxyproto.Read(bufio.NewReader(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != 2 || header.SourceAddr.String() != client.String() {
+		t.Errorf("got header %+v; want a v2 header of %s", header, client)
+	}
+
+	tlvs, err := header.TLVs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[proxyproto.PP2Type]string{
+		proxyproto.PP2_TYPE_AUTHORITY:   "mc.example.com",
+		ProxyProtocolTLVDomain:          "*.example.com",
+		ProxyProtocolTLVProtocolVersion: "763",
+		0xE5:                            "lobby",
+	}
+	if len(tlvs) != len(want) {
+		t.Fatalf("got %d TLVs; want %d", len(tlvs), len(want))
+	}
+	for _, tlv := range tlvs {
+		if value := string(tlv.Value); value != want[tlv.Type] {
+			t.Errorf("type %#x: got %q; want %q", byte(tlv.Type), value, want[tlv.Type])
+		}
+	}
+}
+
+func TestProxyTLVsConfig_Validate(t *testing.T) {
+	for _, typ := range []int{0x02, ProxyProtocolTLVDomain, 0xF0} {
+		cfg := ProxyTLVsConfig{Custom: []CustomTLV{{Type: typ}}}
+		if err := cfg.validate(); err == nil {
+			t.Errorf("type %#x: got no error; want an error for a type outside of the custom range", typ)
+		}
+	}
+}
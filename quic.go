@@ -0,0 +1,51 @@
+package infrared
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// QUICScheme is the prefix of listen addresses of QUIC listeners, like quic://:25565.
+// Companion client mods and edge relays open a bidirectional stream for every Minecraft connection,
+// which carries the same bytes as a TCP connection.
+const QUICScheme = "quic://"
+
+// QUICALPN is the ALPN protocol that the clients of QUIC listeners have to negotiate
+const QUICALPN = "minecraft"
+
+// errQUICUnsupported is the error of QUIC listeners of builds without QUIC
+var errQUICUnsupported = errors.New("QUIC listeners need a build with the quic tag and Go 1.16 or 1.17")
+
+func isQUICAddr(addr string) bool {
+	return strings.HasPrefix(addr, QUICScheme)
+}
+
+// listenQUIC listens on the QUIC address and serves its streams like the connections of a TCP listener.
+// QUIC always uses TLS, so it needs the TLSCertificates.
+func (gateway *Gateway) listenQUIC(addr, key string) error {
+	if len(gateway.TLSCertificates) == 0 {
+		return fmt.Errorf("QUIC listener %s has no TLS certificates", key)
+	}
+
+	log.Println("Creating QUIC listener on", addr)
+	listener, err := listenQUIC(strings.TrimPrefix(addr, QUICScheme), &tls.Config{
+		Certificates: gateway.TLSCertificates,
+		MinVersion:   tls.VersionTLS13,
+		NextProtos:   []string{QUICALPN},
+	})
+	if err != nil {
+		return err
+	}
+	gateway.listeners.Store(key, listener)
+
+	gateway.wg.Add(1)
+	go func() {
+		if err := gateway.listenAndServe(Listener{Listener: listener}, key); err != nil {
+			log.Printf("Failed to listen on %s; error: %s", addr, err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,109 @@
+//go:build quic && !go1.18
+// +build quic,!go1.18
+
+package infrared
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// listenQUIC listens for QUIC connections on the UDP address and accepts every bidirectional stream of them
+// as the connection of a client
+func listenQUIC(addr string, tlsConf *tls.Config) (net.Listener, error) {
+	l, err := quic.ListenAddr(addr, tlsConf, &quic.Config{KeepAlive: true})
+	if err != nil {
+		return nil, err
+	}
+
+	ql := &quicListener{
+		listener: l,
+		accepted: make(chan net.Conn),
+		closed:   make(chan struct{}),
+	}
+	go ql.acceptSessions()
+	return ql, nil
+}
+
+// quicListener accepts the streams of all QUIC connections of its address
+type quicListener struct {
+	listener quic.Listener
+	accepted chan net.Conn
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func (ql *quicListener) acceptSessions() {
+	for {
+		session, err := ql.listener.Accept(context.Background())
+		if err != nil {
+			ql.Close()
+			return
+		}
+		go ql.acceptStreams(session)
+	}
+}
+
+func (ql *quicListener) acceptStreams(session quic.Session) {
+	for {
+		stream, err := session.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+
+		conn := quicStreamConn{Stream: stream, session: session}
+		select {
+		case ql.accepted <- conn:
+		case <-ql.closed:
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (ql *quicListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-ql.accepted:
+		return conn, nil
+	case <-ql.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the listener and all of its QUIC connections
+func (ql *quicListener) Close() error {
+	var err error
+	ql.once.Do(func() {
+		close(ql.closed)
+		err = ql.listener.Close()
+	})
+	return err
+}
+
+func (ql *quicListener) Addr() net.Addr {
+	return ql.listener.Addr()
+}
+
+// quicStreamConn is a stream of a QUIC connection. Closing it closes both directions of the stream,
+// but not the QUIC connection, which can carry more streams.
+type quicStreamConn struct {
+	quic.Stream
+	session quic.Session
+}
+
+func (c quicStreamConn) Close() error {
+	c.Stream.CancelRead(0)
+	return c.Stream.Close()
+}
+
+func (c quicStreamConn) LocalAddr() net.Addr {
+	return c.session.LocalAddr()
+}
+
+func (c quicStreamConn) RemoteAddr() net.Addr {
+	return c.session.RemoteAddr()
+}
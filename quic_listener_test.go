@@ -0,0 +1,71 @@
+//go:build quic && !go1.18
+// +build quic,!go1.18
+
+package infrared
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"testing"
+
+	"github.com/haveachin/infrared/protocol/status"
+	"github.com/lucas-clemente/quic-go"
+)
+
+func TestQUICListener(t *testing.T) {
+	portEnd := 599
+	errorCh := make(chan *testError, 1)
+	statusListen(statusListenerConfig{
+		addr:   serverAddr(portEnd),
+		status: statusPKWithVersion("backend"),
+	}, errorCh)
+
+	config := proxyConfigWithPortEnd(portEnd)
+	config.ListenTo = QUICScheme + "127.0.0.1" + gatewayAddr(portEnd)
+
+	gateway := Gateway{TLSCertificates: []tls.Certificate{selfSignedCertificate(t, serverDomain)}}
+	if err := gateway.ListenAndServe(configToProxies(config)); err != nil {
+		t.Fatalf("Can't start gateway: %v", err)
+	}
+	defer gateway.Close()
+
+	session, err := quic.DialAddr("127.0.0.1"+gatewayAddr(portEnd), &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{QUICALPN},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.CloseWithError(0, "")
+
+	stream, err := session.OpenStreamSync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := wrapConn(quicStreamConn{Stream: stream, session: session})
+	defer conn.Close()
+
+	if err := conn.WritePacket(statusHandshakePort(portEnd)); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WritePacket(status.ServerBoundRequest{}.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+
+	pk, err := conn.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := status.UnmarshalClientBoundResponse(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := status.ResponseJSON{}
+	if err := json.Unmarshal([]byte(response.JSONResponse), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Version.Name != "backend" {
+		t.Errorf("got version %s; want the status of the backend", res.Version.Name)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !quic || go1.18
+// +build !quic go1.18
+
+package infrared
+
+import (
+	"crypto/tls"
+	"net"
+)
+
+// listenQUIC fails, because QUIC is only part of builds with the quic tag
+func listenQUIC(_ string, _ *tls.Config) (net.Listener, error) {
+	return nil, errQUICUnsupported
+}
@@ -0,0 +1,24 @@
+package infrared
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+)
+
+func TestGateway_ListenQUIC(t *testing.T) {
+	gateway := Gateway{}
+	if err := gateway.listenQUIC("quic://127.0.0.1:0", "quic://127.0.0.1:0"); err == nil {
+		t.Fatal("got no error; want an error without TLS certificates")
+	}
+
+	gateway.TLSCertificates = []tls.Certificate{selfSignedCertificate(t, "mc.example.com")}
+	err := gateway.listenQUIC("quic://127.0.0.1:0", "quic://127.0.0.1:0")
+	if errors.Is(err, errQUICUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway.Close()
+}
@@ -0,0 +1,258 @@
+package infrared
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// RateLimitsConfig limits the status requests and the logins of every IP on a proxy independently,
+// so bursty server list refreshes don't count against logins and login floods don't hide the server from the list.
+type RateLimitsConfig struct {
+	Status RateLimitConfig `json:"status"`
+	Login  RateLimitConfig `json:"login"`
+	// Message is the disconnect message of logins over the limit
+	Message string `json:"message"`
+}
+
+// RateLimitConfig allows an IP Requests requests per Window milliseconds. A limit of 0 requests is disabled.
+type RateLimitConfig struct {
+	Requests int `json:"requests"`
+	Window   int `json:"window"`
+}
+
+func (cfg RateLimitConfig) window() time.Duration {
+	if cfg.Window <= 0 {
+		return time.Second
+	}
+	return time.Millisecond * time.Duration(cfg.Window)
+}
+
+// requestLimiterSweepInterval is how often the limiter forgets IPs whose windows ended
+var requestLimiterSweepInterval = time.Minute
+
+type requestLimiterEntry struct {
+	window   time.Time
+	duration time.Duration
+	requests int
+}
+
+// requestLimiter counts the requests of every IP of a proxy in fixed windows, separately for every kind of request
+type requestLimiter struct {
+	mu        sync.Mutex
+	entries   map[string]*requestLimiterEntry
+	lastSweep time.Time
+}
+
+// allow counts the request of the kind from the IP and reports whether it is within the limit
+func (l *requestLimiter) allow(kind string, ip net.IP, cfg RateLimitConfig) bool {
+	if cfg.Requests <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	key := kind + "|" + ip.String()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.entries == nil {
+		l.entries = map[string]*requestLimiterEntry{}
+		l.lastSweep = now
+	}
+
+	if now.Sub(l.lastSweep) > requestLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	entry, ok := l.entries[key]
+	if !ok || now.Sub(entry.window) >= entry.duration {
+		entry = &requestLimiterEntry{window: now, duration: cfg.window()}
+		l.entries[key] = entry
+	}
+
+	entry.requests++
+	return entry.requests <= cfg.Requests
+}
+
+func (l *requestLimiter) sweep(now time.Time) {
+	for key, entry := range l.entries {
+		if now.Sub(entry.window) >= entry.duration {
+			delete(l.entries, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (proxy *Proxy) RateLimits() RateLimitsConfig {
+	if profile := proxy.protectionProfile(); profile.RateLimits != nil {
+		return *profile.RateLimits
+	}
+
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.RateLimits
+}
+
+// allowRequest counts the request of the handshake from the address against the
+// status or the login limit of the proxy and reports whether it is within the limit
+func (proxy *Proxy) allowRequest(hs handshaking.ServerBoundHandshake, addr net.Addr) bool {
+	ip, _, ok := splitIPAddr(unmapAddr(addr))
+	if !ok {
+		return true
+	}
+
+	limits := proxy.RateLimits()
+	if hs.IsStatusRequest() {
+		return proxy.limiter.allow("status", ip, limits.Status)
+	}
+	return proxy.limiter.allow("login", ip, limits.Login)
+}
+
+var rateLimitedConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "infrared_rate_limited_connections",
+	Help: "The total number of connections dropped by the token bucket rate limits",
+}, []string{"stage", "limit"})
+
+// Stages at which the token bucket rate limits drop connections
+const (
+	acceptStage    = "accept"
+	handshakeStage = "handshake"
+)
+
+// TokenBucketConfig refills Rate tokens per second up to Burst tokens. Every connection takes a token
+// and a connection without a token is dropped. A rate of 0 is disabled.
+type TokenBucketConfig struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+	// BanDuration drops every connection for this many milliseconds after the bucket ran out of tokens
+	BanDuration int `json:"banDuration"`
+}
+
+// burst returns the size of the bucket, which is at least the rate rounded up
+func (cfg TokenBucketConfig) burst() float64 {
+	if cfg.Burst > 0 {
+		return float64(cfg.Burst)
+	}
+	return math.Max(1, math.Ceil(cfg.Rate))
+}
+
+// ConnLimitsConfig limits the connections of every client IP and of all clients together with token buckets
+type ConnLimitsConfig struct {
+	PerIP  TokenBucketConfig `json:"perIp"`
+	Global TokenBucketConfig `json:"global"`
+}
+
+type tokenBucket struct {
+	tokens      float64
+	last        time.Time
+	bannedUntil time.Time
+}
+
+// take refills the bucket and takes a token, if the bucket has one and isn't banned
+func (b *tokenBucket) take(now time.Time, cfg TokenBucketConfig) bool {
+	if now.Before(b.bannedUntil) {
+		return false
+	}
+
+	b.refill(now, cfg)
+	if b.tokens < 1 {
+		b.bannedUntil = now.Add(time.Millisecond * time.Duration(cfg.BanDuration))
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *tokenBucket) refill(now time.Time, cfg TokenBucketConfig) {
+	if b.last.IsZero() {
+		b.tokens = cfg.burst()
+	} else {
+		b.tokens = math.Min(cfg.burst(), b.tokens+now.Sub(b.last).Seconds()*cfg.Rate)
+	}
+	b.last = now
+}
+
+// connectionLimiter holds the token buckets of every client IP and the global token bucket
+type connectionLimiter struct {
+	mu        sync.Mutex
+	global    tokenBucket
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// allow takes a token of the IP and a global token and reports whether the connection is within
+// the limits. Connections over a limit return the limit that dropped them.
+func (l *connectionLimiter) allow(ip net.IP, cfg ConnLimitsConfig) (bool, string) {
+	if cfg.PerIP.Rate <= 0 && cfg.Global.Rate <= 0 {
+		return true, ""
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = map[string]*tokenBucket{}
+		l.lastSweep = now
+	}
+
+	if now.Sub(l.lastSweep) > requestLimiterSweepInterval {
+		l.sweep(now, cfg.PerIP)
+	}
+
+	// IPs over their limit don't take global tokens
+	if cfg.PerIP.Rate > 0 && ip != nil {
+		key := ip.String()
+		bucket, ok := l.buckets[key]
+		if !ok {
+			bucket = &tokenBucket{}
+			l.buckets[key] = bucket
+		}
+		if !bucket.take(now, cfg.PerIP) {
+			return false, "ip"
+		}
+	}
+
+	if cfg.Global.Rate > 0 && !l.global.take(now, cfg.Global) {
+		return false, "global"
+	}
+	return true, ""
+}
+
+// sweep forgets the IPs whose buckets are full again and that aren't banned
+func (l *connectionLimiter) sweep(now time.Time, cfg TokenBucketConfig) {
+	for key, bucket := range l.buckets {
+		if now.Before(bucket.bannedUntil) {
+			continue
+		}
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*cfg.Rate >= cfg.burst() {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// allowConnection takes the tokens of the connection from the address at the stage and counts dropped
+// connections. Dropped connections return the limit that dropped them.
+func (l *connectionLimiter) allowConnection(addr net.Addr, cfg ConnLimitsConfig, stage string) (bool, string) {
+	ip, _, _ := splitIPAddr(unmapAddr(addr))
+	ok, limit := l.allow(ip, cfg)
+	if !ok {
+		rateLimitedConnections.With(prometheus.Labels{"stage": stage, "limit": limit}).Inc()
+	}
+	return ok, limit
+}
+
+func (proxy *Proxy) HandshakeLimits() ConnLimitsConfig {
+	if profile := proxy.protectionProfile(); profile.HandshakeLimits != nil {
+		return *profile.HandshakeLimits
+	}
+
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.HandshakeLimits
+}
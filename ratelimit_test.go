@@ -0,0 +1,105 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRequestLimiter_Allow(t *testing.T) {
+	status := RateLimitConfig{Requests: 5, Window: 1000}
+	login := RateLimitConfig{Requests: 1, Window: 1000}
+	ip := net.ParseIP("203.0.113.5")
+
+	var l requestLimiter
+	if !l.allow("login", ip, login) {
+		t.Fatal("got the first login limited; want it allowed")
+	}
+	if l.allow("login", ip, login) {
+		t.Error("got the second login allowed; want it limited")
+	}
+
+	for i := 0; i < 5; i++ {
+		if !l.allow("status", ip, status) {
+			t.Fatalf("got status request %d limited; want the status limit to be independent of logins", i)
+		}
+	}
+	if l.allow("status", ip, status) {
+		t.Error("got the sixth status request allowed; want it limited")
+	}
+
+	l.entries["login|"+ip.String()].window = time.Now().Add(-time.Second)
+	if !l.allow("login", ip, login) {
+		t.Error("got a login limited in the next window; want it allowed")
+	}
+
+	if !l.allow("login", ip, RateLimitConfig{}) {
+		t.Error("got a login limited without a limit; want it allowed")
+	}
+}
+
+func TestRequestLimiter_Sweep(t *testing.T) {
+	var l requestLimiter
+	l.allow("status", net.ParseIP("203.0.113.5"), RateLimitConfig{Requests: 1})
+
+	l.sweep(time.Now().Add(time.Second))
+	if len(l.entries) != 0 {
+		t.Errorf("got %d entries; want the ended window to be removed", len(l.entries))
+	}
+}
+
+func TestConnectionLimiter_Allow(t *testing.T) {
+	cfg := ConnLimitsConfig{
+		PerIP: TokenBucketConfig{Rate: 1, Burst: 2, BanDuration: 60000},
+	}
+	ip := net.ParseIP("203.0.113.5")
+	other := net.ParseIP("198.51.100.1")
+
+	var l connectionLimiter
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow(ip, cfg); !ok {
+			t.Fatalf("got connection %d dropped; want the burst to be allowed", i)
+		}
+	}
+	if ok, limit := l.allow(ip, cfg); ok || limit != "ip" {
+		t.Errorf("got %t and limit %q; want the third connection dropped by the ip limit", ok, limit)
+	}
+	if ok, _ := l.allow(other, cfg); !ok {
+		t.Error("got the connection of another IP dropped; want it allowed")
+	}
+
+	l.buckets[ip.String()].last = time.Now().Add(-time.Minute)
+	if ok, _ := l.allow(ip, cfg); ok {
+		t.Error("got a connection of a banned IP allowed; want it dropped")
+	}
+
+	l.buckets[ip.String()].bannedUntil = time.Time{}
+	if ok, _ := l.allow(ip, cfg); !ok {
+		t.Error("got a connection dropped after the bucket refilled; want it allowed")
+	}
+}
+
+func TestConnectionLimiter_AllowGlobal(t *testing.T) {
+	cfg := ConnLimitsConfig{
+		Global: TokenBucketConfig{Rate: 1},
+	}
+
+	var l connectionLimiter
+	if ok, _ := l.allow(net.ParseIP("203.0.113.5"), cfg); !ok {
+		t.Fatal("got the first connection dropped; want it allowed")
+	}
+	if ok, limit := l.allow(net.ParseIP("198.51.100.1"), cfg); ok || limit != "global" {
+		t.Errorf("got %t and limit %q; want the connection of another IP dropped by the global limit", ok, limit)
+	}
+}
+
+func TestConnectionLimiter_Sweep(t *testing.T) {
+	cfg := TokenBucketConfig{Rate: 1, Burst: 1}
+	var l connectionLimiter
+	l.allow(net.ParseIP("203.0.113.5"), ConnLimitsConfig{PerIP: cfg})
+
+	l.sweep(time.Now().Add(time.Second), cfg)
+	if len(l.buckets) != 0 {
+		t.Errorf("got %d buckets; want the full bucket to be removed", len(l.buckets))
+	}
+}
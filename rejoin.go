@@ -0,0 +1,154 @@
+package infrared
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var rejoinChallenges = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "infrared_rejoin_challenges",
+	Help: "The total number of logins that were disconnected to verify that they reconnect",
+}, []string{"host"})
+
+// Modes of the rejoin verification
+const (
+	// RejoinAlways challenges every new IP
+	RejoinAlways = "always"
+	// RejoinAttack only challenges new IPs while the logins to the proxy exceed the attack threshold
+	RejoinAttack = "attack"
+)
+
+// RejoinConfig disconnects the first login of an IP with the message and only lets the IP
+// log in if it reconnects within the window. Join bots rarely reconnect, players do.
+// Verified IPs aren't challenged again until VerifiedFor has passed.
+type RejoinConfig struct {
+	Mode string `json:"mode"`
+	// AttackLogins are the logins per second to the proxy that start an attack in the attack mode
+	AttackLogins int `json:"attackLogins"`
+	// AttackDuration is how many milliseconds an attack lasts after the logins fell below the threshold
+	AttackDuration int    `json:"attackDuration"`
+	Window         int    `json:"window"`
+	VerifiedFor    int    `json:"verifiedFor"`
+	Message        string `json:"message"`
+}
+
+func (cfg RejoinConfig) validate() error {
+	switch cfg.Mode {
+	case "", RejoinAlways:
+		return nil
+	case RejoinAttack:
+		if cfg.AttackLogins <= 0 {
+			return errors.New("attack mode needs attackLogins")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q", cfg.Mode)
+}
+
+// rejoinVerifier remembers the challenged and the verified IPs of a proxy
+type rejoinVerifier struct {
+	mu sync.Mutex
+	// challenged are the IPs by the time of their first login
+	challenged map[string]time.Time
+	// verified are the IPs by the time until they are verified
+	verified  map[string]time.Time
+	logins    int
+	second    time.Time
+	attackEnd time.Time
+	lastSweep time.Time
+}
+
+// underAttack counts the login and reports whether the logins of the last second exceed the threshold
+// or did so within the attack duration
+func (v *rejoinVerifier) underAttack(now time.Time, cfg RejoinConfig) bool {
+	if now.Sub(v.second) >= time.Second {
+		v.second, v.logins = now, 0
+	}
+	v.logins++
+	if v.logins > cfg.AttackLogins {
+		v.attackEnd = now.Add(time.Millisecond * time.Duration(cfg.AttackDuration))
+	}
+	return now.Before(v.attackEnd)
+}
+
+// verify reports whether the login of the IP may pass. The first login of an unverified IP is challenged
+// and a reconnect within the window verifies the IP.
+func (v *rejoinVerifier) verify(ip net.IP, cfg RejoinConfig) bool {
+	if cfg.Mode == "" || ip == nil {
+		return true
+	}
+
+	now := time.Now()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.challenged == nil {
+		v.challenged = map[string]time.Time{}
+		v.verified = map[string]time.Time{}
+		v.lastSweep = now
+	}
+
+	if now.Sub(v.lastSweep) > requestLimiterSweepInterval {
+		v.sweep(now, cfg)
+	}
+
+	attack := cfg.Mode == RejoinAttack && v.underAttack(now, cfg)
+	key := ip.String()
+	if until, ok := v.verified[key]; ok && now.Before(until) {
+		return true
+	}
+	if cfg.Mode == RejoinAttack && !attack {
+		return true
+	}
+
+	window := time.Millisecond * time.Duration(cfg.Window)
+	if first, ok := v.challenged[key]; ok && now.Sub(first) < window {
+		delete(v.challenged, key)
+		v.verified[key] = now.Add(time.Millisecond * time.Duration(cfg.VerifiedFor))
+		return true
+	}
+
+	v.challenged[key] = now
+	return false
+}
+
+// sweep forgets the challenges whose window ended and the IPs that aren't verified anymore
+func (v *rejoinVerifier) sweep(now time.Time, cfg RejoinConfig) {
+	window := time.Millisecond * time.Duration(cfg.Window)
+	for key, first := range v.challenged {
+		if now.Sub(first) >= window {
+			delete(v.challenged, key)
+		}
+	}
+	for key, until := range v.verified {
+		if !now.Before(until) {
+			delete(v.verified, key)
+		}
+	}
+	v.lastSweep = now
+}
+
+func (proxy *Proxy) RejoinVerification() RejoinConfig {
+	if profile := proxy.protectionProfile(); profile.RejoinVerification != nil {
+		return *profile.RejoinVerification
+	}
+
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.RejoinVerification
+}
+
+// verifyRejoin reports whether the login from the address passes the rejoin verification of the proxy
+func (proxy *Proxy) verifyRejoin(addr net.Addr) bool {
+	ip, _, _ := splitIPAddr(unmapAddr(addr))
+	if proxy.rejoin.verify(ip, proxy.RejoinVerification()) {
+		return true
+	}
+	rejoinChallenges.With(prometheus.Labels{"host": proxy.DomainName()}).Inc()
+	return false
+}
@@ -0,0 +1,71 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRejoinVerifier_Always(t *testing.T) {
+	cfg := RejoinConfig{Mode: RejoinAlways, Window: 60000, VerifiedFor: 60000}
+	ip := net.ParseIP("203.0.113.5")
+
+	var v rejoinVerifier
+	if v.verify(ip, cfg) {
+		t.Fatal("first login: got passed; want challenged")
+	}
+	if !v.verify(ip, cfg) {
+		t.Fatal("rejoin: got challenged; want passed")
+	}
+	if !v.verify(ip, cfg) {
+		t.Error("verified login: got challenged; want passed")
+	}
+	if v.verify(net.ParseIP("198.51.100.1"), cfg) {
+		t.Error("other ip: got passed; want challenged")
+	}
+}
+
+func TestRejoinVerifier_Window(t *testing.T) {
+	cfg := RejoinConfig{Mode: RejoinAlways, Window: 1, VerifiedFor: 60000}
+	ip := net.ParseIP("203.0.113.5")
+
+	var v rejoinVerifier
+	v.verify(ip, cfg)
+	time.Sleep(5 * time.Millisecond)
+	if v.verify(ip, cfg) {
+		t.Error("late rejoin: got passed; want challenged again")
+	}
+}
+
+func TestRejoinVerifier_Attack(t *testing.T) {
+	cfg := RejoinConfig{Mode: RejoinAttack, AttackLogins: 2, AttackDuration: 60000, Window: 60000, VerifiedFor: 60000}
+
+	var v rejoinVerifier
+	for i, ip := range []string{"203.0.113.1", "203.0.113.2"} {
+		if !v.verify(net.ParseIP(ip), cfg) {
+			t.Fatalf("login %d: got challenged; want passed without an attack", i+1)
+		}
+	}
+	if v.verify(net.ParseIP("203.0.113.3"), cfg) {
+		t.Error("login over the threshold: got passed; want challenged")
+	}
+}
+
+func TestRejoinConfig_Validate(t *testing.T) {
+	tt := []struct {
+		cfg   RejoinConfig
+		valid bool
+	}{
+		{cfg: RejoinConfig{}, valid: true},
+		{cfg: RejoinConfig{Mode: RejoinAlways}, valid: true},
+		{cfg: RejoinConfig{Mode: RejoinAttack, AttackLogins: 10}, valid: true},
+		{cfg: RejoinConfig{Mode: RejoinAttack}, valid: false},
+		{cfg: RejoinConfig{Mode: "sometimes"}, valid: false},
+	}
+
+	for _, tc := range tt {
+		if err := tc.cfg.validate(); (err == nil) != tc.valid {
+			t.Errorf("%+v: got %v; want valid %t", tc.cfg, err, tc.valid)
+		}
+	}
+}
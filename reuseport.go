@@ -0,0 +1,74 @@
+package infrared
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"runtime"
+	"strings"
+)
+
+// errReusePortUnsupported is the error of listeners on systems that can't spread connections across sockets
+var errReusePortUnsupported = errors.New("SO_REUSEPORT is not supported")
+
+// listenSockets returns the sockets of the listen address, which each get their own accept loop.
+// With ReusePort, every address gets Acceptors sockets and falls back to one socket without SO_REUSEPORT.
+func (gateway *Gateway) listenSockets(addr string) ([]net.Listener, error) {
+	if !gateway.ReusePort {
+		l, err := listen(addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	}
+
+	acceptors := gateway.Acceptors
+	if acceptors <= 0 {
+		acceptors = runtime.GOMAXPROCS(0)
+	}
+
+	sockets, err := listenReusePort(addr, acceptors)
+	if errors.Is(err, errReusePortUnsupported) {
+		log.Printf("[w] Listening on %s with one socket; error: %s", addr, err)
+		l, err := listen(addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	}
+	return sockets, err
+}
+
+// listenReusePort opens n sockets with SO_REUSEPORT on every address of the listen address.
+// The sockets after the first one bind to the address of the first, so a port of 0 is shared, too.
+func listenReusePort(addr string, n int) ([]net.Listener, error) {
+	lc := net.ListenConfig{Control: controlReusePort}
+	var sockets []net.Listener
+	for _, a := range strings.Split(addr, ListenAddrSeparator) {
+		network, address := splitNetwork(strings.TrimSpace(a))
+		for i := 0; i < n; i++ {
+			l, err := lc.Listen(context.Background(), network, address)
+			if err != nil {
+				socketGroup(sockets).Close()
+				return nil, err
+			}
+			sockets = append(sockets, l)
+			address = l.Addr().String()
+		}
+	}
+	return sockets, nil
+}
+
+// socketGroup closes all sockets of a listener
+type socketGroup []net.Listener
+
+func (sockets socketGroup) Close() error {
+	var err error
+	for _, l := range sockets {
+		if closeErr := l.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
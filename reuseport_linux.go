@@ -0,0 +1,22 @@
+package infrared
+
+import (
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// controlReusePort lets the socket share its address with the other sockets of the listener
+func controlReusePort(_, _ string, c syscall.RawConn) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return fmt.Errorf("%w; %s", errReusePortUnsupported, sockErr)
+	}
+	return nil
+}
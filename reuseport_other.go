@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package infrared
+
+import "syscall"
+
+// controlReusePort fails, because only Linux spreads the connections across the sockets of an address
+func controlReusePort(_, _ string, _ syscall.RawConn) error {
+	return errReusePortUnsupported
+}
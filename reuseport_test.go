@@ -0,0 +1,44 @@
+package infrared
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGateway_ListenSockets(t *testing.T) {
+	gateway := Gateway{ReusePort: true, Acceptors: 3}
+	sockets, err := gateway.listenSockets("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socketGroup(sockets).Close()
+
+	if runtime.GOOS != "linux" {
+		if len(sockets) != 1 {
+			t.Errorf("got %d sockets; want one socket without SO_REUSEPORT", len(sockets))
+		}
+		return
+	}
+
+	if len(sockets) != 3 {
+		t.Fatalf("got %d sockets; want 3", len(sockets))
+	}
+	for _, socket := range sockets[1:] {
+		if socket.Addr().String() != sockets[0].Addr().String() {
+			t.Errorf("got socket on %s; want all sockets on %s", socket.Addr(), sockets[0].Addr())
+		}
+	}
+}
+
+func TestGateway_ListenSockets_WithoutReusePort(t *testing.T) {
+	gateway := Gateway{Acceptors: 3}
+	sockets, err := gateway.listenSockets("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socketGroup(sockets).Close()
+
+	if len(sockets) != 1 {
+		t.Errorf("got %d sockets; want one socket without reuse port", len(sockets))
+	}
+}
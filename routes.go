@@ -0,0 +1,117 @@
+package infrared
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/haveachin/infrared/protocol/handshaking"
+)
+
+// VersionRoute sends clients with a protocol version between MinProtocol and MaxProtocol
+// to its own backend. A bound of 0 is open.
+type VersionRoute struct {
+	MinProtocol int    `json:"minProtocol"`
+	MaxProtocol int    `json:"maxProtocol"`
+	ProxyTo     string `json:"proxyTo"`
+}
+
+// UsernameRoute sends players with one of the Usernames or a username that matches
+// the Pattern regular expression to its own backend. Usernames are compared case-insensitively.
+type UsernameRoute struct {
+	Usernames []string `json:"usernames"`
+	Pattern   string   `json:"pattern"`
+	ProxyTo   string   `json:"proxyTo"`
+}
+
+func (route UsernameRoute) matches(username string) bool {
+	for _, name := range route.Usernames {
+		if strings.EqualFold(name, username) {
+			return true
+		}
+	}
+
+	if route.Pattern == "" {
+		return false
+	}
+	matched, err := regexp.MatchString(route.Pattern, username)
+	return err == nil && matched
+}
+
+func (route VersionRoute) matches(protocolVersion int) bool {
+	if route.MinProtocol != 0 && protocolVersion < route.MinProtocol {
+		return false
+	}
+	if route.MaxProtocol != 0 && protocolVersion > route.MaxProtocol {
+		return false
+	}
+	return true
+}
+
+func (proxy *Proxy) UsernameRoutes() []UsernameRoute {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.UsernameRoutes
+}
+
+func (proxy *Proxy) VersionRoutes() []VersionRoute {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.VersionRoutes
+}
+
+// Policies for clients that were transferred to the proxy by another server
+const (
+	AcceptTransfers = "accept"
+	RejectTransfers = "reject"
+)
+
+func (proxy *Proxy) Transfers() TransferConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Transfers
+}
+
+func (proxy *Proxy) UnsupportedVersionMessage() string {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.UnsupportedVersionMessage
+}
+
+// routeBackends returns the backends for the client of the handshake and the username of its login.
+// Transferred clients go to the proxyTo of the transfers if it's set. Otherwise username routes
+// come before the country routes of the client location, which come before version routes. The first matching route wins,
+// otherwise the client is balanced over the available backends.
+// It reports false if no version route matches and unsupported versions are kicked.
+func (proxy *Proxy) routeBackends(hs handshaking.ServerBoundHandshake, username string, location geoLocation) ([]string, bool) {
+	if hs.IsTransferRequest() {
+		if proxyTo := proxy.Transfers().ProxyTo; proxyTo != "" {
+			return []string{proxyTo}, true
+		}
+	}
+
+	if username != "" {
+		for _, route := range proxy.UsernameRoutes() {
+			if route.matches(username) {
+				return []string{route.ProxyTo}, true
+			}
+		}
+	}
+
+	for _, route := range proxy.GeoIP().Routes {
+		if location.in(route.Countries) {
+			return []string{route.ProxyTo}, true
+		}
+	}
+
+	routes := proxy.VersionRoutes()
+	for _, route := range routes {
+		if route.matches(int(hs.ProtocolVersion)) {
+			return []string{route.ProxyTo}, true
+		}
+	}
+
+	if len(routes) > 0 && proxy.UnsupportedVersionMessage() != "" {
+		return nil, false
+	}
+	return proxy.availableBackends(), true
+}
@@ -0,0 +1,103 @@
+package infrared
+
+import (
+	"testing"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+)
+
+func TestProxy_RouteBackends_Version(t *testing.T) {
+	proxy := &Proxy{Config: &ProxyConfig{
+		ProxyTo: ":25565",
+		VersionRoutes: []VersionRoute{
+			{MaxProtocol: 340, ProxyTo: ":25566"},
+			{MinProtocol: 763, ProxyTo: ":25567"},
+		},
+	}}
+
+	tt := []struct {
+		protocolVersion int
+		message         string
+		backends        []string
+		supported       bool
+	}{
+		{protocolVersion: 47, backends: []string{":25566"}, supported: true},
+		{protocolVersion: 340, backends: []string{":25566"}, supported: true},
+		{protocolVersion: 763, backends: []string{":25567"}, supported: true},
+		{protocolVersion: 578, backends: []string{":25565"}, supported: true},
+		{protocolVersion: 578, message: "Unsupported version", supported: false},
+	}
+
+	for _, tc := range tt {
+		proxy.Config.UnsupportedVersionMessage = tc.message
+		hs := handshaking.ServerBoundHandshake{ProtocolVersion: protocol.VarInt(tc.protocolVersion)}
+		backends, supported := proxy.routeBackends(hs, "", geoLocation{})
+		if supported != tc.supported || len(backends) != len(tc.backends) {
+			t.Errorf("%d: got %v, %t; want %v, %t", tc.protocolVersion, backends, supported, tc.backends, tc.supported)
+			continue
+		}
+		for i := range backends {
+			if backends[i] != tc.backends[i] {
+				t.Errorf("%d: got %v; want %v", tc.protocolVersion, backends, tc.backends)
+			}
+		}
+	}
+}
+
+func TestProxy_RouteBackends_Username(t *testing.T) {
+	proxy := &Proxy{Config: &ProxyConfig{
+		ProxyTo: ":25565",
+		UsernameRoutes: []UsernameRoute{
+			{Usernames: []string{"Notch"}, ProxyTo: ":25566"},
+			{Pattern: `^staff_`, ProxyTo: ":25567"},
+		},
+		VersionRoutes: []VersionRoute{
+			{MaxProtocol: 340, ProxyTo: ":25568"},
+		},
+	}}
+
+	tt := []struct {
+		username string
+		backend  string
+	}{
+		{username: "notch", backend: ":25566"},
+		{username: "staff_jeb", backend: ":25567"},
+		{username: "player", backend: ":25568"},
+		{username: "", backend: ":25568"},
+	}
+
+	hs := handshaking.ServerBoundHandshake{ProtocolVersion: 47}
+	for _, tc := range tt {
+		backends, _ := proxy.routeBackends(hs, tc.username, geoLocation{})
+		if len(backends) != 1 || backends[0] != tc.backend {
+			t.Errorf("%s: got %v; want [%s]", tc.username, backends, tc.backend)
+		}
+	}
+}
+
+func TestProxy_RouteBackends_Transfer(t *testing.T) {
+	proxy := &Proxy{Config: &ProxyConfig{
+		ProxyTo: ":25565",
+		UsernameRoutes: []UsernameRoute{
+			{Usernames: []string{"Notch"}, ProxyTo: ":25566"},
+		},
+		Transfers: TransferConfig{ProxyTo: ":25567"},
+	}}
+
+	tt := []struct {
+		nextState protocol.Byte
+		backend   string
+	}{
+		{nextState: handshaking.ServerBoundHandshakeLoginState, backend: ":25566"},
+		{nextState: handshaking.ServerBoundHandshakeTransferState, backend: ":25567"},
+	}
+
+	for _, tc := range tt {
+		hs := handshaking.ServerBoundHandshake{ProtocolVersion: 766, NextState: tc.nextState}
+		backends, _ := proxy.routeBackends(hs, "notch", geoLocation{})
+		if len(backends) != 1 || backends[0] != tc.backend {
+			t.Errorf("state %d: got %v; want [%s]", tc.nextState, backends, tc.backend)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package infrared
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// ProxyConfigSchema is the JSON Schema of the ProxyConfig
+//
+//go:embed config.schema.json
+var ProxyConfigSchema string
+
+var proxyConfigSchema = jsonschema.MustCompileString("config.schema.json", ProxyConfigSchema)
+
+var quotedPattern = regexp.MustCompile(`'([^']*)'`)
+
+// SchemaViolation is a part of a config that does not match the ProxyConfigSchema
+type SchemaViolation struct {
+	// Path is the JSON pointer to the value, like /offlineStatus/maxPlayers
+	Path string
+	// Line is the line of the value in the config or 0 if it is unknown
+	Line    int
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "/"
+	}
+
+	if v.Line == 0 {
+		return fmt.Sprintf("%s: %s", path, v.Message)
+	}
+	return fmt.Sprintf("line %d %s: %s", v.Line, path, v.Message)
+}
+
+// SchemaError is returned if a config does not match the ProxyConfigSchema
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (err SchemaError) Error() string {
+	violations := make([]string, len(err.Violations))
+	for i, v := range err.Violations {
+		violations[i] = v.String()
+	}
+	return "invalid config; " + strings.Join(violations, "; ")
+}
+
+// ValidateProxyConfig validates the JSON representation of a ProxyConfig against
+// the ProxyConfigSchema. If the config is invalid a SchemaError is returned that
+// contains every violation.
+func ValidateProxyConfig(bb []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(bb))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	err := proxyConfigSchema.Validate(v)
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+
+	lines := jsonLines(bb)
+	var violations []SchemaViolation
+	for _, leaf := range schemaLeafErrors(validationErr) {
+		// Unknown properties are reported on their own lines instead of the line of their object
+		if strings.HasSuffix(leaf.KeywordLocation, "/additionalProperties") {
+			for _, match := range quotedPattern.FindAllStringSubmatch(leaf.Message, -1) {
+				path := leaf.InstanceLocation + "/" + escapeJSONPointer(match[1])
+				violations = append(violations, SchemaViolation{
+					Path:    path,
+					Line:    lines[path],
+					Message: "unknown property",
+				})
+			}
+			continue
+		}
+
+		violations = append(violations, SchemaViolation{
+			Path:    leaf.InstanceLocation,
+			Line:    lines[leaf.InstanceLocation],
+			Message: leaf.Message,
+		})
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Line < violations[j].Line
+	})
+	return SchemaError{Violations: violations}
+}
+
+func schemaLeafErrors(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, schemaLeafErrors(cause)...)
+	}
+	return leaves
+}
+
+// jsonLines maps the JSON pointers of all values in a JSON document to their lines.
+// Documents on a single line, like configs that were converted from other formats,
+// have no meaningful lines and result in an empty map.
+func jsonLines(bb []byte) map[string]int {
+	lines := map[string]int{}
+	if !bytes.Contains(bytes.TrimSpace(bb), []byte("\n")) {
+		return lines
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(bb))
+	lineOf := func(offset int64) int {
+		// The offset is at the end of the previous token
+		for offset < int64(len(bb)) && strings.IndexByte(" \t\r\n,:", bb[offset]) >= 0 {
+			offset++
+		}
+		return bytes.Count(bb[:offset], []byte("\n")) + 1
+	}
+
+	var walk func(ptr string) error
+	walk = func(ptr string) error {
+		lines[ptr] = lineOf(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				keyOffset := dec.InputOffset()
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				keyPtr := ptr + "/" + escapeJSONPointer(fmt.Sprint(key))
+				if err := walk(keyPtr); err != nil {
+					return err
+				}
+				// Violations of a property are reported on the line of its key
+				lines[keyPtr] = lineOf(keyOffset)
+			}
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(ptr + "/" + strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+		default:
+			return nil
+		}
+
+		// Closing delimiter
+		_, err = dec.Token()
+		return err
+	}
+
+	walk("")
+	return lines
+}
+
+func escapeJSONPointer(token string) string {
+	token = strings.Replace(token, "~", "~0", -1)
+	token = strings.Replace(token, "/", "~1", -1)
+	return url.PathEscape(token)
+}
@@ -0,0 +1,107 @@
+package infrared
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValidateProxyConfig(t *testing.T) {
+	tt := []struct {
+		name string
+		cfg  string
+		want []SchemaViolation
+	}{
+		{
+			name: "Valid",
+			cfg: `{
+  "domainName": "mc.example.com",
+  "offlineStatus": {
+    "maxPlayers": 20
+  },
+  "callbackServer": {
+    "events": ["PlayerJoin"]
+  }
+}`,
+		},
+		{
+			name: "WrongTypes",
+			cfg: `{
+  "domainName": "mc.example.com",
+  "offlineStatus": {
+    "maxPlayers": "20"
+  },
+  "callbackServer": {
+    "events": [
+      "PlayerJoin",
+      "PlayerQuit"
+    ]
+  },
+  "timeout": -1
+}`,
+			want: []SchemaViolation{
+				{Path: "/offlineStatus/maxPlayers", Line: 4},
+				{Path: "/callbackServer/events/1", Line: 9},
+				{Path: "/timeout", Line: 12},
+			},
+		},
+		{
+			name: "UnknownField",
+			cfg: `{
+  "domainName": "mc.example.com",
+  "proxyToo": ":25566"
+}`,
+			want: []SchemaViolation{
+				{Path: "/proxyToo", Line: 3},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateProxyConfig([]byte(tc.cfg))
+			if tc.want == nil {
+				if err != nil {
+					t.Fatalf("got error %s", err)
+				}
+				return
+			}
+
+			schemaErr, ok := err.(SchemaError)
+			if !ok {
+				t.Fatalf("got %v; want SchemaError", err)
+			}
+
+			var got []SchemaViolation
+			for _, v := range schemaErr.Violations {
+				got = append(got, SchemaViolation{Path: v.Path, Line: v.Line})
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v; want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateProxyConfig_RoundTrip(t *testing.T) {
+	var cfg ProxyConfig
+	if err := cfg.LoadFromBytes([]byte(`{"domainName":"mc.example.com","proxyTo":":25566"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dumped configs have nil slices and maps as null and must load again
+	for name, c := range map[string]interface{}{"default": DefaultProxyConfig(), "loaded": &cfg} {
+		bb, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateProxyConfig(bb); err != nil {
+			t.Errorf("got error %v; want the marshalled %s config to be valid", err, name)
+		}
+
+		var loaded ProxyConfig
+		if err := loaded.LoadFromBytes(bb); err != nil {
+			t.Errorf("got error %v; want the marshalled %s config to load", err, name)
+		}
+	}
+}
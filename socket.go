@@ -0,0 +1,158 @@
+package infrared
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SocketOptions are the TCP options of connections. Zero values and nil pointers keep the defaults of Go,
+// which enables TCP_NODELAY and sends keepalive probes every 15 seconds.
+type SocketOptions struct {
+	NoDelay *bool `json:"noDelay"`
+	// KeepAlive is the period of the keepalive probes in milliseconds. A negative period disables them.
+	KeepAlive int `json:"keepAlive"`
+	// ReadBuffer and WriteBuffer are the sizes of the socket buffers in bytes
+	ReadBuffer  int `json:"readBuffer"`
+	WriteBuffer int `json:"writeBuffer"`
+	// Linger is the number of seconds that closing a connection waits for the unsent data.
+	// 0 discards the data and resets the connection and a negative linger sends it in the background.
+	Linger *int `json:"linger"`
+}
+
+// ParseSocketOptions parses space separated options like "nodelay=false keepalive=30s read-buffer=65536
+// write-buffer=65536 linger=0". A keepalive of 0 disables the probes.
+func ParseSocketOptions(options string) (SocketOptions, error) {
+	var opts SocketOptions
+	for _, option := range strings.Fields(options) {
+		parts := strings.SplitN(option, "=", 2)
+		if len(parts) != 2 {
+			return opts, fmt.Errorf("invalid socket option %q; want key=value", option)
+		}
+
+		var err error
+		switch key, value := parts[0], parts[1]; key {
+		case "nodelay":
+			var noDelay bool
+			noDelay, err = strconv.ParseBool(value)
+			opts.NoDelay = &noDelay
+		case "keepalive":
+			var period time.Duration
+			period, err = time.ParseDuration(value)
+			opts.KeepAlive = int(period / time.Millisecond)
+			if period == 0 {
+				opts.KeepAlive = -1
+			}
+		case "read-buffer":
+			opts.ReadBuffer, err = strconv.Atoi(value)
+		case "write-buffer":
+			opts.WriteBuffer, err = strconv.Atoi(value)
+		case "linger":
+			var linger int
+			linger, err = strconv.Atoi(value)
+			opts.Linger = &linger
+		default:
+			return opts, fmt.Errorf("unknown socket option %q", key)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid socket option %q; %s", option, err)
+		}
+	}
+	return opts, opts.validate()
+}
+
+func (opts SocketOptions) validate() error {
+	if opts.ReadBuffer < 0 || opts.WriteBuffer < 0 {
+		return errors.New("negative buffer size")
+	}
+	return nil
+}
+
+// override returns the options with the ones that are set in other
+func (opts SocketOptions) override(other SocketOptions) SocketOptions {
+	if other.NoDelay != nil {
+		opts.NoDelay = other.NoDelay
+	}
+	if other.KeepAlive != 0 {
+		opts.KeepAlive = other.KeepAlive
+	}
+	if other.ReadBuffer != 0 {
+		opts.ReadBuffer = other.ReadBuffer
+	}
+	if other.WriteBuffer != 0 {
+		opts.WriteBuffer = other.WriteBuffer
+	}
+	if other.Linger != nil {
+		opts.Linger = other.Linger
+	}
+	return opts
+}
+
+// apply sets the options on the TCP connection of c. Other connections, like the ones of Unix domain sockets,
+// are left as they are.
+func (opts SocketOptions) apply(c net.Conn) error {
+	if wrapped, ok := c.(*conn); ok {
+		c = wrapped.Conn
+	}
+	tcpConn, ok := c.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	if opts.NoDelay != nil {
+		if err := tcpConn.SetNoDelay(*opts.NoDelay); err != nil {
+			return err
+		}
+	}
+	if opts.KeepAlive < 0 {
+		if err := tcpConn.SetKeepAlive(false); err != nil {
+			return err
+		}
+	} else if opts.KeepAlive > 0 {
+		if err := tcpConn.SetKeepAlive(true); err != nil {
+			return err
+		}
+		if err := tcpConn.SetKeepAlivePeriod(time.Duration(opts.KeepAlive) * time.Millisecond); err != nil {
+			return err
+		}
+	}
+	if opts.ReadBuffer > 0 {
+		if err := tcpConn.SetReadBuffer(opts.ReadBuffer); err != nil {
+			return err
+		}
+	}
+	if opts.WriteBuffer > 0 {
+		if err := tcpConn.SetWriteBuffer(opts.WriteBuffer); err != nil {
+			return err
+		}
+	}
+	if opts.Linger != nil {
+		if err := tcpConn.SetLinger(*opts.Linger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listenerSocketOptions returns the socket options of the connections of the listener
+func (gateway *Gateway) listenerSocketOptions(listenTo string) SocketOptions {
+	return gateway.SocketOptions.override(gateway.ListenerSocketOptions[listenTo])
+}
+
+func (gateway *Gateway) validateSocketOptions() error {
+	if err := gateway.SocketOptions.validate(); err != nil {
+		return err
+	}
+	for key, opts := range gateway.ListenerSocketOptions {
+		if _, err := gateway.listenAddr(key); err != nil {
+			return err
+		}
+		if err := opts.validate(); err != nil {
+			return fmt.Errorf("%s; %s", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseSocketOptions(t *testing.T) {
+	opts, err := ParseSocketOptions("nodelay=false keepalive=30s read-buffer=65536 linger=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.NoDelay == nil || *opts.NoDelay || opts.KeepAlive != 30000 || opts.ReadBuffer != 65536 ||
+		opts.WriteBuffer != 0 || opts.Linger == nil || *opts.Linger != 0 {
+		t.Errorf("got %+v; want the parsed options", opts)
+	}
+
+	if opts, _ := ParseSocketOptions("keepalive=0"); opts.KeepAlive != -1 {
+		t.Errorf("got keepalive %d; want -1 to disable it", opts.KeepAlive)
+	}
+
+	for _, options := range []string{"nodelay", "nodelay=maybe", "unknown=1", "read-buffer=-1"} {
+		if _, err := ParseSocketOptions(options); err == nil {
+			t.Errorf("%q: got no error; want an error", options)
+		}
+	}
+}
+
+func TestGateway_ListenerSocketOptions(t *testing.T) {
+	noDelay := false
+	linger := 0
+	gateway := Gateway{
+		SocketOptions: SocketOptions{NoDelay: &noDelay, ReadBuffer: 65536},
+		ListenerSocketOptions: map[string]SocketOptions{
+			"shield": {ReadBuffer: 4096, Linger: &linger},
+		},
+	}
+
+	opts := gateway.listenerSocketOptions("shield")
+	if opts.NoDelay != &noDelay || opts.ReadBuffer != 4096 || opts.Linger != &linger {
+		t.Errorf("got %+v; want the options of the listener over the ones of all listeners", opts)
+	}
+	if opts := gateway.listenerSocketOptions(":25565"); opts.ReadBuffer != 65536 || opts.Linger != nil {
+		t.Errorf("got %+v; want the options of all listeners", opts)
+	}
+}
+
+func TestSocketOptions_Apply(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	noDelay := false
+	linger := 0
+	dialer := Dialer{SocketOptions: SocketOptions{NoDelay: &noDelay, KeepAlive: 60000, ReadBuffer: 65536, Linger: &linger}}
+	c, err := dialer.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// Connections without TCP, like the ones of Unix domain sockets, are left as they are
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	if err := dialer.SocketOptions.apply(wrapConn(c1)); err != nil {
+		t.Errorf("got %s; want no error for a connection without TCP", err)
+	}
+}
@@ -0,0 +1,184 @@
+package infrared
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// SRVScheme is the prefix of backend addresses that are resolved by their SRV records,
+// like srv://_minecraft._tcp.hub.internal
+const SRVScheme = "srv://"
+
+// SRVResolver is the address of the DNS server that resolves SRV backends, like "1.1.1.1:53".
+// The first nameserver of /etc/resolv.conf is used if it's empty.
+var SRVResolver string
+
+// Bounds of how long resolved SRV records are cached, regardless of their TTL
+var (
+	srvMinTTL = time.Second * 5
+	srvMaxTTL = time.Hour
+)
+
+var srvCache = &srvRecordCache{
+	client:  &dns.Client{Timeout: time.Second * 5},
+	entries: map[string]srvEntry{},
+}
+
+type srvEntry struct {
+	records []*dns.SRV
+	expires time.Time
+}
+
+// srvRecordCache caches the SRV records of names until their TTL runs out
+type srvRecordCache struct {
+	mu      sync.Mutex
+	client  *dns.Client
+	entries map[string]srvEntry
+}
+
+// resolveBackend returns the address to dial for a backend address.
+// SRV backends are resolved to one of their targets; all other addresses are returned as they are.
+func resolveBackend(addr string) (string, error) {
+	if !strings.HasPrefix(addr, SRVScheme) {
+		return addr, nil
+	}
+
+	records, err := srvCache.lookup(strings.TrimPrefix(addr, SRVScheme))
+	if err != nil {
+		return "", err
+	}
+
+	record := pickSRV(records)
+	if record == nil {
+		return "", fmt.Errorf("no SRV records for %s", addr)
+	}
+	return net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))), nil
+}
+
+// lookup returns the cached records of the name or resolves them again, if they expired.
+// If resolving fails, the expired records are used until the name resolves again.
+func (cache *srvRecordCache) lookup(name string) ([]*dns.SRV, error) {
+	name = dns.Fqdn(name)
+
+	cache.mu.Lock()
+	entry, ok := cache.entries[name]
+	cache.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.records, nil
+	}
+
+	records, ttl, err := cache.resolve(name)
+	if err != nil {
+		if ok {
+			log.Printf("[w] Failed to refresh SRV records of %s; error: %s", name, err)
+			return entry.records, nil
+		}
+		return nil, err
+	}
+
+	if ttl < srvMinTTL {
+		ttl = srvMinTTL
+	} else if ttl > srvMaxTTL {
+		ttl = srvMaxTTL
+	}
+
+	cache.mu.Lock()
+	cache.entries[name] = srvEntry{records: records, expires: time.Now().Add(ttl)}
+	cache.mu.Unlock()
+	return records, nil
+}
+
+// resolve returns the SRV records of the name and their lowest TTL
+func (cache *srvRecordCache) resolve(name string) ([]*dns.SRV, time.Duration, error) {
+	resolver := SRVResolver
+	if resolver == "" {
+		cfg, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(cfg.Servers) == 0 {
+			return nil, 0, fmt.Errorf("no nameserver configured")
+		}
+		resolver = net.JoinHostPort(cfg.Servers[0], cfg.Port)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, dns.TypeSRV)
+
+	resp, _, err := cache.client.Exchange(msg, resolver)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, 0, fmt.Errorf("unexpected response code %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	var records []*dns.SRV
+	ttl := srvMaxTTL
+	for _, answer := range resp.Answer {
+		srv, ok := answer.(*dns.SRV)
+		if !ok {
+			continue
+		}
+
+		records = append(records, srv)
+		if recordTTL := time.Duration(srv.Hdr.Ttl) * time.Second; recordTTL < ttl {
+			ttl = recordTTL
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, 0, fmt.Errorf("no SRV records for %s", name)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Priority != records[j].Priority {
+			return records[i].Priority < records[j].Priority
+		}
+		return records[i].Target < records[j].Target
+	})
+	return records, ttl, nil
+}
+
+// pickSRV picks one of the records with the lowest priority by their weights like RFC 2782 describes.
+// The records have to be sorted by their priority.
+func pickSRV(records []*dns.SRV) *dns.SRV {
+	if len(records) == 0 {
+		return nil
+	}
+
+	candidates := []*dns.SRV{records[0]}
+	for _, record := range records[1:] {
+		if record.Priority != records[0].Priority {
+			break
+		}
+		candidates = append(candidates, record)
+	}
+
+	total := 0
+	for _, record := range candidates {
+		total += int(record.Weight)
+	}
+	if total == 0 {
+		return candidates[rand.Intn(len(candidates))]
+	}
+
+	n := rand.Intn(total)
+	for _, record := range candidates {
+		n -= int(record.Weight)
+		if n < 0 {
+			return record
+		}
+	}
+	return candidates[len(candidates)-1]
+}
@@ -0,0 +1,76 @@
+package infrared
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestResolveBackend(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var queries int32
+	server := &dns.Server{
+		PacketConn: conn,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			atomic.AddInt32(&queries, 1)
+			resp := new(dns.Msg)
+			resp.SetReply(r)
+			name := r.Question[0].Name
+			if name != "_minecraft._tcp.hub.internal." {
+				resp.Rcode = dns.RcodeNameError
+				w.WriteMsg(resp)
+				return
+			}
+
+			resp.Answer = []dns.RR{
+				&dns.SRV{
+					Hdr:      dns.RR_Header{Name: name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: 300},
+					Priority: 20,
+					Weight:   1,
+					Port:     25567,
+					Target:   "backup.hub.internal.",
+				},
+				&dns.SRV{
+					Hdr:      dns.RR_Header{Name: name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: 300},
+					Priority: 10,
+					Weight:   1,
+					Port:     25566,
+					Target:   "lobby.hub.internal.",
+				},
+			}
+			w.WriteMsg(resp)
+		}),
+	}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	SRVResolver = conn.LocalAddr().String()
+	defer func() { SRVResolver = "" }()
+
+	for i := 0; i < 2; i++ {
+		addr, err := resolveBackend("srv://_minecraft._tcp.hub.internal")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if addr != "lobby.hub.internal:25566" {
+			t.Errorf("got %s; want the target with the lowest priority", addr)
+		}
+	}
+	if queries := atomic.LoadInt32(&queries); queries != 1 {
+		t.Errorf("got %d queries; want the records to be cached", queries)
+	}
+
+	if _, err := resolveBackend("srv://_minecraft._tcp.unknown.internal"); err == nil {
+		t.Error("got no error; want an error for a missing record")
+	}
+
+	if addr, _ := resolveBackend("mc.example.com:25565"); addr != "mc.example.com:25565" {
+		t.Errorf("got %s; want the address unchanged", addr)
+	}
+}
@@ -0,0 +1,148 @@
+package infrared
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/haveachin/infrared/protocol/status"
+)
+
+type statusCacheEntry struct {
+	// ready is closed once the status response of the backend was fetched
+	ready   chan struct{}
+	pk      protocol.Packet
+	err     error
+	expires time.Time
+}
+
+// statusCache caches the status responses of Java backends per proxy and backend until their TTL runs out
+var statusCache = struct {
+	sync.Mutex
+	entries map[string]*statusCacheEntry
+}{entries: map[string]*statusCacheEntry{}}
+
+// ClearStatusCache drops all cached status responses and Bedrock pongs,
+// so the next status request of every proxy is answered by its backend
+func ClearStatusCache() {
+	statusCache.Lock()
+	statusCache.entries = map[string]*statusCacheEntry{}
+	statusCache.Unlock()
+
+	bedrockPongCache.Lock()
+	bedrockPongCache.entries = map[string]bedrockPongEntry{}
+	bedrockPongCache.Unlock()
+}
+
+func (proxy *Proxy) StatusCacheTTL() time.Duration {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return time.Millisecond * time.Duration(proxy.Config.StatusCacheTTL)
+}
+
+// cachedStatus returns the cached status response of the key or fetches it, if it expired.
+// Concurrent requests of an expired key wait for the same fetch, so a refresh storm only reaches the backend once.
+func cachedStatus(key string, ttl time.Duration, fetch func() (protocol.Packet, error)) (protocol.Packet, error) {
+	statusCache.Lock()
+	entry, ok := statusCache.entries[key]
+	if ok && (!entry.fetched() || time.Now().Before(entry.expires)) {
+		statusCache.Unlock()
+		<-entry.ready
+		return entry.pk, entry.err
+	}
+
+	entry = &statusCacheEntry{ready: make(chan struct{})}
+	entries := statusCache.entries
+	entries[key] = entry
+	statusCache.Unlock()
+
+	entry.pk, entry.err = fetch()
+	entry.expires = time.Now().Add(ttl)
+	if entry.err != nil {
+		statusCache.Lock()
+		if entries[key] == entry {
+			delete(entries, key)
+		}
+		statusCache.Unlock()
+	}
+	close(entry.ready)
+	return entry.pk, entry.err
+}
+
+func (entry *statusCacheEntry) fetched() bool {
+	select {
+	case <-entry.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// handleCachedStatusRequest answers the status request with the cached status response of the backend.
+// Backends can answer with the protocol version of the client, so the responses are cached per protocol version.
+func (proxy *Proxy) handleCachedStatusRequest(conn Conn, proxyTo string, connRemoteAddr net.Addr, hs handshaking.ServerBoundHandshake, statusReq statusRequest) error {
+	if proxy.PingMode() == BackendPing {
+		forwardPing, closePing := proxy.dialPing(conn, proxyTo, connRemoteAddr, hs)
+		defer closePing()
+		statusReq.forwardPing = forwardPing
+	}
+
+	key := fmt.Sprintf("%s|%s|%d", proxy.UID(), proxyTo, hs.ProtocolVersion)
+	responsePk, err := cachedStatus(key, proxy.StatusCacheTTL(), func() (protocol.Packet, error) {
+		return proxy.fetchStatus(conn, proxyTo, connRemoteAddr, hs)
+	})
+	if err != nil {
+		log.Printf("[i] %s did not respond to ping; is the target offline?", proxyTo)
+		return proxy.handleStatusRequest(conn, false, statusReq)
+	}
+	return proxy.respondOverriddenStatus(conn, responsePk, statusReq)
+}
+
+// fetchStatus requests the status response of the backend with the handshake and the PROXY protocol header of the client
+func (proxy *Proxy) fetchStatus(conn Conn, proxyTo string, connRemoteAddr net.Addr, hs handshaking.ServerBoundHandshake) (protocol.Packet, error) {
+	dialer, err := proxy.Dialer()
+	if err != nil {
+		return protocol.Packet{}, err
+	}
+
+	rconn, err := dialBackend(dialer, proxyTo)
+	if err != nil {
+		return protocol.Packet{}, err
+	}
+	defer rconn.Close()
+
+	if err := rconn.SetDeadline(time.Now().Add(proxy.Timeout())); err != nil {
+		return protocol.Packet{}, err
+	}
+	if proxy.ProxyProtocol() {
+		if err := proxy.writeProxyProtocolHeader(conn, rconn, connRemoteAddr, hs); err != nil {
+			return protocol.Packet{}, err
+		}
+	}
+	return requestStatus(rconn, proxy.spoofHandshake(hs))
+}
+
+// requestStatus sends the handshake and a status request to the backend and reads its status response
+func requestStatus(rconn Conn, hs handshaking.ServerBoundHandshake) (protocol.Packet, error) {
+	if err := rconn.WritePacket(hs.Marshal()); err != nil {
+		return protocol.Packet{}, err
+	}
+
+	if err := rconn.WritePacket(status.ServerBoundRequest{}.Marshal()); err != nil {
+		return protocol.Packet{}, err
+	}
+
+	pk, err := rconn.ReadPacket()
+	if err != nil {
+		return protocol.Packet{}, err
+	}
+
+	if _, err := status.UnmarshalClientBoundResponse(pk); err != nil {
+		return protocol.Packet{}, err
+	}
+	return pk, nil
+}
@@ -0,0 +1,160 @@
+package infrared
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/haveachin/infrared/protocol/status"
+	"github.com/pires/go-proxyproto"
+)
+
+func TestCachedStatus(t *testing.T) {
+	ClearStatusCache()
+	var fetches int32
+	fetch := func() (protocol.Packet, error) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(time.Millisecond * 10)
+		return protocol.Packet{ID: 0x00, Data: []byte("status")}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pk, err := cachedStatus("proxy|backend", time.Minute, fetch)
+			if err != nil || string(pk.Data) != "status" {
+				t.Errorf("got %v and %v; want the status of the backend", pk, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("got %d fetches; want 1 for concurrent requests", n)
+	}
+
+	ClearStatusCache()
+	cachedStatus("proxy|backend", time.Minute, fetch)
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("got %d fetches; want 2 after clearing the cache", n)
+	}
+
+	cachedStatus("proxy|expired", 0, fetch)
+	cachedStatus("proxy|expired", 0, fetch)
+	if n := atomic.LoadInt32(&fetches); n != 4 {
+		t.Errorf("got %d fetches; want 4 with an expired status", n)
+	}
+}
+
+func TestCachedStatus_Error(t *testing.T) {
+	ClearStatusCache()
+	var fetches int32
+	fetch := func() (protocol.Packet, error) {
+		atomic.AddInt32(&fetches, 1)
+		return protocol.Packet{}, errors.New("offline")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cachedStatus("proxy|offline", time.Minute, fetch); err == nil {
+			t.Error("got no error; want the error of the fetch")
+		}
+	}
+
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("got %d fetches; want errors not to be cached", n)
+	}
+}
+
+func TestProxy_HandleCachedStatusRequest(t *testing.T) {
+	ClearStatusCache()
+	defer ClearStatusCache()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The backend requires the PROXY protocol and answers with the protocol version of the client
+	var headers int32
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				reader := bufio.NewReader(c)
+				if header, err := proxyproto.Read(reader); err != nil || header.Command != proxyproto.PROXY {
+					return
+				}
+				atomic.AddInt32(&headers, 1)
+
+				pk, err := protocol.ReadPacket(reader)
+				if err != nil {
+					return
+				}
+				hs, err := handshaking.UnmarshalServerBoundHandshake(pk)
+				if err != nil {
+					return
+				}
+				if _, err := protocol.ReadPacket(reader); err != nil {
+					return
+				}
+				responsePk, _ := StatusConfig{VersionName: "backend", ProtocolNumber: int(hs.ProtocolVersion)}.StatusResponsePacket()
+				wrapConn(c).WritePacket(responsePk)
+			}()
+		}
+	}()
+
+	var cfg ProxyConfig
+	if err := cfg.LoadFromBytes([]byte(`{"proxyTo":"` + listener.Addr().String() + `","proxyProtocol":true,"statusCacheTtl":60000}`)); err != nil {
+		t.Fatal(err)
+	}
+	proxy := &Proxy{Config: &cfg}
+
+	client := &net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 40000}
+	for _, version := range []protocol.VarInt{758, 763, 758} {
+		c1, c2 := net.Pipe()
+		hs := handshaking.ServerBoundHandshake{ProtocolVersion: version, NextState: handshaking.ServerBoundHandshakeStatusState}
+		statusReq := proxy.newStatusRequest(hs)
+		go proxy.handleCachedStatusRequest(wrapConn(c1), listener.Addr().String(), client, hs, statusReq)
+
+		conn := wrapConn(c2)
+		conn.SetDeadline(time.Now().Add(time.Second))
+		if err := conn.WritePacket(status.ServerBoundRequest{}.Marshal()); err != nil {
+			t.Fatal(err)
+		}
+		pk, err := conn.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := status.UnmarshalClientBoundResponse(pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resJSON status.ResponseJSON
+		if err := json.Unmarshal([]byte(res.JSONResponse), &resJSON); err != nil {
+			t.Fatal(err)
+		}
+		if resJSON.Version.Protocol != int(version) {
+			t.Errorf("got protocol %d; want the cached status of protocol %d", resJSON.Version.Protocol, version)
+		}
+		c1.Close()
+		c2.Close()
+	}
+
+	if n := atomic.LoadInt32(&headers); n != 2 {
+		t.Errorf("got %d status requests with a PROXY protocol header; want 1 per protocol version", n)
+	}
+}
@@ -0,0 +1,217 @@
+package infrared
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/status"
+)
+
+// Modes of player sample overrides
+const (
+	// StaticPlayerSample shows the lines of the override, like rules or a Discord link
+	StaticPlayerSample = "static"
+	// OnlinePlayerSample shows the names of the players that are connected through the proxy
+	OnlinePlayerSample = "online"
+)
+
+// Modes of max player overrides
+const (
+	// FixedMaxPlayers reports the value of the override as max players
+	FixedMaxPlayers = "fixed"
+	// OnlineMaxPlayers reports the online players plus the value of the override, so there is always room
+	OnlineMaxPlayers = "online"
+)
+
+// nilUUID is the ID of player samples that aren't real players
+const nilUUID = "00000000-0000-0000-0000-000000000000"
+
+// StatusOverrideConfig overrides parts of every status response of a proxy,
+// including the responses of its backends
+type StatusOverrideConfig struct {
+	PlayerSample PlayerSampleOverrideConfig `json:"playerSample"`
+	MaxPlayers   MaxPlayersOverrideConfig   `json:"maxPlayers"`
+	Version      VersionOverrideConfig      `json:"version"`
+}
+
+// VersionOverrideConfig overrides the version name and protocol number of status responses.
+// With EchoProtocol the protocol number is the one of the client, so it never sees the server as incompatible.
+type VersionOverrideConfig struct {
+	Name         string `json:"name"`
+	Protocol     int    `json:"protocol"`
+	EchoProtocol bool   `json:"echoProtocol"`
+}
+
+func (cfg VersionOverrideConfig) enabled() bool {
+	return cfg.Name != "" || cfg.Protocol != 0 || cfg.EchoProtocol
+}
+
+// PlayerSampleOverrideConfig overrides the player sample that clients see when they hover over the player count
+type PlayerSampleOverrideConfig struct {
+	Mode  string   `json:"mode"`
+	Lines []string `json:"lines"`
+	// Limit is the maximum number of online players in the sample
+	Limit int `json:"limit"`
+}
+
+// MaxPlayersOverrideConfig overrides the max players of status responses. The result is clamped
+// between Min and Max after the mode is applied; bounds of 0 are disabled.
+type MaxPlayersOverrideConfig struct {
+	Mode  string `json:"mode"`
+	Value int    `json:"value"`
+	Min   int    `json:"min"`
+	Max   int    `json:"max"`
+}
+
+func (cfg MaxPlayersOverrideConfig) enabled() bool {
+	return cfg.Mode != "" || cfg.Min > 0 || cfg.Max > 0
+}
+
+// apply returns the max players for the online players and the max players of the response
+func (cfg MaxPlayersOverrideConfig) apply(online, max int) int {
+	switch cfg.Mode {
+	case FixedMaxPlayers:
+		max = cfg.Value
+	case OnlineMaxPlayers:
+		max = online + cfg.Value
+	}
+
+	if cfg.Min > 0 && max < cfg.Min {
+		max = cfg.Min
+	}
+	if cfg.Max > 0 && max > cfg.Max {
+		max = cfg.Max
+	}
+	return max
+}
+
+// enabled reports whether the override changes status responses
+func (cfg StatusOverrideConfig) enabled() bool {
+	return cfg.PlayerSample.Mode != "" || cfg.MaxPlayers.enabled() || cfg.Version.enabled()
+}
+
+func (proxy *Proxy) StatusOverride() StatusOverrideConfig {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.StatusOverride
+}
+
+// playerNames returns the sorted names of the players that are connected through the proxy
+func (proxy *Proxy) playerNames() []string {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	names := make([]string, 0, len(proxy.players))
+	for _, name := range proxy.players {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// playerSample returns the sample of the override
+func (proxy *Proxy) playerSample(cfg PlayerSampleOverrideConfig) []status.PlayerSampleJSON {
+	samples := []status.PlayerSampleJSON{}
+	switch cfg.Mode {
+	case StaticPlayerSample:
+		for _, line := range cfg.Lines {
+			samples = append(samples, status.PlayerSampleJSON{Name: line, ID: nilUUID})
+		}
+	case OnlinePlayerSample:
+		for _, name := range proxy.playerNames() {
+			if cfg.Limit > 0 && len(samples) >= cfg.Limit {
+				break
+			}
+			samples = append(samples, status.PlayerSampleJSON{Name: name, ID: nilUUID})
+		}
+	}
+	return samples
+}
+
+// overrideStatus applies the status override of the proxy to the status response for a client with the protocol.
+// Fields of the response that aren't overridden, like a chat component as description, are kept as they are.
+func (proxy *Proxy) overrideStatus(pk protocol.Packet, clientProtocol int) (protocol.Packet, error) {
+	cfg := proxy.StatusOverride()
+	if !cfg.enabled() {
+		return pk, nil
+	}
+
+	response, err := status.UnmarshalClientBoundResponse(pk)
+	if err != nil {
+		return pk, err
+	}
+
+	var responseJSON map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(response.JSONResponse), &responseJSON); err != nil {
+		return pk, err
+	}
+
+	players := map[string]json.RawMessage{}
+	if raw, ok := responseJSON["players"]; ok {
+		if err := json.Unmarshal(raw, &players); err != nil {
+			return pk, err
+		}
+	}
+
+	if cfg.PlayerSample.Mode != "" {
+		if players["sample"], err = json.Marshal(proxy.playerSample(cfg.PlayerSample)); err != nil {
+			return pk, err
+		}
+	}
+
+	if cfg.MaxPlayers.enabled() {
+		var online, max int
+		json.Unmarshal(players["online"], &online)
+		json.Unmarshal(players["max"], &max)
+		if players["max"], err = json.Marshal(cfg.MaxPlayers.apply(online, max)); err != nil {
+			return pk, err
+		}
+	}
+
+	if responseJSON["players"], err = json.Marshal(players); err != nil {
+		return pk, err
+	}
+
+	if cfg.Version.enabled() {
+		version := map[string]json.RawMessage{}
+		if raw, ok := responseJSON["version"]; ok {
+			if err := json.Unmarshal(raw, &version); err != nil {
+				return pk, err
+			}
+		}
+
+		if cfg.Version.Name != "" {
+			version["name"], _ = json.Marshal(cfg.Version.Name)
+		}
+		if cfg.Version.Protocol != 0 {
+			version["protocol"], _ = json.Marshal(cfg.Version.Protocol)
+		}
+		// Server list pingers send -1 instead of a protocol
+		if cfg.Version.EchoProtocol && clientProtocol > 0 {
+			version["protocol"], _ = json.Marshal(clientProtocol)
+		}
+
+		if responseJSON["version"], err = json.Marshal(version); err != nil {
+			return pk, err
+		}
+	}
+
+	bb, err := json.Marshal(responseJSON)
+	if err != nil {
+		return pk, err
+	}
+
+	return status.ClientBoundResponse{
+		JSONResponse: protocol.String(bb),
+	}.Marshal(), nil
+}
+
+// respondOverriddenStatus answers the status request with the status response after applying the status override
+func (proxy *Proxy) respondOverriddenStatus(conn Conn, responsePk protocol.Packet, statusReq statusRequest) error {
+	responsePk, err := proxy.overrideStatus(responsePk, statusReq.ClientProtocol)
+	if err != nil {
+		return err
+	}
+	return respondStatus(conn, responsePk, statusReq.pong)
+}
@@ -0,0 +1,105 @@
+package infrared
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/status"
+)
+
+func overriddenStatus(t *testing.T, proxy *Proxy, responseJSON string) map[string]interface{} {
+	pk, err := proxy.overrideStatus(status.ClientBoundResponse{
+		JSONResponse: protocol.String(responseJSON),
+	}.Marshal(), 765)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := status.UnmarshalClientBoundResponse(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]interface{}
+	if err := json.Unmarshal([]byte(response.JSONResponse), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestProxy_OverrideStatus_PlayerSample(t *testing.T) {
+	backendJSON := `{"version":{"name":"Paper 1.20.4","protocol":765},"players":{"max":100,"online":2},"description":{"text":"Backend","color":"gold"}}`
+
+	proxy := &Proxy{
+		Config: &ProxyConfig{StatusOverride: StatusOverrideConfig{
+			PlayerSample: PlayerSampleOverrideConfig{Mode: StaticPlayerSample, Lines: []string{"Rules", "Discord"}},
+		}},
+	}
+	res := overriddenStatus(t, proxy, backendJSON)
+
+	sample := res["players"].(map[string]interface{})["sample"].([]interface{})
+	if len(sample) != 2 || sample[1].(map[string]interface{})["name"] != "Discord" {
+		t.Errorf("got sample %v; want the static lines", sample)
+	}
+	if res["description"].(map[string]interface{})["color"] != "gold" {
+		t.Errorf("got description %v; want the description of the backend", res["description"])
+	}
+
+	proxy.Config.StatusOverride.PlayerSample = PlayerSampleOverrideConfig{Mode: OnlinePlayerSample, Limit: 1}
+	proxy.players = map[Conn]string{&conn{}: "notch", &conn{}: "jeb_"}
+	res = overriddenStatus(t, proxy, backendJSON)
+
+	sample = res["players"].(map[string]interface{})["sample"].([]interface{})
+	if len(sample) != 1 || sample[0].(map[string]interface{})["name"] != "jeb_" {
+		t.Errorf("got sample %v; want the first online player", sample)
+	}
+}
+
+func TestMaxPlayersOverrideConfig_Apply(t *testing.T) {
+	tt := []struct {
+		cfg      MaxPlayersOverrideConfig
+		online   int
+		max      int
+		expected int
+	}{
+		{cfg: MaxPlayersOverrideConfig{}, online: 5, max: 20, expected: 20},
+		{cfg: MaxPlayersOverrideConfig{Mode: FixedMaxPlayers, Value: 500}, online: 5, max: 20, expected: 500},
+		{cfg: MaxPlayersOverrideConfig{Mode: OnlineMaxPlayers, Value: 1}, online: 5, max: 20, expected: 6},
+		{cfg: MaxPlayersOverrideConfig{Mode: OnlineMaxPlayers, Value: 1, Min: 100}, online: 5, max: 20, expected: 100},
+		{cfg: MaxPlayersOverrideConfig{Max: 10}, online: 5, max: 20, expected: 10},
+	}
+
+	for _, tc := range tt {
+		if max := tc.cfg.apply(tc.online, tc.max); max != tc.expected {
+			t.Errorf("%+v: got %d; want %d", tc.cfg, max, tc.expected)
+		}
+	}
+}
+
+func TestProxy_OverrideStatus_MaxPlayers(t *testing.T) {
+	proxy := &Proxy{
+		Config: &ProxyConfig{StatusOverride: StatusOverrideConfig{
+			MaxPlayers: MaxPlayersOverrideConfig{Mode: OnlineMaxPlayers, Value: 1},
+		}},
+	}
+	res := overriddenStatus(t, proxy, `{"players":{"max":100,"online":41}}`)
+
+	if max := res["players"].(map[string]interface{})["max"]; max != float64(42) {
+		t.Errorf("got max players %v; want 42", max)
+	}
+}
+
+func TestProxy_OverrideStatus_Version(t *testing.T) {
+	proxy := &Proxy{
+		Config: &ProxyConfig{StatusOverride: StatusOverrideConfig{
+			Version: VersionOverrideConfig{Name: "1.8-1.20.x", EchoProtocol: true},
+		}},
+	}
+	res := overriddenStatus(t, proxy, `{"version":{"name":"Paper 1.20.4","protocol":47}}`)
+
+	version := res["version"].(map[string]interface{})
+	if version["name"] != "1.8-1.20.x" || version["protocol"] != float64(765) {
+		t.Errorf("got version %v; want the name of the override and the protocol of the client", version)
+	}
+}
@@ -0,0 +1,159 @@
+package infrared
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/haveachin/infrared/protocol/login"
+)
+
+const (
+	// maxServerAddressLength is the length in characters that vanilla servers allow for the address of a handshake
+	maxServerAddressLength = 255
+	// maxHandshakeLength is the longest handshake with an address of maxServerAddressLength characters:
+	// the packet ID, the protocol version, the address with its length, the port and the next state
+	maxHandshakeLength = 1 + 5 + 3 + maxServerAddressLength*4 + 2 + 1
+	// maxLoginStartLength leaves room for the UUID and the signature data that newer clients send after the username
+	maxLoginStartLength = 2048
+	// maxVarIntLength is the number of bytes of the longest VarInt
+	maxVarIntLength = 5
+)
+
+// validUsername matches the usernames that Minecraft accounts can have
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9_]{1,16}$`)
+
+// errPacketTooLong is the error of packets that are longer than the strict protocol allows
+var errPacketTooLong = errors.New("packet too long")
+
+// peekStrictPackets peeks the first packets of the reader without reading them. Every packet is rejected
+// before its content is read if its length exceeds its maximum length, so no long packet is buffered.
+func peekStrictPackets(r *bufio.Reader, maxLengths ...int) ([]protocol.Packet, error) {
+	pks := make([]protocol.Packet, 0, len(maxLengths))
+	offset := 0
+	for _, maxLength := range maxLengths {
+		length, n, err := peekVarInt(r, offset)
+		if err != nil {
+			return nil, err
+		}
+		if length < 1 {
+			return nil, errors.New("packet length too short")
+		}
+		if length > maxLength {
+			return nil, errPacketTooLong
+		}
+
+		offset += n
+		bb, err := r.Peek(offset + length)
+		if err != nil {
+			return nil, err
+		}
+		data := bb[offset:]
+		pks = append(pks, protocol.Packet{ID: data[0], Data: data[1:]})
+		offset += length
+	}
+	return pks, nil
+}
+
+// peekVarInt peeks the VarInt at the offset of the reader and returns it and its length in bytes
+func peekVarInt(r *bufio.Reader, offset int) (int, int, error) {
+	value := 0
+	for i := 0; i < maxVarIntLength; i++ {
+		bb, err := r.Peek(offset + i + 1)
+		if err != nil {
+			return 0, 0, err
+		}
+		b := bb[offset+i]
+		value |= int(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("VarInt is too big")
+}
+
+// validateStrictHandshake rejects handshakes that vanilla servers would reject or that aren't encoded canonically,
+// like handshakes with trailing bytes or overlong VarInts
+func validateStrictHandshake(pk protocol.Packet, hs handshaking.ServerBoundHandshake) error {
+	if hs.ProtocolVersion < 0 {
+		return fmt.Errorf("invalid protocol version %d", hs.ProtocolVersion)
+	}
+
+	addr := string(hs.ServerAddress)
+	if !utf8.ValidString(addr) || utf8.RuneCountInString(addr) > maxServerAddressLength {
+		return errors.New("invalid server address")
+	}
+	for _, r := range hs.ParseServerAddress() {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return errors.New("invalid character in server address")
+		}
+	}
+
+	if marshaled := hs.Marshal(); !bytes.Equal(marshaled.Data, pk.Data) {
+		return errors.New("handshake not encoded canonically")
+	}
+	return nil
+}
+
+// validateStrictLoginStart rejects login starts whose username no Minecraft account can have
+func validateStrictLoginStart(pk protocol.Packet) error {
+	ls, err := login.UnmarshalServerBoundLoginStart(pk)
+	if err != nil {
+		return err
+	}
+	if !validUsername.MatchString(string(ls.Name)) {
+		return fmt.Errorf("invalid username %q", ls.Name)
+	}
+	return nil
+}
+
+// peekHandshake peeks the handshake of the client, in the strict mode of the gateway with peekStrictHandshake
+func (gateway *Gateway) peekHandshake(conn Conn) (handshaking.ServerBoundHandshake, error) {
+	if gateway.StrictProtocol {
+		return peekStrictHandshake(conn)
+	}
+
+	pk, err := conn.PeekPacket()
+	if err != nil {
+		return handshaking.ServerBoundHandshake{}, err
+	}
+
+	hs, err := handshaking.UnmarshalServerBoundHandshake(pk)
+	if err != nil {
+		return hs, err
+	}
+	return hs, validateNextState(hs)
+}
+
+// peekStrictHandshake peeks and validates the handshake of the client in the strict mode and, if the client logs in,
+// its login start. Clients that send anything out of spec are rejected before any of it reaches a backend.
+func peekStrictHandshake(conn Conn) (handshaking.ServerBoundHandshake, error) {
+	pks, err := peekStrictPackets(conn.Reader(), maxHandshakeLength)
+	if err != nil {
+		return handshaking.ServerBoundHandshake{}, err
+	}
+
+	pk := pks[0]
+	hs, err := handshaking.UnmarshalServerBoundHandshake(pk)
+	if err == nil {
+		err = validateNextState(hs)
+	}
+	if err == nil {
+		err = validateStrictHandshake(pk, hs)
+	}
+	if err != nil || !hs.IsLoginRequest() {
+		return hs, err
+	}
+
+	pks, err = peekStrictPackets(conn.Reader(), maxHandshakeLength, maxLoginStartLength)
+	if err != nil {
+		return hs, err
+	}
+	return hs, validateStrictLoginStart(pks[1])
+}
@@ -0,0 +1,84 @@
+package infrared
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/haveachin/infrared/protocol"
+	"github.com/haveachin/infrared/protocol/handshaking"
+	"github.com/haveachin/infrared/protocol/login"
+)
+
+func marshalPackets(t *testing.T, pks ...protocol.Packet) []byte {
+	var bb []byte
+	for _, pk := range pks {
+		b, err := pk.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bb = append(bb, b...)
+	}
+	return bb
+}
+
+func loginStart(name string) protocol.Packet {
+	return protocol.MarshalPacket(login.ServerBoundLoginStartPacketID, protocol.String(name))
+}
+
+func TestPeekStrictHandshake(t *testing.T) {
+	hs := handshaking.ServerBoundHandshake{
+		ProtocolVersion: 766,
+		ServerAddress:   "mc.example.com",
+		ServerPort:      25565,
+		NextState:       handshaking.ServerBoundHandshakeLoginState,
+	}
+	status := hs
+	status.NextState = handshaking.ServerBoundHandshakeStatusState
+	longAddr := hs
+	longAddr.ServerAddress = protocol.String(strings.Repeat("a", 256))
+	badAddr := hs
+	badAddr.ServerAddress = "mc.example.com\n"
+	trailing := hs.Marshal()
+	trailing.Data = append(trailing.Data, 0x00)
+
+	tt := []struct {
+		name  string
+		bb    []byte
+		valid bool
+	}{
+		{name: "login", bb: marshalPackets(t, hs.Marshal(), loginStart("Notch")), valid: true},
+		{name: "status", bb: marshalPackets(t, status.Marshal()), valid: true},
+		{name: "invalid username", bb: marshalPackets(t, hs.Marshal(), loginStart("Not ch")), valid: false},
+		{name: "empty username", bb: marshalPackets(t, hs.Marshal(), loginStart("")), valid: false},
+		{name: "long address", bb: marshalPackets(t, longAddr.Marshal()), valid: false},
+		{name: "control character", bb: marshalPackets(t, badAddr.Marshal()), valid: false},
+		{name: "trailing bytes", bb: marshalPackets(t, trailing), valid: false},
+		{name: "wrong login packet id", bb: marshalPackets(t, hs.Marshal(), protocol.MarshalPacket(0x01)), valid: false},
+	}
+
+	for _, tc := range tt {
+		c1, c2 := net.Pipe()
+		go func() {
+			c2.Write(tc.bb)
+			c2.Close()
+		}()
+
+		_, err := peekStrictHandshake(wrapConn(c1))
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: got %v; want valid %t", tc.name, err, tc.valid)
+		}
+		c1.Close()
+	}
+}
+
+func TestPeekStrictPackets_TooLong(t *testing.T) {
+	// The length of the packet is checked before its content is read
+	r := bufio.NewReader(bytes.NewReader(protocol.VarInt(1 << 20).Encode()))
+	if _, err := peekStrictPackets(r, maxHandshakeLength); !errors.Is(err, errPacketTooLong) {
+		t.Errorf("got %v; want %v", err, errPacketTooLong)
+	}
+}
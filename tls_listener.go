@@ -0,0 +1,67 @@
+package infrared
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// terminatesTLS reports whether the connections of the listener are wrapped in TLS
+func (gateway *Gateway) terminatesTLS(listenTo string) bool {
+	for _, key := range gateway.TLSListeners {
+		if key == listenTo {
+			return true
+		}
+	}
+	return false
+}
+
+func (gateway *Gateway) validateTLS() error {
+	if len(gateway.TLSListeners) == 0 {
+		return nil
+	}
+	if len(gateway.TLSCertificates) == 0 {
+		return errors.New("no certificates")
+	}
+	for _, key := range gateway.TLSListeners {
+		addr, err := gateway.listenAddr(key)
+		if err != nil {
+			return err
+		}
+		if isBedrockAddr(addr) {
+			return fmt.Errorf("%s is a Bedrock listener", key)
+		}
+		if isQUICAddr(addr) {
+			return fmt.Errorf("%s is a QUIC listener, which always uses TLS", key)
+		}
+	}
+
+	gateway.tlsConfig = &tls.Config{
+		Certificates: gateway.TLSCertificates,
+		MinVersion:   tls.VersionTLS12,
+	}
+	return nil
+}
+
+// terminateTLS does the TLS handshake of the connection and returns the plaintext connection and the SNI of the
+// client. Pending connections keep being pending with the plaintext connection.
+func (gateway *Gateway) terminateTLS(conn Conn) (Conn, string, error) {
+	// TLS runs on the connection inside a pending one, which is then pointed at the plaintext
+	pending, isPending := conn.(*pendingConn)
+	if isPending {
+		conn = pending.Conn
+	}
+
+	tlsConn := tls.Server(conn, gateway.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, "", fmt.Errorf("TLS handshake failed; %s", err)
+	}
+
+	sni := tlsConn.ConnectionState().ServerName
+	plaintext := wrapConn(tlsConn)
+	if isPending {
+		pending.Conn = plaintext
+		return pending, sni, nil
+	}
+	return plaintext, sni, nil
+}
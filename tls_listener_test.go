@@ -0,0 +1,109 @@
+package infrared
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/haveachin/infrared/protocol/handshaking"
+)
+
+func selfSignedCertificate(t *testing.T, domain string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestGateway_TerminateTLS(t *testing.T) {
+	gateway := Gateway{
+		Listeners:       map[string]string{"tls": ":25566"},
+		TLSListeners:    []string{"tls"},
+		TLSCertificates: []tls.Certificate{selfSignedCertificate(t, "mc.example.com")},
+	}
+	if err := gateway.validateTLS(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, isPending := range []bool{false, true} {
+		c1, c2 := net.Pipe()
+		go func() {
+			client := tls.Client(c2, &tls.Config{ServerName: "mc.example.com", InsecureSkipVerify: true})
+			hs := handshaking.ServerBoundHandshake{ProtocolVersion: 763, ServerAddress: "ignored.example.com", NextState: 1}
+			wrapConn(client).WritePacket(hs.Marshal())
+		}()
+
+		// The gateway serves connections that are still pending
+		var conn Conn = wrapConn(c1)
+		if isPending {
+			pending, ok := gateway.acquirePending(conn)
+			if !ok {
+				t.Fatal("got no room for a pending connection")
+			}
+			conn = pending
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+
+		conn, sni, err := gateway.terminateTLS(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sni != "mc.example.com" {
+			t.Errorf("got SNI %q; want mc.example.com", sni)
+		}
+		if _, ok := conn.(*pendingConn); ok != isPending {
+			t.Errorf("got pending %t; want %t", ok, isPending)
+		}
+
+		pk, err := conn.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hs, err := handshaking.UnmarshalServerBoundHandshake(pk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hs.ProtocolVersion != 763 {
+			t.Errorf("got protocol version %d; want the plaintext handshake", hs.ProtocolVersion)
+		}
+		c1.Close()
+		c2.Close()
+	}
+}
+
+func TestGateway_ValidateTLS(t *testing.T) {
+	gateway := Gateway{TLSListeners: []string{":25566"}}
+	if err := gateway.validateTLS(); err == nil {
+		t.Error("got no error; want an error without certificates")
+	}
+
+	gateway.TLSCertificates = []tls.Certificate{selfSignedCertificate(t, "mc.example.com")}
+	gateway.TLSListeners = []string{"udp://:19132"}
+	if err := gateway.validateTLS(); err == nil {
+		t.Error("got no error; want an error for a Bedrock listener")
+	}
+
+	gateway.TLSListeners = []string{"quic://:25567"}
+	if err := gateway.validateTLS(); err == nil {
+		t.Error("got no error; want an error for a QUIC listener")
+	}
+}
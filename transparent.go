@@ -0,0 +1,53 @@
+package infrared
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+)
+
+// errTransparentUnsupported is the error of transparent dials on systems that can't bind to the IPs of clients
+var errTransparentUnsupported = errors.New("transparent proxying is not supported")
+
+var (
+	transparentMu sync.Mutex
+	// transparentErr is the first errTransparentUnsupported, after which backends are dialed normally
+	transparentErr error
+)
+
+func (proxy *Proxy) Transparent() bool {
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.Transparent
+}
+
+// dialTransparent dials the backend from the IP of the client with IP_TRANSPARENT. Without the capability to bind
+// to foreign IPs, like without CAP_NET_ADMIN or on other systems than Linux, Infrared logs a warning once and dials
+// backends from its own address.
+func dialTransparent(dialer *Dialer, addr string, clientAddr net.Addr) (Conn, error) {
+	transparentMu.Lock()
+	unsupported := transparentErr != nil
+	transparentMu.Unlock()
+
+	ip, _, ok := splitIPAddr(unmapAddr(clientAddr))
+	if unsupported || !ok {
+		return dialBackend(dialer, addr)
+	}
+
+	transparent := *dialer
+	transparent.LocalAddr = &net.TCPAddr{IP: ip}
+	transparent.Control = controlTransparent
+	rconn, err := dialBackend(&transparent, addr)
+	if !errors.Is(err, errTransparentUnsupported) {
+		return rconn, err
+	}
+
+	transparentMu.Lock()
+	if transparentErr == nil {
+		transparentErr = err
+		log.Printf("[w] Dialing backends from the address of Infrared; error: %s", err)
+	}
+	transparentMu.Unlock()
+	return dialBackend(dialer, addr)
+}
@@ -0,0 +1,33 @@
+package infrared
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// ipv6Transparent is IPV6_TRANSPARENT, which the syscall package doesn't have
+const ipv6Transparent = 0x4b
+
+// controlTransparent allows the socket to bind to the IP of the client
+func controlTransparent(network, _ string, c syscall.RawConn) error {
+	var level, opt int
+	switch network {
+	case "tcp4":
+		level, opt = syscall.SOL_IP, syscall.IP_TRANSPARENT
+	case "tcp6":
+		level, opt = syscall.SOL_IPV6, ipv6Transparent
+	default:
+		return nil
+	}
+
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), level, opt, 1)
+	}); err != nil {
+		return err
+	}
+	if sockErr != nil {
+		return fmt.Errorf("%w; %s", errTransparentUnsupported, sockErr)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package infrared
+
+import "syscall"
+
+// controlTransparent fails, because IP_TRANSPARENT only exists on Linux
+func controlTransparent(network, _ string, _ syscall.RawConn) error {
+	if network == "tcp4" || network == "tcp6" {
+		return errTransparentUnsupported
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package infrared
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDialTransparent(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	client := &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 51234}
+	rconn, err := dialTransparent(&Dialer{}, l.Addr().String(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rconn.Close()
+
+	backend, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	if transparentErr != nil {
+		t.Skipf("dialed from the address of Infrared; error: %s", transparentErr)
+	}
+
+	ip, _, _ := splitIPAddr(backend.RemoteAddr())
+	if !ip.Equal(client.IP) {
+		t.Errorf("got connection from %s; want it from %s", ip, client.IP)
+	}
+}
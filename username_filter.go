@@ -0,0 +1,60 @@
+package infrared
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// UsernameFilterConfig rejects logins whose username doesn't match the Pattern regular expression,
+// is shorter than MinLength or longer than MaxLength characters or is Blocked. Blocked usernames are
+// compared case-insensitively. Rejected players are disconnected with the Message.
+type UsernameFilterConfig struct {
+	Pattern   string   `json:"pattern"`
+	MinLength int      `json:"minLength"`
+	MaxLength int      `json:"maxLength"`
+	Blocked   []string `json:"blocked"`
+	Message   string   `json:"message"`
+}
+
+func (cfg UsernameFilterConfig) enabled() bool {
+	return cfg.Pattern != "" || cfg.MinLength > 0 || cfg.MaxLength > 0 || len(cfg.Blocked) > 0
+}
+
+func (cfg UsernameFilterConfig) validate() error {
+	_, err := regexp.Compile(cfg.Pattern)
+	return err
+}
+
+// allows reports whether the filter allows the username
+func (cfg UsernameFilterConfig) allows(username string) bool {
+	length := utf8.RuneCountInString(username)
+	if cfg.MinLength > 0 && length < cfg.MinLength {
+		return false
+	}
+	if cfg.MaxLength > 0 && length > cfg.MaxLength {
+		return false
+	}
+
+	for _, name := range cfg.Blocked {
+		if strings.EqualFold(name, username) {
+			return false
+		}
+	}
+
+	if cfg.Pattern == "" {
+		return true
+	}
+	matched, err := regexp.MatchString(cfg.Pattern, username)
+	return err == nil && matched
+}
+
+func (proxy *Proxy) UsernameFilter() UsernameFilterConfig {
+	if profile := proxy.protectionProfile(); profile.UsernameFilter != nil {
+		return *profile.UsernameFilter
+	}
+
+	proxy.Config.RLock()
+	defer proxy.Config.RUnlock()
+	return proxy.Config.UsernameFilter
+}
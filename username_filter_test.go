@@ -0,0 +1,33 @@
+package infrared
+
+import "testing"
+
+func TestUsernameFilterConfig_Allows(t *testing.T) {
+	tt := []struct {
+		name     string
+		filter   UsernameFilterConfig
+		username string
+		allowed  bool
+	}{
+		{name: "empty", username: "x", allowed: true},
+		{name: "pattern", filter: UsernameFilterConfig{Pattern: "^[A-Za-z0-9_]+$"}, username: "Notch", allowed: true},
+		{name: "pattern mismatch", filter: UsernameFilterConfig{Pattern: "^[A-Za-z0-9_]+$"}, username: "x$y", allowed: false},
+		{name: "too short", filter: UsernameFilterConfig{MinLength: 3}, username: "ab", allowed: false},
+		{name: "too long", filter: UsernameFilterConfig{MaxLength: 5}, username: "abcdef", allowed: false},
+		{name: "within length", filter: UsernameFilterConfig{MinLength: 3, MaxLength: 5}, username: "abcd", allowed: true},
+		{name: "blocked", filter: UsernameFilterConfig{Blocked: []string{"Player"}}, username: "player", allowed: false},
+		{name: "not blocked", filter: UsernameFilterConfig{Blocked: []string{"Player"}}, username: "Player1", allowed: true},
+	}
+
+	for _, tc := range tt {
+		if allowed := tc.filter.allows(tc.username); allowed != tc.allowed {
+			t.Errorf("%s: got %t; want %t", tc.name, allowed, tc.allowed)
+		}
+	}
+}
+
+func TestUsernameFilterConfig_Validate(t *testing.T) {
+	if err := (UsernameFilterConfig{Pattern: "^[a-z"}).validate(); err == nil {
+		t.Error("got no error; want an invalid pattern")
+	}
+}